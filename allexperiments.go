@@ -15,13 +15,17 @@ import (
 	"github.com/ooni/probe-engine/experiment/riseupvpn"
 	"github.com/ooni/probe-engine/experiment/run"
 	"github.com/ooni/probe-engine/experiment/sniblocking"
+	"github.com/ooni/probe-engine/experiment/snowflake"
 	"github.com/ooni/probe-engine/experiment/stunreachability"
 	"github.com/ooni/probe-engine/experiment/telegram"
+	"github.com/ooni/probe-engine/experiment/throttling"
+	"github.com/ooni/probe-engine/experiment/tlsscan"
 	"github.com/ooni/probe-engine/experiment/tlstool"
 	"github.com/ooni/probe-engine/experiment/tor"
 	"github.com/ooni/probe-engine/experiment/urlgetter"
 	"github.com/ooni/probe-engine/experiment/webconnectivity"
 	"github.com/ooni/probe-engine/experiment/whatsapp"
+	"github.com/ooni/probe-engine/model"
 )
 
 var experimentsByName = map[string]func(*Session) *ExperimentBuilder{
@@ -207,9 +211,15 @@ var experimentsByName = map[string]func(*Session) *ExperimentBuilder{
 	"run": func(session *Session) *ExperimentBuilder {
 		return &ExperimentBuilder{
 			build: func(config interface{}) *Experiment {
-				return NewExperiment(session, run.NewExperimentMeasurer(
-					*config.(*run.Config),
-				))
+				cfg := *config.(*run.Config)
+				if cfg.NewMeasurer == nil {
+					cfg.NewMeasurer = func(
+						name string, options map[string]string,
+					) (model.ExperimentMeasurer, error) {
+						return newMeasurer(session, name, options)
+					}
+				}
+				return NewExperiment(session, run.NewExperimentMeasurer(cfg))
 			},
 			config:      &run.Config{},
 			inputPolicy: InputStrictlyRequired,
@@ -228,6 +238,18 @@ var experimentsByName = map[string]func(*Session) *ExperimentBuilder{
 		}
 	},
 
+	"snowflake": func(session *Session) *ExperimentBuilder {
+		return &ExperimentBuilder{
+			build: func(config interface{}) *Experiment {
+				return NewExperiment(session, snowflake.NewExperimentMeasurer(
+					*config.(*snowflake.Config),
+				))
+			},
+			config:      &snowflake.Config{},
+			inputPolicy: InputNone,
+		}
+	},
+
 	"stun_reachability": func(session *Session) *ExperimentBuilder {
 		return &ExperimentBuilder{
 			build: func(config interface{}) *Experiment {
@@ -252,6 +274,30 @@ var experimentsByName = map[string]func(*Session) *ExperimentBuilder{
 		}
 	},
 
+	"throttling": func(session *Session) *ExperimentBuilder {
+		return &ExperimentBuilder{
+			build: func(config interface{}) *Experiment {
+				return NewExperiment(session, throttling.NewExperimentMeasurer(
+					*config.(*throttling.Config),
+				))
+			},
+			config:      &throttling.Config{},
+			inputPolicy: InputStrictlyRequired,
+		}
+	},
+
+	"tlsscan": func(session *Session) *ExperimentBuilder {
+		return &ExperimentBuilder{
+			build: func(config interface{}) *Experiment {
+				return NewExperiment(session, tlsscan.NewExperimentMeasurer(
+					*config.(*tlsscan.Config),
+				))
+			},
+			config:      &tlsscan.Config{},
+			inputPolicy: InputStrictlyRequired,
+		}
+	},
+
 	"tlstool": func(session *Session) *ExperimentBuilder {
 		return &ExperimentBuilder{
 			build: func(config interface{}) *Experiment {
@@ -313,8 +359,11 @@ var experimentsByName = map[string]func(*Session) *ExperimentBuilder{
 	},
 }
 
-// AllExperiments returns the name of all experiments
+// AllExperiments returns the name of all experiments, including
+// the ones registered using RegisterExperiment.
 func AllExperiments() []string {
+	experimentsMu.Lock()
+	defer experimentsMu.Unlock()
 	var names []string
 	for key := range experimentsByName {
 		names = append(names, key)
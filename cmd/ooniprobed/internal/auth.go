@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// LoadOrCreateToken reads the token from path. If the file does not
+// exist, we generate a random token and write it to path, readable
+// only by the current user, so that local clients can read it.
+func LoadOrCreateToken(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", errors.New("the token file is empty")
+		}
+		return token, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	if err := ioutil.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// authHandler only allows requests carrying the token in
+// the `Authorization: Bearer <token>` header.
+type authHandler struct {
+	handler http.Handler
+	token   string
+}
+
+func (h authHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	const prefix = "Bearer "
+	auth := req.Header.Get("Authorization")
+	if h.token == "" || !strings.HasPrefix(auth, prefix) ||
+		subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(h.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(401)
+		return
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// Handler returns the handler implementing the daemon API.
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/events", authHandler{
+		handler: eventsHandler{events: d.events},
+		token:   d.config.Token,
+	})
+	mux.Handle("/rpc", authHandler{
+		handler: rpcHandler{daemon: d},
+		token:   d.config.Token,
+	})
+	return mux
+}
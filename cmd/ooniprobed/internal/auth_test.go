@@ -0,0 +1,42 @@
+package internal_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ooni/probe-engine/cmd/ooniprobed/internal"
+)
+
+func TestLoadOrCreateToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ooniprobed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	created, err := internal.LoadOrCreateToken(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 64 {
+		t.Fatal("unexpected token length")
+	}
+	loaded, err := internal.LoadOrCreateToken(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != created {
+		t.Fatal("we did not load the same token")
+	}
+	if err := ioutil.WriteFile(path, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := internal.LoadOrCreateToken(path); err == nil {
+		t.Fatal("expected an error here")
+	}
+	if _, err := internal.LoadOrCreateToken(filepath.Join(dir, "x", "token")); err == nil {
+		t.Fatal("expected an error here")
+	}
+}
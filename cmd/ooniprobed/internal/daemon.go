@@ -0,0 +1,312 @@
+// Package internal contains the implementation of ooniprobed.
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/oonimkall/tasks"
+)
+
+const (
+	// maxHistory is the maximum number of finished tasks and of
+	// pending submissions we keep in memory.
+	maxHistory = 1000
+
+	// maxQueuedTasks is the maximum number of queued tasks.
+	maxQueuedTasks = 128
+
+	// schedulesKey is the key under which we save the schedules.
+	schedulesKey = "ooniprobed_schedules.json"
+
+	statusDone    = "done"
+	statusQueued  = "queued"
+	statusRunning = "running"
+	statusStopped = "stopped"
+)
+
+var (
+	// ErrNoSuchSchedule indicates that a schedule does not exist.
+	ErrNoSuchSchedule = errors.New("no such schedule")
+
+	// ErrNoSuchTask indicates that a task does not exist.
+	ErrNoSuchTask = errors.New("no such task")
+
+	// ErrQueueFull indicates that there are too many queued tasks.
+	ErrQueueFull = errors.New("too many queued tasks")
+
+	// ErrInvalidInterval indicates that a schedule interval is not positive.
+	ErrInvalidInterval = errors.New("invalid schedule interval")
+)
+
+// Config contains the daemon configuration.
+type Config struct {
+	// KVStore is the optional store where we save the schedules. When
+	// not set, the schedules do not survive a daemon restart.
+	KVStore model.KeyValueStore
+
+	// Locate is the function that looks up the probe location.
+	Locate func(ctx context.Context) (*Location, error)
+
+	// Logger is the logger to use.
+	Logger model.Logger
+
+	// RunTask is the function that runs a task until completion,
+	// emitting events on the out channel. The daemon never calls this
+	// function concurrently, so it can share the same session.
+	RunTask func(ctx context.Context, settings *tasks.Settings, out chan<- *tasks.Event)
+
+	// Token is the token that clients must provide.
+	Token string
+}
+
+// Location is the probe location.
+type Location struct {
+	ProbeASN            string `json:"probe_asn"`
+	ProbeCC             string `json:"probe_cc"`
+	ProbeIP             string `json:"probe_ip"`
+	ProbeNetworkName    string `json:"probe_network_name"`
+	ResolverASN         string `json:"resolver_asn"`
+	ResolverIP          string `json:"resolver_ip"`
+	ResolverNetworkName string `json:"resolver_network_name"`
+}
+
+// Event is a task event as seen by clients.
+type Event struct {
+	TaskID string      `json:"task_id"`
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+}
+
+// PendingSubmission is a measurement that we could not submit.
+type PendingSubmission struct {
+	Failure     string          `json:"failure"`
+	Input       string          `json:"input"`
+	Measurement json.RawMessage `json:"measurement"`
+	TaskID      string          `json:"task_id"`
+}
+
+// Schedule describes a recurring task.
+type Schedule struct {
+	ID       string         `json:"id"`
+	Interval int64          `json:"interval_seconds"`
+	NextRun  time.Time      `json:"next_run"`
+	Settings tasks.Settings `json:"settings"`
+}
+
+// TaskInfo describes a task.
+type TaskInfo struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	ScheduleID string `json:"schedule_id,omitempty"`
+	Status     string `json:"status"`
+}
+
+type task struct {
+	cancel   context.CancelFunc
+	info     TaskInfo
+	settings *tasks.Settings
+}
+
+// Daemon runs tasks on behalf of clients.
+type Daemon struct {
+	config    Config
+	events    *hub
+	location  *Location
+	mu        sync.Mutex
+	pending   []PendingSubmission
+	queue     chan *task
+	schedules []*Schedule
+	tasks     []*task
+}
+
+// New creates a new daemon. It fails if we cannot load the schedules.
+func New(config Config) (*Daemon, error) {
+	d := &Daemon{
+		config: config,
+		events: newHub(),
+		queue:  make(chan *task, maxQueuedTasks),
+	}
+	if err := d.loadSchedules(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Run runs the daemon until ctx is done. We look up the location
+// first, and then we run the queued and the scheduled tasks.
+func (d *Daemon) Run(ctx context.Context) {
+	d.refreshLocation(ctx)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			d.startScheduledTasks(now)
+		case t := <-d.queue:
+			d.runTask(ctx, t)
+		}
+	}
+}
+
+// StartTask queues a new task and returns its ID.
+func (d *Daemon) StartTask(settings *tasks.Settings) (string, error) {
+	return d.startTask(settings, "")
+}
+
+func (d *Daemon) startTask(settings *tasks.Settings, scheduleID string) (string, error) {
+	t := &task{
+		info: TaskInfo{
+			ID:         uuid.Must(uuid.NewRandom()).String(),
+			Name:       settings.Name,
+			ScheduleID: scheduleID,
+			Status:     statusQueued,
+		},
+		settings: settings,
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	select {
+	case d.queue <- t:
+	default:
+		return "", ErrQueueFull
+	}
+	d.tasks = append(d.tasks, t)
+	d.pruneTasksLocked()
+	return t.info.ID, nil
+}
+
+// pruneTasksLocked forgets the oldest finished tasks when we have
+// more than maxHistory tasks. It requires holding d.mu.
+func (d *Daemon) pruneTasksLocked() {
+	excess := len(d.tasks) - maxHistory
+	if excess <= 0 {
+		return
+	}
+	var kept []*task
+	for _, t := range d.tasks {
+		finished := t.info.Status == statusDone || t.info.Status == statusStopped
+		if excess > 0 && finished {
+			excess--
+			continue
+		}
+		kept = append(kept, t)
+	}
+	d.tasks = kept
+}
+
+// StopTask stops a queued or running task.
+func (d *Daemon) StopTask(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, t := range d.tasks {
+		if t.info.ID != id {
+			continue
+		}
+		switch t.info.Status {
+		case statusQueued:
+			t.info.Status = statusStopped
+		case statusRunning:
+			t.info.Status = statusStopped
+			t.cancel()
+		}
+		return nil
+	}
+	return ErrNoSuchTask
+}
+
+// Tasks returns information on the known tasks.
+func (d *Daemon) Tasks() []TaskInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := []TaskInfo{}
+	for _, t := range d.tasks {
+		out = append(out, t.info)
+	}
+	return out
+}
+
+func (d *Daemon) runTask(parent context.Context, t *task) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	d.mu.Lock()
+	if t.info.Status != statusQueued {
+		d.mu.Unlock()
+		return // stopped while queued
+	}
+	t.info.Status = statusRunning
+	t.cancel = cancel
+	d.mu.Unlock()
+	d.config.Logger.Infof("ooniprobed: running task %s (%s)", t.info.ID, t.info.Name)
+	out := make(chan *tasks.Event)
+	done := make(chan interface{})
+	go func() {
+		defer close(done)
+		for ev := range out {
+			d.handleEvent(t.info.ID, ev)
+		}
+	}()
+	d.config.RunTask(ctx, t.settings, out)
+	close(out)
+	<-done
+	d.mu.Lock()
+	if t.info.Status == statusRunning {
+		t.info.Status = statusDone
+	}
+	d.mu.Unlock()
+	d.refreshLocation(parent)
+}
+
+func (d *Daemon) handleEvent(taskID string, ev *tasks.Event) {
+	d.events.publish(&Event{TaskID: taskID, Key: ev.Key, Value: ev.Value})
+	if ev.Key != "failure.measurement_submission" {
+		return
+	}
+	mev, ok := ev.Value.(tasks.EventMeasurementGeneric)
+	if !ok || mev.JSONStr == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending = append(d.pending, PendingSubmission{
+		Failure:     mev.Failure,
+		Input:       mev.Input,
+		Measurement: json.RawMessage(mev.JSONStr),
+		TaskID:      taskID,
+	})
+	if excess := len(d.pending) - maxHistory; excess > 0 {
+		d.pending = d.pending[excess:]
+	}
+}
+
+// PendingSubmissions returns the measurements we could not submit.
+func (d *Daemon) PendingSubmissions() []PendingSubmission {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]PendingSubmission{}, d.pending...)
+}
+
+func (d *Daemon) refreshLocation(ctx context.Context) {
+	location, err := d.config.Locate(ctx)
+	if err != nil {
+		d.config.Logger.Warnf("ooniprobed: cannot lookup location: %s", err.Error())
+		return
+	}
+	d.mu.Lock()
+	d.location = location
+	d.mu.Unlock()
+}
+
+// Location returns the probe location or nil, if we do not know it yet.
+func (d *Daemon) Location() *Location {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.location
+}
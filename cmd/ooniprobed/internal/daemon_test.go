@@ -0,0 +1,132 @@
+package internal_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-engine/cmd/ooniprobed/internal"
+	"github.com/ooni/probe-engine/oonimkall/tasks"
+)
+
+const token = "xo"
+
+func newDaemon(t *testing.T, runTask func(
+	ctx context.Context, settings *tasks.Settings, out chan<- *tasks.Event)) *internal.Daemon {
+	d, err := internal.New(internal.Config{
+		Locate: func(ctx context.Context) (*internal.Location, error) {
+			return &internal.Location{ProbeCC: "IT"}, nil
+		},
+		Logger:  log.Log,
+		RunTask: runTask,
+		Token:   token,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// runFailingSubmission emits the events of a task whose measurement
+// we could not submit to the collector.
+func runFailingSubmission(ctx context.Context, settings *tasks.Settings, out chan<- *tasks.Event) {
+	out <- &tasks.Event{Key: "status.started", Value: struct{}{}}
+	out <- &tasks.Event{Key: "failure.measurement_submission", Value: tasks.EventMeasurementGeneric{
+		Failure: "generic_timeout_error",
+		Idx:     0,
+		Input:   "https://www.example.com/",
+		JSONStr: `{"test_name":"web_connectivity"}`,
+	}}
+	out <- &tasks.Event{Key: "status.end", Value: struct{}{}}
+}
+
+// runUntilCanceled emits an event and then blocks until ctx is done.
+func runUntilCanceled(ctx context.Context, settings *tasks.Settings, out chan<- *tasks.Event) {
+	out <- &tasks.Event{Key: "status.started", Value: struct{}{}}
+	<-ctx.Done()
+}
+
+func waitForStatus(t *testing.T, d *internal.Daemon, id, status string) {
+	for i := 0; i < 100; i++ {
+		for _, info := range d.Tasks() {
+			if info.ID == id && info.Status == status {
+				return
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("task %s never reached status %s", id, status)
+}
+
+func TestDaemonRunsTasks(t *testing.T) {
+	d := newDaemon(t, runFailingSubmission)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+	id, err := d.StartTask(&tasks.Settings{Name: "WebConnectivity"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, d, id, "done")
+	pending := d.PendingSubmissions()
+	if len(pending) != 1 {
+		t.Fatal("unexpected number of pending submissions")
+	}
+	if pending[0].TaskID != id || pending[0].Failure != "generic_timeout_error" {
+		t.Fatalf("unexpected pending submission: %+v", pending[0])
+	}
+	if string(pending[0].Measurement) != `{"test_name":"web_connectivity"}` {
+		t.Fatal("unexpected pending measurement")
+	}
+	if location := d.Location(); location == nil || location.ProbeCC != "IT" {
+		t.Fatal("unexpected location")
+	}
+}
+
+func TestDaemonStopsTasks(t *testing.T) {
+	d := newDaemon(t, runUntilCanceled)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first, err := d.StartTask(&tasks.Settings{Name: "Example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := d.StartTask(&tasks.Settings{Name: "Example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.StopTask(second); err != nil {
+		t.Fatal(err) // stopping a queued task
+	}
+	go d.Run(ctx)
+	waitForStatus(t, d, first, "running")
+	if err := d.StopTask(first); err != nil {
+		t.Fatal(err) // stopping a running task
+	}
+	waitForStatus(t, d, first, "stopped")
+	waitForStatus(t, d, second, "stopped")
+	if err := d.StopTask("antani"); !errors.Is(err, internal.ErrNoSuchTask) {
+		t.Fatal("not the error we expected", err)
+	}
+}
+
+func TestDaemonWithLocateFailure(t *testing.T) {
+	d, err := internal.New(internal.Config{
+		Locate: func(ctx context.Context) (*internal.Location, error) {
+			return nil, errors.New("mocked error")
+		},
+		Logger:  log.Log,
+		RunTask: runFailingSubmission,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // Run returns after looking up the location
+	d.Run(ctx)
+	if d.Location() != nil {
+		t.Fatal("expected nil location")
+	}
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// subscriberBuffer is the number of events we buffer for each
+// subscriber. We drop events for subscribers that are too slow, so
+// that a stuck client cannot block the running task.
+const subscriberBuffer = 256
+
+type hub struct {
+	mu          sync.Mutex
+	subscribers map[chan *Event]bool
+}
+
+func newHub() *hub {
+	return &hub{subscribers: make(map[chan *Event]bool)}
+}
+
+func (h *hub) subscribe() chan *Event {
+	ch := make(chan *Event, subscriberBuffer)
+	h.mu.Lock()
+	h.subscribers[ch] = true
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *hub) unsubscribe(ch chan *Event) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+func (h *hub) publish(ev *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+var upgrader = websocket.Upgrader{}
+
+// eventsHandler streams events using WebSocket, if the client asks for
+// an upgrade, and Server-Sent Events otherwise. The optional task_id
+// query parameter selects the events of a single task.
+type eventsHandler struct {
+	events *hub
+}
+
+func (h eventsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		w.WriteHeader(405)
+		return
+	}
+	if websocket.IsWebSocketUpgrade(req) {
+		h.serveWebSocket(w, req)
+		return
+	}
+	h.serveSSE(w, req)
+}
+
+func (h eventsHandler) serveWebSocket(w http.ResponseWriter, req *http.Request) {
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return // the upgrader has already written the response
+	}
+	defer conn.Close()
+	// Implementation note: we need to read from the connection to
+	// notice when the client has closed it.
+	closed := make(chan interface{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	ch := h.events.subscribe()
+	defer h.events.unsubscribe(ch)
+	taskID := req.URL.Query().Get("task_id")
+	for {
+		select {
+		case <-closed:
+			return
+		case ev := <-ch:
+			if taskID != "" && ev.TaskID != taskID {
+				continue
+			}
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h eventsHandler) serveSSE(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(500)
+		return
+	}
+	ch := h.events.subscribe()
+	defer h.events.unsubscribe(ch)
+	taskID := req.URL.Query().Get("task_id")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(200)
+	flusher.Flush()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case ev := <-ch:
+			if taskID != "" && ev.TaskID != taskID {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Key, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
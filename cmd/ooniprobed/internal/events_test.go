@@ -0,0 +1,88 @@
+package internal_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/ooni/probe-engine/cmd/ooniprobed/internal"
+	"github.com/ooni/probe-engine/oonimkall/tasks"
+)
+
+func TestEventsWithSSE(t *testing.T) {
+	d := newDaemon(t, runFailingSubmission)
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal("unexpected content type")
+	}
+	go d.Run(ctx)
+	id, err := d.StartTask(&tasks.Settings{Name: "Example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data: ") && strings.Contains(line, `"key":"status.end"`) {
+			if !strings.Contains(line, id) {
+				t.Fatal("missing task ID")
+			}
+			return
+		}
+	}
+	t.Fatal("we did not see the status.end event")
+}
+
+func TestEventsWithWebSocket(t *testing.T) {
+	d := newDaemon(t, runFailingSubmission)
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	URL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/events?task_id=antani"
+	if _, _, err := websocket.DefaultDialer.Dial(URL, nil); err == nil {
+		t.Fatal("expected an error here") // no token
+	}
+	go d.Run(ctx)
+	first, err := d.StartTask(&tasks.Settings{Name: "Example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, d, first, "done")
+	URL = "ws" + strings.TrimPrefix(srv.URL, "http") + "/events"
+	conn, _, err := websocket.DefaultDialer.Dial(URL, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	second, err := d.StartTask(&tasks.Settings{Name: "Example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ev internal.Event
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.TaskID != second || ev.Key != "status.started" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+}
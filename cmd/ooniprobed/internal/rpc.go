@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/ooni/probe-engine/oonimkall/tasks"
+)
+
+// maxRequestBody is the maximum size of a JSON-RPC request.
+const maxRequestBody = 1 << 20
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+type rpcRequest struct {
+	ID      json.RawMessage `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+}
+
+// idParams are the params of methods that take an ID.
+type idParams struct {
+	ID string `json:"id"`
+}
+
+// idResult is the result of methods that create something.
+type idResult struct {
+	ID string `json:"id"`
+}
+
+type scheduleParams struct {
+	Interval int64          `json:"interval_seconds"`
+	Settings tasks.Settings `json:"settings"`
+}
+
+// rpcHandler implements the JSON-RPC 2.0 API. We do not support
+// batches, because clients do not need them.
+type rpcHandler struct {
+	daemon *Daemon
+}
+
+func (h rpcHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.WriteHeader(405)
+		return
+	}
+	reader := &io.LimitedReader{R: req.Body, N: maxRequestBody}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	resp := &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	var rreq rpcRequest
+	if err := json.Unmarshal(data, &rreq); err != nil {
+		resp.Error = &rpcError{Code: rpcParseError, Message: err.Error()}
+	} else if rreq.JSONRPC != "2.0" || rreq.Method == "" {
+		resp.Error = &rpcError{Code: rpcInvalidRequest, Message: "invalid request"}
+	} else {
+		if len(rreq.ID) > 0 {
+			resp.ID = rreq.ID
+		}
+		resp.Result, resp.Error = h.call(rreq.Method, rreq.Params)
+	}
+	// We assume that the following call cannot fail because it's a
+	// clearly serializable data structure.
+	data, _ = json.Marshal(resp)
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (h rpcHandler) call(method string, params json.RawMessage) (interface{}, *rpcError) {
+	switch method {
+	case "Geolocation.Get":
+		location := h.daemon.Location()
+		if location == nil {
+			return nil, serverError(errors.New("location not available yet"))
+		}
+		return location, nil
+	case "Schedules.Add":
+		var p scheduleParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		id, err := h.daemon.AddSchedule(p.Settings, p.Interval)
+		if err != nil {
+			return nil, serverError(err)
+		}
+		return idResult{ID: id}, nil
+	case "Schedules.List":
+		return h.daemon.Schedules(), nil
+	case "Schedules.Remove":
+		var p idParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		if err := h.daemon.RemoveSchedule(p.ID); err != nil {
+			return nil, serverError(err)
+		}
+		return idResult{ID: p.ID}, nil
+	case "Submissions.ListPending":
+		return h.daemon.PendingSubmissions(), nil
+	case "Tasks.List":
+		return h.daemon.Tasks(), nil
+	case "Tasks.Start":
+		var settings tasks.Settings
+		if err := json.Unmarshal(params, &settings); err != nil {
+			return nil, invalidParams(err)
+		}
+		id, err := h.daemon.StartTask(&settings)
+		if err != nil {
+			return nil, serverError(err)
+		}
+		return idResult{ID: id}, nil
+	case "Tasks.Stop":
+		var p idParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		if err := h.daemon.StopTask(p.ID); err != nil {
+			return nil, serverError(err)
+		}
+		return idResult{ID: p.ID}, nil
+	default:
+		return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found"}
+	}
+}
+
+func invalidParams(err error) *rpcError {
+	return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+}
+
+func serverError(err error) *rpcError {
+	return &rpcError{Code: rpcServerError, Message: err.Error()}
+}
@@ -0,0 +1,146 @@
+package internal_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type rpcResult struct {
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+}
+
+func call(t *testing.T, URL, body string) *rpcResult {
+	req, err := http.NewRequest("POST", URL+"/rpc", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatal("unexpected status code", resp.StatusCode)
+	}
+	var result rpcResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	return &result
+}
+
+func TestRPCAuthentication(t *testing.T) {
+	d := newDaemon(t, runFailingSubmission)
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+	for _, auth := range []string{"", "Bearer ", "Bearer antani", "Basic xo"} {
+		req, err := http.NewRequest("POST", srv.URL+"/rpc", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", auth)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 401 {
+			t.Fatalf("%s: unexpected status code: %d", auth, resp.StatusCode)
+		}
+	}
+}
+
+func TestRPCErrors(t *testing.T) {
+	d := newDaemon(t, runFailingSubmission)
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{{
+		name: "with invalid JSON",
+		body: "{",
+		code: -32700,
+	}, {
+		name: "with invalid version",
+		body: `{"jsonrpc":"1.0","method":"Tasks.List","id":1}`,
+		code: -32600,
+	}, {
+		name: "with unknown method",
+		body: `{"jsonrpc":"2.0","method":"Tasks.Antani","id":1}`,
+		code: -32601,
+	}, {
+		name: "with invalid params",
+		body: `{"jsonrpc":"2.0","method":"Tasks.Start","params":[],"id":1}`,
+		code: -32602,
+	}, {
+		name: "with unknown task",
+		body: `{"jsonrpc":"2.0","method":"Tasks.Stop","params":{"id":"x"},"id":1}`,
+		code: -32000,
+	}, {
+		name: "with unknown location",
+		body: `{"jsonrpc":"2.0","method":"Geolocation.Get","id":1}`,
+		code: -32000,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := call(t, srv.URL, tt.body)
+			if result.Error == nil || result.Error.Code != tt.code {
+				t.Fatalf("unexpected result: %+v", result)
+			}
+		})
+	}
+}
+
+func TestRPCTasks(t *testing.T) {
+	d := newDaemon(t, runFailingSubmission)
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+	result := call(t, srv.URL, `{"jsonrpc":"2.0","method":"Tasks.Start",
+		"params":{"name":"Example","version":1},"id":"antani"}`)
+	if result.Error != nil || string(result.ID) != `"antani"` {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	var started struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(result.Result, &started); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, d, started.ID, "done")
+	result = call(t, srv.URL, `{"jsonrpc":"2.0","method":"Tasks.List","id":2}`)
+	if !strings.Contains(string(result.Result), started.ID) {
+		t.Fatal("the task is not listed")
+	}
+	result = call(t, srv.URL, `{"jsonrpc":"2.0","method":"Submissions.ListPending","id":3}`)
+	if !strings.Contains(string(result.Result), `"test_name":"web_connectivity"`) {
+		t.Fatal("the pending submission is not listed")
+	}
+	result = call(t, srv.URL, `{"jsonrpc":"2.0","method":"Geolocation.Get","id":4}`)
+	if !strings.Contains(string(result.Result), `"probe_cc":"IT"`) {
+		t.Fatal("unexpected location")
+	}
+	result = call(t, srv.URL, `{"jsonrpc":"2.0","method":"Schedules.Add",
+		"params":{"interval_seconds":3600,"settings":{"name":"Example"}},"id":5}`)
+	if result.Error != nil {
+		t.Fatal(result.Error.Message)
+	}
+	result = call(t, srv.URL, `{"jsonrpc":"2.0","method":"Schedules.List","id":6}`)
+	if !strings.Contains(string(result.Result), `"interval_seconds":3600`) {
+		t.Fatal("the schedule is not listed")
+	}
+}
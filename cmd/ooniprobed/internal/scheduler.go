@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ooni/probe-engine/oonimkall/tasks"
+)
+
+// AddSchedule adds a schedule that runs a task with the given
+// settings every interval seconds, starting now.
+func (d *Daemon) AddSchedule(settings tasks.Settings, interval int64) (string, error) {
+	if interval <= 0 {
+		return "", ErrInvalidInterval
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	schedule := &Schedule{
+		ID:       uuid.Must(uuid.NewRandom()).String(),
+		Interval: interval,
+		NextRun:  time.Now(),
+		Settings: settings,
+	}
+	d.schedules = append(d.schedules, schedule)
+	if err := d.saveSchedulesLocked(); err != nil {
+		d.schedules = d.schedules[:len(d.schedules)-1]
+		return "", err
+	}
+	return schedule.ID, nil
+}
+
+// RemoveSchedule removes a schedule. Tasks that the schedule has
+// already started are not affected.
+func (d *Daemon) RemoveSchedule(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for idx, schedule := range d.schedules {
+		if schedule.ID != id {
+			continue
+		}
+		schedules := append([]*Schedule{}, d.schedules[:idx]...)
+		d.schedules = append(schedules, d.schedules[idx+1:]...)
+		return d.saveSchedulesLocked()
+	}
+	return ErrNoSuchSchedule
+}
+
+// Schedules returns the current schedules.
+func (d *Daemon) Schedules() []Schedule {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := []Schedule{}
+	for _, schedule := range d.schedules {
+		out = append(out, *schedule)
+	}
+	return out
+}
+
+// startScheduledTasks queues the tasks that should run at now. When
+// the daemon was not running for a while, we only run a task once
+// rather than once per missed interval.
+func (d *Daemon) startScheduledTasks(now time.Time) {
+	d.mu.Lock()
+	var ready []*Schedule
+	for _, schedule := range d.schedules {
+		if schedule.NextRun.After(now) {
+			continue
+		}
+		ready = append(ready, schedule)
+		interval := time.Duration(schedule.Interval) * time.Second
+		for !schedule.NextRun.After(now) {
+			schedule.NextRun = schedule.NextRun.Add(interval)
+		}
+	}
+	if len(ready) > 0 {
+		if err := d.saveSchedulesLocked(); err != nil {
+			d.config.Logger.Warnf("ooniprobed: cannot save schedules: %s", err.Error())
+		}
+	}
+	d.mu.Unlock()
+	for _, schedule := range ready {
+		settings := schedule.Settings // shallow copy
+		if _, err := d.startTask(&settings, schedule.ID); err != nil {
+			d.config.Logger.Warnf("ooniprobed: cannot start scheduled task: %s", err.Error())
+		}
+	}
+}
+
+func (d *Daemon) loadSchedules() error {
+	if d.config.KVStore == nil {
+		return nil
+	}
+	data, err := d.config.KVStore.Get(schedulesKey)
+	if err != nil {
+		return nil // nothing saved yet
+	}
+	return json.Unmarshal(data, &d.schedules)
+}
+
+// saveSchedulesLocked saves the schedules. It requires holding d.mu.
+func (d *Daemon) saveSchedulesLocked() error {
+	if d.config.KVStore == nil {
+		return nil
+	}
+	data, err := json.Marshal(d.schedules)
+	if err != nil {
+		return err
+	}
+	return d.config.KVStore.Set(schedulesKey, data)
+}
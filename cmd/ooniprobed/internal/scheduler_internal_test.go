@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-engine/internal/kvstore"
+	"github.com/ooni/probe-engine/oonimkall/tasks"
+)
+
+func TestSchedules(t *testing.T) {
+	config := Config{
+		KVStore: kvstore.NewMemoryKeyValueStore(),
+		Logger:  log.Log,
+		RunTask: func(ctx context.Context, settings *tasks.Settings, out chan<- *tasks.Event) {},
+	}
+	d, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.AddSchedule(tasks.Settings{}, 0); !errors.Is(err, ErrInvalidInterval) {
+		t.Fatal("not the error we expected", err)
+	}
+	id, err := d.AddSchedule(tasks.Settings{Name: "Example"}, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	d.startScheduledTasks(now)
+	d.startScheduledTasks(now) // not yet time to run again
+	infos := d.Tasks()
+	if len(infos) != 1 || infos[0].ScheduleID != id || infos[0].Name != "Example" {
+		t.Fatalf("unexpected tasks: %+v", infos)
+	}
+	if next := d.Schedules()[0].NextRun; !next.After(now) || next.Sub(now) > time.Hour {
+		t.Fatal("unexpected next run")
+	}
+	reloaded, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedules := reloaded.Schedules(); len(schedules) != 1 || schedules[0].ID != id {
+		t.Fatal("we did not reload the schedules")
+	}
+	if err := d.RemoveSchedule(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RemoveSchedule(id); !errors.Is(err, ErrNoSuchSchedule) {
+		t.Fatal("not the error we expected", err)
+	}
+	if len(d.Schedules()) != 0 {
+		t.Fatal("we did not remove the schedule")
+	}
+}
+
+func TestPruneTasks(t *testing.T) {
+	d := &Daemon{}
+	d.tasks = append(d.tasks, &task{info: TaskInfo{Status: statusRunning}})
+	for i := 0; i < maxHistory; i++ {
+		d.tasks = append(d.tasks, &task{info: TaskInfo{Status: statusDone}})
+	}
+	d.pruneTasksLocked()
+	if len(d.tasks) != maxHistory || d.tasks[0].info.Status != statusRunning {
+		t.Fatal("we did not prune the oldest finished task")
+	}
+}
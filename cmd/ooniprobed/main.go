@@ -0,0 +1,130 @@
+// Command ooniprobed is a daemon that keeps a session alive and exposes
+// an authenticated local JSON-RPC API for running tasks.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/apex/log"
+	engine "github.com/ooni/probe-engine"
+	"github.com/ooni/probe-engine/cmd/ooniprobed/internal"
+	"github.com/ooni/probe-engine/internal/runtimex"
+	"github.com/ooni/probe-engine/oonimkall/tasks"
+	"github.com/ooni/probe-engine/version"
+)
+
+var (
+	endpoint = flag.String("endpoint", "127.0.0.1:9876", "Endpoint where to listen")
+	homeDir  = flag.String("home", "", "Directory where to store state")
+)
+
+func shutdown(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
+}
+
+func main() {
+	logmap := map[bool]log.Level{
+		true:  log.DebugLevel,
+		false: log.InfoLevel,
+	}
+	debug := flag.Bool("debug", false, "Toggle debug mode")
+	flag.Parse()
+	log.SetLevel(logmap[*debug])
+	if *homeDir == "" {
+		*homeDir = filepath.Join(os.Getenv("HOME"), ".ooniprobed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+	if err := testableMain(ctx); err != nil {
+		log.WithError(err).Fatal("ooniprobed: cannot serve the API")
+	}
+}
+
+func newSession() *engine.Session {
+	assetsDir := filepath.Join(*homeDir, "assets")
+	err := os.MkdirAll(assetsDir, 0700)
+	runtimex.PanicOnError(err, "cannot create assets directory")
+	kvstore, err := engine.NewFileSystemKVStore(filepath.Join(*homeDir, "kvstore2"))
+	runtimex.PanicOnError(err, "cannot create kvstore2 directory")
+	sess, err := engine.NewSession(engine.SessionConfig{
+		AssetsDir:       assetsDir,
+		KVStore:         kvstore,
+		Logger:          log.Log,
+		SoftwareName:    "ooniprobed",
+		SoftwareVersion: version.Version,
+	})
+	runtimex.PanicOnError(err, "cannot create session")
+	return sess
+}
+
+// testableMain runs the daemon until ctx is done or we cannot serve
+// the API anymore, in which case it returns the serving error.
+func testableMain(ctx context.Context) error {
+	sess := newSession()
+	defer sess.Close()
+	token, err := internal.LoadOrCreateToken(filepath.Join(*homeDir, "token"))
+	runtimex.PanicOnError(err, "cannot load or create token")
+	daemon, err := internal.New(internal.Config{
+		KVStore: sess.KeyValueStore(),
+		Locate: func(ctx context.Context) (*internal.Location, error) {
+			if err := sess.RefreshLocationContext(ctx); err != nil {
+				return nil, err
+			}
+			return &internal.Location{
+				ProbeASN:            sess.ProbeASNString(),
+				ProbeCC:             sess.ProbeCC(),
+				ProbeIP:             sess.ProbeIP(),
+				ProbeNetworkName:    sess.ProbeNetworkName(),
+				ResolverASN:         sess.ResolverASNString(),
+				ResolverIP:          sess.ResolverIP(),
+				ResolverNetworkName: sess.ResolverNetworkName(),
+			}, nil
+		},
+		Logger: log.Log,
+		RunTask: func(ctx context.Context, settings *tasks.Settings, out chan<- *tasks.Event) {
+			tasks.NewRunnerWithSession(settings, out, sess).Run(ctx)
+		},
+		Token: token,
+	})
+	runtimex.PanicOnError(err, "cannot create daemon")
+	srv := &http.Server{Addr: *endpoint, Handler: daemon.Handler()}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	daemonDone := make(chan interface{})
+	go func() {
+		defer close(daemonDone)
+		daemon.Run(ctx)
+	}()
+	log.Infof("ooniprobed: listening at %s", *endpoint)
+	select {
+	case <-ctx.Done():
+		shutdown(srv)
+	case err = <-serveErr:
+		cancel() // stop the daemon
+	}
+	<-daemonDone // the session must outlive the running task
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	return err
+}
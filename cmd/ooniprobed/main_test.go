@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestSmoke(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ooniprobed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	*homeDir = dir
+	*endpoint = "127.0.0.1:0"
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // kills the listener
+	if err := testableMain(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListenFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ooniprobed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	*homeDir = dir
+	*endpoint = conn.Addr().String() // already in use
+	if err := testableMain(context.Background()); err == nil {
+		t.Fatal("expected an error here")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"strconv"
@@ -114,7 +115,7 @@ func (e *Experiment) MeasureWithContext(
 	err = e.measurer.Run(ctx, e.session, measurement, e.callbacks)
 	stop := time.Now()
 	measurement.MeasurementRuntime = stop.Sub(start).Seconds()
-	scrubErr := measurement.Scrub(e.session.ProbeIP())
+	scrubErr := measurement.Scrub(e.session.probeIPs()...)
 	if err == nil {
 		err = scrubErr
 	}
@@ -168,10 +169,21 @@ func (e *Experiment) newMeasurement(input string) *model.Measurement {
 		TestStartTime:             e.testStartTime,
 		TestVersion:               e.testVersion,
 	}
-	m.AddAnnotation("assets_version", strconv.FormatInt(resources.Version, 10))
+	m.AddAnnotation("assets_version", strconv.FormatInt(
+		resources.InstalledVersion(e.session.assetsDir), 10))
 	m.AddAnnotation("engine_name", "ooniprobe-engine")
 	m.AddAnnotation("engine_version", version.Version)
 	m.AddAnnotation("platform", platform.Name())
+	// On dual-stack networks, IPv4 and IPv6 traffic may be routed
+	// through different ASes, so we record both identities.
+	if identity := e.session.ProbeIPv4Identity(); identity != nil {
+		m.AddAnnotation("probe_ipv4_asn", fmt.Sprintf("AS%d", identity.ASN))
+		m.AddAnnotation("probe_ipv4_cc", identity.CountryCode)
+	}
+	if identity := e.session.ProbeIPv6Identity(); identity != nil {
+		m.AddAnnotation("probe_ipv6_asn", fmt.Sprintf("AS%d", identity.ASN))
+		m.AddAnnotation("probe_ipv6_cc", identity.CountryCode)
+	}
 	return m
 }
 
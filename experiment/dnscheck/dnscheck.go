@@ -75,7 +75,7 @@ type Config struct {
 	HTTP3Enabled  bool   `json:"http3_enabled" ooni:"use http3 instead of http/1.1 or http2"`
 	HTTPHost      string `json:"http_host" ooni:"force using specific HTTP Host header"`
 	TLSServerName string `json:"tls_server_name" ooni:"force TLS to using a specific SNI in Client Hello"`
-	TLSVersion    string `json:"tls_version" ooni:"Force specific TLS version (e.g. 'TLSv1.3')"`
+	TLSVersion    string `json:"tls_version" ooni:"Force specific TLS version (e.g. 'TLSv1.3')" ooni_enum:"TLSv1,TLSv1.0,TLSv1.1,TLSv1.2,TLSv1.3"`
 }
 
 // TestKeys contains the results of the dnscheck experiment.
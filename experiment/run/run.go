@@ -1,23 +1,59 @@
 // Package run contains code to run other experiments.
 //
+// The input of this experiment is a JSON document describing either
+// a single experiment to run or a list of steps. Each step names an
+// experiment along with its input and options and may be conditional
+// on the outcome of a previous step. Steps share a DNS cache, so that
+// later steps reuse the addresses discovered by earlier ones.
+//
 // This code is currently alpha.
 package run
 
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/ooni/probe-engine/experiment/dnscheck"
 	"github.com/ooni/probe-engine/experiment/urlgetter"
+	"github.com/ooni/probe-engine/internal/multierror"
 	"github.com/ooni/probe-engine/model"
 )
 
+// Sink saves and submits the measurement of each step. Because each
+// step may run a different experiment, the Sink should open a new report
+// using the step measurement as template whenever the test name changes,
+// so that each step ends up in the report of its own experiment.
+type Sink interface {
+	SaveAndSubmit(ctx context.Context, m *model.Measurement) error
+}
+
 // Config contains settings.
-type Config struct{}
+type Config struct {
+	// NewMeasurer creates a measurer for the experiment with the given
+	// name configured using the given options. We use this function for
+	// the experiments that are not natively supported by this package. If
+	// this field is nil, we only support dnscheck and urlgetter.
+	NewMeasurer func(name string, options map[string]string) (model.ExperimentMeasurer, error)
+
+	// Sink, if not nil, receives the measurement of every step. In such
+	// case, we return to the caller a measurement whose test keys summarize
+	// the steps. Otherwise, we return the measurement of the last step
+	// that we have run and we discard the other ones.
+	Sink Sink
+}
+
+// SetSink sets the Sink. The engine uses this method to give
+// us the sink that should receive the measurement of every step.
+func (c *Config) SetSink(sink Sink) {
+	c.Sink = sink
+}
 
 // Measurer runs the measurement.
-type Measurer struct{}
+type Measurer struct {
+	config Config
+}
 
 // ExperimentName implements ExperimentMeasurer.ExperimentName.
 func (Measurer) ExperimentName() string {
@@ -26,7 +62,7 @@ func (Measurer) ExperimentName() string {
 
 // ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
 func (Measurer) ExperimentVersion() string {
-	return "0.2.0"
+	return "0.4.0"
 }
 
 // StructuredInput contains structured input for this experiment.
@@ -41,15 +77,74 @@ type StructuredInput struct {
 	// URLGetter contains settings for the urlgetter experiment.
 	URLGetter urlgetter.Config `json:"urlgetter"`
 
+	// ID identifies this step such that later steps could
+	// refer to it in their conditions.
+	ID string `json:"id"`
+
+	// If is the optional condition that must be true
+	// for this step to run.
+	If *Condition `json:"if"`
+
 	// Name is the name of the experiment to run.
 	Name string `json:"name"`
 
 	// Input is the input for this experiment.
 	Input string `json:"input"`
+
+	// Options contains the options of experiments different
+	// from dnscheck and urlgetter, which we set using the
+	// same rules that apply to command line options.
+	Options map[string]string `json:"options"`
+
+	// Steps contains the steps to run. When this field is
+	// empty, the structured input itself is the only step. We
+	// do not allow a step to contain other steps.
+	Steps []StructuredInput `json:"steps"`
+}
+
+// TestKeys contains the test keys of the measurement we return when
+// we have a Sink, which summarize the steps that we have run.
+type TestKeys struct {
+	Steps []StepResult `json:"steps"`
+}
+
+// StepResult is the result of a step.
+type StepResult struct {
+	// Failure is the error that occurred when running the step.
+	Failure *string `json:"failure"`
+
+	// ID is the ID of the step.
+	ID string `json:"id,omitempty"`
+
+	// Input is the input of the step.
+	Input string `json:"input"`
+
+	// Name is the name of the experiment run by the step.
+	Name string `json:"name"`
+
+	// ReportID is the ID of the report containing the step
+	// measurement, or empty if we could not submit it.
+	ReportID string `json:"report_id"`
+
+	// Skipped indicates that we skipped the step because
+	// its condition was false.
+	Skipped bool `json:"skipped"`
+}
+
+// Condition is a condition on the outcome of a previous step.
+type Condition struct {
+	// Step is the ID of a previous step.
+	Step string `json:"step"`
+
+	// Failed indicates whether we want the previous step to have
+	// failed (if true) or to have succeeded (if false). We consider
+	// a step skipped because of its condition as neither failed
+	// nor succeeded, hence the condition is always false.
+	Failed bool `json:"failed"`
 }
 
 // Run implements ExperimentMeasurer.ExperimentVersion.
-func (Measurer) Run(
+func (m Measurer) Run(
 	ctx context.Context, sess model.ExperimentSession,
 	measurement *model.Measurement, callbacks model.ExperimentCallbacks,
 ) error {
@@ -57,12 +152,114 @@ func (Measurer) Run(
 	if err := json.Unmarshal([]byte(measurement.Input), &input); err != nil {
 		return err
 	}
-	exprun, found := table[input.Name]
-	if !found {
-		return fmt.Errorf("no such experiment: %s", input.Name)
+	steps := input.Steps
+	if len(steps) <= 0 {
+		steps = []StructuredInput{input}
+	}
+	if err := m.validate(steps); err != nil {
+		return err
 	}
 	measurement.AddAnnotations(input.Annotations)
-	return exprun.do(ctx, input, sess, measurement, callbacks)
+	state := newRunState()
+	union := multierror.New(ErrStepsFailed)
+	tk := new(TestKeys)
+	var current *model.Measurement
+	for idx, step := range steps {
+		result := StepResult{ID: step.ID, Input: step.Input, Name: step.Name}
+		if !state.shouldRun(step) {
+			sess.Logger().Infof("run: skipping step #%d (%s)", idx, step.Name)
+			result.Skipped = true
+			tk.Steps = append(tk.Steps, result)
+			continue
+		}
+		current = newStepMeasurement(measurement)
+		current.AddAnnotations(step.Annotations)
+		err := m.runStep(ctx, state, step, sess, current, callbacks)
+		state.record(step, current, err)
+		if err != nil {
+			union.AddWithPrefix(fmt.Sprintf("step #%d (%s)", idx, step.Name), err)
+			failure := err.Error()
+			result.Failure = &failure
+		}
+		if m.config.Sink != nil {
+			// Implementation note: a sink failure is not fatal, since
+			// we still want to run the remaining steps.
+			if err := m.config.Sink.SaveAndSubmit(ctx, current); err != nil {
+				sess.Logger().Warnf("run: cannot save or submit step: %s", err.Error())
+			}
+			result.ReportID = current.ReportID
+		}
+		tk.Steps = append(tk.Steps, result)
+	}
+	if m.config.Sink != nil {
+		measurement.TestKeys = tk
+	} else if current != nil {
+		*measurement = *current
+	}
+	if len(union.Children) > 0 {
+		return union
+	}
+	return nil
+}
+
+// ErrStepsFailed indicates that one or more steps failed. The
+// returned error wraps the errors of all the failed steps.
+var ErrStepsFailed = errors.New("run: one or more steps failed")
+
+// errNestedSteps indicates that a step contains other steps.
+var errNestedSteps = errors.New("steps cannot contain other steps")
+
+// errNoSuchStep indicates that a condition refers to a step
+// that does not exist or that does not run before it.
+var errNoSuchStep = errors.New("condition refers to unknown step")
+
+// validate ensures that we are able to run all the steps
+// before we actually start running any of them.
+func (m Measurer) validate(steps []StructuredInput) error {
+	ids := make(map[string]bool)
+	for _, step := range steps {
+		if len(step.Steps) > 0 {
+			return errNestedSteps
+		}
+		if _, found := table[step.Name]; !found && m.config.NewMeasurer == nil {
+			return fmt.Errorf("no such experiment: %s", step.Name)
+		}
+		if step.If != nil && !ids[step.If.Step] {
+			return fmt.Errorf("%w: %s", errNoSuchStep, step.If.Step)
+		}
+		if step.ID != "" {
+			ids[step.ID] = true
+		}
+	}
+	return nil
+}
+
+func (m Measurer) runStep(ctx context.Context, state *runState,
+	step StructuredInput, sess model.ExperimentSession,
+	measurement *model.Measurement, callbacks model.ExperimentCallbacks) error {
+	if exprun, found := table[step.Name]; found {
+		return exprun.do(ctx, state.withDNSCache(step), sess, measurement, callbacks)
+	}
+	exp, err := m.config.NewMeasurer(step.Name, step.Options)
+	if err != nil {
+		return err
+	}
+	measurement.TestName = exp.ExperimentName()
+	measurement.TestVersion = exp.ExperimentVersion()
+	measurement.Input = model.MeasurementTarget(step.Input)
+	return exp.Run(ctx, sess, measurement, callbacks)
+}
+
+// newStepMeasurement returns a copy of the measurement created by the
+// engine that we will fill with the results of a single step.
+func newStepMeasurement(template *model.Measurement) *model.Measurement {
+	measurement := *template
+	measurement.Annotations = nil
+	measurement.AddAnnotations(template.Annotations)
+	measurement.Extensions = nil
+	measurement.ReportID = ""
+	measurement.TestKeys = nil
+	return &measurement
 }
 
 // GetSummaryKeys implements ExperimentMeasurer.GetSummaryKeys
@@ -75,5 +272,5 @@ func (Measurer) GetSummaryKeys(*model.Measurement) (interface{}, error) {
 // NewExperimentMeasurer creates a new model.ExperimentMeasurer
 // implementing the run experiment.
 func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
-	return Measurer{}
+	return Measurer{config: config}
 }
@@ -2,10 +2,16 @@ package run_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/apex/log"
 	"github.com/ooni/probe-engine/experiment/dnscheck"
+	"github.com/ooni/probe-engine/experiment/example"
 	"github.com/ooni/probe-engine/experiment/run"
 	"github.com/ooni/probe-engine/experiment/urlgetter"
 	"github.com/ooni/probe-engine/internal/mockable"
@@ -17,7 +23,7 @@ func TestExperimentNameAndVersion(t *testing.T) {
 	if measurer.ExperimentName() != "run" {
 		t.Error("unexpected experiment name")
 	}
-	if measurer.ExperimentVersion() != "0.2.0" {
+	if measurer.ExperimentVersion() != "0.4.0" {
 		t.Error("unexpected experiment version")
 	}
 }
@@ -105,3 +111,185 @@ func TestRunWithUnknownExperiment(t *testing.T) {
 		t.Fatalf("not the error we expected: %+v", err)
 	}
 }
+
+type fakeSink struct {
+	measurements []*model.Measurement
+	err          error
+}
+
+func (fs *fakeSink) SaveAndSubmit(ctx context.Context, m *model.Measurement) error {
+	m.ReportID = fmt.Sprintf("report-%s", m.TestName)
+	fs.measurements = append(fs.measurements, m)
+	return fs.err
+}
+
+func TestRunWithStepsAndConditions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(200)
+		}))
+	defer server.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close() // so that connecting fails
+	sink := &fakeSink{err: errors.New("mocked error")}
+	measurer := run.NewExperimentMeasurer(run.Config{Sink: sink})
+	input := fmt.Sprintf(`{"annotations": {"x": "y"}, "steps": [
+		{"id": "first", "name": "urlgetter", "input": "%s"},
+		{"id": "second", "name": "urlgetter", "input": "%s",
+			"if": {"step": "first", "failed": true},
+			"annotations": {"retry": "true"}},
+		{"name": "urlgetter", "input": "%s",
+			"if": {"step": "first", "failed": false}}
+	]}`, closed.URL, server.URL, server.URL)
+	measurement := new(model.Measurement)
+	measurement.Input = model.MeasurementTarget(input)
+	measurement.TestName = "run"
+	measurement.ReportID = "report-run"
+	sess := &mockable.Session{MockableLogger: log.Log}
+	callbacks := model.NewPrinterCallbacks(log.Log)
+	err := measurer.Run(context.Background(), sess, measurement, callbacks)
+	if !errors.Is(err, run.ErrStepsFailed) {
+		t.Fatal("not the error we expected", err)
+	}
+	if len(sink.measurements) != 2 {
+		t.Fatal("expected two measurements in the sink")
+	}
+	first := sink.measurements[0]
+	if first.TestName != "urlgetter" || string(first.Input) != closed.URL {
+		t.Fatal("unexpected first measurement")
+	}
+	if first.TestKeys.(*urlgetter.TestKeys).Failure == nil {
+		t.Fatal("expected the first step to fail")
+	}
+	if _, found := first.Annotations["retry"]; found {
+		t.Fatal("unexpected annotation in first measurement")
+	}
+	second := sink.measurements[1]
+	if second.TestName != "urlgetter" || string(second.Input) != server.URL {
+		t.Fatal("unexpected second measurement")
+	}
+	if second.TestKeys.(*urlgetter.TestKeys).Failure != nil {
+		t.Fatal("expected the second step to succeed")
+	}
+	if second.Annotations["x"] != "y" || second.Annotations["retry"] != "true" {
+		t.Fatal("unexpected annotations in second measurement")
+	}
+	if measurement.TestName != "run" || measurement.ReportID != "report-run" {
+		t.Fatal("the returned measurement should belong to the run report")
+	}
+	tk := measurement.TestKeys.(*run.TestKeys)
+	if len(tk.Steps) != 3 {
+		t.Fatal("unexpected number of steps")
+	}
+	if tk.Steps[0].ID != "first" || tk.Steps[0].Failure == nil ||
+		tk.Steps[0].ReportID != "report-urlgetter" {
+		t.Fatal("unexpected first step result")
+	}
+	if tk.Steps[1].ID != "second" || tk.Steps[1].Failure != nil || tk.Steps[1].Skipped {
+		t.Fatal("unexpected second step result")
+	}
+	if !tk.Steps[2].Skipped || tk.Steps[2].ReportID != "" {
+		t.Fatal("unexpected third step result")
+	}
+}
+
+func TestRunAggregatesStepErrors(t *testing.T) {
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close() // so that connecting fails
+	measurer := run.NewExperimentMeasurer(run.Config{})
+	input := fmt.Sprintf(`{"steps": [
+		{"name": "urlgetter", "input": "%s"},
+		{"name": "urlgetter", "input": "%s"}
+	]}`, closed.URL, "https://www.example.com/antani")
+	measurement := new(model.Measurement)
+	measurement.Input = model.MeasurementTarget(input)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // fail immediately
+	sess := &mockable.Session{MockableLogger: log.Log}
+	callbacks := model.NewPrinterCallbacks(log.Log)
+	err := measurer.Run(ctx, sess, measurement, callbacks)
+	if !errors.Is(err, run.ErrStepsFailed) {
+		t.Fatal("not the error we expected", err)
+	}
+	if !strings.Contains(err.Error(), "step #0 (urlgetter)") ||
+		!strings.Contains(err.Error(), "step #1 (urlgetter)") {
+		t.Fatal("expected the errors of both steps", err)
+	}
+	if string(measurement.Input) != "https://www.example.com/antani" {
+		t.Fatal("expected the measurement of the last step")
+	}
+}
+
+func TestRunWithNestedSteps(t *testing.T) {
+	measurer := run.NewExperimentMeasurer(run.Config{})
+	input := `{"steps": [
+		{"name": "urlgetter", "input": "https://www.example.com/", "steps": [
+			{"name": "urlgetter", "input": "https://www.example.org/"}
+		]}
+	]}`
+	measurement := new(model.Measurement)
+	measurement.Input = model.MeasurementTarget(input)
+	ctx := context.Background()
+	sess := &mockable.Session{MockableLogger: log.Log}
+	callbacks := model.NewPrinterCallbacks(log.Log)
+	err := measurer.Run(ctx, sess, measurement, callbacks)
+	if err == nil || err.Error() != "steps cannot contain other steps" {
+		t.Fatalf("not the error we expected: %+v", err)
+	}
+}
+
+func TestRunWithConditionOnUnknownStep(t *testing.T) {
+	measurer := run.NewExperimentMeasurer(run.Config{})
+	input := `{"steps": [
+		{"name": "urlgetter", "input": "https://www.example.com/"},
+		{"name": "urlgetter", "input": "https://www.example.org/",
+			"if": {"step": "antani", "failed": true}}
+	]}`
+	measurement := new(model.Measurement)
+	measurement.Input = model.MeasurementTarget(input)
+	ctx := context.Background()
+	sess := &mockable.Session{MockableLogger: log.Log}
+	callbacks := model.NewPrinterCallbacks(log.Log)
+	err := measurer.Run(ctx, sess, measurement, callbacks)
+	if err == nil || !strings.HasSuffix(err.Error(), "unknown step: antani") {
+		t.Fatalf("not the error we expected: %+v", err)
+	}
+}
+
+func TestRunWithNewMeasurer(t *testing.T) {
+	var gotOptions map[string]string
+	measurer := run.NewExperimentMeasurer(run.Config{
+		NewMeasurer: func(
+			name string, options map[string]string,
+		) (model.ExperimentMeasurer, error) {
+			if name != "example" {
+				return nil, fmt.Errorf("no such experiment: %s", name)
+			}
+			gotOptions = options
+			return example.NewExperimentMeasurer(example.Config{}, name), nil
+		},
+	})
+	input := `{"name": "example", "options": {"Message": "antani"}}`
+	measurement := new(model.Measurement)
+	measurement.Input = model.MeasurementTarget(input)
+	ctx := context.Background()
+	sess := &mockable.Session{MockableLogger: log.Log}
+	callbacks := model.NewPrinterCallbacks(log.Log)
+	err := measurer.Run(ctx, sess, measurement, callbacks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if measurement.TestName != "example" {
+		t.Fatal("unexpected test name")
+	}
+	if gotOptions["Message"] != "antani" {
+		t.Fatal("options not passed to NewMeasurer")
+	}
+	input = `{"name": "antani"}`
+	measurement = new(model.Measurement)
+	measurement.Input = model.MeasurementTarget(input)
+	err = measurer.Run(ctx, sess, measurement, callbacks)
+	if err == nil || err.Error() != "no such experiment: antani" {
+		t.Fatalf("not the error we expected: %+v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package run
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/ooni/probe-engine/experiment/dnscheck"
+	"github.com/ooni/probe-engine/experiment/urlgetter"
+	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx/archival"
+)
+
+// runState is the state shared by the steps run for a given input.
+type runState struct {
+	// dnsCache maps a domain to the addresses we have discovered
+	// when running the previous steps.
+	dnsCache map[string][]string
+
+	// failed maps the ID of each step that has run to
+	// whether such step failed or succeeded.
+	failed map[string]bool
+}
+
+func newRunState() *runState {
+	return &runState{
+		dnsCache: make(map[string][]string),
+		failed:   make(map[string]bool),
+	}
+}
+
+// shouldRun returns whether the condition of step, if any, is true.
+func (s *runState) shouldRun(step StructuredInput) bool {
+	if step.If == nil {
+		return true
+	}
+	failed, found := s.failed[step.If.Step]
+	return found && failed == step.If.Failed
+}
+
+// record records the outcome of running the given step.
+func (s *runState) record(step StructuredInput, measurement *model.Measurement, err error) {
+	if step.ID != "" {
+		s.failed[step.ID] = err != nil || hasFailure(measurement.TestKeys)
+	}
+	switch tk := measurement.TestKeys.(type) {
+	case *urlgetter.TestKeys:
+		s.updateDNSCache(tk.Queries)
+	case *dnscheck.TestKeys:
+		for _, lookup := range tk.Lookups {
+			s.updateDNSCache(lookup.Queries)
+		}
+	}
+}
+
+func (s *runState) updateDNSCache(queries []archival.DNSQueryEntry) {
+	for _, query := range queries {
+		if query.Failure != nil {
+			continue
+		}
+		for _, answer := range query.Answers {
+			var address string
+			switch answer.AnswerType {
+			case "A":
+				address = answer.IPv4
+			case "AAAA":
+				address = answer.IPv6
+			}
+			if address != "" && !contains(s.dnsCache[query.Hostname], address) {
+				s.dnsCache[query.Hostname] = append(s.dnsCache[query.Hostname], address)
+			}
+		}
+	}
+}
+
+// withDNSCache returns a copy of step where the urlgetter config uses
+// the shared DNS cache for the domain in the step input. We do not do
+// that when the step explicitly configures a DNS cache or when the
+// step is a DNS lookup, because it would prevent measuring DNS.
+func (s *runState) withDNSCache(step StructuredInput) StructuredInput {
+	if step.Name != "urlgetter" || step.URLGetter.DNSCache != "" {
+		return step
+	}
+	URL, err := url.Parse(step.Input)
+	if err != nil || URL.Scheme == "dnslookup" {
+		return step
+	}
+	addrs := s.dnsCache[URL.Hostname()]
+	if len(addrs) <= 0 {
+		return step
+	}
+	step.URLGetter.DNSCache = strings.Join(append([]string{URL.Hostname()}, addrs...), " ")
+	return step
+}
+
+// hasFailure returns true if the test keys are a pointer to a struct
+// that contains a non-nil Failure field of type *string.
+func hasFailure(testKeys interface{}) bool {
+	value := reflect.ValueOf(testKeys)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return false
+	}
+	value = value.Elem()
+	if value.Kind() != reflect.Struct {
+		return false
+	}
+	field := value.FieldByName("Failure")
+	if !field.IsValid() || field.Type() != reflect.TypeOf((*string)(nil)) {
+		return false
+	}
+	return !field.IsNil()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
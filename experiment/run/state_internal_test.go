@@ -0,0 +1,124 @@
+package run
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ooni/probe-engine/experiment/urlgetter"
+	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx/archival"
+)
+
+func TestRunStateConditions(t *testing.T) {
+	state := newRunState()
+	failure := "connection_refused"
+	state.record(StructuredInput{ID: "dns"}, &model.Measurement{
+		TestKeys: &urlgetter.TestKeys{Failure: &failure},
+	}, nil)
+	state.record(StructuredInput{ID: "https"}, &model.Measurement{
+		TestKeys: &urlgetter.TestKeys{},
+	}, nil)
+	state.record(StructuredInput{ID: "broken"}, &model.Measurement{}, errors.New("mocked error"))
+	tests := []struct {
+		cond   *Condition
+		expect bool
+	}{
+		{cond: nil, expect: true},
+		{cond: &Condition{Step: "dns", Failed: true}, expect: true},
+		{cond: &Condition{Step: "dns", Failed: false}, expect: false},
+		{cond: &Condition{Step: "https", Failed: true}, expect: false},
+		{cond: &Condition{Step: "https", Failed: false}, expect: true},
+		{cond: &Condition{Step: "broken", Failed: true}, expect: true},
+		{cond: &Condition{Step: "skipped", Failed: true}, expect: false},
+		{cond: &Condition{Step: "skipped", Failed: false}, expect: false},
+	}
+	for idx, tt := range tests {
+		if state.shouldRun(StructuredInput{If: tt.cond}) != tt.expect {
+			t.Fatalf("#%d: unexpected result", idx)
+		}
+	}
+}
+
+func TestRunStateDNSCache(t *testing.T) {
+	state := newRunState()
+	failure := "dns_nxdomain_error"
+	state.record(StructuredInput{}, &model.Measurement{
+		TestKeys: &urlgetter.TestKeys{Queries: []archival.DNSQueryEntry{{
+			Answers: []archival.DNSAnswerEntry{{
+				AnswerType: "A",
+				IPv4:       "93.184.216.34",
+			}, {
+				AnswerType: "CNAME",
+				Hostname:   "www.example.com",
+			}},
+			Hostname: "example.com",
+		}, {
+			Answers: []archival.DNSAnswerEntry{{
+				AnswerType: "AAAA",
+				IPv6:       "2606:2800:220:1:248:1893:25c8:1946",
+			}, {
+				AnswerType: "A",
+				IPv4:       "93.184.216.34",
+			}},
+			Hostname: "example.com",
+		}, {
+			Failure:  &failure,
+			Hostname: "example.org",
+		}}},
+	}, nil)
+	t.Run("we fill the cache for an HTTPS step", func(t *testing.T) {
+		step := state.withDNSCache(StructuredInput{
+			Name: "urlgetter", Input: "https://example.com/",
+		})
+		expect := "example.com 93.184.216.34 2606:2800:220:1:248:1893:25c8:1946"
+		if step.URLGetter.DNSCache != expect {
+			t.Fatalf("unexpected DNS cache: %s", step.URLGetter.DNSCache)
+		}
+	})
+	t.Run("we do not override an explicit cache", func(t *testing.T) {
+		step := state.withDNSCache(StructuredInput{
+			Name: "urlgetter", Input: "https://example.com/",
+			URLGetter: urlgetter.Config{DNSCache: "example.com 1.1.1.1"},
+		})
+		if step.URLGetter.DNSCache != "example.com 1.1.1.1" {
+			t.Fatal("we have overriden the DNS cache")
+		}
+	})
+	t.Run("we do not fill the cache for DNS lookups", func(t *testing.T) {
+		step := state.withDNSCache(StructuredInput{
+			Name: "urlgetter", Input: "dnslookup://example.com",
+		})
+		if step.URLGetter.DNSCache != "" {
+			t.Fatal("we should not have filled the DNS cache")
+		}
+	})
+	t.Run("we do not fill the cache for failed lookups", func(t *testing.T) {
+		step := state.withDNSCache(StructuredInput{
+			Name: "urlgetter", Input: "https://example.org/",
+		})
+		if step.URLGetter.DNSCache != "" {
+			t.Fatal("we should not have filled the DNS cache")
+		}
+	})
+}
+
+func TestHasFailure(t *testing.T) {
+	failure := "generic_timeout_error"
+	number := 17
+	tests := []struct {
+		tk     interface{}
+		expect bool
+	}{
+		{tk: nil, expect: false},
+		{tk: &number, expect: false},
+		{tk: urlgetter.TestKeys{Failure: &failure}, expect: false},
+		{tk: &struct{ Failure string }{Failure: "x"}, expect: false},
+		{tk: &urlgetter.TestKeys{}, expect: false},
+		{tk: &urlgetter.TestKeys{Failure: &failure}, expect: true},
+	}
+	for idx, tt := range tests {
+		if hasFailure(tt.tk) != tt.expect {
+			t.Fatalf("#%d: unexpected result", idx)
+		}
+	}
+}
@@ -13,12 +13,9 @@ type experimentMain interface {
 		callbacks model.ExperimentCallbacks) error
 }
 
+// table contains the experiments we natively support. We run all
+// the other experiments using Config.NewMeasurer.
 var table = map[string]experimentMain{
-	// TODO(bassosimone): before extending run to support more than
-	// single experiment, we need to handle the case in which we are
-	// including different experiments into the same report ID.
-	// Probably, the right way to implement this functionality is to
-	// use proveservices.Submitter to submit reports.
 	"dnscheck": &dnsCheckMain{
 		Endpoints: &dnscheck.Endpoints{},
 	},
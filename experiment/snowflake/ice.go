@@ -0,0 +1,121 @@
+package snowflake
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+
+	"github.com/ooni/probe-engine/netx/dialer"
+	"github.com/pion/stun"
+)
+
+// ICE attributes not defined by pion/stun. See RFC8445 section 16.1.
+const (
+	attrPriority       stun.AttrType = 0x0024
+	attrICEControlling stun.AttrType = 0x802A
+)
+
+// prflxPriority is the priority of a peer-reflexive candidate
+// computed as specified in RFC8445 section 5.1.2.1.
+const prflxPriority = 110<<24 | 65535<<8 | 255
+
+// errUnexpectedResponse indicates that we received a STUN message
+// that is not a binding success response.
+var errUnexpectedResponse = errors.New("snowflake: unexpected STUN response")
+
+// rawAttribute is a stun.Setter adding an arbitrary attribute.
+type rawAttribute struct {
+	t stun.AttrType
+	v []byte
+}
+
+// AddTo implements stun.Setter.AddTo.
+func (ra rawAttribute) AddTo(m *stun.Message) error {
+	m.Add(ra.t, ra.v)
+	return nil
+}
+
+// stunBindingRequest sends a STUN binding request to endpoint.
+func stunBindingRequest(ctx context.Context, dialer dialer.Dialer, endpoint string) error {
+	_, err := stunTransaction(ctx, dialer, endpoint, stun.TransactionID, stun.BindingRequest)
+	return err
+}
+
+// iceConnectivityCheck performs an ICE connectivity check towards
+// the candidate, as the controlling agent, using the credentials in
+// the offer and in the answer (see RFC8445 section 7.2).
+func iceConnectivityCheck(ctx context.Context, dialer dialer.Dialer,
+	candidate string, o *offer, a *answer) error {
+	priority := make([]byte, 4)
+	binary.BigEndian.PutUint32(priority, prflxPriority)
+	tiebreaker := make([]byte, 8)
+	if _, err := rand.Read(tiebreaker); err != nil {
+		return err
+	}
+	integrity := stun.NewShortTermIntegrity(a.pwd)
+	response, err := stunTransaction(
+		ctx, dialer, candidate, stun.TransactionID, stun.BindingRequest,
+		stun.NewUsername(a.ufrag+":"+o.ufrag),
+		rawAttribute{t: attrPriority, v: priority},
+		rawAttribute{t: attrICEControlling, v: tiebreaker},
+		integrity, stun.Fingerprint,
+	)
+	if err != nil {
+		return err
+	}
+	return integrity.Check(response)
+}
+
+// stunTransaction sends a STUN request built using the setters
+// and returns the binding success response.
+func stunTransaction(ctx context.Context, dialer dialer.Dialer,
+	endpoint string, setters ...stun.Setter) (*stun.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, stepTimeout)
+	defer cancel()
+	conn, err := dialer.DialContext(ctx, "udp", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	client, err := stun.NewClient(conn, stun.WithNoConnClose)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	message, err := stun.Build(setters...)
+	if err != nil {
+		return nil, err
+	}
+	type result struct {
+		err     error
+		message *stun.Message
+	}
+	ch := make(chan result, 1) // buffered: we may not read it
+	err = client.Start(message, func(ev stun.Event) {
+		if ev.Error != nil {
+			ch <- result{err: ev.Error}
+			return
+		}
+		if ev.Message.Type != stun.BindingSuccess {
+			ch <- result{err: errUnexpectedResponse}
+			return
+		}
+		response := new(stun.Message)
+		// the event message is reused, so we need to clone it
+		if err := ev.Message.CloneTo(response); err != nil {
+			ch <- result{err: err}
+			return
+		}
+		ch <- result{message: response}
+	})
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case r := <-ch:
+		return r.message, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
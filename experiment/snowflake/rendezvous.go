@@ -0,0 +1,170 @@
+package snowflake
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ooni/probe-engine/internal/httpheader"
+)
+
+// maxAnswerSize is the maximum size of the broker's answer.
+const maxAnswerSize = 1 << 16
+
+// sessionDescription is the JSON encoding of a WebRTC session
+// description used by the legacy broker protocol.
+type sessionDescription struct {
+	SDP  string `json:"sdp"`
+	Type string `json:"type"`
+}
+
+// offer is the SDP offer we send to the broker.
+type offer struct {
+	pwd   string
+	sdp   string
+	ufrag string
+}
+
+// newOffer creates an SDP offer for a WebRTC data channel. We don't
+// include any candidate, since we don't want the proxy to connect
+// to us and we don't want to disclose the probe IP to the broker. We
+// also use a random DTLS fingerprint, since we don't perform DTLS.
+func newOffer() (*offer, error) {
+	random := make([]byte, 60)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	o := &offer{
+		ufrag: hex.EncodeToString(random[:4]),
+		pwd:   hex.EncodeToString(random[4:16]),
+	}
+	var fingerprint []string
+	for _, b := range random[16:48] {
+		fingerprint = append(fingerprint, fmt.Sprintf("%02X", b))
+	}
+	sessionID := new(bytes.Buffer)
+	for _, b := range random[48:56] {
+		fmt.Fprintf(sessionID, "%d", b%10)
+	}
+	o.sdp = strings.Join([]string{
+		"v=0",
+		"o=- 1" + sessionID.String() + " 2 IN IP4 127.0.0.1",
+		"s=-",
+		"t=0 0",
+		"a=group:BUNDLE 0",
+		"m=application 9 UDP/DTLS/SCTP webrtc-datachannel",
+		"c=IN IP4 0.0.0.0",
+		"a=ice-ufrag:" + o.ufrag,
+		"a=ice-pwd:" + o.pwd,
+		"a=fingerprint:sha-256 " + strings.Join(fingerprint, ":"),
+		"a=setup:actpass",
+		"a=mid:0",
+		"a=sctp-port:5000",
+		"a=max-message-size:262144",
+		"a=end-of-candidates",
+		"",
+	}, "\r\n")
+	return o, nil
+}
+
+// answer is the SDP answer of the proxy.
+type answer struct {
+	candidates []string
+	pwd        string
+	ufrag      string
+}
+
+// udpCandidates returns the endpoints of the UDP candidates.
+func (a *answer) udpCandidates() []string {
+	return a.candidates
+}
+
+// parseAnswer parses the SDP answer. We only parse the ICE credentials
+// and the UDP candidates, because we need nothing else.
+func parseAnswer(sdp string) (*answer, error) {
+	a := new(answer)
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "a=ice-ufrag:"):
+			a.ufrag = strings.TrimPrefix(line, "a=ice-ufrag:")
+		case strings.HasPrefix(line, "a=ice-pwd:"):
+			a.pwd = strings.TrimPrefix(line, "a=ice-pwd:")
+		case strings.HasPrefix(line, "a=candidate:"):
+			// candidate:foundation component transport priority address port typ type...
+			fields := strings.Fields(strings.TrimPrefix(line, "a=candidate:"))
+			if len(fields) < 6 || !strings.EqualFold(fields[2], "udp") {
+				continue
+			}
+			port, err := strconv.Atoi(fields[5])
+			if err != nil || port <= 0 || port > 65535 || net.ParseIP(fields[4]) == nil {
+				continue // e.g., mDNS candidates
+			}
+			a.candidates = append(a.candidates, newHostPort(fields[4], port))
+		}
+	}
+	if a.ufrag == "" || a.pwd == "" {
+		return nil, fmt.Errorf("snowflake: answer without ICE credentials")
+	}
+	return a, nil
+}
+
+// rendezvous sends the offer to the broker and returns the proxy
+// answer. When frontDomain is not empty, we use it as the domain in
+// the URL, keeping the broker's port, and we set the Host header to
+// the broker's domain.
+func rendezvous(ctx context.Context, client *http.Client,
+	brokerURL, frontDomain string, o *offer) (*answer, error) {
+	URL, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, err
+	}
+	URL.Path = strings.TrimSuffix(URL.Path, "/") + "/client"
+	host, port := URL.Host, URL.Port()
+	if frontDomain != "" {
+		URL.Host = frontDomain
+		if port != "" {
+			URL.Host = net.JoinHostPort(frontDomain, port)
+		}
+	}
+	data, err := json.Marshal(sessionDescription{SDP: o.sdp, Type: "offer"})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", URL.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Host = host
+	req.Header.Set("User-Agent", httpheader.UserAgent())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("snowflake: broker returned %d", resp.StatusCode)
+	}
+	data, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxAnswerSize))
+	if err != nil {
+		return nil, err
+	}
+	var sd sessionDescription
+	if err := json.Unmarshal(data, &sd); err != nil {
+		return nil, err
+	}
+	if sd.Type != "answer" {
+		return nil, fmt.Errorf("snowflake: unexpected session description type: %s", sd.Type)
+	}
+	return parseAnswer(sd.SDP)
+}
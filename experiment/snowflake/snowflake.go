@@ -0,0 +1,276 @@
+// Package snowflake contains the snowflake experiment. This experiment
+// checks whether we can reach the components used to bootstrap Tor's
+// Snowflake pluggable transport.
+//
+// We perform the first steps of the Snowflake client: we check whether
+// the STUN servers are reachable, we rendezvous with a proxy by sending
+// an SDP offer to the broker using domain fronting, and we perform ICE
+// connectivity checks towards the candidates in the proxy's SDP answer.
+//
+// We do not attempt the DTLS handshake that follows the ICE connectivity
+// checks, because we don't have a DTLS implementation among our
+// dependencies. Hence, we can tell whether the broker and the proxies
+// are reachable, but not whether someone is interfering with DTLS. For
+// the same reason, the test keys only contain ICE connectivity checks.
+package snowflake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx"
+	"github.com/ooni/probe-engine/netx/archival"
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/trace"
+)
+
+const (
+	testName    = "snowflake"
+	testVersion = "0.1.0"
+)
+
+const (
+	// defaultBrokerURL is the default URL of the broker.
+	defaultBrokerURL = "https://snowflake-broker.torproject.net.global.prod.fastly.net/"
+
+	// defaultFrontDomain is the default domain used for domain fronting.
+	defaultFrontDomain = "cdn.sstatic.net"
+
+	// defaultSTUNServers are the default STUN servers.
+	defaultSTUNServers = "stun.l.google.com:19302,stun.voip.blackberry.com:3478"
+
+	// maxICECandidates is the maximum number of proxy candidates we check.
+	maxICECandidates = 4
+
+	// stepTimeout is the timeout of each STUN or ICE check.
+	stepTimeout = 10 * time.Second
+)
+
+// Config contains the experiment config.
+type Config struct {
+	BrokerURL   string `ooni:"URL of the Snowflake broker"`
+	FrontDomain string `ooni:"Domain used for domain fronting the broker (empty means the default front domain)"`
+	NoFronting  bool   `ooni:"Contact the broker directly without using domain fronting"`
+	STUNServers string `ooni:"Comma separated list of STUN servers"`
+}
+
+func (c Config) brokerURL() string {
+	if c.BrokerURL != "" {
+		return c.BrokerURL
+	}
+	return defaultBrokerURL
+}
+
+func (c Config) frontDomain() string {
+	if c.NoFronting {
+		return ""
+	}
+	if c.FrontDomain != "" {
+		return c.FrontDomain
+	}
+	return defaultFrontDomain
+}
+
+func (c Config) stunServers() (out []string) {
+	servers := c.STUNServers
+	if servers == "" {
+		servers = defaultSTUNServers
+	}
+	for _, server := range strings.Split(servers, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			out = append(out, server)
+		}
+	}
+	return
+}
+
+// STUNResult is the result of querying a STUN server. We don't
+// save the mapped address because it is the probe IP.
+type STUNResult struct {
+	Endpoint string  `json:"endpoint"`
+	Failure  *string `json:"failure"`
+}
+
+// ICECheckResult is the result of the ICE connectivity check
+// towards a proxy candidate.
+type ICECheckResult struct {
+	Endpoint string  `json:"endpoint"`
+	Failure  *string `json:"failure"`
+}
+
+// TestKeys contains the experiment's result.
+type TestKeys struct {
+	BrokerFailure    *string                    `json:"broker_failure"`
+	BrokerURL        string                     `json:"broker_url"`
+	Failure          *string                    `json:"failure"`
+	FrontDomain      string                     `json:"front_domain"`
+	ICEChecks        []ICECheckResult           `json:"ice_connectivity_checks"`
+	ICEChecksFailure *string                    `json:"ice_connectivity_checks_failure"`
+	NetworkEvents    []archival.NetworkEvent    `json:"network_events"`
+	Queries          []archival.DNSQueryEntry   `json:"queries"`
+	Requests         []archival.RequestEntry    `json:"requests"`
+	STUN             []STUNResult               `json:"stun"`
+	TCPConnect       []archival.TCPConnectEntry `json:"tcp_connect"`
+	TLSHandshakes    []archival.TLSHandshake    `json:"tls_handshakes"`
+}
+
+func registerExtensions(m *model.Measurement) {
+	archival.ExtDNS.AddTo(m)
+	archival.ExtHTTP.AddTo(m)
+	archival.ExtNetevents.AddTo(m)
+	archival.ExtTCPConnect.AddTo(m)
+	archival.ExtTLSHandshake.AddTo(m)
+}
+
+// Measurer performs the measurement.
+type Measurer struct {
+	config Config
+}
+
+// ExperimentName implements ExperimentMeasurer.ExperiExperimentName.
+func (m *Measurer) ExperimentName() string {
+	return testName
+}
+
+// ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
+func (m *Measurer) ExperimentVersion() string {
+	return testVersion
+}
+
+func wrap(err error, operation string) error {
+	return errorx.SafeErrWrapperBuilder{
+		Error:     err,
+		Operation: operation,
+	}.MaybeBuild()
+}
+
+// errNoCandidates indicates that the answer has no usable candidates.
+var errNoCandidates = errors.New("snowflake: no usable ICE candidates")
+
+// Run implements ExperimentMeasurer.Run.
+func (m *Measurer) Run(
+	ctx context.Context, sess model.ExperimentSession,
+	measurement *model.Measurement, callbacks model.ExperimentCallbacks,
+) error {
+	tk := &TestKeys{
+		BrokerURL:   m.config.brokerURL(),
+		FrontDomain: m.config.frontDomain(),
+	}
+	measurement.TestKeys = tk
+	registerExtensions(measurement)
+	saver := new(trace.Saver)
+	config := netx.Config{
+		ContextByteCounting: true,
+		DialSaver:           saver,
+		HTTPSaver:           saver,
+		Logger:              sess.Logger(),
+		ReadWriteSaver:      saver,
+		ResolveSaver:        saver,
+		TLSSaver:            saver,
+	}
+	begin := time.Now()
+	err := tk.run(ctx, m.config, config, callbacks)
+	events := saver.Read()
+	tk.NetworkEvents = archival.NewNetworkEventsList(begin, events)
+	tk.Queries = archival.NewDNSQueriesList(begin, events, sess.ASNDatabasePath())
+	tk.Requests = archival.NewRequestList(begin, events)
+	tk.TCPConnect = archival.NewTCPConnectList(begin, events)
+	tk.TLSHandshakes = archival.NewTLSHandshakesList(begin, events)
+	if err != nil {
+		s := err.Error()
+		tk.Failure = &s
+	}
+	// Implementation note: we don't return the error because the
+	// measurement is valid and we want to submit it.
+	return nil
+}
+
+func (tk *TestKeys) run(ctx context.Context, config Config,
+	netxConfig netx.Config, callbacks model.ExperimentCallbacks) error {
+	dialer := netx.NewDialer(netxConfig)
+	servers := config.stunServers()
+	for idx, server := range servers {
+		callbacks.OnProgress(0.1*float64(idx)/float64(len(servers)),
+			fmt.Sprintf("snowflake: STUN %s...", server))
+		err := wrap(stunBindingRequest(ctx, dialer, server), "stun")
+		tk.STUN = append(tk.STUN, STUNResult{
+			Endpoint: server,
+			Failure:  archival.NewFailure(err),
+		})
+	}
+	callbacks.OnProgress(0.1, "snowflake: rendezvous with the broker...")
+	offer, err := newOffer()
+	if err != nil {
+		return err
+	}
+	txp := netx.NewHTTPTransport(netxConfig)
+	defer txp.CloseIdleConnections()
+	answer, err := rendezvous(ctx, &http.Client{Transport: txp},
+		config.brokerURL(), config.frontDomain(), offer)
+	if err = wrap(err, "broker"); err != nil {
+		tk.BrokerFailure = archival.NewFailure(err)
+		return err
+	}
+	candidates := answer.udpCandidates()
+	if len(candidates) > maxICECandidates {
+		candidates = candidates[:maxICECandidates]
+	}
+	if len(candidates) <= 0 {
+		err = wrap(errNoCandidates, "ice_connectivity_check")
+		tk.ICEChecksFailure = archival.NewFailure(err)
+		return err
+	}
+	for idx, candidate := range candidates {
+		callbacks.OnProgress(0.5+0.5*float64(idx)/float64(len(candidates)),
+			fmt.Sprintf("snowflake: ICE check %s...", candidate))
+		err = wrap(iceConnectivityCheck(
+			ctx, dialer, candidate, offer, answer), "ice_connectivity_check")
+		tk.ICEChecks = append(tk.ICEChecks, ICECheckResult{
+			Endpoint: candidate,
+			Failure:  archival.NewFailure(err),
+		})
+		if err == nil {
+			break // like ICE, we're happy with the first working pair
+		}
+	}
+	if err != nil {
+		tk.ICEChecksFailure = archival.NewFailure(err)
+	}
+	callbacks.OnProgress(1, "snowflake: done")
+	return err
+}
+
+// newHostPort is like net.JoinHostPort but takes an integer port.
+func newHostPort(host string, port int) string {
+	return net.JoinHostPort(host, fmt.Sprintf("%d", port))
+}
+
+// NewExperimentMeasurer creates a new ExperimentMeasurer.
+func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
+	return &Measurer{config: config}
+}
+
+// SummaryKeys contains summary keys for this experiment.
+//
+// Note that this structure is part of the ABI contract with probe-cli
+// therefore we should be careful when changing it.
+type SummaryKeys struct {
+	IsAnomaly bool `json:"-"`
+}
+
+// GetSummaryKeys implements model.ExperimentMeasurer.GetSummaryKeys.
+func (m Measurer) GetSummaryKeys(measurement *model.Measurement) (interface{}, error) {
+	sk := SummaryKeys{IsAnomaly: false}
+	tk, ok := measurement.TestKeys.(*TestKeys)
+	if !ok {
+		return sk, errors.New("invalid test keys type")
+	}
+	sk.IsAnomaly = tk.Failure != nil
+	return sk, nil
+}
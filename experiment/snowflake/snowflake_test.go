@@ -0,0 +1,258 @@
+package snowflake
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-engine/internal/mockable"
+	"github.com/ooni/probe-engine/model"
+	"github.com/pion/stun"
+)
+
+// fakeSTUNServer is a local STUN server. When pwd is not empty, it
+// also behaves like an ICE agent using pwd as its password.
+func fakeSTUNServer(t *testing.T, pwd string) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			count, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request := &stun.Message{Raw: append([]byte{}, buf[:count]...)}
+			if err := request.Decode(); err != nil {
+				continue
+			}
+			udpAddr := addr.(*net.UDPAddr)
+			setters := []stun.Setter{
+				request, stun.BindingSuccess,
+				&stun.XORMappedAddress{IP: udpAddr.IP, Port: udpAddr.Port},
+			}
+			if pwd != "" {
+				setters = append(setters, stun.NewShortTermIntegrity(pwd), stun.Fingerprint)
+			}
+			response, err := stun.Build(setters...)
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(response.Raw, addr)
+		}
+	}()
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+// fakeBroker is a local broker returning an answer with the
+// given ICE credentials and candidate.
+func fakeBroker(t *testing.T, ufrag, pwd, candidate string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.Host)
+		if host != "broker.example.org" || r.URL.Path != "/client" || r.Method != "POST" {
+			w.WriteHeader(400)
+			return
+		}
+		var sd sessionDescription
+		if err := json.NewDecoder(r.Body).Decode(&sd); err != nil || sd.Type != "offer" {
+			w.WriteHeader(400)
+			return
+		}
+		if candidate == "" {
+			w.WriteHeader(503)
+			return
+		}
+		host, port, _ := net.SplitHostPort(candidate)
+		json.NewEncoder(w).Encode(sessionDescription{
+			SDP: strings.Join([]string{
+				"v=0",
+				"a=ice-ufrag:" + ufrag,
+				"a=ice-pwd:" + pwd,
+				"a=candidate:1 1 UDP 2122252543 abcdef.local 54321 typ host",
+				"a=candidate:2 1 UDP 1686052607 " + host + " " + port + " typ srflx",
+				"",
+			}, "\r\n"),
+			Type: "answer",
+		})
+	}))
+}
+
+func newConfig(t *testing.T, server *httptest.Server, stunServer string) Config {
+	URL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Config{
+		BrokerURL:   "http://broker.example.org:" + URL.Port() + "/",
+		FrontDomain: URL.Hostname(),
+		STUNServers: stunServer,
+	}
+}
+
+func runMeasurer(t *testing.T, config Config) *TestKeys {
+	measurer := NewExperimentMeasurer(config)
+	measurement := new(model.Measurement)
+	err := measurer.Run(
+		context.Background(),
+		&mockable.Session{MockableLogger: log.Log},
+		measurement,
+		model.NewPrinterCallbacks(log.Log),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return measurement.TestKeys.(*TestKeys)
+}
+
+func TestMeasurerExperimentNameVersion(t *testing.T) {
+	measurer := NewExperimentMeasurer(Config{})
+	if measurer.ExperimentName() != "snowflake" {
+		t.Fatal("unexpected ExperimentName")
+	}
+	if measurer.ExperimentVersion() != "0.1.0" {
+		t.Fatal("unexpected ExperimentVersion")
+	}
+}
+
+func TestRunGood(t *testing.T) {
+	stunServer, stunStop := fakeSTUNServer(t, "")
+	defer stunStop()
+	proxy, proxyStop := fakeSTUNServer(t, "proxypassword")
+	defer proxyStop()
+	broker := fakeBroker(t, "proxyufrag", "proxypassword", proxy)
+	defer broker.Close()
+	tk := runMeasurer(t, newConfig(t, broker, stunServer))
+	if tk.Failure != nil {
+		t.Fatal(*tk.Failure)
+	}
+	if len(tk.STUN) != 1 || tk.STUN[0].Failure != nil {
+		t.Fatalf("unexpected STUN results: %+v", tk.STUN)
+	}
+	if tk.BrokerFailure != nil || tk.ICEChecksFailure != nil {
+		t.Fatal("unexpected failure")
+	}
+	if len(tk.ICEChecks) != 1 || tk.ICEChecks[0].Endpoint != proxy ||
+		tk.ICEChecks[0].Failure != nil {
+		t.Fatalf("unexpected ICE results: %+v", tk.ICEChecks)
+	}
+	if len(tk.Requests) != 1 || len(tk.NetworkEvents) <= 0 {
+		t.Fatal("we did not archive the steps")
+	}
+}
+
+func TestRunWithoutProxies(t *testing.T) {
+	stunServer, stunStop := fakeSTUNServer(t, "")
+	defer stunStop()
+	broker := fakeBroker(t, "proxyufrag", "proxypassword", "")
+	defer broker.Close()
+	tk := runMeasurer(t, newConfig(t, broker, stunServer))
+	if tk.Failure == nil || tk.BrokerFailure == nil {
+		t.Fatal("expected a broker failure here")
+	}
+	if tk.ICEChecks != nil || tk.ICEChecksFailure != nil {
+		t.Fatal("we should not have performed ICE checks")
+	}
+}
+
+func TestRunWithWrongICEPassword(t *testing.T) {
+	stunServer, stunStop := fakeSTUNServer(t, "")
+	defer stunStop()
+	proxy, proxyStop := fakeSTUNServer(t, "anotherpassword")
+	defer proxyStop()
+	broker := fakeBroker(t, "proxyufrag", "proxypassword", proxy)
+	defer broker.Close()
+	tk := runMeasurer(t, newConfig(t, broker, stunServer))
+	if tk.Failure == nil || tk.ICEChecksFailure == nil {
+		t.Fatal("expected an ICE failure here")
+	}
+	if tk.BrokerFailure != nil {
+		t.Fatal("unexpected broker failure")
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	var config Config
+	if config.brokerURL() != defaultBrokerURL {
+		t.Fatal("unexpected broker URL")
+	}
+	if config.frontDomain() != defaultFrontDomain {
+		t.Fatal("unexpected front domain")
+	}
+	if len(config.stunServers()) != 2 {
+		t.Fatal("unexpected STUN servers")
+	}
+	config.NoFronting = true
+	if config.frontDomain() != "" {
+		t.Fatal("unexpected front domain")
+	}
+	config.STUNServers = " a:1, ,b:2"
+	servers := config.stunServers()
+	if len(servers) != 2 || servers[0] != "a:1" || servers[1] != "b:2" {
+		t.Fatalf("unexpected STUN servers: %+v", servers)
+	}
+}
+
+func TestParseAnswer(t *testing.T) {
+	t.Run("without credentials", func(t *testing.T) {
+		if _, err := parseAnswer("v=0\r\n"); err == nil {
+			t.Fatal("expected an error here")
+		}
+	})
+	t.Run("with TCP and IPv6 candidates", func(t *testing.T) {
+		a, err := parseAnswer(strings.Join([]string{
+			"a=ice-ufrag:u",
+			"a=ice-pwd:p",
+			"a=candidate:1 1 TCP 2122252543 10.0.0.1 9 typ host tcptype active",
+			"a=candidate:2 1 udp 2122252543 2001:db8::1 5000 typ host",
+			"a=candidate:3 1 udp 2122252543 10.0.0.1 70000 typ host",
+		}, "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		candidates := a.udpCandidates()
+		if len(candidates) != 1 || candidates[0] != "[2001:db8::1]:5000" {
+			t.Fatalf("unexpected candidates: %+v", candidates)
+		}
+	})
+}
+
+func TestNewOffer(t *testing.T) {
+	o, err := newOffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(o.sdp, "a=ice-ufrag:"+o.ufrag+"\r\n") {
+		t.Fatal("missing ufrag")
+	}
+	if !strings.Contains(o.sdp, "a=ice-pwd:"+o.pwd+"\r\n") {
+		t.Fatal("missing pwd")
+	}
+	if strings.Contains(o.sdp, "a=candidate:") {
+		t.Fatal("the offer should not contain candidates")
+	}
+}
+
+func TestSummaryKeys(t *testing.T) {
+	measurer := &Measurer{}
+	if _, err := measurer.GetSummaryKeys(&model.Measurement{}); err == nil {
+		t.Fatal("expected an error here")
+	}
+	failure := "mocked_error"
+	sk, err := measurer.GetSummaryKeys(&model.Measurement{
+		TestKeys: &TestKeys{Failure: &failure},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sk.(SummaryKeys).IsAnomaly {
+		t.Fatal("expected an anomaly here")
+	}
+}
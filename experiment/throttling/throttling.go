@@ -0,0 +1,286 @@
+// Package throttling contains the throttling network experiment.
+//
+// This experiment downloads the same body from the same server twice: the
+// first time using the SNI of the target URL, the second time using a
+// control SNI. While downloading, we sample the number of bytes received
+// at regular intervals. Comparing the speed of the two downloads allows
+// us to tell whether the target SNI is throttled, even when it is not
+// blocked. This experiment has not been specified yet.
+package throttling
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ooni/probe-engine/experiment/urlgetter"
+	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx/bytecounter"
+	"github.com/ooni/probe-engine/netx/errorx"
+)
+
+const (
+	testName    = "throttling"
+	testVersion = "0.1.0"
+)
+
+const (
+	defaultControlSNI     = "example.com"
+	defaultMaxRuntime     = 30
+	defaultSampleInterval = 250
+)
+
+// Config contains the experiment config.
+type Config struct {
+	// not settable from command line
+	CertPool *x509.CertPool
+
+	// settable from command line
+	ControlSNI     string `ooni:"SNI to use for the control download" ooni_pattern:"^([a-z0-9]+(-[a-z0-9]+)*\\.)+[a-z]{2,}$"`
+	MaxRuntime     int64  `ooni:"Maximum number of seconds spent downloading using each SNI"`
+	SampleInterval int64  `ooni:"Milliseconds between two consecutive throughput samples"`
+}
+
+// Sample is a point in the time series of the bytes received.
+type Sample struct {
+	// T is the number of seconds elapsed since the download started.
+	T float64 `json:"t"`
+
+	// BytesReceived is the number of bytes received at time T.
+	BytesReceived int64 `json:"bytes_received"`
+}
+
+// Subresult contains the keys of a single download that
+// uses either the target or the control SNI.
+type Subresult struct {
+	urlgetter.TestKeys
+	BytesReceived int64    `json:"bytes_received"`
+	Elapsed       float64  `json:"elapsed"`
+	SNI           string   `json:"sni"`
+	Samples       []Sample `json:"samples"`
+	Speed         float64  `json:"speed"`
+}
+
+// TestKeys contains throttling test keys.
+type TestKeys struct {
+	Control         Subresult `json:"control"`
+	Target          Subresult `json:"target"`
+	ThrottlingRatio *float64  `json:"throttling_ratio"`
+}
+
+// throttlingThreshold is the ratio between the target and the
+// control speed below which we flag the measurement.
+const throttlingThreshold = 0.5
+
+// Measurer performs the measurement.
+type Measurer struct {
+	config Config
+}
+
+// ExperimentName implements ExperimentMeasurer.ExperimentName.
+func (m Measurer) ExperimentName() string {
+	return testName
+}
+
+// ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
+func (m Measurer) ExperimentVersion() string {
+	return testVersion
+}
+
+var (
+	// errNoInputProvided indicates you didn't provide any input
+	errNoInputProvided = errors.New("no input provided")
+
+	// errInputIsNotAnURL indicates that input is not an URL
+	errInputIsNotAnURL = errors.New("input is not an URL")
+
+	// errInvalidScheme indicates that the scheme is invalid
+	errInvalidScheme = errors.New("scheme must be https")
+)
+
+// Run implements ExperimentMeasurer.Run.
+func (m Measurer) Run(
+	ctx context.Context,
+	sess model.ExperimentSession,
+	measurement *model.Measurement,
+	callbacks model.ExperimentCallbacks,
+) error {
+	if measurement.Input == "" {
+		return errNoInputProvided
+	}
+	URL, err := url.Parse(string(measurement.Input))
+	if err != nil {
+		return errInputIsNotAnURL
+	}
+	if URL.Scheme != "https" {
+		return errInvalidScheme
+	}
+	if m.config.ControlSNI == "" {
+		m.config.ControlSNI = defaultControlSNI
+	}
+	if m.config.MaxRuntime <= 0 {
+		m.config.MaxRuntime = defaultMaxRuntime
+	}
+	if m.config.SampleInterval <= 0 {
+		m.config.SampleInterval = defaultSampleInterval
+	}
+	urlgetter.RegisterExtensions(measurement)
+	tk := new(TestKeys)
+	measurement.TestKeys = tk
+	tk.Target = m.measure(ctx, sess, measurement, urlgetter.Config{
+		CertPool: m.config.CertPool,
+	}, URL.Hostname())
+	callbacks.OnProgress(0.5, fmt.Sprintf("target: %.1f KiB/s", tk.Target.Speed/1024))
+	// Implementation note: the control SNI does not match the certificate
+	// returned by the server, hence we need to disable TLS verification.
+	tk.Control = m.measure(ctx, sess, measurement, urlgetter.Config{
+		NoTLSVerify:   true,
+		TLSServerName: m.config.ControlSNI,
+	}, m.config.ControlSNI)
+	callbacks.OnProgress(1, fmt.Sprintf("control: %.1f KiB/s", tk.Control.Speed/1024))
+	tk.ThrottlingRatio = computeRatio(tk.Target, tk.Control)
+	return nil
+}
+
+func (m Measurer) measure(
+	ctx context.Context, sess model.ExperimentSession,
+	measurement *model.Measurement, config urlgetter.Config, sni string,
+) Subresult {
+	counter := bytecounter.New()
+	config.ByteCounter = counter
+	config.Timeout = time.Duration(m.config.MaxRuntime) * time.Second
+	g := urlgetter.Getter{
+		Begin:   measurement.MeasurementStartTimeSaved,
+		Config:  config,
+		Session: sess,
+		Target:  string(measurement.Input),
+	}
+	s := newSampler(counter, time.Duration(m.config.SampleInterval)*time.Millisecond)
+	tk, _ := g.Get(ctx)
+	samples := s.stop()
+	result := Subresult{
+		TestKeys:      tk,
+		BytesReceived: counter.BytesReceived(),
+		SNI:           sni,
+		Samples:       samples,
+	}
+	if len(samples) > 0 {
+		result.Elapsed = samples[len(samples)-1].T
+	}
+	if result.Elapsed > 0 {
+		result.Speed = float64(result.BytesReceived) / result.Elapsed
+	}
+	return result
+}
+
+// computeRatio returns the ratio between the target and the control
+// speed, or nil if we cannot meaningfully compute such ratio.
+func computeRatio(target, control Subresult) *float64 {
+	if !isValidSample(target) || !isValidSample(control) || control.Speed <= 0 {
+		return nil
+	}
+	ratio := target.Speed / control.Speed
+	return &ratio
+}
+
+// isValidSample returns whether we can use the speed of the given
+// download to compute the throttling ratio. A download that hits the
+// timeout after the TLS handshake, i.e., while we are receiving the
+// response, is a valid partial sample whose speed we compute from the
+// bytes received before the timeout. This is actually what we expect
+// to see when the target SNI is severely throttled.
+func isValidSample(r Subresult) bool {
+	if r.Failure == nil {
+		return true
+	}
+	if *r.Failure != errorx.FailureGenericTimeoutError || r.BytesReceived <= 0 {
+		return false
+	}
+	for _, hs := range r.TLSHandshakes {
+		if hs.Failure == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// sampler periodically samples the bytes received by a counter.
+type sampler struct {
+	begin   time.Time
+	counter *bytecounter.Counter
+	done    chan interface{}
+	mu      sync.Mutex
+	samples []Sample
+	wg      sync.WaitGroup
+}
+
+func newSampler(counter *bytecounter.Counter, interval time.Duration) *sampler {
+	s := &sampler{
+		begin:   time.Now(),
+		counter: counter,
+		done:    make(chan interface{}),
+	}
+	s.wg.Add(1)
+	go s.loop(interval)
+	return s
+}
+
+func (s *sampler) loop(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sample()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *sampler) sample() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, Sample{
+		T:             time.Since(s.begin).Seconds(),
+		BytesReceived: s.counter.BytesReceived(),
+	})
+}
+
+// stop stops the sampler and returns the samples collected so far
+// including a final sample taken when stop is called.
+func (s *sampler) stop() []Sample {
+	close(s.done)
+	s.wg.Wait()
+	s.sample()
+	return s.samples
+}
+
+// NewExperimentMeasurer creates a new ExperimentMeasurer.
+func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
+	return Measurer{config: config}
+}
+
+// SummaryKeys contains summary keys for this experiment.
+//
+// Note that this structure is part of the ABI contract with probe-cli
+// therefore we should be careful when changing it.
+type SummaryKeys struct {
+	IsAnomaly bool `json:"-"`
+}
+
+// GetSummaryKeys implements model.ExperimentMeasurer.GetSummaryKeys.
+func (m Measurer) GetSummaryKeys(measurement *model.Measurement) (interface{}, error) {
+	sk := SummaryKeys{IsAnomaly: false}
+	tk, ok := measurement.TestKeys.(*TestKeys)
+	if !ok {
+		return sk, errors.New("invalid test keys type")
+	}
+	sk.IsAnomaly = tk.ThrottlingRatio != nil && *tk.ThrottlingRatio < throttlingThreshold
+	return sk, nil
+}
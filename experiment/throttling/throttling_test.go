@@ -0,0 +1,310 @@
+package throttling
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-engine/experiment/urlgetter"
+	"github.com/ooni/probe-engine/internal/mockable"
+	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx/archival"
+	"github.com/ooni/probe-engine/netx/bytecounter"
+	"github.com/ooni/probe-engine/netx/errorx"
+)
+
+func TestNewExperimentMeasurer(t *testing.T) {
+	measurer := NewExperimentMeasurer(Config{})
+	if measurer.ExperimentName() != "throttling" {
+		t.Fatal("unexpected name")
+	}
+	if measurer.ExperimentVersion() != "0.1.0" {
+		t.Fatal("unexpected version")
+	}
+}
+
+func TestMeasurerRunInputErrors(t *testing.T) {
+	var inputs = []struct {
+		input model.MeasurementTarget
+		err   error
+	}{
+		{input: "", err: errNoInputProvided},
+		{input: "\t", err: errInputIsNotAnURL},
+		{input: "http://www.example.com/", err: errInvalidScheme},
+	}
+	for _, in := range inputs {
+		measurer := NewExperimentMeasurer(Config{})
+		err := measurer.Run(
+			context.Background(),
+			newsession(),
+			&model.Measurement{Input: in.input},
+			model.NewPrinterCallbacks(log.Log),
+		)
+		if !errors.Is(err, in.err) {
+			t.Fatalf("for %s: not the error we expected: %+v", in.input, err)
+		}
+	}
+}
+
+// newThrottlingServer returns a server that serves bodySize bytes and
+// that sleeps between each chunk when the client does not send any SNI
+// (which is the case when we connect using an IP address).
+func newThrottlingServer(bodySize int, delay time.Duration) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			chunk := make([]byte, 8192)
+			w.WriteHeader(200)
+			for sent := 0; sent < bodySize; sent += len(chunk) {
+				if r.TLS != nil && r.TLS.ServerName == "" {
+					time.Sleep(delay)
+				}
+				if _, err := w.Write(chunk); err != nil {
+					return
+				}
+				w.(http.Flusher).Flush()
+			}
+		}))
+}
+
+func TestMeasurerRunWithThrottling(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip test in short mode")
+	}
+	server := newThrottlingServer(1<<20, 10*time.Millisecond)
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	measurer := NewExperimentMeasurer(Config{
+		CertPool:       pool,
+		SampleInterval: 50,
+	})
+	measurement := &model.Measurement{Input: model.MeasurementTarget(server.URL)}
+	err := measurer.Run(
+		context.Background(),
+		newsession(),
+		measurement,
+		model.NewPrinterCallbacks(log.Log),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := measurement.TestKeys.(*TestKeys)
+	if tk.Target.Failure != nil {
+		t.Fatal(*tk.Target.Failure)
+	}
+	if tk.Control.Failure != nil {
+		t.Fatal(*tk.Control.Failure)
+	}
+	if tk.Target.SNI != "127.0.0.1" || tk.Control.SNI != defaultControlSNI {
+		t.Fatal("unexpected SNI values")
+	}
+	if len(tk.Target.Samples) < 2 {
+		t.Fatal("expected more target samples")
+	}
+	if tk.Target.BytesReceived < 1<<20 || tk.Control.BytesReceived < 1<<20 {
+		t.Fatal("expected to receive the whole body")
+	}
+	if tk.ThrottlingRatio == nil {
+		t.Fatal("expected a throttling ratio")
+	}
+	sk, err := measurer.GetSummaryKeys(measurement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sk.(SummaryKeys).IsAnomaly {
+		t.Fatalf("expected an anomaly with ratio %f", *tk.ThrottlingRatio)
+	}
+}
+
+func TestMeasurerRunWithThrottlingAndTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip test in short mode")
+	}
+	// The target download is so slow that it hits the timeout, while
+	// the control download completes well before the timeout.
+	server := newThrottlingServer(1<<22, 50*time.Millisecond)
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	measurer := NewExperimentMeasurer(Config{
+		CertPool:       pool,
+		MaxRuntime:     1,
+		SampleInterval: 50,
+	})
+	measurement := &model.Measurement{Input: model.MeasurementTarget(server.URL)}
+	err := measurer.Run(
+		context.Background(),
+		newsession(),
+		measurement,
+		model.NewPrinterCallbacks(log.Log),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := measurement.TestKeys.(*TestKeys)
+	if tk.Target.Failure == nil || *tk.Target.Failure != errorx.FailureGenericTimeoutError {
+		t.Fatal("expected the target download to time out")
+	}
+	if tk.Target.BytesReceived <= 0 || tk.Target.Speed <= 0 {
+		t.Fatal("expected a partial target sample")
+	}
+	if tk.Control.Failure != nil {
+		t.Fatal(*tk.Control.Failure)
+	}
+	if tk.ThrottlingRatio == nil {
+		t.Fatal("expected a throttling ratio")
+	}
+	sk, err := measurer.GetSummaryKeys(measurement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sk.(SummaryKeys).IsAnomaly {
+		t.Fatalf("expected an anomaly with ratio %f", *tk.ThrottlingRatio)
+	}
+}
+
+func TestMeasurerRunWithFailure(t *testing.T) {
+	server := newThrottlingServer(1<<10, 0)
+	server.Close() // so that connecting fails
+	measurer := NewExperimentMeasurer(Config{})
+	measurement := &model.Measurement{Input: model.MeasurementTarget(server.URL)}
+	err := measurer.Run(
+		context.Background(),
+		newsession(),
+		measurement,
+		model.NewPrinterCallbacks(log.Log),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := measurement.TestKeys.(*TestKeys)
+	if tk.Target.Failure == nil || tk.Control.Failure == nil {
+		t.Fatal("expected failures here")
+	}
+	if tk.ThrottlingRatio != nil {
+		t.Fatal("expected no throttling ratio")
+	}
+}
+
+func TestComputeRatio(t *testing.T) {
+	failure := "connection_reset"
+	timeout := errorx.FailureGenericTimeoutError
+	tests := []struct {
+		name    string
+		target  Subresult
+		control Subresult
+		isNil   bool
+		expect  float64
+	}{{
+		name:    "control with zero speed",
+		target:  Subresult{Speed: 10},
+		control: Subresult{Speed: 0},
+		isNil:   true,
+	}, {
+		name: "target with failure",
+		target: Subresult{
+			TestKeys: urlgetter.TestKeys{Failure: &failure},
+			Speed:    10,
+		},
+		control: Subresult{Speed: 40},
+		isNil:   true,
+	}, {
+		name: "target with timeout during the TLS handshake",
+		target: Subresult{
+			TestKeys: urlgetter.TestKeys{
+				Failure:       &timeout,
+				TLSHandshakes: []archival.TLSHandshake{{Failure: &timeout}},
+			},
+			BytesReceived: 1024,
+			Speed:         10,
+		},
+		control: Subresult{Speed: 40},
+		isNil:   true,
+	}, {
+		name: "target with timeout while receiving the body",
+		target: Subresult{
+			TestKeys: urlgetter.TestKeys{
+				Failure:       &timeout,
+				TLSHandshakes: []archival.TLSHandshake{{}},
+			},
+			BytesReceived: 1024,
+			Speed:         10,
+		},
+		control: Subresult{Speed: 40},
+		expect:  0.25,
+	}, {
+		name:    "both successful",
+		target:  Subresult{Speed: 10},
+		control: Subresult{Speed: 40},
+		expect:  0.25,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ratio := computeRatio(tt.target, tt.control)
+			if tt.isNil != (ratio == nil) {
+				t.Fatal("unexpected ratio nil-ness")
+			}
+			if ratio != nil && *ratio != tt.expect {
+				t.Fatalf("unexpected ratio: %f", *ratio)
+			}
+		})
+	}
+}
+
+func TestSamplerFinalSample(t *testing.T) {
+	counter := bytecounter.New()
+	s := newSampler(counter, time.Hour)
+	counter.CountBytesReceived(128)
+	samples := s.stop()
+	if len(samples) != 1 {
+		t.Fatal("expected a single sample")
+	}
+	if samples[0].BytesReceived != 128 {
+		t.Fatal("unexpected number of bytes received")
+	}
+}
+
+func TestSummaryKeysInvalidType(t *testing.T) {
+	measurement := new(model.Measurement)
+	m := &Measurer{}
+	_, err := m.GetSummaryKeys(measurement)
+	if err.Error() != "invalid test keys type" {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestSummaryKeysWorksAsIntended(t *testing.T) {
+	ratio := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name      string
+		tk        TestKeys
+		isAnomaly bool
+	}{
+		{name: "no ratio", tk: TestKeys{}, isAnomaly: false},
+		{name: "high ratio", tk: TestKeys{ThrottlingRatio: ratio(0.9)}, isAnomaly: false},
+		{name: "low ratio", tk: TestKeys{ThrottlingRatio: ratio(0.1)}, isAnomaly: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Measurer{}
+			measurement := &model.Measurement{TestKeys: &tt.tk}
+			got, err := m.GetSummaryKeys(measurement)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sk := got.(SummaryKeys)
+			if sk.IsAnomaly != tt.isAnomaly {
+				t.Fatal("unexpected isAnomaly value")
+			}
+		})
+	}
+}
+
+func newsession() model.ExperimentSession {
+	return &mockable.Session{MockableLogger: log.Log}
+}
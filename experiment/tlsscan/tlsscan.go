@@ -0,0 +1,307 @@
+// Package tlsscan contains the TLS scan network experiment.
+//
+// This experiment has not been specified yet. For a given host:port
+// and SNI, it tries each TLS version, ALPN value (including h3 over
+// QUIC), and class of TLS 1.2 cipher suites, and it records which
+// combinations succeed. By comparing the results with the ones of an
+// unfiltered path, we can detect middleboxes that, e.g., block TLS 1.3
+// or specific ALPNs.
+package tlsscan
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx"
+	"github.com/ooni/probe-engine/netx/archival"
+	"github.com/ooni/probe-engine/netx/trace"
+)
+
+const (
+	testName    = "tlsscan"
+	testVersion = "0.1.0"
+)
+
+// Config contains the experiment config.
+type Config struct {
+	// not settable from command line
+	CertPool *x509.CertPool
+
+	// settable from command line
+	HandshakeTimeout int64  `ooni:"Milliseconds to wait for each TLS or QUIC handshake (default: 10000)"`
+	NoTLSVerify      bool   `ooni:"Disable TLS verification"`
+	SNI              string `ooni:"Force using the specified SNI"`
+}
+
+// handshakeTimeout returns the timeout for each handshake.
+func (c Config) handshakeTimeout() time.Duration {
+	if c.HandshakeTimeout > 0 {
+		return time.Duration(c.HandshakeTimeout) * time.Millisecond
+	}
+	return 10 * time.Second
+}
+
+// Probe is a TLS or QUIC handshake using a specific combination
+// of TLS version, ALPN and class of cipher suites.
+type Probe struct {
+	// ALPN is the ALPN we offer. When empty, we offer "h2" and
+	// "http/1.1" like an ordinary browser.
+	ALPN string `json:"alpn"`
+
+	// CipherSuites is the class of cipher suites we offer. When
+	// it is "default", we offer the Go default cipher suites.
+	CipherSuites string `json:"cipher_suites"`
+
+	// Failure is the handshake failure.
+	Failure *string `json:"failure"`
+
+	// NegotiatedProtocol is the ALPN negotiated with the server.
+	NegotiatedProtocol string `json:"negotiated_protocol"`
+
+	// Supported indicates that the handshake succeeded and the server
+	// agreed on the ALPN, if any, we offered.
+	Supported bool `json:"supported"`
+
+	// TLSVersion is the TLS version we force. When empty, we
+	// allow the Go default TLS versions.
+	TLSVersion string `json:"tls_version"`
+
+	// Transport is either "tcp" or "quic".
+	Transport string `json:"transport"`
+}
+
+// TestKeys contains the experiment test keys.
+type TestKeys struct {
+	Probes        []Probe                 `json:"probes"`
+	SNI           string                  `json:"sni"`
+	TLSHandshakes []archival.TLSHandshake `json:"tls_handshakes"`
+}
+
+// The following are the classes of cipher suites we probe. We only
+// probe them using TLS 1.2 because Go does not allow to configure
+// the TLS 1.3 cipher suites and because TLS 1.0 and TLS 1.1 only
+// support the CBC and legacy classes anyway.
+const (
+	CipherSuitesDefault  = "default"
+	CipherSuitesAESGCM   = "aes-gcm"
+	CipherSuitesCBC      = "cbc"
+	CipherSuitesChaCha20 = "chacha20"
+	CipherSuitesLegacy   = "legacy"
+)
+
+var allCipherSuites = map[string][]uint16{
+	CipherSuitesAESGCM: {
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	},
+	CipherSuitesCBC: {
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+		tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+		tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	},
+	CipherSuitesChaCha20: {
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	},
+	CipherSuitesLegacy: {
+		tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
+		tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
+		tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+		tls.TLS_RSA_WITH_RC4_128_SHA,
+	},
+}
+
+// allProbes returns all the probes we run. We vary one dimension
+// at a time, rather than trying all the combinations, to keep the
+// number of handshakes we perform reasonably small.
+func allProbes() []Probe {
+	var out []Probe
+	for _, version := range []string{"TLSv1.0", "TLSv1.1", "TLSv1.2", "TLSv1.3"} {
+		out = append(out, Probe{
+			CipherSuites: CipherSuitesDefault,
+			TLSVersion:   version,
+			Transport:    "tcp",
+		})
+	}
+	for _, alpn := range []string{"h2", "http/1.1"} {
+		out = append(out, Probe{
+			ALPN:         alpn,
+			CipherSuites: CipherSuitesDefault,
+			Transport:    "tcp",
+		})
+	}
+	out = append(out, Probe{
+		ALPN:         "h3",
+		CipherSuites: CipherSuitesDefault,
+		TLSVersion:   "TLSv1.3",
+		Transport:    "quic",
+	})
+	for _, class := range []string{
+		CipherSuitesAESGCM, CipherSuitesCBC, CipherSuitesChaCha20, CipherSuitesLegacy,
+	} {
+		out = append(out, Probe{
+			CipherSuites: class,
+			TLSVersion:   "TLSv1.2",
+			Transport:    "tcp",
+		})
+	}
+	return out
+}
+
+// Measurer performs the measurement.
+type Measurer struct {
+	config Config
+}
+
+// ExperimentName implements ExperimentMeasurer.ExperiExperimentName.
+func (m Measurer) ExperimentName() string {
+	return testName
+}
+
+// ExperimentVersion implements ExperimentMeasurer.ExperimentVersion.
+func (m Measurer) ExperimentVersion() string {
+	return testVersion
+}
+
+// ErrInputIsNotAnEndpoint indicates that the input is not an endpoint.
+var ErrInputIsNotAnEndpoint = errors.New("tlsscan: input is not an endpoint")
+
+// Run implements ExperimentMeasurer.Run.
+func (m Measurer) Run(
+	ctx context.Context,
+	sess model.ExperimentSession,
+	measurement *model.Measurement,
+	callbacks model.ExperimentCallbacks,
+) error {
+	address := string(measurement.Input)
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ErrInputIsNotAnEndpoint
+	}
+	tk := &TestKeys{SNI: m.config.SNI}
+	if tk.SNI == "" {
+		tk.SNI = host
+	}
+	measurement.TestKeys = tk
+	probes := allProbes()
+	for idx, probe := range probes {
+		saver := new(trace.Saver)
+		err := m.run(ctx, runConfig{
+			address: address,
+			logger:  sess.Logger(),
+			probe:   probe,
+			saver:   saver,
+			sni:     tk.SNI,
+		})
+		probe.Failure = archival.NewFailure(err)
+		handshakes := archival.NewTLSHandshakesList(
+			measurement.MeasurementStartTimeSaved, saver.Read())
+		if len(handshakes) > 0 {
+			probe.NegotiatedProtocol = handshakes[len(handshakes)-1].NegotiatedProtocol
+		}
+		probe.Supported = err == nil && (probe.ALPN == "" ||
+			probe.ALPN == probe.NegotiatedProtocol)
+		tk.Probes = append(tk.Probes, probe)
+		tk.TLSHandshakes = append(tk.TLSHandshakes, handshakes...)
+		percent := float64(idx+1) / float64(len(probes))
+		callbacks.OnProgress(percent, fmt.Sprintf(
+			"%s version=%s alpn=%s ciphers=%s: supported=%+v", probe.Transport,
+			probe.TLSVersion, probe.ALPN, probe.CipherSuites, probe.Supported))
+	}
+	return nil
+}
+
+type runConfig struct {
+	address string
+	logger  model.Logger
+	probe   Probe
+	saver   *trace.Saver
+	sni     string
+}
+
+func (m Measurer) run(ctx context.Context, config runConfig) error {
+	tlsConfig := &tls.Config{
+		CipherSuites: allCipherSuites[config.probe.CipherSuites],
+		NextProtos:   []string{"h2", "http/1.1"},
+		ServerName:   config.sni,
+	}
+	if config.probe.ALPN != "" {
+		tlsConfig.NextProtos = []string{config.probe.ALPN}
+	}
+	if err := netx.ConfigureTLSVersion(tlsConfig, config.probe.TLSVersion); err != nil {
+		return err
+	}
+	netxConfig := netx.Config{
+		CertPool:    m.config.CertPool,
+		Logger:      config.logger,
+		NoTLSVerify: m.config.NoTLSVerify,
+		TLSConfig:   tlsConfig,
+		TLSSaver:    config.saver,
+		Timeouts: netx.Timeouts{
+			QUICHandshake: m.config.handshakeTimeout(),
+			TLSHandshake:  m.config.handshakeTimeout(),
+		},
+	}
+	if config.probe.Transport == "quic" {
+		return m.runQUIC(netxConfig, config.address)
+	}
+	conn, err := netx.NewTLSDialer(netxConfig).DialTLSContext(ctx, "tcp", config.address)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+	return nil
+}
+
+func (m Measurer) runQUIC(config netx.Config, address string) error {
+	// The QUIC dialer does not use the CertPool and the NoTLSVerify
+	// settings, hence we need to set them into the TLS config.
+	if config.CertPool == nil {
+		config.CertPool = netx.NewDefaultCertPool()
+	}
+	config.TLSConfig.InsecureSkipVerify = config.NoTLSVerify
+	config.TLSConfig.RootCAs = config.CertPool
+	sess, err := netx.NewQUICDialer(config).Dial(
+		"udp", address, config.TLSConfig, &quic.Config{})
+	if err != nil {
+		return err
+	}
+	sess.CloseWithError(0, "")
+	return nil
+}
+
+// NewExperimentMeasurer creates a new ExperimentMeasurer.
+func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
+	return Measurer{config: config}
+}
+
+// SummaryKeys contains summary keys for this experiment.
+//
+// Note that this structure is part of the ABI contract with probe-cli
+// therefore we should be careful when changing it.
+type SummaryKeys struct {
+	IsAnomaly bool `json:"-"`
+}
+
+// GetSummaryKeys implements model.ExperimentMeasurer.GetSummaryKeys.
+func (m Measurer) GetSummaryKeys(measurement *model.Measurement) (interface{}, error) {
+	return SummaryKeys{IsAnomaly: false}, nil
+}
@@ -0,0 +1,114 @@
+package tlsscan_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-engine/experiment/tlsscan"
+	"github.com/ooni/probe-engine/internal/mockable"
+	"github.com/ooni/probe-engine/model"
+)
+
+func TestMeasurerExperimentNameVersion(t *testing.T) {
+	measurer := tlsscan.NewExperimentMeasurer(tlsscan.Config{})
+	if measurer.ExperimentName() != "tlsscan" {
+		t.Fatal("unexpected ExperimentName")
+	}
+	if measurer.ExperimentVersion() != "0.1.0" {
+		t.Fatal("unexpected ExperimentVersion")
+	}
+}
+
+func TestRunWithInputNotAnEndpoint(t *testing.T) {
+	measurer := tlsscan.NewExperimentMeasurer(tlsscan.Config{})
+	measurement := new(model.Measurement)
+	measurement.Input = "https://www.example.com/"
+	err := measurer.Run(
+		context.Background(),
+		&mockable.Session{MockableLogger: log.Log},
+		measurement,
+		model.NewPrinterCallbacks(log.Log),
+	)
+	if !errors.Is(err, tlsscan.ErrInputIsNotAnEndpoint) {
+		t.Fatal("not the error we expected")
+	}
+	if measurement.TestKeys != nil {
+		t.Fatal("expected nil test keys")
+	}
+}
+
+func TestRunWithRestrictedServer(t *testing.T) {
+	// The server only supports TLS 1.2, the AES-GCM cipher suites
+	// and http/1.1, and does not speak QUIC at all.
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = &tls.Config{
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		},
+		MaxVersion: tls.VersionTLS12,
+		NextProtos: []string{"http/1.1"},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	measurer := tlsscan.NewExperimentMeasurer(tlsscan.Config{
+		CertPool:         pool,
+		HandshakeTimeout: 500,
+	})
+	measurement := new(model.Measurement)
+	measurement.Input = model.MeasurementTarget(srv.Listener.Addr().String())
+	err := measurer.Run(
+		context.Background(),
+		&mockable.Session{MockableLogger: log.Log},
+		measurement,
+		model.NewPrinterCallbacks(log.Log),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := measurement.TestKeys.(*tlsscan.TestKeys)
+	if tk.SNI != "127.0.0.1" {
+		t.Fatal("unexpected SNI")
+	}
+	expected := map[string]bool{
+		"tcp TLSv1.0  default":    false,
+		"tcp TLSv1.1  default":    false,
+		"tcp TLSv1.2  default":    true,
+		"tcp TLSv1.3  default":    false,
+		"tcp  h2 default":         false,
+		"tcp  http/1.1 default":   true,
+		"quic TLSv1.3 h3 default": false,
+		"tcp TLSv1.2  aes-gcm":    true,
+		"tcp TLSv1.2  cbc":        false,
+		"tcp TLSv1.2  chacha20":   false,
+		"tcp TLSv1.2  legacy":     false,
+	}
+	if len(tk.Probes) != len(expected) {
+		t.Fatal("unexpected number of probes")
+	}
+	for _, probe := range tk.Probes {
+		key := probe.Transport + " " + probe.TLSVersion + " " +
+			probe.ALPN + " " + probe.CipherSuites
+		supported, found := expected[key]
+		if !found {
+			t.Fatalf("unexpected probe: %s", key)
+		}
+		if probe.Supported != supported {
+			t.Fatalf("%s: expected supported=%+v", key, supported)
+		}
+		if supported && probe.Failure != nil {
+			t.Fatalf("%s: unexpected failure: %s", key, *probe.Failure)
+		}
+	}
+	if len(tk.TLSHandshakes) != len(tk.Probes) {
+		t.Fatal("we should have one handshake for each probe")
+	}
+}
@@ -0,0 +1,171 @@
+package tor
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	goptlib "git.torproject.org/pluggable-transports/goptlib.git"
+	"github.com/ooni/probe-engine/internal/httpheader"
+	"github.com/ooni/probe-engine/internal/tunnel"
+	"github.com/ooni/probe-engine/netx"
+	"github.com/ooni/probe-engine/netx/httptransport"
+	"github.com/ooni/probe-engine/netx/trace"
+	obfs4base "gitlab.com/yawning/obfs4.git/transports/base"
+)
+
+// This file contains the code to connect to the targets. We use
+// netx.Config to specify the logger and the savers.
+
+// httpGet fetches the given URL saving at most snapshotSize bytes
+// of each body, which we also read from the response.
+func httpGet(ctx context.Context, config netx.Config, saver *trace.Saver,
+	URL string, snapshotSize int) error {
+	// We wire the HTTP savers ourselves because we need to use a
+	// specific snapshot size for the bodies.
+	var txp netx.HTTPRoundTripper = netx.NewHTTPTransport(config)
+	txp = httptransport.SaverMetadataHTTPTransport{
+		RoundTripper: txp, Saver: saver, Transport: "tcp"}
+	txp = httptransport.SaverBodyHTTPTransport{
+		RoundTripper: txp, Saver: saver, SnapshotSize: snapshotSize}
+	txp = httptransport.SaverTransactionHTTPTransport{
+		RoundTripper: txp, Saver: saver}
+	defer txp.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(ctx, "GET", URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", httpheader.Accept())
+	req.Header.Set("Accept-Language", httpheader.AcceptLanguage())
+	req.Header.Set("User-Agent", httpheader.UserAgent())
+	resp, err := (&http.Client{Transport: txp}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = ioutil.ReadAll(io.LimitReader(resp.Body, int64(snapshotSize)))
+	return err
+}
+
+// tlsConnect performs a TLS handshake with the given address.
+func tlsConnect(ctx context.Context, config netx.Config, address string) error {
+	config.TLSConfig = &tls.Config{}
+	conn, err := netx.NewTLSDialer(config).DialTLSContext(ctx, "tcp", address)
+	if conn != nil {
+		conn.Close()
+	}
+	return err
+}
+
+// tcpConnect connects to the given address.
+func tcpConnect(ctx context.Context, config netx.Config, address string) error {
+	conn, err := netx.NewDialer(config).DialContext(ctx, "tcp", address)
+	if conn != nil {
+		conn.Close()
+	}
+	return err
+}
+
+// obfs4Config contains the obfs4Connect settings.
+type obfs4Config struct {
+	Address string
+
+	// AfterHandshake is an optional function called after a successful
+	// OBFS4 handshake, before closing the connection, for example to
+	// perform a Tor link handshake over the OBFS4 connection.
+	AfterHandshake func(conn net.Conn) error
+
+	Params        goptlib.Args
+	StateBaseDir  string
+	Timeout       time.Duration
+	ioutilTempDir func(dir string, prefix string) (string, error)
+	transportsGet func(name string) (obfs4base.Transport, error)
+	setDeadline   func(net.Conn, time.Time) error
+}
+
+// obfs4Results contains the results of obfs4Connect.
+type obfs4Results struct {
+	Error error
+
+	// HandshakeDuration is the time it took to connect and
+	// to complete (or fail) the OBFS4 handshake.
+	HandshakeDuration time.Duration
+
+	// HandshakeError is the error that occurred during the
+	// connect or the OBFS4 handshake, if any.
+	HandshakeError error
+}
+
+// obfs4Connect performs an OBFS4 handshake with the given bridge.
+func obfs4Connect(
+	ctx context.Context, config netx.Config, o4config obfs4Config,
+) (results obfs4Results) {
+	dialer := netx.NewDialer(config)
+	transportsGet := o4config.transportsGet
+	if transportsGet == nil {
+		transportsGet = tunnel.GetTransport
+	}
+	txp, err := transportsGet("obfs4")
+	if err != nil {
+		results.Error = err
+		return
+	}
+	ioutilTempDir := o4config.ioutilTempDir
+	if ioutilTempDir == nil {
+		ioutilTempDir = ioutil.TempDir
+	}
+	dirname, err := ioutilTempDir(o4config.StateBaseDir, "obfs4")
+	if err != nil {
+		results.Error = err
+		return
+	}
+	factory, err := txp.ClientFactory(dirname)
+	if err != nil {
+		results.Error = err
+		return
+	}
+	parsedargs, err := factory.ParseArgs(&o4config.Params)
+	if err != nil {
+		results.Error = err
+		return
+	}
+	dialfunc := func(network, address string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		// I didn't immediately see an API for limiting in time the
+		// duration of the handshake, so let's set a deadline.
+		timeout := o4config.Timeout
+		if timeout == 0 {
+			timeout = 30 * time.Second
+		}
+		setDeadline := o4config.setDeadline
+		if setDeadline == nil {
+			setDeadline = func(conn net.Conn, t time.Time) error {
+				return conn.SetDeadline(t)
+			}
+		}
+		if err := setDeadline(conn, time.Now().Add(timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+	start := time.Now()
+	conn, err := factory.Dial("tcp", o4config.Address, dialfunc, parsedargs)
+	results.HandshakeDuration = time.Since(start)
+	results.HandshakeError = err
+	if conn != nil {
+		defer conn.Close()
+	}
+	if err == nil && o4config.AfterHandshake != nil {
+		err = o4config.AfterHandshake(conn)
+	}
+	results.Error = err
+	return
+}
@@ -0,0 +1,175 @@
+package tor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	goptlib "git.torproject.org/pluggable-transports/goptlib.git"
+	"github.com/ooni/probe-engine/internal/tunnel"
+	"github.com/ooni/probe-engine/netx"
+	"github.com/ooni/probe-engine/netx/trace"
+	obfs4base "gitlab.com/yawning/obfs4.git/transports/base"
+)
+
+func TestHTTPGetBadURL(t *testing.T) {
+	saver := new(trace.Saver)
+	err := httpGet(context.Background(), netx.Config{}, saver, "\t", 1<<8)
+	if err == nil || !strings.HasSuffix(err.Error(), "invalid control character in URL") {
+		t.Fatal("not the error we expected")
+	}
+	if events := saver.Read(); len(events) != 0 {
+		t.Fatal("did not expect any event here")
+	}
+}
+
+func obfs4config() obfs4Config {
+	// TODO(bassosimone): this is a public working bridge we have found
+	// with @hellais. We should ask @phw whether there is some obfs4 bridge
+	// dedicated to integration testing that we should use instead.
+	return obfs4Config{
+		Address:      "109.105.109.165:10527",
+		StateBaseDir: "../../testdata/",
+		Params: map[string][]string{
+			"cert": {
+				"Bvg/itxeL4TWKLP6N1MaQzSOC6tcRIBv6q57DYAZc3b2AzuM+/TfB7mqTFEfXILCjEwzVA",
+			},
+			"iat-mode": {"1"},
+		},
+	}
+}
+
+func TestOBFS4ConnectGood(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip test in short mode")
+	}
+	netsaver := new(trace.Saver)
+	config := netx.Config{DialSaver: netsaver, ReadWriteSaver: netsaver}
+	results := obfs4Connect(context.Background(), config, obfs4config())
+	if results.Error != nil {
+		t.Fatal(results.Error)
+	}
+	if events := netsaver.Read(); len(events) <= 1 {
+		t.Fatal("expected connect, read, and write events here")
+	}
+}
+
+func TestOBFS4ConnectAfterHandshakeFailure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip test in short mode")
+	}
+	o4config := obfs4config()
+	expected := errors.New("mocked error")
+	o4config.AfterHandshake = func(conn net.Conn) error {
+		return expected
+	}
+	results := obfs4Connect(context.Background(), netx.Config{}, o4config)
+	if !errors.Is(results.Error, expected) {
+		t.Fatal("not the error we expected")
+	}
+	if results.HandshakeError != nil {
+		t.Fatal(results.HandshakeError)
+	}
+	if results.HandshakeDuration <= 0 {
+		t.Fatal("unexpected handshake duration")
+	}
+}
+
+func TestOBFS4IoutilTempDirError(t *testing.T) {
+	o4config := obfs4config()
+	expected := errors.New("mocked error")
+	o4config.ioutilTempDir = func(dir, prefix string) (string, error) {
+		return "", expected
+	}
+	results := obfs4Connect(context.Background(), netx.Config{}, o4config)
+	if !errors.Is(results.Error, expected) {
+		t.Fatal("not the error that we expected")
+	}
+}
+
+func TestOBFS4TransportsGetError(t *testing.T) {
+	o4config := obfs4config()
+	expected := errors.New("mocked error")
+	o4config.transportsGet = func(name string) (obfs4base.Transport, error) {
+		return nil, expected
+	}
+	results := obfs4Connect(context.Background(), netx.Config{}, o4config)
+	if !errors.Is(results.Error, expected) {
+		t.Fatal("not the error that we expected")
+	}
+}
+
+func TestOBFS4ClientFactoryError(t *testing.T) {
+	o4config := obfs4config()
+	o4config.transportsGet = func(name string) (obfs4base.Transport, error) {
+		txp, err := tunnel.GetTransport(name)
+		if name == "obfs4" && txp != nil {
+			txp = &faketransport{txp: txp}
+		}
+		return txp, err
+	}
+	results := obfs4Connect(context.Background(), netx.Config{}, o4config)
+	if results.Error.Error() != "mocked ClientFactory error" {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestOBFS4ParseArgsError(t *testing.T) {
+	o4config := obfs4config()
+	o4config.Params = make(map[string][]string) // cause ParseArgs error
+	results := obfs4Connect(context.Background(), netx.Config{}, o4config)
+	if results.Error.Error() != "missing argument 'node-id'" {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestOBFS4DialContextError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // should cause DialContex to fail
+	netsaver := new(trace.Saver)
+	config := netx.Config{DialSaver: netsaver}
+	results := obfs4Connect(ctx, config, obfs4config())
+	if results.Error.Error() != "interrupted" {
+		t.Fatal("not the error we expected")
+	}
+	if results.HandshakeError == nil {
+		t.Fatal("expected a handshake error here")
+	}
+	events := netsaver.Read()
+	if len(events) != 1 || events[0].Name != "connect" {
+		t.Fatal("expected a single connect event here")
+	}
+}
+
+func TestOBFS4SetDeadlineError(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip test in short mode")
+	}
+	o4config := obfs4config()
+	o4config.setDeadline = func(net.Conn, time.Time) error {
+		return errors.New("mocked error")
+	}
+	results := obfs4Connect(context.Background(), netx.Config{}, o4config)
+	if !strings.HasSuffix(results.Error.Error(), "mocked error") {
+		t.Fatal("not the error we expected")
+	}
+}
+
+type faketransport struct {
+	txp obfs4base.Transport
+}
+
+func (txp *faketransport) Name() string {
+	return txp.txp.Name()
+}
+
+func (txp *faketransport) ClientFactory(stateDir string) (obfs4base.ClientFactory, error) {
+	return nil, errors.New("mocked ClientFactory error")
+}
+
+func (txp *faketransport) ServerFactory(stateDir string, args *goptlib.Args) (obfs4base.ServerFactory, error) {
+	return txp.txp.ServerFactory(stateDir, args)
+}
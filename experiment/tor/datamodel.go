@@ -0,0 +1,171 @@
+package tor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ooni/probe-engine/netx/archival"
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/trace"
+)
+
+// This file converts the events saved by netx into the tor data
+// format. We reuse the archival package where its output has the
+// same JSON representation of the data format that we emitted with
+// legacy code. Otherwise, we use a tor-specific type.
+
+// HTTPRequest is like archival.HTTPRequest except that it
+// does not contain the x_transport field.
+type HTTPRequest struct {
+	Body            archival.HTTPBody                    `json:"body"`
+	BodyIsTruncated bool                                 `json:"body_is_truncated"`
+	HeadersList     []archival.HTTPHeader                `json:"headers_list"`
+	Headers         map[string]archival.MaybeBinaryValue `json:"headers"`
+	Method          string                               `json:"method"`
+	Tor             archival.HTTPTor                     `json:"tor"`
+	URL             string                               `json:"url"`
+}
+
+// RequestEntry is like archival.RequestEntry except that it
+// does not contain the t field.
+type RequestEntry struct {
+	Failure       *string               `json:"failure"`
+	Request       HTTPRequest           `json:"request"`
+	Response      archival.HTTPResponse `json:"response"`
+	TransactionID int64                 `json:"transaction_id,omitempty"`
+}
+
+// TLSHandshake is like archival.TLSHandshake except that it does
+// not contain the no_tls_verify and server_name fields.
+type TLSHandshake struct {
+	CipherSuite        string                      `json:"cipher_suite"`
+	ConnID             int64                       `json:"conn_id,omitempty"`
+	Failure            *string                     `json:"failure"`
+	NegotiatedProtocol string                      `json:"negotiated_protocol"`
+	PeerCertificates   []archival.MaybeBinaryValue `json:"peer_certificates"`
+	T                  float64                     `json:"t"`
+	TLSVersion         string                      `json:"tls_version"`
+	TransactionID      int64                       `json:"transaction_id,omitempty"`
+}
+
+// targetEvents contains the events saved while measuring a target.
+type targetEvents struct {
+	// Beginning is the time relative to which we compute t.
+	Beginning time.Time
+
+	// LowLevel indicates whether we should also include
+	// the read and write events into the network events.
+	LowLevel bool
+
+	// Network contains the connect, read, and write events.
+	Network []trace.Event
+
+	// Others contains all the other events.
+	Others []trace.Event
+}
+
+// fill fills the data format fields of the target results.
+func (te targetEvents) fill(tr *TargetResults, cm *connmapper) {
+	tr.Queries = archival.NewDNSQueriesList(te.Beginning, te.Others, "")
+	tr.Requests = te.newRequestList()
+	tr.TCPConnect = archival.NewTCPConnectList(te.Beginning, te.Network)
+	for idx := range tr.TCPConnect {
+		tr.TCPConnect[idx].ConnID = cm.scramble(tr.TCPConnect[idx].ConnID)
+	}
+	tr.TLSHandshakes = te.newTLSHandshakesList(cm)
+	if te.LowLevel {
+		tr.NetworkEvents = te.newNetworkEventsList(cm)
+	}
+}
+
+func (te targetEvents) newRequestList() (out []RequestEntry) {
+	for _, in := range archival.NewRequestList(te.Beginning, te.Others) {
+		out = append(out, RequestEntry{
+			Failure: in.Failure,
+			Request: HTTPRequest{
+				Body:            in.Request.Body,
+				BodyIsTruncated: in.Request.BodyIsTruncated,
+				HeadersList:     in.Request.HeadersList,
+				Headers:         in.Request.Headers,
+				Method:          in.Request.Method,
+				Tor:             in.Request.Tor,
+				URL:             in.Request.URL,
+			},
+			Response: archival.HTTPResponse{
+				Body:            in.Response.Body,
+				BodyIsTruncated: in.Response.BodyIsTruncated,
+				Code:            in.Response.Code,
+				HeadersList:     in.Response.HeadersList,
+				Headers:         in.Response.Headers,
+				Locations:       in.Response.Locations,
+			},
+			TransactionID: in.TransactionID,
+		})
+	}
+	return
+}
+
+func (te targetEvents) newTLSHandshakesList(cm *connmapper) (out []TLSHandshake) {
+	for _, in := range archival.NewTLSHandshakesList(te.Beginning, te.Others) {
+		out = append(out, TLSHandshake{
+			CipherSuite:        in.CipherSuite,
+			ConnID:             cm.scramble(in.ConnID),
+			Failure:            in.Failure,
+			NegotiatedProtocol: in.NegotiatedProtocol,
+			PeerCertificates:   in.PeerCertificates,
+			T:                  in.T,
+			TLSVersion:         in.TLSVersion,
+			TransactionID:      in.TransactionID,
+		})
+	}
+	return
+}
+
+// newNetworkEventsList mirrors the legacy code, which always included
+// the protocol and did not include the dial and transaction IDs in the
+// read and write events. We only use TCP, hence the protocol.
+func (te targetEvents) newNetworkEventsList(cm *connmapper) (out []archival.NetworkEvent) {
+	for _, ev := range archival.NewNetworkEventsList(te.Beginning, te.Network) {
+		ev.ConnID = cm.scramble(ev.ConnID)
+		ev.Proto = "tcp"
+		if ev.Operation != errorx.ConnectOperation {
+			ev.DialID, ev.TransactionID = 0, 0
+		}
+		out = append(out, ev)
+	}
+	return
+}
+
+// connmapper maps a ConnID to a different number to avoid emitting
+// the local port numbers. We preserve the sign because it's used to
+// distinguish between TCP (positive) and UDP (negative). A special
+// case is zero, which is always mapped to zero, since the zero
+// ConnID means "unknown" in netx code.
+type connmapper struct {
+	counter int64
+	mu      sync.Mutex
+	table   map[int64]int64
+}
+
+func (m *connmapper) scramble(cid int64) int64 {
+	if cid == 0 {
+		return 0
+	}
+	// See https://stackoverflow.com/a/38140573/4354461
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if value, found := m.table[cid]; found {
+		return value
+	}
+	if m.table == nil {
+		m.table = make(map[int64]int64)
+	}
+	var factor int64 = 1
+	if cid < 0 {
+		factor = -1
+	}
+	m.counter++ // we must never emit zero
+	value := factor * m.counter
+	m.table[cid] = value
+	return value
+}
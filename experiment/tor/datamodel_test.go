@@ -0,0 +1,212 @@
+package tor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/google/go-cmp/cmp"
+	"github.com/ooni/probe-engine/internal/mockable"
+	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/trace"
+)
+
+func TestConnmapper(t *testing.T) {
+	var mapper connmapper
+	if mapper.scramble(-1) >= 0 {
+		t.Fatal("unexpected value for negative input")
+	}
+	if mapper.scramble(1234) != 2 {
+		t.Fatal("unexpected second value")
+	}
+	if mapper.scramble(12) != 3 {
+		t.Fatal("unexpected third value")
+	}
+	if mapper.scramble(12) != mapper.scramble(12) {
+		t.Fatal("not idempotent")
+	}
+	if mapper.scramble(0) != 0 {
+		t.Fatal("unexpected value for zero input")
+	}
+}
+
+// goldenBeginning is the beginning of the golden measurement.
+var goldenBeginning = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// goldenAt returns the time of an event happening after the
+// given number of milliseconds since goldenBeginning.
+func goldenAt(millis int) time.Time {
+	return goldenBeginning.Add(time.Duration(millis) * time.Millisecond)
+}
+
+// goldenTargets maps each target to the events that netx would
+// save when measuring it. We set the IDs that netx would set, even
+// where the legacy data format does not include them, to make sure
+// that we only emit what the legacy code would have emitted.
+var goldenTargets = map[string]struct {
+	target model.TorTarget
+	events targetEvents
+	err    error
+}{
+	"dir_port": {
+		target: model.TorTarget{Address: "128.31.0.39:9131", Protocol: "dir_port"},
+		events: targetEvents{
+			Beginning: goldenBeginning,
+			Network: []trace.Event{{
+				Address:       "128.31.0.39:9131",
+				ConnID:        50123,
+				DialID:        1,
+				Name:          errorx.ConnectOperation,
+				Proto:         "tcp",
+				Time:          goldenAt(150),
+				TransactionID: 1,
+			}},
+			Others: []trace.Event{{
+				Name:          "http_transaction_start",
+				Time:          goldenAt(100),
+				TransactionID: 1,
+			}, {
+				HTTPHeaders:   http.Header{"Accept": {"*/*"}},
+				HTTPMethod:    "GET",
+				HTTPURL:       "http://128.31.0.39:9131/tor/status-vote/current/consensus.z",
+				Name:          "http_request_metadata",
+				Time:          goldenAt(100),
+				Transport:     "tcp",
+				TransactionID: 1,
+			}, {
+				HTTPHeaders:    http.Header{"Content-Type": {"application/octet-stream"}},
+				HTTPStatusCode: 200,
+				Name:           "http_response_metadata",
+				Time:           goldenAt(300),
+				TransactionID:  1,
+			}, {
+				Data:            []byte("x\x9c"),
+				DataIsTruncated: true,
+				Name:            "http_response_body_snapshot",
+				Time:            goldenAt(300),
+				TransactionID:   1,
+			}, {
+				Name:          "http_transaction_done",
+				Time:          goldenAt(300),
+				TransactionID: 1,
+			}},
+		},
+	},
+	"or_port": {
+		target: model.TorTarget{Address: "66.111.2.131:9001", Protocol: "or_port"},
+		events: targetEvents{
+			Beginning: goldenBeginning,
+			LowLevel:  true,
+			Network: []trace.Event{{
+				Address: "66.111.2.131:9001",
+				ConnID:  50124,
+				DialID:  2,
+				Name:    errorx.ConnectOperation,
+				Proto:   "tcp",
+				Time:    goldenAt(120),
+			}, {
+				ConnID:   50124,
+				DialID:   2,
+				Name:     errorx.WriteOperation,
+				NumBytes: 517,
+				Time:     goldenAt(121),
+			}, {
+				ConnID: 50124,
+				DialID: 2,
+				Err: &errorx.ErrWrapper{
+					Failure: errorx.FailureEOFError, Operation: errorx.ReadOperation},
+				Name: errorx.ReadOperation,
+				Time: goldenAt(250),
+			}},
+			Others: []trace.Event{{
+				Name:        "tls_handshake_start",
+				NoTLSVerify: true,
+				Time:        goldenAt(120),
+			}, {
+				ConnID: 50124,
+				Err: &errorx.ErrWrapper{
+					Failure: errorx.FailureEOFError, Operation: errorx.TLSHandshakeOperation},
+				Name:        "tls_handshake_done",
+				NoTLSVerify: true,
+				Time:        goldenAt(250),
+			}},
+		},
+		err: &errorx.ErrWrapper{
+			Failure: errorx.FailureEOFError, Operation: errorx.TLSHandshakeOperation},
+	},
+	"obfs4": {
+		target: model.TorTarget{Address: "192.95.36.142:443", Protocol: "obfs4"},
+		events: targetEvents{
+			Beginning: goldenBeginning,
+			LowLevel:  true,
+			Network: []trace.Event{{
+				Address: "192.95.36.142:443",
+				DialID:  3,
+				Err: &errorx.ErrWrapper{
+					Failure: errorx.FailureConnectionRefused, Operation: errorx.ConnectOperation},
+				Name:  errorx.ConnectOperation,
+				Proto: "tcp",
+				Time:  goldenAt(130),
+			}},
+		},
+		err: &errorx.ErrWrapper{
+			Failure: errorx.FailureConnectionRefused, Operation: errorx.ConnectOperation},
+	},
+	"or_port_tcp": {
+		target: model.TorTarget{Address: "66.111.2.131:9030", Protocol: "tcp"},
+		events: targetEvents{
+			Beginning: goldenBeginning,
+			Network: []trace.Event{{
+				Address: "66.111.2.131:9030",
+				ConnID:  50125,
+				DialID:  4,
+				Name:    errorx.ConnectOperation,
+				Proto:   "tcp",
+				Time:    goldenAt(140),
+			}},
+		},
+	},
+}
+
+// TestGoldenTestKeys checks whether the test keys we generate are
+// the same that the legacy implementation would have generated. The
+// testdata/testkeys.json file contains the output of the legacy
+// implementation for the legacy equivalent of goldenTargets, which
+// is in testdata/golden_legacy_test.go.txt. We generated it using the
+// legacy implementation at commit 841b828fa5c0895c34343f6a26372b110f5814b8
+// by running `./experiment/tor/testdata/golden_legacy.sh`.
+func TestGoldenTestKeys(t *testing.T) {
+	rc := newResultsCollector(
+		&mockable.Session{MockableLogger: log.Log},
+		&model.Measurement{MeasurementStartTimeSaved: goldenBeginning},
+		model.NewPrinterCallbacks(log.Log),
+	)
+	rc.flexibleConnect = func(ctx context.Context, kt keytarget) (targetEvents, handshakeResults, error) {
+		golden := goldenTargets[kt.key]
+		return golden.events, handshakeResults{}, golden.err
+	}
+	// Measure in a well defined order so that conn IDs are deterministic.
+	for _, key := range []string{"dir_port", "obfs4", "or_port", "or_port_tcp"} {
+		kt := keytarget{key: key, target: goldenTargets[key].target}
+		rc.measureSingleTarget(context.Background(), kt, len(goldenTargets))
+	}
+	tk := &TestKeys{Targets: rc.targetresults}
+	tk.fillToplevelKeys()
+	data, err := json.MarshalIndent(tk, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile("testdata/testkeys.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(bytes.TrimSpace(expected)), string(data)); diff != "" {
+		t.Fatal(diff)
+	}
+}
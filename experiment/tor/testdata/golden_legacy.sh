@@ -0,0 +1,21 @@
+#!/bin/sh
+# Regenerates testkeys.json using the legacy implementation of the tor
+# experiment. Run this script from the toplevel directory of the repository.
+set -ex
+# The legacy implementation is in the parent of the commit that removed
+# the legacy packages, i.e., of the last commit touching them.
+legacy=$(git rev-list -1 HEAD -- legacy/oonitemplates)^
+worktree=$(mktemp -d)
+trap 'git worktree remove --force $worktree' EXIT
+git worktree add --detach $worktree $legacy
+sed '/+build ignore/d' experiment/tor/testdata/golden_legacy_test.go.txt \
+  > $worktree/experiment/tor/golden_legacy_test.go
+# The generator needs to feed the legacy events to oonitemplates.
+cat >> $worktree/legacy/oonitemplates/oonitemplates.go << EOF
+
+func (r *Results) OnMeasurementForGolden(m modelx.Measurement, lowLevel bool) {
+	r.onMeasurement(m, lowLevel)
+}
+EOF
+(cd $worktree && go test -count=1 -run TestGenerateGolden ./experiment/tor)
+cp $worktree/experiment/tor/testdata/testkeys.json experiment/tor/testdata/
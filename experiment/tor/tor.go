@@ -8,18 +8,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"sync"
 	"time"
 
 	"github.com/ooni/probe-engine/atomicx"
-	"github.com/ooni/probe-engine/internal/httpheader"
 	"github.com/ooni/probe-engine/internal/runtimex"
-	"github.com/ooni/probe-engine/legacy/netxlogger"
-	"github.com/ooni/probe-engine/legacy/oonidatamodel"
-	"github.com/ooni/probe-engine/legacy/oonitemplates"
 	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx"
+	"github.com/ooni/probe-engine/netx/archival"
 	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/trace"
 )
 
 const (
@@ -30,11 +30,27 @@ const (
 	testName = "tor"
 
 	// testVersion is the version of this experiment
-	testVersion = "0.3.0"
+	testVersion = "0.4.0"
 )
 
 // Config contains the experiment config.
-type Config struct{}
+type Config struct {
+	TorLinkHandshake bool `ooni:"After the obfs4 handshake, also perform a Tor link handshake"`
+}
+
+// HandshakeResult contains the result of a handshake with a bridge.
+type HandshakeResult struct {
+	Duration float64 `json:"duration"`
+	Failure  *string `json:"failure"`
+	T        float64 `json:"t"`
+}
+
+// handshakeResults contains the results of the handshakes
+// we performed with a bridge, if any.
+type handshakeResults struct {
+	obfs4   *HandshakeResult
+	torLink *HandshakeResult
+}
 
 // Summary contains a summary of what happened.
 type Summary struct {
@@ -43,26 +59,28 @@ type Summary struct {
 
 // TargetResults contains the results of measuring a target.
 type TargetResults struct {
-	Agent          string                          `json:"agent"`
-	Failure        *string                         `json:"failure"`
-	NetworkEvents  oonidatamodel.NetworkEventsList `json:"network_events"`
-	Queries        oonidatamodel.DNSQueriesList    `json:"queries"`
-	Requests       oonidatamodel.RequestList       `json:"requests"`
-	Summary        map[string]Summary              `json:"summary"`
-	TargetAddress  string                          `json:"target_address"`
-	TargetName     string                          `json:"target_name,omitempty"`
-	TargetProtocol string                          `json:"target_protocol"`
-	TargetSource   string                          `json:"target_source,omitempty"`
-	TCPConnect     oonidatamodel.TCPConnectList    `json:"tcp_connect"`
-	TLSHandshakes  oonidatamodel.TLSHandshakesList `json:"tls_handshakes"`
+	Agent            string                     `json:"agent"`
+	Failure          *string                    `json:"failure"`
+	NetworkEvents    []archival.NetworkEvent    `json:"network_events"`
+	OBFS4Handshake   *HandshakeResult           `json:"obfs4_handshake,omitempty"`
+	Queries          []archival.DNSQueryEntry   `json:"queries"`
+	Requests         []RequestEntry             `json:"requests"`
+	Summary          map[string]Summary         `json:"summary"`
+	TargetAddress    string                     `json:"target_address"`
+	TargetName       string                     `json:"target_name,omitempty"`
+	TargetProtocol   string                     `json:"target_protocol"`
+	TargetSource     string                     `json:"target_source,omitempty"`
+	TCPConnect       []archival.TCPConnectEntry `json:"tcp_connect"`
+	TLSHandshakes    []TLSHandshake             `json:"tls_handshakes"`
+	TorLinkHandshake *HandshakeResult           `json:"tor_link_handshake,omitempty"`
 }
 
 func registerExtensions(m *model.Measurement) {
-	oonidatamodel.ExtHTTP.AddTo(m)
-	oonidatamodel.ExtNetevents.AddTo(m)
-	oonidatamodel.ExtDNS.AddTo(m)
-	oonidatamodel.ExtTCPConnect.AddTo(m)
-	oonidatamodel.ExtTLSHandshake.AddTo(m)
+	archival.ExtHTTP.AddTo(m)
+	archival.ExtNetevents.AddTo(m)
+	archival.ExtDNS.AddTo(m)
+	archival.ExtTCPConnect.AddTo(m)
+	archival.ExtTLSHandshake.AddTo(m)
 }
 
 // fillSummary fills the Summary field used by the UI.
@@ -79,10 +97,19 @@ func (tr *TargetResults) fillSummary() {
 		// The UI currently doesn't care about this protocol
 		// as long as drawing a table is concerned.
 	case "obfs4":
-		// We currently only perform an OBFS4 handshake, hence
-		// the final Failure is the handshake result
+		// The final Failure is the OBFS4 handshake result unless we
+		// also performed a Tor link handshake over OBFS4
+		failure := tr.Failure
+		if tr.OBFS4Handshake != nil {
+			failure = tr.OBFS4Handshake.Failure
+		}
 		tr.Summary["handshake"] = Summary{
-			Failure: tr.Failure,
+			Failure: failure,
+		}
+		if tr.TorLinkHandshake != nil {
+			tr.Summary["link_handshake"] = Summary{
+				Failure: tr.TorLinkHandshake.Failure,
+			}
 		}
 	case "or_port_dirauth", "or_port":
 		if len(tr.TLSHandshakes) < 1 {
@@ -100,6 +127,7 @@ type TestKeys struct {
 	DirPortAccessible       int64                    `json:"dir_port_accessible"`
 	OBFS4Total              int64                    `json:"obfs4_total"`
 	OBFS4Accessible         int64                    `json:"obfs4_accessible"`
+	OBFS4Usable             int64                    `json:"obfs4_usable"`
 	ORPortDirauthTotal      int64                    `json:"or_port_dirauth_total"`
 	ORPortDirauthAccessible int64                    `json:"or_port_dirauth_accessible"`
 	ORPortTotal             int64                    `json:"or_port_total"`
@@ -117,9 +145,18 @@ func (tk *TestKeys) fillToplevelKeys() {
 			}
 		case "obfs4":
 			tk.OBFS4Total++
-			if value.Failure == nil {
+			if value.OBFS4Handshake != nil {
+				if value.OBFS4Handshake.Failure == nil {
+					tk.OBFS4Accessible++
+				}
+			} else if value.Failure == nil {
 				tk.OBFS4Accessible++
 			}
+			// A bridge is usable when we can speak Tor with it, which
+			// we only know when we perform the Tor link handshake.
+			if value.TorLinkHandshake != nil && value.TorLinkHandshake.Failure == nil {
+				tk.OBFS4Usable++
+			}
 		case "or_port_dirauth":
 			tk.ORPortDirauthTotal++
 			if value.Failure == nil {
@@ -228,6 +265,7 @@ func (m *Measurer) measureTargets(
 	// run measurements in parallel
 	var waitgroup sync.WaitGroup
 	rc := newResultsCollector(sess, measurement, callbacks)
+	rc.config = m.config
 	waitgroup.Add(len(targets))
 	workch := make(chan keytarget)
 	for i := 0; i < parallelism; i++ {
@@ -251,8 +289,10 @@ func (m *Measurer) measureTargets(
 
 type resultsCollector struct {
 	callbacks       model.ExperimentCallbacks
+	cm              connmapper
 	completed       *atomicx.Int64
-	flexibleConnect func(context.Context, keytarget) (oonitemplates.Results, error)
+	config          Config
+	flexibleConnect func(context.Context, keytarget) (targetEvents, handshakeResults, error)
 	measurement     *model.Measurement
 	mu              sync.Mutex
 	sess            model.ExperimentSession
@@ -294,16 +334,14 @@ func maybeSanitize(input TargetResults, kt keytarget) TargetResults {
 func (rc *resultsCollector) measureSingleTarget(
 	ctx context.Context, kt keytarget, total int,
 ) {
-	tk, err := rc.flexibleConnect(ctx, kt)
+	te, hr, err := rc.flexibleConnect(ctx, kt)
 	tr := TargetResults{
-		Agent:         "redirect",
-		Failure:       setFailure(err),
-		NetworkEvents: oonidatamodel.NewNetworkEventsList(tk),
-		Queries:       oonidatamodel.NewDNSQueriesList(tk),
-		Requests:      oonidatamodel.NewRequestList(tk),
-		TCPConnect:    oonidatamodel.NewTCPConnectList(tk),
-		TLSHandshakes: oonidatamodel.NewTLSHandshakesList(tk),
+		Agent:            "redirect",
+		Failure:          setFailure(err),
+		OBFS4Handshake:   hr.obfs4,
+		TorLinkHandshake: hr.torLink,
 	}
+	te.fill(&tr, &rc.cm)
 	tr.fillSummary()
 	tr = maybeSanitize(tr, kt)
 	rc.mu.Lock()
@@ -366,8 +404,21 @@ func maybeScrubbingLogger(input model.Logger, kt keytarget) model.Logger {
 
 func (rc *resultsCollector) defaultFlexibleConnect(
 	ctx context.Context, kt keytarget,
-) (tk oonitemplates.Results, err error) {
-	logger := maybeScrubbingLogger(rc.sess.Logger(), kt)
+) (te targetEvents, hr handshakeResults, err error) {
+	te.Beginning = rc.measurement.MeasurementStartTimeSaved
+	if te.Beginning.IsZero() {
+		te.Beginning = time.Now()
+	}
+	// We save the connect, read, and write events into netsaver and all
+	// the other events into saver, so it's easy to convert them.
+	netsaver, saver := new(trace.Saver), new(trace.Saver)
+	defer func() {
+		te.Network, te.Others = netsaver.Read(), saver.Read()
+	}()
+	config := netx.Config{
+		DialSaver: netsaver,
+		Logger:    maybeScrubbingLogger(rc.sess.Logger(), kt),
+	}
 	switch kt.target.Protocol {
 	case "dir_port":
 		url := url.URL{
@@ -376,46 +427,61 @@ func (rc *resultsCollector) defaultFlexibleConnect(
 			Scheme: "http",
 		}
 		const snapshotsize = 1 << 8 // no need to include all in report
-		r := oonitemplates.HTTPDo(ctx, oonitemplates.HTTPDoConfig{
-			Accept:                  httpheader.Accept(),
-			AcceptLanguage:          httpheader.AcceptLanguage(),
-			Beginning:               rc.measurement.MeasurementStartTimeSaved,
-			MaxEventsBodySnapSize:   snapshotsize,
-			MaxResponseBodySnapSize: snapshotsize,
-			Handler:                 netxlogger.NewHandler(logger),
-			Method:                  "GET",
-			URL:                     url.String(),
-			UserAgent:               httpheader.UserAgent(),
-		})
-		tk, err = r.TestKeys, r.Error
+		err = httpGet(ctx, config, saver, url.String(), snapshotsize)
 	case "or_port", "or_port_dirauth":
-		r := oonitemplates.TLSConnect(ctx, oonitemplates.TLSConnectConfig{
-			Address:            kt.target.Address,
-			Beginning:          rc.measurement.MeasurementStartTimeSaved,
-			InsecureSkipVerify: true,
-			Handler:            netxlogger.NewHandler(logger),
-		})
-		tk, err = r.TestKeys, r.Error
+		te.LowLevel = true
+		config.NoTLSVerify = true
+		config.ReadWriteSaver = netsaver
+		config.TLSSaver = saver
+		err = tlsConnect(ctx, config, kt.target.Address)
 	case "obfs4":
-		r := oonitemplates.OBFS4Connect(ctx, oonitemplates.OBFS4ConnectConfig{
+		te.LowLevel = true
+		config.ReadWriteSaver = netsaver
+		beginning := rc.measurement.MeasurementStartTimeSaved
+		o4config := obfs4Config{
 			Address:      kt.target.Address,
-			Beginning:    rc.measurement.MeasurementStartTimeSaved,
-			Handler:      netxlogger.NewHandler(logger),
 			Params:       kt.target.Params,
 			StateBaseDir: rc.sess.TempDir(),
-		})
-		tk, err = r.TestKeys, r.Error
+		}
+		var obfs4Done time.Time
+		o4config.AfterHandshake = func(conn net.Conn) error {
+			obfs4Done = time.Now()
+			if !rc.config.TorLinkHandshake {
+				return nil
+			}
+			err := torLinkHandshake(ctx, conn)
+			hr.torLink = &HandshakeResult{
+				Duration: time.Since(obfs4Done).Seconds(),
+				Failure:  setFailure(err),
+				T:        elapsed(beginning, time.Now()),
+			}
+			return err
+		}
+		r := obfs4Connect(ctx, config, o4config)
+		err = r.Error
+		if obfs4Done.IsZero() {
+			obfs4Done = time.Now() // the handshake failed
+		}
+		hr.obfs4 = &HandshakeResult{
+			Duration: r.HandshakeDuration.Seconds(),
+			Failure:  setFailure(r.HandshakeError),
+			T:        elapsed(beginning, obfs4Done),
+		}
 	default:
-		r := oonitemplates.TCPConnect(ctx, oonitemplates.TCPConnectConfig{
-			Address:   kt.target.Address,
-			Beginning: rc.measurement.MeasurementStartTimeSaved,
-			Handler:   netxlogger.NewHandler(logger),
-		})
-		tk, err = r.TestKeys, r.Error
+		err = tcpConnect(ctx, config, kt.target.Address)
 	}
 	return
 }
 
+// elapsed returns the seconds elapsed between beginning and t, or
+// zero if we don't know when the measurement began.
+func elapsed(beginning, t time.Time) float64 {
+	if beginning.IsZero() {
+		return 0
+	}
+	return t.Sub(beginning).Seconds()
+}
+
 // NewExperimentMeasurer creates a new ExperimentMeasurer.
 func NewExperimentMeasurer(config Config) model.ExperimentMeasurer {
 	return NewMeasurer(config)
@@ -14,9 +14,8 @@ import (
 	"github.com/apex/log"
 	"github.com/google/go-cmp/cmp"
 	"github.com/ooni/probe-engine/internal/mockable"
-	"github.com/ooni/probe-engine/legacy/oonidatamodel"
-	"github.com/ooni/probe-engine/legacy/oonitemplates"
 	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx/archival"
 	"github.com/ooni/probe-engine/netx/errorx"
 	"github.com/ooni/probe-engine/probeservices"
 )
@@ -26,7 +25,7 @@ func TestNewExperimentMeasurer(t *testing.T) {
 	if measurer.ExperimentName() != "tor" {
 		t.Fatal("unexpected name")
 	}
-	if measurer.ExperimentVersion() != "0.3.0" {
+	if measurer.ExperimentVersion() != "0.4.0" {
 		t.Fatal("unexpected version")
 	}
 }
@@ -288,8 +287,8 @@ func TestResultsCollectorMeasureSingleTargetGood(t *testing.T) {
 		new(model.Measurement),
 		model.NewPrinterCallbacks(log.Log),
 	)
-	rc.flexibleConnect = func(context.Context, keytarget) (oonitemplates.Results, error) {
-		return oonitemplates.Results{}, nil
+	rc.flexibleConnect = func(context.Context, keytarget) (targetEvents, handshakeResults, error) {
+		return targetEvents{}, handshakeResults{}, nil
 	}
 	rc.measureSingleTarget(
 		context.Background(), wrapTestingTarget(staticTestingTargets[0]),
@@ -299,7 +298,7 @@ func TestResultsCollectorMeasureSingleTargetGood(t *testing.T) {
 		t.Fatal("wrong number of entries")
 	}
 	// Implementation note: here we won't bother with checking that
-	// oonidatamodel works correctly because we already test that.
+	// the data format is correct because we already test that.
 	if rc.targetresults["xx"].Agent != "redirect" {
 		t.Fatal("agent is invalid")
 	}
@@ -322,8 +321,8 @@ func TestResultsCollectorMeasureSingleTargetWithFailure(t *testing.T) {
 		new(model.Measurement),
 		model.NewPrinterCallbacks(log.Log),
 	)
-	rc.flexibleConnect = func(context.Context, keytarget) (oonitemplates.Results, error) {
-		return oonitemplates.Results{}, errors.New("mocked error")
+	rc.flexibleConnect = func(context.Context, keytarget) (targetEvents, handshakeResults, error) {
+		return targetEvents{}, handshakeResults{}, errors.New("mocked error")
 	}
 	rc.measureSingleTarget(
 		context.Background(), keytarget{
@@ -336,7 +335,7 @@ func TestResultsCollectorMeasureSingleTargetWithFailure(t *testing.T) {
 		t.Fatal("wrong number of entries")
 	}
 	// Implementation note: here we won't bother with checking that
-	// oonidatamodel works correctly because we already test that.
+	// the data format is correct because we already test that.
 	if rc.targetresults["xx"].Agent != "redirect" {
 		t.Fatal("agent is invalid")
 	}
@@ -361,14 +360,19 @@ func TestDefautFlexibleConnectDirPort(t *testing.T) {
 	)
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	tk, err := rc.defaultFlexibleConnect(ctx, wrapTestingTarget(staticTestingTargets[1]))
+	te, _, err := rc.defaultFlexibleConnect(ctx, wrapTestingTarget(staticTestingTargets[1]))
 	if err == nil {
 		t.Fatal("expected an error here")
 	}
 	if !strings.HasSuffix(err.Error(), "interrupted") {
 		t.Fatal("not the error we expected")
 	}
-	if tk.HTTPRequests == nil {
+	if te.LowLevel {
+		t.Fatal("did not expect low level data here")
+	}
+	var tr TargetResults
+	te.fill(&tr, new(connmapper))
+	if tr.Requests == nil {
 		t.Fatal("expected HTTP data here")
 	}
 }
@@ -383,17 +387,22 @@ func TestDefautFlexibleConnectOrPort(t *testing.T) {
 	)
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	tk, err := rc.defaultFlexibleConnect(ctx, wrapTestingTarget(staticTestingTargets[2]))
+	te, _, err := rc.defaultFlexibleConnect(ctx, wrapTestingTarget(staticTestingTargets[2]))
 	if err == nil {
 		t.Fatal("expected an error here")
 	}
 	if err.Error() != "interrupted" {
 		t.Fatal("not the error we expected")
 	}
-	if tk.Connects == nil {
+	if !te.LowLevel {
+		t.Fatal("expected low level data here")
+	}
+	var tr TargetResults
+	te.fill(&tr, new(connmapper))
+	if tr.TCPConnect == nil {
 		t.Fatal("expected connects data here")
 	}
-	if tk.NetworkEvents == nil {
+	if tr.NetworkEvents == nil {
 		t.Fatal("expected network events data here")
 	}
 }
@@ -408,17 +417,22 @@ func TestDefautFlexibleConnectOBFS4(t *testing.T) {
 	)
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	tk, err := rc.defaultFlexibleConnect(ctx, wrapTestingTarget(staticTestingTargets[0]))
+	te, _, err := rc.defaultFlexibleConnect(ctx, wrapTestingTarget(staticTestingTargets[0]))
 	if err == nil {
 		t.Fatal("expected an error here")
 	}
 	if err.Error() != "interrupted" {
 		t.Fatal("not the error we expected")
 	}
-	if tk.Connects == nil {
+	if !te.LowLevel {
+		t.Fatal("expected low level data here")
+	}
+	var tr TargetResults
+	te.fill(&tr, new(connmapper))
+	if tr.TCPConnect == nil {
 		t.Fatal("expected connects data here")
 	}
-	if tk.NetworkEvents == nil {
+	if tr.NetworkEvents == nil {
 		t.Fatal("expected network events data here")
 	}
 }
@@ -433,15 +447,20 @@ func TestDefautFlexibleConnectDefault(t *testing.T) {
 	)
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	tk, err := rc.defaultFlexibleConnect(ctx, wrapTestingTarget(staticTestingTargets[3]))
+	te, _, err := rc.defaultFlexibleConnect(ctx, wrapTestingTarget(staticTestingTargets[3]))
 	if err == nil {
 		t.Fatal("expected an error here")
 	}
 	if err.Error() != "interrupted" {
 		t.Fatalf("not the error we expected: %+v", err)
 	}
-	if tk.Connects == nil {
-		t.Fatalf("expected connects data here, found: %+v", tk.Connects)
+	var tr TargetResults
+	te.fill(&tr, new(connmapper))
+	if tr.TCPConnect == nil {
+		t.Fatalf("expected connects data here, found: %+v", tr.TCPConnect)
+	}
+	if tr.NetworkEvents != nil {
+		t.Fatal("did not expect network events data here")
 	}
 }
 
@@ -466,8 +485,8 @@ func TestSummary(t *testing.T) {
 	t.Run("with a TCP connect and nothing else", func(t *testing.T) {
 		tr := new(TargetResults)
 		failure := "mocked_error"
-		tr.TCPConnect = append(tr.TCPConnect, oonidatamodel.TCPConnectEntry{
-			Status: oonidatamodel.TCPConnectStatus{
+		tr.TCPConnect = append(tr.TCPConnect, archival.TCPConnectEntry{
+			Status: archival.TCPConnectStatus{
 				Success: true,
 				Failure: &failure,
 			},
@@ -483,8 +502,8 @@ func TestSummary(t *testing.T) {
 
 	t.Run("for OBFS4", func(t *testing.T) {
 		tr := new(TargetResults)
-		tr.TCPConnect = append(tr.TCPConnect, oonidatamodel.TCPConnectEntry{
-			Status: oonidatamodel.TCPConnectStatus{
+		tr.TCPConnect = append(tr.TCPConnect, archival.TCPConnectEntry{
+			Status: archival.TCPConnectStatus{
 				Success: true,
 			},
 		})
@@ -503,11 +522,35 @@ func TestSummary(t *testing.T) {
 		}
 	})
 
+	t.Run("for OBFS4 with link handshake", func(t *testing.T) {
+		tr := new(TargetResults)
+		tr.TCPConnect = append(tr.TCPConnect, archival.TCPConnectEntry{
+			Status: archival.TCPConnectStatus{
+				Success: true,
+			},
+		})
+		failure := "mocked_error"
+		tr.TargetProtocol = "obfs4"
+		tr.Failure = &failure
+		tr.OBFS4Handshake = &HandshakeResult{}
+		tr.TorLinkHandshake = &HandshakeResult{Failure: &failure}
+		tr.fillSummary()
+		if len(tr.Summary) != 3 {
+			t.Fatal("cannot find expected entry")
+		}
+		if tr.Summary["handshake"].Failure != nil {
+			t.Fatal("invalid failure")
+		}
+		if *tr.Summary["link_handshake"].Failure != failure {
+			t.Fatal("invalid failure")
+		}
+	})
+
 	t.Run("for or_port/or_port_dirauth", func(t *testing.T) {
-		doit := func(targetProtocol string, handshake *oonidatamodel.TLSHandshake) {
+		doit := func(targetProtocol string, handshake *TLSHandshake) {
 			tr := new(TargetResults)
-			tr.TCPConnect = append(tr.TCPConnect, oonidatamodel.TCPConnectEntry{
-				Status: oonidatamodel.TCPConnectStatus{
+			tr.TCPConnect = append(tr.TCPConnect, archival.TCPConnectEntry{
+				Status: archival.TCPConnectStatus{
 					Success: true,
 				},
 			})
@@ -537,7 +580,7 @@ func TestSummary(t *testing.T) {
 		}
 		doit("or_port_dirauth", nil)
 		doit("or_port", nil)
-		doit("or_port", &oonidatamodel.TLSHandshake{
+		doit("or_port", &TLSHandshake{
 			Failure: (func() *string {
 				s := io.EOF.Error()
 				return &s
@@ -558,6 +601,39 @@ func TestFillToplevelKeys(t *testing.T) {
 	}
 }
 
+func TestFillToplevelKeysOBFS4(t *testing.T) {
+	failure := "mocked_error"
+	tk := new(TestKeys)
+	tk.Targets = map[string]TargetResults{
+		"usable": {
+			TargetProtocol:   "obfs4",
+			OBFS4Handshake:   &HandshakeResult{},
+			TorLinkHandshake: &HandshakeResult{},
+		},
+		"accessible": {
+			Failure:          &failure,
+			TargetProtocol:   "obfs4",
+			OBFS4Handshake:   &HandshakeResult{},
+			TorLinkHandshake: &HandshakeResult{Failure: &failure},
+		},
+		"unreachable": {
+			Failure:        &failure,
+			TargetProtocol: "obfs4",
+			OBFS4Handshake: &HandshakeResult{Failure: &failure},
+		},
+	}
+	tk.fillToplevelKeys()
+	if tk.OBFS4Total != 3 {
+		t.Fatal("unexpected OBFS4Total value")
+	}
+	if tk.OBFS4Accessible != 2 {
+		t.Fatal("unexpected OBFS4Accessible value")
+	}
+	if tk.OBFS4Usable != 1 {
+		t.Fatal("unexpected OBFS4Usable value")
+	}
+}
+
 func newsession() *mockable.Session {
 	return &mockable.Session{
 		MockableLogger:     log.Log,
@@ -0,0 +1,106 @@
+package tor
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Tor cell commands we use. See tor-spec.txt section 3.
+const (
+	cellVersions = 7
+	cellCerts    = 129
+)
+
+// linkProtocolVersions are the link protocol versions we offer.
+var linkProtocolVersions = []uint16{3, 4, 5}
+
+// errNoCommonLinkVersion indicates that we don't share a link
+// protocol version with the bridge or relay.
+var errNoCommonLinkVersion = errors.New("tor: no common link protocol version")
+
+// torLinkHandshake performs the first part of the Tor link handshake
+// described in tor-spec.txt section 4: we complete the TLS handshake, we
+// exchange VERSIONS cells, and we receive the responder's CERTS cell. We
+// do not authenticate the responder, since we only want to know whether
+// a Tor relay is actually reachable over conn.
+func torLinkHandshake(ctx context.Context, conn net.Conn) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	tlsconn := tls.Client(conn, &tls.Config{
+		// Tor relays use self-signed certificates
+		InsecureSkipVerify: true,
+	})
+	if err := tlsconn.Handshake(); err != nil {
+		return err
+	}
+	// The VERSIONS cell always uses two-bytes circuit IDs.
+	payload := make([]byte, 2*len(linkProtocolVersions))
+	for idx, version := range linkProtocolVersions {
+		binary.BigEndian.PutUint16(payload[2*idx:], version)
+	}
+	cell := []byte{0, 0, cellVersions, 0, byte(len(payload))}
+	if _, err := tlsconn.Write(append(cell, payload...)); err != nil {
+		return err
+	}
+	command, payload, err := readVariableCell(tlsconn, 2)
+	if err != nil {
+		return err
+	}
+	if command != cellVersions {
+		return fmt.Errorf("tor: expected VERSIONS cell, got %d", command)
+	}
+	version := negotiateLinkVersion(payload)
+	if version == 0 {
+		return errNoCommonLinkVersion
+	}
+	circIDLen := 2
+	if version >= 4 {
+		circIDLen = 4
+	}
+	command, _, err = readVariableCell(tlsconn, circIDLen)
+	if err != nil {
+		return err
+	}
+	if command != cellCerts {
+		return fmt.Errorf("tor: expected CERTS cell, got %d", command)
+	}
+	return nil
+}
+
+// readVariableCell reads a variable-length cell and returns
+// its command and its payload.
+func readVariableCell(r io.Reader, circIDLen int) (byte, []byte, error) {
+	header := make([]byte, circIDLen+3)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	command := header[circIDLen]
+	length := binary.BigEndian.Uint16(header[circIDLen+1:])
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return command, payload, nil
+}
+
+// negotiateLinkVersion returns the highest version that we and
+// the responder support, or zero if there is no such version.
+func negotiateLinkVersion(payload []byte) (version uint16) {
+	for idx := 0; idx+1 < len(payload); idx += 2 {
+		theirs := binary.BigEndian.Uint16(payload[idx:])
+		for _, ours := range linkProtocolVersions {
+			if theirs == ours && theirs > version {
+				version = theirs
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,118 @@
+package tor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeRelay emulates the responder side of the Tor link handshake.
+func fakeRelay(t *testing.T, conn net.Conn, versions []byte, nextCommand byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	template := &x509.Certificate{
+		NotAfter:     time.Now().Add(time.Hour),
+		NotBefore:    time.Now().Add(-time.Hour),
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	tlsconn := tls.Server(conn, &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	defer tlsconn.Close()
+	if _, _, err := readVariableCell(tlsconn, 2); err != nil {
+		return
+	}
+	cell := []byte{0, 0, cellVersions, 0, byte(len(versions))}
+	tlsconn.Write(append(cell, versions...))
+	tlsconn.Write([]byte{0, 0, 0, 0, nextCommand, 0, 1, 0})
+}
+
+// socketpair returns two connected TCP connections. We don't use net.Pipe
+// because it's unbuffered and the TLS handshake may deadlock.
+func socketpair(t *testing.T) (net.Conn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func TestTorLinkHandshake(t *testing.T) {
+	t.Run("with a working relay", func(t *testing.T) {
+		client, server := socketpair(t)
+		go fakeRelay(t, server, []byte{0, 3, 0, 4}, cellCerts)
+		defer client.Close()
+		if err := torLinkHandshake(context.Background(), client); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("without common versions", func(t *testing.T) {
+		client, server := socketpair(t)
+		go fakeRelay(t, server, []byte{0, 1, 0, 2}, cellCerts)
+		defer client.Close()
+		err := torLinkHandshake(context.Background(), client)
+		if !errors.Is(err, errNoCommonLinkVersion) {
+			t.Fatal("not the error we expected", err)
+		}
+	})
+
+	t.Run("with an unexpected cell", func(t *testing.T) {
+		client, server := socketpair(t)
+		go fakeRelay(t, server, []byte{0, 4}, cellVersions)
+		defer client.Close()
+		if err := torLinkHandshake(context.Background(), client); err == nil {
+			t.Fatal("expected an error here")
+		}
+	})
+
+	t.Run("with a non-TLS peer", func(t *testing.T) {
+		client, server := socketpair(t)
+		go func() {
+			server.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+			server.Close()
+		}()
+		defer client.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := torLinkHandshake(ctx, client); err == nil {
+			t.Fatal("expected an error here")
+		}
+	})
+}
+
+func TestNegotiateLinkVersion(t *testing.T) {
+	if v := negotiateLinkVersion([]byte{0, 3, 0, 5, 0, 9}); v != 5 {
+		t.Fatal("unexpected version", v)
+	}
+	if v := negotiateLinkVersion([]byte{0, 9, 0}); v != 0 {
+		t.Fatal("unexpected version", v)
+	}
+}
@@ -1,7 +1,9 @@
 package urlgetter
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"net"
 	"net/url"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/ooni/probe-engine/model"
 	"github.com/ooni/probe-engine/netx"
+	"github.com/ooni/probe-engine/netx/httptransport"
 	"github.com/ooni/probe-engine/netx/trace"
 )
 
@@ -38,19 +41,30 @@ func (c Configurer) NewConfiguration() (Configuration, error) {
 	// set up defaults
 	configuration := Configuration{
 		HTTPConfig: netx.Config{
-			BogonIsError:        c.Config.RejectDNSBogons,
-			CacheResolutions:    true,
-			CertPool:            c.Config.CertPool,
-			ContextByteCounting: true,
-			DialSaver:           c.Saver,
-			HTTP3Enabled:        c.Config.HTTP3Enabled,
-			HTTPSaver:           c.Saver,
-			Logger:              c.Logger,
-			ReadWriteSaver:      c.Saver,
-			ResolveSaver:        c.Saver,
-			TLSSaver:            c.Saver,
+			BogonIsError:         c.Config.RejectDNSBogons,
+			ByteCounter:          c.Config.ByteCounter,
+			CacheResolutions:     true,
+			CertPool:             c.Config.CertPool,
+			ContextByteCounting:  true,
+			DialSaver:            c.Saver,
+			HTTP3Enabled:         c.Config.HTTP3Enabled,
+			HTTPBodyCapture:      httptransport.BodyCapture(c.Config.HTTPBodyCapture),
+			HTTPBodyDir:          c.Config.HTTPBodyDir,
+			HTTPBodySnapshotSize: int(c.Config.HTTPBodySnapshotSize),
+			HTTPSaver:            c.Saver,
+			Logger:               c.Logger,
+			ReadWriteSaver:       c.Saver,
+			ResolveSaver:         c.Saver,
+			Retries:              c.Config.netxRetries(),
+			TLSSaver:             c.Saver,
+			Timeouts:             c.Config.netxTimeouts(),
 		},
 	}
+	// the budget is shared by all the round trips of the measurement
+	if c.Config.HTTPBodyBudget > 0 {
+		configuration.HTTPConfig.HTTPBodyBudget = httptransport.NewBodyBudget(
+			c.Config.HTTPBodyBudget)
+	}
 	// fill DNS cache
 	if c.Config.DNSCache != "" {
 		entry := strings.Split(c.Config.DNSCache, " ")
@@ -72,6 +86,47 @@ func (c Configurer) NewConfiguration() (Configuration, error) {
 			entry[0]: addresses,
 		}
 	}
+	// pin the front domain to the fronting IPs
+	if c.Config.FrontingIPs != "" {
+		if c.Config.FrontingDomain == "" {
+			return configuration, errors.New("FrontingIPs requires FrontingDomain")
+		}
+		addresses := strings.Fields(c.Config.FrontingIPs)
+		for _, address := range addresses {
+			if net.ParseIP(address) == nil {
+				return configuration, errors.New("invalid IP in FrontingIPs")
+			}
+		}
+		if configuration.HTTPConfig.DNSCache == nil {
+			configuration.HTTPConfig.DNSCache = make(map[string][]string)
+		}
+		configuration.HTTPConfig.DNSCache[c.Config.FrontingDomain] = addresses
+	}
+	// load custom root CAs
+	if c.Config.RootCAs != "" {
+		pool, err := netx.NewCertPoolFromPEMFiles(
+			c.Config.RootCAsReplace, strings.Fields(c.Config.RootCAs)...)
+		if err != nil {
+			return configuration, err
+		}
+		configuration.HTTPConfig.CertPool = pool
+	}
+	// fill SPKI pins
+	if c.Config.SPKIPins != "" {
+		entry := strings.Split(c.Config.SPKIPins, " ")
+		if len(entry) < 2 {
+			return configuration, errors.New("invalid SPKIPins string")
+		}
+		for i := 1; i < len(entry); i++ {
+			pin, err := base64.StdEncoding.DecodeString(entry[i])
+			if err != nil || len(pin) != sha256.Size {
+				return configuration, errors.New("invalid pin in SPKIPins")
+			}
+		}
+		configuration.HTTPConfig.SPKIPins = map[string][]string{
+			entry[0]: entry[1:],
+		}
+	}
 	dnsclient, err := netx.NewDNSClientWithOverrides(
 		configuration.HTTPConfig, c.Config.ResolverURL,
 		c.Config.DNSHTTPHost, c.Config.DNSTLSServerName,
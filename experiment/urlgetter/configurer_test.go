@@ -2,15 +2,25 @@ package urlgetter_test
 
 import (
 	"crypto/tls"
+	"encoding/pem"
 	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/ooni/probe-engine/experiment/urlgetter"
 	"github.com/ooni/probe-engine/netx"
+	"github.com/ooni/probe-engine/netx/bytecounter"
+	"github.com/ooni/probe-engine/netx/httptransport"
 	"github.com/ooni/probe-engine/netx/resolver"
+	"github.com/ooni/probe-engine/netx/retry"
 	"github.com/ooni/probe-engine/netx/trace"
 )
 
@@ -448,6 +458,77 @@ func TestConfigurerNewConfigurationDNSCacheGood(t *testing.T) {
 	}
 }
 
+func TestConfigurerNewConfigurationFrontingIPsWithoutDomain(t *testing.T) {
+	configurer := urlgetter.Configurer{
+		Config: urlgetter.Config{
+			FrontingIPs: "8.8.8.8",
+		},
+		Logger: log.Log,
+		Saver:  new(trace.Saver),
+	}
+	_, err := configurer.NewConfiguration()
+	if err == nil || !strings.HasSuffix(err.Error(), "FrontingIPs requires FrontingDomain") {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestConfigurerNewConfigurationFrontingIPsNotIP(t *testing.T) {
+	configurer := urlgetter.Configurer{
+		Config: urlgetter.Config{
+			FrontingDomain: "a0.awsstatic.com",
+			FrontingIPs:    "8.8.8.8 b",
+		},
+		Logger: log.Log,
+		Saver:  new(trace.Saver),
+	}
+	_, err := configurer.NewConfiguration()
+	if err == nil || !strings.HasSuffix(err.Error(), "invalid IP in FrontingIPs") {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestConfigurerNewConfigurationFrontingIPsGood(t *testing.T) {
+	configurer := urlgetter.Configurer{
+		Config: urlgetter.Config{
+			DNSCache:       "dns.google.com 8.8.8.8",
+			FrontingDomain: "a0.awsstatic.com",
+			FrontingIPs:    "13.35.7.1 13.35.7.2",
+		},
+		Logger: log.Log,
+		Saver:  new(trace.Saver),
+	}
+	configuration, err := configurer.NewConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configuration.CloseIdleConnections()
+	if len(configuration.HTTPConfig.DNSCache) != 2 {
+		t.Fatal("invalid number of entries in DNSCache")
+	}
+	addresses := configuration.HTTPConfig.DNSCache["a0.awsstatic.com"]
+	if len(addresses) != 2 || addresses[0] != "13.35.7.1" || addresses[1] != "13.35.7.2" {
+		t.Fatal("invalid fronting IPs saved in DNSCache")
+	}
+}
+
+func TestConfigurerNewConfigurationWithByteCounter(t *testing.T) {
+	counter := bytecounter.New()
+	configurer := urlgetter.Configurer{
+		Config: urlgetter.Config{
+			ByteCounter: counter,
+		},
+		Logger: log.Log,
+	}
+	configuration, err := configurer.NewConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configuration.CloseIdleConnections()
+	if configuration.HTTPConfig.ByteCounter != counter {
+		t.Fatal("not the ByteCounter we expected")
+	}
+}
+
 func TestConfigurerNewConfigurationResolverInvalidURL(t *testing.T) {
 	saver := new(trace.Saver)
 	configurer := urlgetter.Configurer{
@@ -732,3 +813,192 @@ func TestConfigurerNewConfigurationProxyURL(t *testing.T) {
 		t.Fatal("invalid ProxyURL")
 	}
 }
+
+func TestConfigurerNewConfigurationTimeoutsAndRetries(t *testing.T) {
+	saver := new(trace.Saver)
+	configurer := urlgetter.Configurer{
+		Config: urlgetter.Config{
+			ConnectRetries:       1,
+			ConnectTimeout:       30000,
+			DNSRetries:           2,
+			DNSTimeout:           10000,
+			FirstByteTimeout:     20000,
+			IdleTimeout:          90000,
+			QUICHandshakeRetries: 3,
+			QUICHandshakeTimeout: 25000,
+			RetryBackoff:         500,
+			TLSHandshakeRetries:  4,
+			TLSHandshakeTimeout:  15000,
+			TotalTimeout:         120000,
+		},
+		Logger: log.Log,
+		Saver:  saver,
+	}
+	configuration, err := configurer.NewConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configuration.CloseIdleConnections()
+	expectedTimeouts := netx.Timeouts{
+		Connect:       30 * time.Second,
+		DNS:           10 * time.Second,
+		FirstByte:     20 * time.Second,
+		Idle:          90 * time.Second,
+		QUICHandshake: 25 * time.Second,
+		TLSHandshake:  15 * time.Second,
+		Total:         2 * time.Minute,
+	}
+	if configuration.HTTPConfig.Timeouts != expectedTimeouts {
+		t.Fatal("not the Timeouts we expected")
+	}
+	backoff := 500 * time.Millisecond
+	expectedRetries := netx.Retries{
+		Connect:       retry.Policy{Backoff: backoff, MaxRetries: 1},
+		DNS:           retry.Policy{Backoff: backoff, MaxRetries: 2},
+		QUICHandshake: retry.Policy{Backoff: backoff, MaxRetries: 3},
+		TLSHandshake:  retry.Policy{Backoff: backoff, MaxRetries: 4},
+	}
+	if configuration.HTTPConfig.Retries != expectedRetries {
+		t.Fatal("not the Retries we expected")
+	}
+}
+
+func TestConfigurerNewConfigurationBodyCapture(t *testing.T) {
+	saver := new(trace.Saver)
+	configurer := urlgetter.Configurer{
+		Config: urlgetter.Config{
+			HTTPBodyBudget:       1 << 20,
+			HTTPBodyCapture:      "file",
+			HTTPBodyDir:          "/tmp/bodies",
+			HTTPBodySnapshotSize: 1 << 10,
+		},
+		Logger: log.Log,
+		Saver:  saver,
+	}
+	configuration, err := configurer.NewConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configuration.CloseIdleConnections()
+	if configuration.HTTPConfig.HTTPBodyCapture != httptransport.BodyCaptureFile {
+		t.Fatal("not the HTTPBodyCapture we expected")
+	}
+	if configuration.HTTPConfig.HTTPBodyDir != "/tmp/bodies" {
+		t.Fatal("not the HTTPBodyDir we expected")
+	}
+	if configuration.HTTPConfig.HTTPBodySnapshotSize != 1<<10 {
+		t.Fatal("not the HTTPBodySnapshotSize we expected")
+	}
+	if configuration.HTTPConfig.HTTPBodyBudget.Remaining() != 1<<20 {
+		t.Fatal("not the HTTPBodyBudget we expected")
+	}
+}
+
+func TestConfigurerNewConfigurationNoBodyBudget(t *testing.T) {
+	configurer := urlgetter.Configurer{
+		Logger: log.Log,
+		Saver:  new(trace.Saver),
+	}
+	configuration, err := configurer.NewConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configuration.CloseIdleConnections()
+	if configuration.HTTPConfig.HTTPBodyBudget != nil {
+		t.Fatal("expected no HTTPBodyBudget")
+	}
+}
+
+func TestConfigurerNewConfigurationRootCAs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "urlgetter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	path := filepath.Join(dir, "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	configurer := urlgetter.Configurer{
+		Config: urlgetter.Config{
+			RootCAs:        path,
+			RootCAsReplace: true,
+		},
+		Logger: log.Log,
+		Saver:  new(trace.Saver),
+	}
+	configuration, err := configurer.NewConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configuration.CloseIdleConnections()
+	if len(configuration.HTTPConfig.CertPool.Subjects()) != 1 {
+		t.Fatal("not the CertPool we expected")
+	}
+}
+
+func TestConfigurerNewConfigurationRootCAsNonexistentFile(t *testing.T) {
+	configurer := urlgetter.Configurer{
+		Config: urlgetter.Config{
+			RootCAs: "/nonexistent/ca.pem",
+		},
+		Logger: log.Log,
+		Saver:  new(trace.Saver),
+	}
+	_, err := configurer.NewConfiguration()
+	if !os.IsNotExist(err) {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestConfigurerNewConfigurationSPKIPinsInvalidString(t *testing.T) {
+	configurer := urlgetter.Configurer{
+		Config: urlgetter.Config{
+			SPKIPins: "x.org",
+		},
+		Logger: log.Log,
+		Saver:  new(trace.Saver),
+	}
+	_, err := configurer.NewConfiguration()
+	if err == nil || !strings.HasSuffix(err.Error(), "invalid SPKIPins string") {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestConfigurerNewConfigurationSPKIPinsInvalidPin(t *testing.T) {
+	configurer := urlgetter.Configurer{
+		Config: urlgetter.Config{
+			SPKIPins: "x.org antani",
+		},
+		Logger: log.Log,
+		Saver:  new(trace.Saver),
+	}
+	_, err := configurer.NewConfiguration()
+	if err == nil || !strings.HasSuffix(err.Error(), "invalid pin in SPKIPins") {
+		t.Fatal("not the error we expected")
+	}
+}
+
+func TestConfigurerNewConfigurationSPKIPinsGood(t *testing.T) {
+	const pin = "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	configurer := urlgetter.Configurer{
+		Config: urlgetter.Config{
+			SPKIPins: "x.org " + pin,
+		},
+		Logger: log.Log,
+		Saver:  new(trace.Saver),
+	}
+	configuration, err := configurer.NewConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer configuration.CloseIdleConnections()
+	pins := configuration.HTTPConfig.SPKIPins["x.org"]
+	if len(pins) != 1 || pins[0] != pin {
+		t.Fatal("not the SPKIPins we expected")
+	}
+}
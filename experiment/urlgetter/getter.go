@@ -2,10 +2,13 @@ package urlgetter
 
 import (
 	"context"
+	"fmt"
 	"net/url"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/ooni/probe-engine/internal/torx"
 	"github.com/ooni/probe-engine/internal/tunnel"
 	"github.com/ooni/probe-engine/model"
 	"github.com/ooni/probe-engine/netx/archival"
@@ -80,9 +83,26 @@ func (g Getter) Get(ctx context.Context) (TestKeys, error) {
 	tk.TLSHandshakes = append(
 		tk.TLSHandshakes, archival.NewTLSHandshakesList(g.Begin, events)...,
 	)
+	tk.TLSInterception = archival.TLSInterception(tk.TLSHandshakes)
 	return tk, err
 }
 
+// bootstrapReporter is implemented by tor based tunnels.
+type bootstrapReporter interface {
+	BootstrapReport() *torx.BootstrapReport
+}
+
+// isTunnelName returns whether name is the name of a registered tunnel. We
+// do not use a static enum because more tunnels may be registered.
+func isTunnelName(name string) bool {
+	for _, candidate := range tunnel.Names() {
+		if name == candidate {
+			return true
+		}
+	}
+	return false
+}
+
 func (g Getter) get(ctx context.Context, saver *trace.Saver) (TestKeys, error) {
 	tk := TestKeys{
 		Agent:  "redirect",
@@ -97,15 +117,33 @@ func (g Getter) get(ctx context.Context, saver *trace.Saver) (TestKeys, error) {
 	// start tunnel
 	var proxyURL *url.URL
 	if g.Config.Tunnel != "" {
-		tun, err := tunnel.Start(ctx, tunnel.Config{
+		if !isTunnelName(g.Config.Tunnel) {
+			return tk, fmt.Errorf("urlgetter: unsupported tunnel: %s (use one of: %s)",
+				g.Config.Tunnel, strings.Join(tunnel.Names(), ", "))
+		}
+		config := tunnel.Config{
 			Name:    g.Config.Tunnel,
 			Session: g.Session,
 			WorkDir: filepath.Join(g.Session.TempDir(), "urlgetter-tunnel"),
-		})
+		}
+		if g.Config.TunnelBridge != "" {
+			config.Bridges = []string{g.Config.TunnelBridge}
+		}
+		if g.Config.TunnelProxy != "" {
+			URL, err := url.Parse(g.Config.TunnelProxy)
+			if err != nil {
+				return tk, err
+			}
+			config.UpstreamProxy = URL
+		}
+		tun, err := tunnel.Start(ctx, config)
 		if err != nil {
 			return tk, err
 		}
 		tk.BootstrapTime = tun.BootstrapTime().Seconds()
+		if reporter, ok := tun.(bootstrapReporter); ok {
+			tk.TorBootstrap = reporter.BootstrapReport()
+		}
 		proxyURL = tun.SOCKS5ProxyURL()
 		tk.SOCKSProxy = proxyURL.String()
 		defer tun.Stop()
@@ -10,6 +10,7 @@ import (
 	"github.com/apex/log"
 	"github.com/ooni/probe-engine/experiment/urlgetter"
 	"github.com/ooni/probe-engine/internal/mockable"
+	"github.com/ooni/probe-engine/internal/tunnel"
 	"github.com/ooni/probe-engine/netx/errorx"
 )
 
@@ -286,6 +287,44 @@ func TestGetterWithCancelledContextNoFollowRedirects(t *testing.T) {
 	}
 }
 
+func TestGetterWithUnsupportedTunnel(t *testing.T) {
+	g := urlgetter.Getter{
+		Config: urlgetter.Config{
+			Tunnel: "antani",
+		},
+		Session: &mockable.Session{MockableLogger: log.Log},
+		Target:  "https://www.google.com",
+	}
+	tk, err := g.Get(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "urlgetter: unsupported tunnel: antani") {
+		t.Fatalf("not the error we expected: %+v", err)
+	}
+	if tk.Failure == nil || len(tk.NetworkEvents) != 0 {
+		t.Fatal("not the TestKeys we expected")
+	}
+}
+
+func TestGetterWithRegisteredTunnel(t *testing.T) {
+	expected := errors.New("mocked error")
+	err := tunnel.Register("urlgetter-test", func(
+		ctx context.Context, config tunnel.Config) (tunnel.Tunnel, error) {
+		return nil, expected
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := urlgetter.Getter{
+		Config: urlgetter.Config{
+			Tunnel: "urlgetter-test",
+		},
+		Session: &mockable.Session{MockableLogger: log.Log},
+		Target:  "https://www.google.com",
+	}
+	if _, err := g.Get(context.Background()); !errors.Is(err, expected) {
+		t.Fatalf("not the error we expected: %+v", err)
+	}
+}
+
 func TestGetterWithCancelledContextCannotStartTunnel(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // fail immediately
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -69,6 +70,9 @@ func (r Runner) httpGet(ctx context.Context, url string) error {
 	req.Header.Set("Accept", httpheader.Accept())
 	req.Header.Set("Accept-Language", httpheader.AcceptLanguage())
 	req.Header.Set("User-Agent", MaybeUserAgent(r.Config.UserAgent))
+	if r.Config.FrontingDomain != "" {
+		frontRequest(req, r.Config.FrontingDomain)
+	}
 	if r.Config.HTTPHost != "" {
 		req.Host = r.Config.HTTPHost
 	}
@@ -85,6 +89,18 @@ func (r Runner) httpGet(ctx context.Context, url string) error {
 		httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
 			return http.ErrUseLastResponse
 		}
+	} else if r.Config.FrontingDomain != "" {
+		httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			// Relative redirects keep the fronted URL and the Host header
+			// of the previous request, while absolute redirects do not.
+			if req.Host == "" {
+				frontRequest(req, r.Config.FrontingDomain)
+			}
+			return nil
+		}
 	}
 	defer httpClient.CloseIdleConnections()
 	resp, err := httpClient.Do(req)
@@ -104,6 +120,17 @@ func (r Runner) httpGet(ctx context.Context, url string) error {
 	return nil
 }
 
+// frontRequest implements domain fronting: we connect to the front, which
+// we also use as the SNI, and we put the real domain in the Host header.
+func frontRequest(req *http.Request, front string) {
+	port := req.URL.Port()
+	req.Host = req.URL.Host
+	req.URL.Host = front
+	if port != "" {
+		req.URL.Host = net.JoinHostPort(front, port)
+	}
+}
+
 func (r Runner) dnsLookup(ctx context.Context, hostname string) error {
 	resolver := netx.NewResolver(r.HTTPConfig)
 	_, err := resolver.LookupHost(ctx, hostname)
@@ -6,9 +6,11 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/ooni/probe-engine/atomicx"
 	"github.com/ooni/probe-engine/experiment/urlgetter"
 	"github.com/ooni/probe-engine/internal/httpheader"
@@ -144,6 +146,75 @@ func TestRunnerHTTPSetHostHeader(t *testing.T) {
 	}
 }
 
+func TestRunnerHTTPDomainFronting(t *testing.T) {
+	var host, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, path = r.Host, r.URL.Path
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+	URL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := urlgetter.Runner{
+		Config: urlgetter.Config{
+			FrontingDomain: URL.Hostname(),
+		},
+		Target: "http://x.org:" + URL.Port() + "/antani",
+	}
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if host != "x.org:"+URL.Port() {
+		t.Fatal("not the host we expected")
+	}
+	if path != "/antani" {
+		t.Fatal("not the path we expected")
+	}
+}
+
+func TestRunnerHTTPDomainFrontingWithRedirect(t *testing.T) {
+	var hosts, paths []string
+	var port string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts, paths = append(hosts, r.Host), append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/antani":
+			w.Header().Add("Location", "http://y.org:"+port+"/mascetti")
+			w.WriteHeader(302)
+		case "/mascetti":
+			w.Header().Add("Location", "/melandri")
+			w.WriteHeader(302)
+		default:
+			w.WriteHeader(200)
+		}
+	}))
+	defer server.Close()
+	URL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port = URL.Port()
+	r := urlgetter.Runner{
+		Config: urlgetter.Config{
+			FrontingDomain: URL.Hostname(),
+		},
+		Target: "http://x.org:" + port + "/antani",
+	}
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectHosts := []string{"x.org:" + port, "y.org:" + port, "y.org:" + port}
+	if diff := cmp.Diff(expectHosts, hosts); diff != "" {
+		t.Fatal(diff)
+	}
+	expectPaths := []string{"/antani", "/mascetti", "/melandri"}
+	if diff := cmp.Diff(expectPaths, paths); diff != "" {
+		t.Fatal(diff)
+	}
+}
+
 func TestRunnerHTTPNoRedirect(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Location", "http:///") // cause failure if we redirect
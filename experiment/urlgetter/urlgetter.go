@@ -8,8 +8,12 @@ import (
 	"crypto/x509"
 	"time"
 
+	"github.com/ooni/probe-engine/internal/torx"
 	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx"
 	"github.com/ooni/probe-engine/netx/archival"
+	"github.com/ooni/probe-engine/netx/bytecounter"
+	"github.com/ooni/probe-engine/netx/retry"
 )
 
 const (
@@ -20,26 +24,81 @@ const (
 // Config contains the experiment's configuration.
 type Config struct {
 	// not settable from command line
-	CertPool *x509.CertPool
-	Timeout  time.Duration
+	ByteCounter *bytecounter.Counter
+	CertPool    *x509.CertPool
+	Timeout     time.Duration
 
 	// settable from command line
-	DNSCache          string `ooni:"Add 'DOMAIN IP...' to cache"`
-	DNSHTTPHost       string `ooni:"Force using specific HTTP Host header for DNS requests"`
-	DNSTLSServerName  string `ooni:"Force TLS to using a specific SNI for encrypted DNS requests"`
-	DNSTLSVersion     string `ooni:"Force specific TLS version used for DoT/DoH (e.g. 'TLSv1.3')"`
-	FailOnHTTPError   bool   `ooni:"Fail HTTP request if status code is 400 or above"`
-	HTTP3Enabled      bool   `ooni:"use http3 instead of http/1.1 or http2"`
-	HTTPHost          string `ooni:"Force using specific HTTP Host header"`
-	Method            string `ooni:"Force HTTP method different than GET"`
-	NoFollowRedirects bool   `ooni:"Disable following redirects"`
-	NoTLSVerify       bool   `ooni:"Disable TLS verification"`
-	RejectDNSBogons   bool   `ooni:"Fail DNS lookup if response contains bogons"`
-	ResolverURL       string `ooni:"URL describing the resolver to use"`
-	TLSServerName     string `ooni:"Force TLS to using a specific SNI in Client Hello"`
-	TLSVersion        string `ooni:"Force specific TLS version (e.g. 'TLSv1.3')"`
-	Tunnel            string `ooni:"Run experiment over a tunnel, e.g. psiphon"`
-	UserAgent         string `ooni:"Use the specified User-Agent"`
+	ConnectRetries       int64  `ooni:"Number of times we retry a TCP connect that times out"`
+	ConnectTimeout       int64  `ooni:"Milliseconds to wait for a TCP connect to complete"`
+	DNSCache             string `ooni:"Add 'DOMAIN IP...' to cache"`
+	DNSHTTPHost          string `ooni:"Force using specific HTTP Host header for DNS requests"`
+	DNSRetries           int64  `ooni:"Number of times we retry a DNS lookup that times out"`
+	DNSTimeout           int64  `ooni:"Milliseconds to wait for a DNS lookup to complete"`
+	DNSTLSServerName     string `ooni:"Force TLS to using a specific SNI for encrypted DNS requests"`
+	DNSTLSVersion        string `ooni:"Force specific TLS version used for DoT/DoH (e.g. 'TLSv1.3')" ooni_enum:"TLSv1,TLSv1.0,TLSv1.1,TLSv1.2,TLSv1.3"`
+	FailOnHTTPError      bool   `ooni:"Fail HTTP request if status code is 400 or above"`
+	FirstByteTimeout     int64  `ooni:"Milliseconds to wait for the HTTP response headers after sending the request"`
+	FrontingDomain       string `ooni:"Connect to and use as SNI this front domain, and put the target domain in the HTTP Host header"`
+	FrontingIPs          string `ooni:"Space separated IPs of the front domain (default: resolve the front domain)"`
+	HTTP3Enabled         bool   `ooni:"use http3 instead of http/1.1 or http2"`
+	HTTPBodyBudget       int64  `ooni:"Maximum number of body bytes captured by the measurement (default: no limit)"`
+	HTTPBodyCapture      string `ooni:"How to capture HTTP bodies (default: head)" ooni_enum:"none,head,full,file"`
+	HTTPBodyDir          string `ooni:"Directory where the file body capture mode saves bodies named after their SHA256"`
+	HTTPBodySnapshotSize int64  `ooni:"Number of body bytes captured by the head body capture mode (default: 131072)"`
+	HTTPHost             string `ooni:"Force using specific HTTP Host header"`
+	IdleTimeout          int64  `ooni:"Milliseconds after which we close idle HTTP connections"`
+	Method               string `ooni:"Force HTTP method different than GET" ooni_pattern:"^[A-Z]+$"`
+	NoFollowRedirects    bool   `ooni:"Disable following redirects"`
+	NoTLSVerify          bool   `ooni:"Disable TLS verification"`
+	QUICHandshakeRetries int64  `ooni:"Number of times we retry a QUIC handshake that times out"`
+	QUICHandshakeTimeout int64  `ooni:"Milliseconds to wait for a QUIC handshake to complete"`
+	RejectDNSBogons      bool   `ooni:"Fail DNS lookup if response contains bogons"`
+	ResolverURL          string `ooni:"URL describing the resolver to use"`
+	RetryBackoff         int64  `ooni:"Milliseconds to wait before the first retry (doubled at each retry)"`
+	RootCAs              string `ooni:"Space separated PEM files containing additional root CAs"`
+	RootCAsReplace       bool   `ooni:"Only trust the root CAs in RootCAs rather than also trusting the default root CAs"`
+	SPKIPins             string `ooni:"Add 'DOMAIN PIN...' SPKI pins (base64 SHA256 of the SPKI)"`
+	TLSHandshakeRetries  int64  `ooni:"Number of times we retry a TLS handshake that times out"`
+	TLSHandshakeTimeout  int64  `ooni:"Milliseconds to wait for a TLS handshake to complete"`
+	TLSServerName        string `ooni:"Force TLS to using a specific SNI in Client Hello"`
+	TLSVersion           string `ooni:"Force specific TLS version (e.g. 'TLSv1.3')" ooni_enum:"TLSv1,TLSv1.0,TLSv1.1,TLSv1.2,TLSv1.3"`
+	TotalTimeout         int64  `ooni:"Milliseconds to wait for an HTTP round trip, including reading the body"`
+	Tunnel               string `ooni:"Run experiment over a tunnel, e.g. psiphon"`
+	TunnelBridge         string `ooni:"Bridge line to use with the meek and obfs4 tunnels"`
+	TunnelProxy          string `ooni:"Upstream SOCKS5 or HTTP proxy URL to use with the proxy tunnel"`
+	UserAgent            string `ooni:"Use the specified User-Agent"`
+}
+
+// millis converts the milliseconds of a config option to a duration.
+func millis(value int64) time.Duration {
+	return time.Duration(value) * time.Millisecond
+}
+
+// netxTimeouts returns the netx timeouts set by the config.
+func (c Config) netxTimeouts() netx.Timeouts {
+	return netx.Timeouts{
+		Connect:       millis(c.ConnectTimeout),
+		DNS:           millis(c.DNSTimeout),
+		FirstByte:     millis(c.FirstByteTimeout),
+		Idle:          millis(c.IdleTimeout),
+		QUICHandshake: millis(c.QUICHandshakeTimeout),
+		TLSHandshake:  millis(c.TLSHandshakeTimeout),
+		Total:         millis(c.TotalTimeout),
+	}
+}
+
+// netxRetries returns the netx retry policies set by the config.
+func (c Config) netxRetries() netx.Retries {
+	policy := func(retries int64) retry.Policy {
+		return retry.Policy{Backoff: millis(c.RetryBackoff), MaxRetries: int(retries)}
+	}
+	return netx.Retries{
+		Connect:       policy(c.ConnectRetries),
+		DNS:           policy(c.DNSRetries),
+		QUICHandshake: policy(c.QUICHandshakeRetries),
+		TLSHandshake:  policy(c.TLSHandshakeRetries),
+	}
 }
 
 // TestKeys contains the experiment's result.
@@ -56,6 +115,7 @@ type TestKeys struct {
 	SOCKSProxy      string                     `json:"socksproxy,omitempty"`
 	TCPConnect      []archival.TCPConnectEntry `json:"tcp_connect"`
 	TLSHandshakes   []archival.TLSHandshake    `json:"tls_handshakes"`
+	TorBootstrap    *torx.BootstrapReport      `json:"tor_bootstrap,omitempty"`
 	Tunnel          string                     `json:"tunnel,omitempty"`
 
 	// The following fields are not serialised but are useful to simplify
@@ -63,6 +123,7 @@ type TestKeys struct {
 	HTTPResponseStatus    int64    `json:"-"`
 	HTTPResponseBody      string   `json:"-"`
 	HTTPResponseLocations []string `json:"-"`
+	TLSInterception       bool     `json:"-"`
 }
 
 // RegisterExtensions registers the extensions used by the urlgetter
@@ -10,9 +10,10 @@ import (
 
 // ConnectsConfig contains the config for Connects
 type ConnectsConfig struct {
-	Session       model.ExperimentSession
-	TargetURL     *url.URL
-	URLGetterURLs []string
+	Session         model.ExperimentSession
+	TargetURL       *url.URL
+	URLGetterConfig urlgetter.Config // optional base config
+	URLGetterURLs   []string
 }
 
 // TODO(bassosimone): we should normalize the timings
@@ -31,10 +32,10 @@ func Connects(ctx context.Context, config ConnectsConfig) (out ConnectsResult) {
 	multi := urlgetter.Multi{Session: config.Session}
 	inputs := []urlgetter.MultiInput{}
 	for _, url := range config.URLGetterURLs {
+		urlgetterConfig := config.URLGetterConfig
+		urlgetterConfig.TLSServerName = config.TargetURL.Hostname()
 		inputs = append(inputs, urlgetter.MultiInput{
-			Config: urlgetter.Config{
-				TLSServerName: config.TargetURL.Hostname(),
-			},
+			Config: urlgetterConfig,
 			Target: url,
 		})
 	}
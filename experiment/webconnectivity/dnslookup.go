@@ -12,8 +12,9 @@ import (
 
 // DNSLookupConfig contains settings for the DNS lookup.
 type DNSLookupConfig struct {
-	Session model.ExperimentSession
-	URL     *url.URL
+	Session         model.ExperimentSession
+	URL             *url.URL
+	URLGetterConfig urlgetter.Config // optional base config
 }
 
 // DNSLookupResult contains the result of the DNS lookup.
@@ -27,7 +28,11 @@ type DNSLookupResult struct {
 func DNSLookup(ctx context.Context, config DNSLookupConfig) (out DNSLookupResult) {
 	target := fmt.Sprintf("dnslookup://%s", config.URL.Hostname())
 	config.Session.Logger().Infof("%s...", target)
-	result, err := urlgetter.Getter{Session: config.Session, Target: target}.Get(ctx)
+	result, err := urlgetter.Getter{
+		Config:  config.URLGetterConfig,
+		Session: config.Session,
+		Target:  target,
+	}.Get(ctx)
 	out.Addrs = make(map[string]int64)
 	for _, query := range result.Queries {
 		for _, answer := range query.Answers {
@@ -57,6 +57,10 @@ func HTTPBodyLengthChecks(
 		return
 	}
 	measurement := int64(len(response.Body.Value))
+	if response.BodyLength > 0 {
+		// the body may be in a side file (see BodyCaptureFile)
+		measurement = response.BodyLength
+	}
 	if measurement <= 0 {
 		return
 	}
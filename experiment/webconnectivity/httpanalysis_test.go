@@ -70,6 +70,24 @@ func TestHTTPBodyLengthChecks(t *testing.T) {
 			},
 		},
 		lengthMatch: nil,
+	}, {
+		name: "match with body saved into a side file",
+		args: args{
+			tk: urlgetter.TestKeys{
+				Requests: []archival.RequestEntry{{
+					Response: archival.HTTPResponse{
+						BodyLength: 768,
+					},
+				}},
+			},
+			ctrl: webconnectivity.ControlResponse{
+				HTTPRequest: webconnectivity.ControlHTTPRequestResult{
+					BodyLength: 1024,
+				},
+			},
+		},
+		lengthMatch: &trueValue,
+		proportion:  0.75,
 	}, {
 		name: "match with bigger control",
 		args: args{
@@ -12,9 +12,10 @@ import (
 
 // HTTPGetConfig contains the config for HTTPGet
 type HTTPGetConfig struct {
-	Addresses []string
-	Session   model.ExperimentSession
-	TargetURL *url.URL
+	Addresses       []string
+	Session         model.ExperimentSession
+	TargetURL       *url.URL
+	URLGetterConfig urlgetter.Config // optional base config
 }
 
 // TODO(bassosimone): we should normalize the timings
@@ -36,10 +37,10 @@ func HTTPGet(ctx context.Context, config HTTPGetConfig) (out HTTPGetResult) {
 	target := config.TargetURL.String()
 	config.Session.Logger().Infof("GET %s...", target)
 	domain := config.TargetURL.Hostname()
+	urlgetterConfig := config.URLGetterConfig
+	urlgetterConfig.DNSCache = fmt.Sprintf("%s %s", domain, addresses)
 	result, err := urlgetter.Getter{
-		Config: urlgetter.Config{
-			DNSCache: fmt.Sprintf("%s %s", domain, addresses),
-		},
+		Config:  urlgetterConfig,
 		Session: config.Session,
 		Target:  target,
 	}.Get(ctx)
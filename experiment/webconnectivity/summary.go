@@ -36,6 +36,8 @@ const (
 	StatusExperimentHTTP    // ... in the HTTP experiment
 
 	StatusBugNoRequests // this should never happen
+
+	StatusAnomalyTLSInterception // the certificate chain is not anchored to a public CA
 )
 
 // Summary contains the Web Connectivity summary.
@@ -225,6 +227,9 @@ func Summarize(tk *TestKeys) (out Summary) {
 			out.BlockingReason = &httpFailure
 			out.Accessible = &inaccessible
 			out.Status |= StatusAnomalyTLSHandshake
+			if tk.TLSInterception {
+				out.Status |= StatusAnomalyTLSInterception
+			}
 		default:
 			// We have not been able to classify the error. Could this perhaps be
 			// caused by a programmer's error? Let us be conservative.
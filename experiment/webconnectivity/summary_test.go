@@ -316,6 +316,24 @@ func TestSummarize(t *testing.T) {
 			Status: webconnectivity.StatusExperimentHTTP |
 				webconnectivity.StatusAnomalyTLSHandshake,
 		},
+	}, {
+		name: "with SSL unknown auth _and_ TLS interception",
+		args: args{
+			tk: &webconnectivity.TestKeys{
+				Requests: []archival.RequestEntry{{
+					Failure: &probeSSLUnknownAuth,
+				}},
+				TLSInterception: true,
+			},
+		},
+		wantOut: webconnectivity.Summary{
+			BlockingReason: &httpFailure,
+			Blocking:       &httpFailure,
+			Accessible:     &falseValue,
+			Status: webconnectivity.StatusExperimentHTTP |
+				webconnectivity.StatusAnomalyTLSHandshake |
+				webconnectivity.StatusAnomalyTLSInterception,
+		},
 	}, {
 		name: "with SSL unknown auth _and_ untrustworthy DNS",
 		args: args{
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/ooni/probe-engine/experiment/urlgetter"
 	"github.com/ooni/probe-engine/experiment/webconnectivity/internal"
 	"github.com/ooni/probe-engine/internal/httpheader"
 	"github.com/ooni/probe-engine/model"
@@ -23,7 +24,54 @@ const (
 )
 
 // Config contains the experiment config.
-type Config struct{}
+type Config struct {
+	ConnectRetries       int64  `ooni:"Number of times we retry a TCP connect that times out"`
+	ConnectTimeout       int64  `ooni:"Milliseconds to wait for a TCP connect to complete"`
+	DNSRetries           int64  `ooni:"Number of times we retry a DNS lookup that times out"`
+	DNSTimeout           int64  `ooni:"Milliseconds to wait for a DNS lookup to complete"`
+	FirstByteTimeout     int64  `ooni:"Milliseconds to wait for the HTTP response headers after sending the request"`
+	HTTPBodyBudget       int64  `ooni:"Maximum number of body bytes captured when measuring each URL (default: no limit)"`
+	HTTPBodyCapture      string `ooni:"How to capture HTTP bodies (default: head)" ooni_enum:"none,head,full,file"`
+	HTTPBodyDir          string `ooni:"Directory where the file body capture mode saves bodies named after their SHA256"`
+	HTTPBodySnapshotSize int64  `ooni:"Number of body bytes captured by the head body capture mode (default: 131072)"`
+	MaxRuntime           int64  `ooni:"Maximum number of seconds spent measuring each URL (default: 60)"`
+	RetryBackoff         int64  `ooni:"Milliseconds to wait before the first retry (doubled at each retry)"`
+	RootCAs              string `ooni:"Space separated PEM files containing additional root CAs"`
+	RootCAsReplace       bool   `ooni:"Only trust the root CAs in RootCAs rather than also trusting the default root CAs"`
+	SPKIPins             string `ooni:"Add 'DOMAIN PIN...' SPKI pins (base64 SHA256 of the SPKI)"`
+	TLSHandshakeRetries  int64  `ooni:"Number of times we retry a TLS handshake that times out"`
+	TLSHandshakeTimeout  int64  `ooni:"Milliseconds to wait for a TLS handshake to complete"`
+}
+
+// urlgetterConfig returns the urlgetter config that we use as the
+// base config for all the urlgetter runs performed by Web Connectivity.
+func (c Config) urlgetterConfig() urlgetter.Config {
+	return urlgetter.Config{
+		ConnectRetries:       c.ConnectRetries,
+		ConnectTimeout:       c.ConnectTimeout,
+		DNSRetries:           c.DNSRetries,
+		DNSTimeout:           c.DNSTimeout,
+		FirstByteTimeout:     c.FirstByteTimeout,
+		HTTPBodyBudget:       c.HTTPBodyBudget,
+		HTTPBodyCapture:      c.HTTPBodyCapture,
+		HTTPBodyDir:          c.HTTPBodyDir,
+		HTTPBodySnapshotSize: c.HTTPBodySnapshotSize,
+		RetryBackoff:         c.RetryBackoff,
+		RootCAs:              c.RootCAs,
+		RootCAsReplace:       c.RootCAsReplace,
+		SPKIPins:             c.SPKIPins,
+		TLSHandshakeRetries:  c.TLSHandshakeRetries,
+		TLSHandshakeTimeout:  c.TLSHandshakeTimeout,
+	}
+}
+
+// maxRuntime returns the maximum runtime for measuring an URL.
+func (c Config) maxRuntime() time.Duration {
+	if c.MaxRuntime > 0 {
+		return time.Duration(c.MaxRuntime) * time.Second
+	}
+	return 60 * time.Second
+}
 
 // TestKeys contains webconnectivity test keys.
 type TestKeys struct {
@@ -47,6 +95,11 @@ type TestKeys struct {
 	TCPConnectSuccesses int                        `json:"-"`
 	TCPConnectAttempts  int                        `json:"-"`
 
+	// TLS handshakes performed by the TCP connect and HTTP experiments. This
+	// is an extension of the spec, so we do not include peer certificates.
+	TLSHandshakes   []archival.TLSHandshake `json:"x_tls_handshakes"`
+	TLSInterception bool                    `json:"x_tls_interception"`
+
 	// HTTP experiment
 	Requests              []archival.RequestEntry `json:"requests"`
 	HTTPExperimentFailure *string                 `json:"http_experiment_failure"`
@@ -97,7 +150,7 @@ func (m Measurer) Run(
 	measurement *model.Measurement,
 	callbacks model.ExperimentCallbacks,
 ) error {
-	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, m.Config.maxRuntime())
 	defer cancel()
 	tk := new(TestKeys)
 	measurement.TestKeys = tk
@@ -129,7 +182,11 @@ func (m Measurer) Run(
 		"backend": testhelper,
 	}
 	// 2. perform the DNS lookup step
-	dnsResult := DNSLookup(ctx, DNSLookupConfig{Session: sess, URL: URL})
+	dnsResult := DNSLookup(ctx, DNSLookupConfig{
+		Session:         sess,
+		URL:             URL,
+		URLGetterConfig: m.Config.urlgetterConfig(),
+	})
 	tk.Queries = append(tk.Queries, dnsResult.TestKeys.Queries...)
 	tk.DNSExperimentFailure = dnsResult.Failure
 	epnts := NewEndpoints(URL, dnsResult.Addresses())
@@ -153,9 +210,10 @@ func (m Measurer) Run(
 		tk.DNSAnalysisResult.DNSConsistency))
 	// 5. perform TCP/TLS connects
 	connectsResult := Connects(ctx, ConnectsConfig{
-		Session:       sess,
-		TargetURL:     URL,
-		URLGetterURLs: epnts.URLs(),
+		Session:         sess,
+		TargetURL:       URL,
+		URLGetterConfig: m.Config.urlgetterConfig(),
+		URLGetterURLs:   epnts.URLs(),
 	})
 	sess.Logger().Infof(
 		"TCP/TLS endpoints: %d/%d reachable", connectsResult.Successes, connectsResult.Total)
@@ -164,17 +222,23 @@ func (m Measurer) Run(
 		// sad that we're storing analysis result inside the measurement
 		tk.TCPConnect = append(tk.TCPConnect, ComputeTCPBlocking(
 			tcpkeys.TCPConnect, tk.Control.TCPConnect)...)
+		tk.TLSHandshakes = append(
+			tk.TLSHandshakes, withoutPeerCertificates(tcpkeys.TLSHandshakes)...)
 	}
 	tk.TCPConnectAttempts = connectsResult.Total
 	tk.TCPConnectSuccesses = connectsResult.Successes
 	// 6. perform HTTP/HTTPS measurement
 	httpResult := HTTPGet(ctx, HTTPGetConfig{
-		Addresses: dnsResult.Addresses(),
-		Session:   sess,
-		TargetURL: URL,
+		Addresses:       dnsResult.Addresses(),
+		Session:         sess,
+		TargetURL:       URL,
+		URLGetterConfig: m.Config.urlgetterConfig(),
 	})
 	tk.HTTPExperimentFailure = httpResult.Failure
 	tk.Requests = append(tk.Requests, httpResult.TestKeys.Requests...)
+	tk.TLSHandshakes = append(
+		tk.TLSHandshakes, withoutPeerCertificates(httpResult.TestKeys.TLSHandshakes)...)
+	tk.TLSInterception = archival.TLSInterception(tk.TLSHandshakes)
 	// 7. compare HTTP measurement to control
 	tk.HTTPAnalysisResult = HTTPAnalysis(httpResult.TestKeys, tk.Control)
 	tk.HTTPAnalysisResult.Log(sess.Logger())
@@ -183,6 +247,17 @@ func (m Measurer) Run(
 	return nil
 }
 
+// withoutPeerCertificates returns a copy of the TLS handshakes without
+// the peer certificates, keeping the analysis of the certificate chain.
+func withoutPeerCertificates(in []archival.TLSHandshake) []archival.TLSHandshake {
+	out := make([]archival.TLSHandshake, 0, len(in))
+	for _, entry := range in {
+		entry.PeerCertificates = nil
+		out = append(out, entry)
+	}
+	return out
+}
+
 // ComputeTCPBlocking will return a copy of the input TCPConnect structure
 // where we set the Blocking value depending on the control results.
 func ComputeTCPBlocking(measurement []archival.TCPConnectEntry,
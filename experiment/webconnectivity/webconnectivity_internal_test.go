@@ -0,0 +1,78 @@
+package webconnectivity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-engine/experiment/urlgetter"
+	"github.com/ooni/probe-engine/netx/archival"
+	"github.com/ooni/probe-engine/netx/certchain"
+)
+
+func TestConfigMaxRuntime(t *testing.T) {
+	if (Config{}).maxRuntime() != 60*time.Second {
+		t.Fatal("not the default max runtime")
+	}
+	if (Config{MaxRuntime: 180}).maxRuntime() != 180*time.Second {
+		t.Fatal("not the max runtime we expected")
+	}
+}
+
+func TestConfigURLGetterConfig(t *testing.T) {
+	config := Config{
+		ConnectRetries:       1,
+		ConnectTimeout:       2,
+		DNSRetries:           3,
+		DNSTimeout:           4,
+		FirstByteTimeout:     5,
+		HTTPBodyBudget:       10,
+		HTTPBodyCapture:      "full",
+		HTTPBodyDir:          "/tmp",
+		HTTPBodySnapshotSize: 11,
+		MaxRuntime:           6,
+		RetryBackoff:         7,
+		RootCAs:              "/tmp/ca.pem",
+		RootCAsReplace:       true,
+		SPKIPins:             "x.org pin",
+		TLSHandshakeRetries:  8,
+		TLSHandshakeTimeout:  9,
+	}
+	expected := urlgetter.Config{
+		ConnectRetries:       1,
+		ConnectTimeout:       2,
+		DNSRetries:           3,
+		DNSTimeout:           4,
+		FirstByteTimeout:     5,
+		HTTPBodyBudget:       10,
+		HTTPBodyCapture:      "full",
+		HTTPBodyDir:          "/tmp",
+		HTTPBodySnapshotSize: 11,
+		RetryBackoff:         7,
+		RootCAs:              "/tmp/ca.pem",
+		RootCAsReplace:       true,
+		SPKIPins:             "x.org pin",
+		TLSHandshakeRetries:  8,
+		TLSHandshakeTimeout:  9,
+	}
+	if config.urlgetterConfig() != expected {
+		t.Fatal("not the urlgetter config we expected")
+	}
+}
+
+func TestWithoutPeerCertificates(t *testing.T) {
+	analysis := &certchain.Analysis{Interception: true}
+	in := []archival.TLSHandshake{{
+		CertificateChain: analysis,
+		PeerCertificates: []archival.MaybeBinaryValue{{Value: "deadbeef"}},
+	}}
+	out := withoutPeerCertificates(in)
+	if len(out) != 1 || out[0].PeerCertificates != nil {
+		t.Fatal("expected no peer certificates")
+	}
+	if out[0].CertificateChain != analysis {
+		t.Fatal("expected the certificate chain analysis")
+	}
+	if len(in[0].PeerCertificates) != 1 {
+		t.Fatal("we should not modify the input")
+	}
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -8,6 +9,7 @@ import (
 	"strconv"
 
 	"github.com/iancoleman/strcase"
+	"github.com/ooni/probe-engine/experiment/run"
 	"github.com/ooni/probe-engine/model"
 )
 
@@ -120,6 +122,9 @@ func (b *ExperimentBuilder) SetOptionString(key, value string) error {
 	if field.Kind() != reflect.String {
 		return errors.New("field is not a string")
 	}
+	if err := validateOptionString(b.config, key, value); err != nil {
+		return err
+	}
 	field.SetString(value)
 	return nil
 }
@@ -157,6 +162,46 @@ func (b *ExperimentBuilder) SetCallbacks(callbacks model.ExperimentCallbacks) {
 	b.callbacks = callbacks
 }
 
+// StepSink saves and submits the measurements of the steps of experiments,
+// such as run, that perform several measurements for each input. Each step
+// may run a different experiment, hence a StepSink should submit the step
+// measurements using a dedicated Submitter, which opens a new report using
+// the step measurement as template whenever the test name changes.
+type StepSink interface {
+	SaveAndSubmit(ctx context.Context, m *model.Measurement) error
+}
+
+// stepSinkConfig is the config of experiments that perform several
+// measurements for each input, such as run.
+type stepSinkConfig interface {
+	SetSink(sink run.Sink)
+}
+
+// ErrStepSinkNotSupported indicates that the experiment does not
+// perform several measurements for each input.
+var ErrStepSinkNotSupported = errors.New("experiment does not support step sinks")
+
+// SupportsStepSink returns whether the experiment performs several
+// measurements for each input, such as run, and hence whether you
+// should call SetStepSink to save and submit all of them.
+func (b *ExperimentBuilder) SupportsStepSink() bool {
+	_, ok := b.config.(stepSinkConfig)
+	return ok
+}
+
+// SetStepSink sets the sink used by experiments that perform several
+// measurements for each input, such as run, to save and submit all the
+// measurements of their steps. This method fails with ErrStepSinkNotSupported
+// when SupportsStepSink returns false.
+func (b *ExperimentBuilder) SetStepSink(sink StepSink) error {
+	config, ok := b.config.(stepSinkConfig)
+	if !ok {
+		return ErrStepSinkNotSupported
+	}
+	config.SetSink(sink)
+	return nil
+}
+
 func fieldbyname(v interface{}, key string) (reflect.Value, error) {
 	// See https://stackoverflow.com/a/6396678/4354461
 	ptrinfo := reflect.ValueOf(v)
@@ -192,8 +237,26 @@ func canonicalizeExperimentName(name string) string {
 	return name
 }
 
+// newMeasurer creates the measurer of the experiment with the given name
+// using the given options, which we set using SetOptionsGuessType. The run
+// experiment uses this function to run any other experiment.
+func newMeasurer(
+	session *Session, name string, options map[string]string,
+) (model.ExperimentMeasurer, error) {
+	builder, err := newExperimentBuilder(session, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := builder.SetOptionsGuessType(options); err != nil {
+		return nil, err
+	}
+	return builder.NewExperiment().measurer, nil
+}
+
 func newExperimentBuilder(session *Session, name string) (*ExperimentBuilder, error) {
+	experimentsMu.Lock()
 	factory, _ := experimentsByName[canonicalizeExperimentName(name)]
+	experimentsMu.Unlock()
 	if factory == nil {
 		return nil, fmt.Errorf("no such experiment: %s", name)
 	}
@@ -1,9 +1,13 @@
 package engine
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/ooni/probe-engine/experiment/example"
+	"github.com/ooni/probe-engine/experiment/run"
+	"github.com/ooni/probe-engine/model"
 )
 
 func TestExperimentBuilderOptions(t *testing.T) {
@@ -188,3 +192,40 @@ func TestExperimentBuilderSetOptionGuessType(t *testing.T) {
 		}
 	})
 }
+
+type stubStepSink struct{}
+
+func (stubStepSink) SaveAndSubmit(ctx context.Context, m *model.Measurement) error {
+	return nil
+}
+
+func TestExperimentBuilderSetStepSink(t *testing.T) {
+	t.Run("when the experiment supports step sinks", func(t *testing.T) {
+		config := &run.Config{}
+		b := &ExperimentBuilder{config: config}
+		if !b.SupportsStepSink() {
+			t.Fatal("expected the experiment to support step sinks")
+		}
+		if err := b.SetStepSink(stubStepSink{}); err != nil {
+			t.Fatal(err)
+		}
+		if config.Sink == nil {
+			t.Fatal("expected the sink to be set")
+		}
+	})
+	t.Run("when the experiment does not support step sinks", func(t *testing.T) {
+		b := &ExperimentBuilder{config: &example.Config{}}
+		if b.SupportsStepSink() {
+			t.Fatal("expected the experiment not to support step sinks")
+		}
+		if err := b.SetStepSink(stubStepSink{}); !errors.Is(err, ErrStepSinkNotSupported) {
+			t.Fatal("not the error we expected")
+		}
+	})
+	t.Run("with the run experiment builder", func(t *testing.T) {
+		b := experimentsByName["run"](nil)
+		if !b.SupportsStepSink() {
+			t.Fatal("the run experiment should support step sinks")
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/ooni/probe-engine/internal/runtimex"
 	"github.com/ooni/probe-engine/model"
@@ -71,6 +72,21 @@ type Results struct {
 	// IP is the probe IP
 	ProbeIP string
 
+	// ProbeIPConfidence is the fraction of the IP lookup backends
+	// that did not fail and agree with ProbeIP. It is one when
+	// the probe IP is a static override.
+	ProbeIPConfidence float64
+
+	// ProbeIPLookups contains the results of each IP lookup backend.
+	ProbeIPLookups []IPLookupResult
+
+	// ProbeIPv4 is the IPv4 identity of the probe, or nil if we
+	// did not discover it (see Config.NewHTTPClientForFamily).
+	ProbeIPv4 *ProbeIdentity
+
+	// ProbeIPv6 is like ProbeIPv4 but for IPv6.
+	ProbeIPv6 *ProbeIdentity
+
 	// ResolverASN is the resolver ASN
 	ResolverASN uint
 
@@ -81,13 +97,48 @@ type Results struct {
 	ResolverNetworkName string
 }
 
+// ProbeIdentity is the identity of the probe when using a specific
+// address family. On dual-stack networks, IPv4 and IPv6 traffic
+// may be routed through different autonomous systems.
+type ProbeIdentity struct {
+	// ASN is the autonomous system number.
+	ASN uint
+
+	// CountryCode is the country code.
+	CountryCode string
+
+	// NetworkName is the network name.
+	NetworkName string
+
+	// ProbeIP is the probe IP.
+	ProbeIP string
+
+	// ProbeIPConfidence is like Results.ProbeIPConfidence.
+	ProbeIPConfidence float64
+
+	// ProbeIPLookups is like Results.ProbeIPLookups.
+	ProbeIPLookups []IPLookupResult
+}
+
 // ASNString returns the ASN as a string
 func (r *Results) ASNString() string {
 	return fmt.Sprintf("AS%d", r.ASN)
 }
 
+// ProbeIPDisagreements returns the results of the IP lookup
+// backends that did not fail and disagree with ProbeIP.
+func (r *Results) ProbeIPDisagreements() []IPLookupResult {
+	var out []IPLookupResult
+	for _, result := range r.ProbeIPLookups {
+		if result.Failure == "" && result.IP != r.ProbeIP {
+			out = append(out, result)
+		}
+	}
+	return out
+}
+
 type probeIPLookupper interface {
-	LookupProbeIP(ctx context.Context) (addr string, err error)
+	LookupProbeIP(ctx context.Context) (*ipLookupConsensus, error)
 }
 
 type asnLookupper interface {
@@ -129,6 +180,16 @@ type Config struct {
 	// use a logger that discards all messages.
 	Logger Logger
 
+	// NewHTTPClientForFamily is the optional factory for HTTP clients
+	// that only use the given address family ("4" or "6"). If set,
+	// we also discover the IPv4 and the IPv6 identities of the probe
+	// using such clients and forcing the family for STUN.
+	NewHTTPClientForFamily func(family string) *http.Client
+
+	// Overrides contains optional overrides allowing, for
+	// example, to geolocate without using the network.
+	Overrides Overrides
+
 	// ResourcesManager is the mandatory resources manager. If not
 	// set, we will not be able to perform any lookup.
 	ResourcesManager ResourcesManager
@@ -158,7 +219,7 @@ func NewTask(config Config) (*Task, error) {
 	if config.UserAgent == "" {
 		config.UserAgent = fmt.Sprintf("ooniprobe-engine/%s", version.Version)
 	}
-	return &Task{
+	task := &Task{
 		countryLookupper:     mmdbLookupper{},
 		enableResolverLookup: config.EnableResolverLookup,
 		probeIPLookupper: ipLookupClient{
@@ -170,7 +231,23 @@ func NewTask(config Config) (*Task, error) {
 		resolverASNLookupper: mmdbLookupper{},
 		resolverIPLookupper:  resolverLookupClient{},
 		resourcesManager:     config.ResourcesManager,
-	}, nil
+	}
+	if config.NewHTTPClientForFamily != nil {
+		task.probeIPv4Lookupper = ipLookupClient{
+			Family:     "4",
+			HTTPClient: config.NewHTTPClientForFamily("4"),
+			Logger:     config.Logger,
+			UserAgent:  config.UserAgent,
+		}
+		task.probeIPv6Lookupper = ipLookupClient{
+			Family:     "6",
+			HTTPClient: config.NewHTTPClientForFamily("6"),
+			Logger:     config.Logger,
+			UserAgent:  config.UserAgent,
+		}
+	}
+	config.Overrides.apply(task)
+	return task, nil
 }
 
 // Task performs a geolocation. You must create a new
@@ -179,6 +256,8 @@ type Task struct {
 	countryLookupper     countryLookupper
 	enableResolverLookup bool
 	probeIPLookupper     probeIPLookupper
+	probeIPv4Lookupper   probeIPLookupper
+	probeIPv6Lookupper   probeIPLookupper
 	probeASNLookupper    asnLookupper
 	resolverASNLookupper asnLookupper
 	resolverIPLookupper  resolverIPLookupper
@@ -200,11 +279,13 @@ func (op Task) Run(ctx context.Context) (*Results, error) {
 	if err := op.resourcesManager.MaybeUpdateResources(ctx); err != nil {
 		return out, fmt.Errorf("MaybeUpdateResource failed: %w", err)
 	}
-	ip, err := op.probeIPLookupper.LookupProbeIP(ctx)
+	consensus, err := op.probeIPLookupper.LookupProbeIP(ctx)
 	if err != nil {
 		return out, fmt.Errorf("lookupProbeIP failed: %w", err)
 	}
-	out.ProbeIP = ip
+	out.ProbeIP = consensus.ip
+	out.ProbeIPConfidence = consensus.confidence
+	out.ProbeIPLookups = consensus.results
 	asn, networkName, err := op.probeASNLookupper.LookupASN(
 		op.resourcesManager.ASNDatabasePath(), out.ProbeIP)
 	if err != nil {
@@ -218,6 +299,7 @@ func (op Task) Run(ctx context.Context) (*Results, error) {
 		return out, fmt.Errorf("lookupProbeCC failed: %w", err)
 	}
 	out.CountryCode = cc
+	op.lookupProbeIdentities(ctx, out)
 	if op.enableResolverLookup {
 		out.DidResolverLookup = true
 		// Note: ignoring the result of lookupResolverIP and lookupASN
@@ -240,3 +322,50 @@ func (op Task) Run(ctx context.Context) (*Results, error) {
 	}
 	return out, nil
 }
+
+// lookupProbeIdentities discovers the IPv4 and IPv6 identities of the
+// probe, if configured to do that. Like for the resolver lookup, we do
+// not want a failure here to influence the overall lookup, also because
+// it is normal for a network not to have IPv6 connectivity.
+func (op Task) lookupProbeIdentities(ctx context.Context, out *Results) {
+	if op.probeIPv4Lookupper == nil || op.probeIPv6Lookupper == nil {
+		return
+	}
+	wg := new(sync.WaitGroup)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		out.ProbeIPv4 = op.lookupProbeIdentity(ctx, op.probeIPv4Lookupper)
+	}()
+	go func() {
+		defer wg.Done()
+		out.ProbeIPv6 = op.lookupProbeIdentity(ctx, op.probeIPv6Lookupper)
+	}()
+	wg.Wait()
+}
+
+func (op Task) lookupProbeIdentity(
+	ctx context.Context, lookupper probeIPLookupper) *ProbeIdentity {
+	consensus, err := lookupper.LookupProbeIP(ctx)
+	if err != nil {
+		return nil
+	}
+	asn, networkName, err := op.probeASNLookupper.LookupASN(
+		op.resourcesManager.ASNDatabasePath(), consensus.ip)
+	if err != nil {
+		return nil
+	}
+	cc, err := op.countryLookupper.LookupCC(
+		op.resourcesManager.CountryDatabasePath(), consensus.ip)
+	if err != nil {
+		return nil
+	}
+	return &ProbeIdentity{
+		ASN:               asn,
+		CountryCode:       cc,
+		NetworkName:       networkName,
+		ProbeIP:           consensus.ip,
+		ProbeIPConfidence: consensus.confidence,
+		ProbeIPLookups:    consensus.results,
+	}
+}
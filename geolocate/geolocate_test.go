@@ -3,6 +3,7 @@ package geolocate
 import (
 	"context"
 	"errors"
+	"net/http"
 	"testing"
 )
 
@@ -62,8 +63,8 @@ type taskProbeIPLookupper struct {
 	err error
 }
 
-func (c taskProbeIPLookupper) LookupProbeIP(ctx context.Context) (string, error) {
-	return c.ip, c.err
+func (c taskProbeIPLookupper) LookupProbeIP(ctx context.Context) (*ipLookupConsensus, error) {
+	return &ipLookupConsensus{confidence: 1, ip: c.ip}, c.err
 }
 
 func TestLocationLookupCannotLookupProbeIP(t *testing.T) {
@@ -393,3 +394,65 @@ func TestNewTaskWithNoResourcesManager(t *testing.T) {
 		t.Fatal("expected nil task here")
 	}
 }
+
+func TestResultsProbeIPDisagreements(t *testing.T) {
+	results := &Results{
+		ProbeIP: "130.25.90.7",
+		ProbeIPLookups: []IPLookupResult{
+			{Backend: "avast", IP: "10.0.0.1"},
+			{Backend: "ipinfo", IP: "130.25.90.7"},
+			{Backend: "ubuntu", Failure: "generic_timeout_error"},
+		},
+	}
+	disagreements := results.ProbeIPDisagreements()
+	if len(disagreements) != 1 || disagreements[0].Backend != "avast" {
+		t.Fatalf("unexpected disagreements: %+v", disagreements)
+	}
+}
+
+func TestLocationLookupWithProbeIdentities(t *testing.T) {
+	op := Task{
+		resourcesManager:   taskResourcesManager{},
+		probeIPLookupper:   taskProbeIPLookupper{ip: "1.2.3.4"},
+		probeIPv4Lookupper: taskProbeIPLookupper{ip: "1.2.3.4"},
+		probeIPv6Lookupper: taskProbeIPLookupper{err: errors.New("mocked error")},
+		probeASNLookupper:  taskASNLookupper{asn: 1234, name: "1234.com"},
+		countryLookupper:   taskCCLookupper{cc: "US"},
+	}
+	out, err := op.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ProbeIPv4 == nil || out.ProbeIPv4.ProbeIP != "1.2.3.4" {
+		t.Fatalf("unexpected IPv4 identity: %+v", out.ProbeIPv4)
+	}
+	if out.ProbeIPv4.ASN != 1234 || out.ProbeIPv4.CountryCode != "US" {
+		t.Fatalf("unexpected IPv4 identity: %+v", out.ProbeIPv4)
+	}
+	if out.ProbeIPv6 != nil {
+		t.Fatal("expected nil IPv6 identity")
+	}
+}
+
+func TestNewTaskWithNewHTTPClientForFamily(t *testing.T) {
+	var families []string
+	task, err := NewTask(Config{
+		NewHTTPClientForFamily: func(family string) *http.Client {
+			families = append(families, family)
+			return http.DefaultClient
+		},
+		ResourcesManager: taskResourcesManager{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 2 || families[0] != "4" || families[1] != "6" {
+		t.Fatal("unexpected families", families)
+	}
+	if task.probeIPv4Lookupper.(ipLookupClient).Family != "4" {
+		t.Fatal("unexpected IPv4 lookupper family")
+	}
+	if task.probeIPv6Lookupper.(ipLookupClient).Family != "6" {
+		t.Fatal("unexpected IPv6 lookupper family")
+	}
+}
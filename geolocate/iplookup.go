@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math/rand"
 	"net"
 	"net/http"
 	"sync"
@@ -21,6 +20,10 @@ var (
 	// ErrInvalidIPAddress indicates that the code returned to us a
 	// string that actually isn't a valid IP address.
 	ErrInvalidIPAddress = errors.New("lookupper did not return a valid IP")
+
+	// ErrWrongAddressFamily indicates that the lookupper returned
+	// an IP address not belonging to the requested family.
+	ErrWrongAddressFamily = errors.New("lookupper returned IP of wrong family")
 )
 
 type lookupFunc func(
@@ -60,11 +63,18 @@ var (
 			fn:   ubuntuIPLookup,
 		},
 	}
-
-	once sync.Once
 )
 
+// ipLookupTimeout is the maximum time we wait for all backends. A
+// backend that does not reply within this time counts as failed.
+const ipLookupTimeout = 15 * time.Second
+
 type ipLookupClient struct {
+	// Family is the optional address family ("4" or "6"). When set,
+	// HTTPClient must only use such family, and we also force STUN
+	// to use it and we reject IPs belonging to the other family.
+	Family string
+
 	// HTTPClient is the HTTP client to use
 	HTTPClient *http.Client
 
@@ -75,14 +85,24 @@ type ipLookupClient struct {
 	UserAgent string
 }
 
-func makeSlice() []method {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	ret := make([]method, len(methods))
-	perm := r.Perm(len(methods))
-	for idx, randIdx := range perm {
-		ret[idx] = methods[randIdx]
-	}
-	return ret
+// IPLookupResult is the result of looking up the probe IP using a
+// specific backend (e.g., an HTTP service or a STUN server).
+type IPLookupResult struct {
+	// Backend is the name of the backend.
+	Backend string `json:"backend"`
+
+	// Failure is the failure that occurred, if any.
+	Failure string `json:"failure,omitempty"`
+
+	// IP is the IP returned by the backend on success.
+	IP string `json:"ip,omitempty"`
+}
+
+// ipLookupConsensus is the consensus among several backends.
+type ipLookupConsensus struct {
+	confidence float64
+	ip         string
+	results    []IPLookupResult
 }
 
 func (c ipLookupClient) doWithCustomFunc(
@@ -92,22 +112,77 @@ func (c ipLookupClient) doWithCustomFunc(
 	if err != nil {
 		return DefaultProbeIP, err
 	}
-	if net.ParseIP(ip) == nil {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
 		return DefaultProbeIP, fmt.Errorf("%w: %s", ErrInvalidIPAddress, ip)
 	}
+	if c.Family != "" && (parsed.To4() != nil) != (c.Family == "4") {
+		return DefaultProbeIP, fmt.Errorf("%w: %s", ErrWrongAddressFamily, ip)
+	}
 	c.Logger.Debugf("iplookup: IP: %s", ip)
-	return ip, nil
+	return parsed.String(), nil // canonical form to compare results
+}
+
+// LookupProbeIP queries all the backends in parallel, so that a single
+// lying or captive-portal service cannot mislocate the probe.
+func (c ipLookupClient) LookupProbeIP(ctx context.Context) (*ipLookupConsensus, error) {
+	ctx, cancel := context.WithTimeout(ctx, ipLookupTimeout)
+	defer cancel()
+	ctx = withAddressFamily(ctx, c.Family)
+	results := make([]IPLookupResult, len(methods))
+	errs := make([]error, len(methods))
+	wg := new(sync.WaitGroup)
+	for idx, method := range methods {
+		wg.Add(1)
+		go func(idx int, method method) {
+			defer wg.Done()
+			c.Logger.Debugf("iplookup: using %s", method.name)
+			ip, err := c.doWithCustomFunc(ctx, method.fn)
+			results[idx] = IPLookupResult{Backend: method.name}
+			if err != nil {
+				errs[idx] = err
+				results[idx].Failure = err.Error()
+				return
+			}
+			results[idx].IP = ip
+		}(idx, method)
+	}
+	wg.Wait()
+	consensus := computeIPLookupConsensus(results)
+	if consensus.ip == DefaultProbeIP {
+		union := multierror.New(ErrAllIPLookuppersFailed)
+		for _, err := range errs {
+			union.Add(err)
+		}
+		return consensus, union
+	}
+	c.Logger.Debugf("iplookup: consensus: %s (confidence: %f)",
+		consensus.ip, consensus.confidence)
+	return consensus, nil
 }
 
-func (c ipLookupClient) LookupProbeIP(ctx context.Context) (string, error) {
-	union := multierror.New(ErrAllIPLookuppersFailed)
-	for _, method := range makeSlice() {
-		c.Logger.Debugf("iplookup: using %s", method.name)
-		ip, err := c.doWithCustomFunc(ctx, method.fn)
-		if err == nil {
-			return ip, nil
+// computeIPLookupConsensus returns the IP returned by most of the
+// backends that did not fail, breaking ties using the order of the
+// backends. The confidence is the fraction of such backends that
+// agree with the consensus. On total failure, the IP is DefaultProbeIP
+// and the confidence is zero.
+func computeIPLookupConsensus(results []IPLookupResult) *ipLookupConsensus {
+	consensus := &ipLookupConsensus{ip: DefaultProbeIP, results: results}
+	votes := make(map[string]int)
+	var successes, best int
+	for _, result := range results {
+		if result.Failure != "" {
+			continue
 		}
-		union.Add(err)
+		successes++
+		votes[result.IP]++
+		if votes[result.IP] > best {
+			best = votes[result.IP]
+			consensus.ip = result.IP
+		}
+	}
+	if successes > 0 {
+		consensus.confidence = float64(best) / float64(successes)
 	}
-	return DefaultProbeIP, union
+	return consensus
 }
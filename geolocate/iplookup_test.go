@@ -11,7 +11,7 @@ import (
 )
 
 func TestIPLookupGood(t *testing.T) {
-	ip, err := (ipLookupClient{
+	consensus, err := (ipLookupClient{
 		HTTPClient: http.DefaultClient,
 		Logger:     log.Log,
 		UserAgent:  "ooniprobe-engine/0.1.0",
@@ -19,15 +19,21 @@ func TestIPLookupGood(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if net.ParseIP(ip) == nil {
+	if net.ParseIP(consensus.ip) == nil {
 		t.Fatal("not an IP address")
 	}
+	if consensus.confidence <= 0 || consensus.confidence > 1 {
+		t.Fatal("unexpected confidence")
+	}
+	if len(consensus.results) != len(methods) {
+		t.Fatal("unexpected number of results")
+	}
 }
 
 func TestIPLookupAllFailed(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // immediately cancel to cause Do() to fail
-	ip, err := (ipLookupClient{
+	consensus, err := (ipLookupClient{
 		HTTPClient: http.DefaultClient,
 		Logger:     log.Log,
 		UserAgent:  "ooniprobe-engine/0.1.0",
@@ -35,9 +41,14 @@ func TestIPLookupAllFailed(t *testing.T) {
 	if !errors.Is(err, context.Canceled) {
 		t.Fatal("expected an error here")
 	}
-	if ip != DefaultProbeIP {
+	if consensus.ip != DefaultProbeIP || consensus.confidence != 0 {
 		t.Fatal("expected the default IP here")
 	}
+	for _, result := range consensus.results {
+		if result.Failure == "" {
+			t.Fatalf("expected a failure here: %+v", result)
+		}
+	}
 }
 
 func TestIPLookupInvalidIP(t *testing.T) {
@@ -54,3 +65,86 @@ func TestIPLookupInvalidIP(t *testing.T) {
 		t.Fatal("expected the default IP here")
 	}
 }
+
+func TestComputeIPLookupConsensus(t *testing.T) {
+	tests := []struct {
+		name       string
+		results    []IPLookupResult
+		ip         string
+		confidence float64
+	}{{
+		name:       "with no results",
+		ip:         DefaultProbeIP,
+		confidence: 0,
+	}, {
+		name: "with all failures",
+		results: []IPLookupResult{
+			{Backend: "avast", Failure: "generic_timeout_error"},
+			{Backend: "ubuntu", Failure: "connection_refused"},
+		},
+		ip:         DefaultProbeIP,
+		confidence: 0,
+	}, {
+		name: "with a lying backend",
+		results: []IPLookupResult{
+			{Backend: "avast", IP: "10.0.0.1"},
+			{Backend: "ipinfo", IP: "130.25.90.7"},
+			{Backend: "stun_google", IP: "130.25.90.7"},
+			{Backend: "ubuntu", IP: "130.25.90.7"},
+			{Backend: "ipconfig", Failure: "generic_timeout_error"},
+		},
+		ip:         "130.25.90.7",
+		confidence: 0.75,
+	}, {
+		name: "with a tie",
+		results: []IPLookupResult{
+			{Backend: "avast", IP: "130.25.90.7"},
+			{Backend: "ipinfo", IP: "10.0.0.1"},
+		},
+		ip:         "130.25.90.7",
+		confidence: 0.5,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consensus := computeIPLookupConsensus(tt.results)
+			if consensus.ip != tt.ip {
+				t.Fatal("unexpected IP", consensus.ip)
+			}
+			if consensus.confidence != tt.confidence {
+				t.Fatal("unexpected confidence", consensus.confidence)
+			}
+		})
+	}
+}
+
+func TestIPLookupCanonicalizesIPv6(t *testing.T) {
+	ip, err := (ipLookupClient{Logger: log.Log}).doWithCustomFunc(
+		context.Background(), func(
+			ctx context.Context, client *http.Client,
+			logger Logger, userAgent string,
+		) (string, error) {
+			return "2001:DB8:0:0::1", nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "2001:db8::1" {
+		t.Fatal("unexpected IP", ip)
+	}
+}
+
+func TestIPLookupWrongAddressFamily(t *testing.T) {
+	ip, err := (ipLookupClient{Family: "4", Logger: log.Log}).doWithCustomFunc(
+		context.Background(), func(
+			ctx context.Context, client *http.Client,
+			logger Logger, userAgent string,
+		) (string, error) {
+			return "2001:db8::1", nil
+		})
+	if !errors.Is(err, ErrWrongAddressFamily) {
+		t.Fatal("not the error we expected", err)
+	}
+	if ip != DefaultProbeIP {
+		t.Fatal("expected the default IP here")
+	}
+}
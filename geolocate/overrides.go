@@ -0,0 +1,118 @@
+package geolocate
+
+import "context"
+
+// Overrides allows to geolocate the probe without using the network
+// or using custom MMDB databases, e.g., for lab probes and tests.
+//
+// We geolocate offline when ProbeIP is set or when both ProbeASN and
+// ProbeCC are set. In such case, we do not look up the probe IP or
+// the resolver IP, and we do not update resources, so we only use the
+// databases that are already available when we need them.
+type Overrides struct {
+	// ASNDatabasePath is the optional path of the MMDB ASN database
+	// to use instead of the one of the ResourcesManager.
+	ASNDatabasePath string
+
+	// CountryDatabasePath is the optional path of the MMDB country
+	// database to use instead of the one of the ResourcesManager.
+	CountryDatabasePath string
+
+	// ProbeASN is the optional static probe ASN. When set, we
+	// do not use the ASN database to map the probe IP to an ASN.
+	ProbeASN uint
+
+	// ProbeCC is the optional static probe country code. When set, we
+	// do not use the country database to map the probe IP to a CC.
+	ProbeCC string
+
+	// ProbeIP is the optional static probe IP. When set, we do
+	// not look up the probe IP using external services.
+	ProbeIP string
+
+	// ProbeNetworkName is the network name to use along
+	// with ProbeASN. We ignore it when ProbeASN is not set.
+	ProbeNetworkName string
+}
+
+// Offline returns whether we can geolocate without using the network.
+func (ov Overrides) Offline() bool {
+	return ov.ProbeIP != "" || (ov.ProbeASN != 0 && ov.ProbeCC != "")
+}
+
+// apply modifies the task such that it honours the overrides.
+func (ov Overrides) apply(task *Task) {
+	if ov.ASNDatabasePath != "" || ov.CountryDatabasePath != "" || ov.Offline() {
+		task.resourcesManager = overridesResourcesManager{
+			ResourcesManager:    task.resourcesManager,
+			asnDatabasePath:     ov.ASNDatabasePath,
+			countryDatabasePath: ov.CountryDatabasePath,
+			skipUpdate: ov.Offline() || (ov.ASNDatabasePath != "" &&
+				ov.CountryDatabasePath != ""),
+		}
+	}
+	if ov.Offline() {
+		ip := ov.ProbeIP
+		if ip == "" {
+			ip = DefaultProbeIP
+		}
+		task.probeIPLookupper = staticLookupper{ip: ip}
+		task.probeIPv4Lookupper = nil
+		task.probeIPv6Lookupper = nil
+		task.enableResolverLookup = false
+	}
+	if ov.ProbeASN != 0 {
+		task.probeASNLookupper = staticLookupper{
+			asn: ov.ProbeASN, networkName: ov.ProbeNetworkName}
+	}
+	if ov.ProbeCC != "" {
+		task.countryLookupper = staticLookupper{cc: ov.ProbeCC}
+	}
+}
+
+type overridesResourcesManager struct {
+	ResourcesManager
+	asnDatabasePath     string
+	countryDatabasePath string
+	skipUpdate          bool
+}
+
+func (rm overridesResourcesManager) ASNDatabasePath() string {
+	if rm.asnDatabasePath != "" {
+		return rm.asnDatabasePath
+	}
+	return rm.ResourcesManager.ASNDatabasePath()
+}
+
+func (rm overridesResourcesManager) CountryDatabasePath() string {
+	if rm.countryDatabasePath != "" {
+		return rm.countryDatabasePath
+	}
+	return rm.ResourcesManager.CountryDatabasePath()
+}
+
+func (rm overridesResourcesManager) MaybeUpdateResources(ctx context.Context) error {
+	if rm.skipUpdate {
+		return nil
+	}
+	return rm.ResourcesManager.MaybeUpdateResources(ctx)
+}
+
+type staticLookupper struct {
+	asn         uint
+	cc          string
+	ip          string
+	networkName string
+}
+
+func (sl staticLookupper) LookupProbeIP(ctx context.Context) (*ipLookupConsensus, error) {
+	return &ipLookupConsensus{confidence: 1, ip: sl.ip}, nil
+}
+
+func (sl staticLookupper) LookupASN(path, ip string) (uint, string, error) {
+	return sl.asn, sl.networkName, nil
+}
+
+func (sl staticLookupper) LookupCC(path, ip string) (string, error) {
+	return sl.cc, nil
+}
@@ -0,0 +1,116 @@
+package geolocate
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOverridesOffline(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides Overrides
+		expect    bool
+	}{
+		{name: "with no overrides", overrides: Overrides{}, expect: false},
+		{name: "with probe IP", overrides: Overrides{ProbeIP: "8.8.8.8"}, expect: true},
+		{name: "with probe ASN only", overrides: Overrides{ProbeASN: 30722}, expect: false},
+		{name: "with probe CC only", overrides: Overrides{ProbeCC: "IT"}, expect: false},
+		{name: "with probe ASN and CC", overrides: Overrides{
+			ProbeASN: 30722, ProbeCC: "IT"}, expect: true},
+		{name: "with databases only", overrides: Overrides{
+			ASNDatabasePath: asnDBPath, CountryDatabasePath: countryDBPath}, expect: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.overrides.Offline() != tt.expect {
+				t.Fatal("unexpected result")
+			}
+		})
+	}
+}
+
+func TestTaskWithStaticOverrides(t *testing.T) {
+	task, err := NewTask(Config{
+		EnableResolverLookup: true,
+		Overrides: Overrides{
+			ProbeASN:         30722,
+			ProbeCC:          "IT",
+			ProbeNetworkName: "Vodafone Italia S.p.A.",
+		},
+		ResourcesManager: taskResourcesManager{err: errors.New("mocked error")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := task.Run(context.Background())
+	if err != nil {
+		t.Fatal(err) // we should not update resources
+	}
+	if out.ASN != 30722 || out.CountryCode != "IT" {
+		t.Fatal("unexpected ASN or CC")
+	}
+	if out.NetworkName != "Vodafone Italia S.p.A." {
+		t.Fatal("unexpected network name")
+	}
+	if out.ProbeIP != DefaultProbeIP {
+		t.Fatal("unexpected probe IP")
+	}
+	if out.DidResolverLookup || out.ResolverIP != DefaultResolverIP {
+		t.Fatal("we should not have looked up the resolver")
+	}
+}
+
+func TestTaskWithProbeIPAndCustomDatabases(t *testing.T) {
+	maybeFetchResources(t)
+	task, err := NewTask(Config{
+		EnableResolverLookup: true,
+		Overrides: Overrides{
+			ASNDatabasePath:     asnDBPath,
+			CountryDatabasePath: countryDBPath,
+			ProbeIP:             ipAddr,
+		},
+		ResourcesManager: taskResourcesManager{err: errors.New("mocked error")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := task.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ProbeIP != ipAddr {
+		t.Fatal("unexpected probe IP")
+	}
+	if out.ASN <= 0 || len(out.CountryCode) != 2 {
+		t.Fatalf("unexpected ASN or CC: %d %s", out.ASN, out.CountryCode)
+	}
+	if out.DidResolverLookup {
+		t.Fatal("we should not have looked up the resolver")
+	}
+}
+
+func TestOverridesResourcesManager(t *testing.T) {
+	expected := errors.New("mocked error")
+	rm := overridesResourcesManager{
+		ResourcesManager: taskResourcesManager{
+			asnDatabasePath:     "asn.mmdb",
+			countryDatabasePath: "country.mmdb",
+			err:                 expected,
+		},
+		asnDatabasePath: "custom-asn.mmdb",
+	}
+	if rm.ASNDatabasePath() != "custom-asn.mmdb" {
+		t.Fatal("unexpected ASN database path")
+	}
+	if rm.CountryDatabasePath() != "country.mmdb" {
+		t.Fatal("unexpected country database path")
+	}
+	if err := rm.MaybeUpdateResources(context.Background()); !errors.Is(err, expected) {
+		t.Fatal("not the error we expected", err)
+	}
+	rm.skipUpdate = true
+	if err := rm.MaybeUpdateResources(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
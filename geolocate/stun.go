@@ -18,6 +18,19 @@ type stunConfig struct {
 	Logger   Logger
 }
 
+type addressFamilyKey struct{}
+
+// withAddressFamily returns a context forcing STUN to use the
+// given address family. An empty family means any family.
+func withAddressFamily(ctx context.Context, family string) context.Context {
+	return context.WithValue(ctx, addressFamilyKey{}, family)
+}
+
+func addressFamily(ctx context.Context) string {
+	family, _ := ctx.Value(addressFamilyKey{}).(string)
+	return family
+}
+
 func stunDialer(network string, address string) (stunClient, error) {
 	return stun.Dial(network, address)
 }
@@ -29,7 +42,7 @@ func stunIPLookup(ctx context.Context, config stunConfig) (string, error) {
 		if dial == nil {
 			dial = stunDialer
 		}
-		clnt, err := dial("udp", config.Endpoint)
+		clnt, err := dial("udp"+addressFamily(ctx), config.Endpoint)
 		if err != nil {
 			return DefaultProbeIP, err
 		}
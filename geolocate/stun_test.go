@@ -152,3 +152,23 @@ func TestIPLookupWorksUsingSTUNGoogle(t *testing.T) {
 		t.Fatalf("not an IP address: '%s'", ip)
 	}
 }
+
+func TestSTUNIPLookupWithAddressFamily(t *testing.T) {
+	expected := errors.New("mocked error")
+	ctx := withAddressFamily(context.Background(), "6")
+	var network string
+	_, err := stunIPLookup(ctx, stunConfig{
+		Dial: func(n, address string) (stunClient, error) {
+			network = n
+			return nil, expected
+		},
+		Endpoint: "stun.l.google.com:19302",
+		Logger:   log.Log,
+	})
+	if !errors.Is(err, expected) {
+		t.Fatalf("not the error we expected: %+v", err)
+	}
+	if network != "udp6" {
+		t.Fatalf("not the network we expected: %s", network)
+	}
+}
@@ -18,12 +18,14 @@ type Resolver struct {
 	Primary         netx.DNSClient
 	PrimaryFailure  *atomicx.Int64
 	PrimaryQuery    *atomicx.Int64
+	PrimaryTimeout  time.Duration // default: 4 seconds
 	Fallback        netx.DNSClient
 	FallbackFailure *atomicx.Int64
 	FallbackQuery   *atomicx.Int64
 }
 
-// New creates a new session resolver.
+// New creates a new session resolver. We use config.Timeouts.DNS, if
+// set, as the time we wait for the primary before falling back.
 func New(config netx.Config) *Resolver {
 	primary, err := netx.NewDNSClientWithOverrides(config,
 		"https://cloudflare.com/dns-query", "dns.cloudflare.com", "", "")
@@ -34,6 +36,7 @@ func New(config netx.Config) *Resolver {
 		Primary:         primary,
 		PrimaryFailure:  atomicx.NewInt64(),
 		PrimaryQuery:    atomicx.NewInt64(),
+		PrimaryTimeout:  config.Timeouts.DNS,
 		Fallback:        fallback,
 		FallbackFailure: atomicx.NewInt64(),
 		FallbackQuery:   atomicx.NewInt64(),
@@ -58,9 +61,14 @@ func (r *Resolver) LookupHost(ctx context.Context, hostname string) ([]string, e
 	// Algorithm similar to Firefox TRR2 mode. See:
 	// https://wiki.mozilla.org/Trusted_Recursive_Resolver#DNS-over-HTTPS_Prefs_in_Firefox
 	// We use a higher timeout than Firefox's timeout (1.5s) to be on the safe side
-	// and therefore see to use DoH more often.
+	// and therefore see to use DoH more often. On high-latency networks
+	// you may want to further increase the timeout using PrimaryTimeout.
 	r.PrimaryQuery.Add(1)
-	trr2, cancel := context.WithTimeout(ctx, 4*time.Second)
+	timeout := 4 * time.Second
+	if r.PrimaryTimeout > 0 {
+		timeout = r.PrimaryTimeout
+	}
+	trr2, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	addrs, err := r.Primary.LookupHost(trr2, hostname)
 	if err != nil {
@@ -2,9 +2,12 @@ package sessionresolver_test
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/ooni/probe-engine/atomicx"
 	"github.com/ooni/probe-engine/internal/sessionresolver"
 	"github.com/ooni/probe-engine/netx"
 )
@@ -29,3 +32,64 @@ func TestFallbackWorks(t *testing.T) {
 		t.Fatal("not the counters we expected to see here")
 	}
 }
+
+func TestNewWithDNSTimeout(t *testing.T) {
+	reso := sessionresolver.New(netx.Config{
+		Timeouts: netx.Timeouts{DNS: 10 * time.Second},
+	})
+	defer reso.CloseIdleConnections()
+	if reso.PrimaryTimeout != 10*time.Second {
+		t.Fatal("not the timeout we expected")
+	}
+}
+
+type deadlineResolver struct {
+	deadline time.Time
+	err      error
+}
+
+func (r *deadlineResolver) LookupHost(ctx context.Context, hostname string) ([]string, error) {
+	r.deadline, _ = ctx.Deadline()
+	if r.err != nil {
+		return nil, r.err
+	}
+	return []string{"8.8.8.8"}, nil
+}
+
+func (r *deadlineResolver) Network() string {
+	return "deadline"
+}
+
+func (r *deadlineResolver) Address() string {
+	return ""
+}
+
+func TestPrimaryTimeout(t *testing.T) {
+	for _, timeout := range []time.Duration{0, time.Minute} {
+		primary := &deadlineResolver{err: errors.New("mocked error")}
+		reso := &sessionresolver.Resolver{
+			Primary:         netx.DNSClient{Resolver: primary},
+			PrimaryFailure:  atomicx.NewInt64(),
+			PrimaryQuery:    atomicx.NewInt64(),
+			PrimaryTimeout:  timeout,
+			Fallback:        netx.DNSClient{Resolver: &deadlineResolver{}},
+			FallbackFailure: atomicx.NewInt64(),
+			FallbackQuery:   atomicx.NewInt64(),
+		}
+		begin := time.Now()
+		addrs, err := reso.LookupHost(context.Background(), "dns.google")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(addrs) != 1 || addrs[0] != "8.8.8.8" {
+			t.Fatal("not the addrs we expected")
+		}
+		expected := timeout
+		if expected == 0 {
+			expected = 4 * time.Second
+		}
+		if delta := primary.deadline.Sub(begin); delta < expected || delta > expected+time.Second {
+			t.Fatal("not the deadline we expected")
+		}
+	}
+}
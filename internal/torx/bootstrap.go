@@ -0,0 +1,161 @@
+package torx
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cretz/bine/control"
+	"github.com/cretz/bine/tor"
+	"github.com/ooni/probe-engine/model"
+)
+
+// BootstrapEvent is a bootstrap status event emitted by tor.
+type BootstrapEvent struct {
+	Progress int64   `json:"progress"`
+	Summary  string  `json:"summary"`
+	T        float64 `json:"t"`
+	Tag      string  `json:"tag"`
+}
+
+// BootstrapReport describes how tor bootstrapped. The Guard is the first
+// hop of the circuits built by tor, i.e., the guard or the bridge, using
+// the "$fingerprint~nickname" format used by tor's control port.
+type BootstrapReport struct {
+	BootstrapTime float64          `json:"bootstrap_time"`
+	Circuits      int64            `json:"circuits"`
+	Events        []BootstrapEvent `json:"events"`
+	Guard         string           `json:"guard"`
+}
+
+// bootstrapMonitor collects the bootstrap status events.
+type bootstrapMonitor struct {
+	begin      time.Time
+	ch         chan control.Event
+	done       chan interface{}
+	events     []BootstrapEvent
+	logger     model.Logger
+	mu         sync.Mutex
+	onProgress func(ev BootstrapEvent)
+	wg         sync.WaitGroup
+}
+
+func newBootstrapMonitor(logger model.Logger, onProgress func(ev BootstrapEvent)) *bootstrapMonitor {
+	if logger == nil {
+		logger = model.DiscardLogger
+	}
+	return &bootstrapMonitor{
+		begin: time.Now(),
+		// Implementation note: bine blocks when writing into a listener
+		// channel, so we buffer and we keep draining until we have
+		// removed the listener from the control connection.
+		ch:         make(chan control.Event, 128),
+		done:       make(chan interface{}),
+		logger:     logger,
+		onProgress: onProgress,
+	}
+}
+
+// start starts the background goroutine processing events.
+func (bm *bootstrapMonitor) start() {
+	bm.wg.Add(1)
+	go func() {
+		defer bm.wg.Done()
+		for {
+			select {
+			case ev := <-bm.ch:
+				bm.handle(ev)
+			case <-bm.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops the background goroutine. You must call this function
+// after you have removed the channel from the event listeners, so that
+// we can safely process the events we have not processed yet.
+func (bm *bootstrapMonitor) stop() {
+	close(bm.done)
+	bm.wg.Wait()
+	for {
+		select {
+		case ev := <-bm.ch:
+			bm.handle(ev)
+		default:
+			return
+		}
+	}
+}
+
+func (bm *bootstrapMonitor) handle(ev control.Event) {
+	status, ok := ev.(*control.StatusEvent)
+	if !ok || status.Action != "BOOTSTRAP" {
+		return
+	}
+	progress, err := strconv.ParseInt(status.Arguments["PROGRESS"], 10, 64)
+	if err != nil {
+		return
+	}
+	bev := BootstrapEvent{
+		Progress: progress,
+		Summary:  status.Arguments["SUMMARY"],
+		T:        time.Since(bm.begin).Seconds(),
+		Tag:      status.Arguments["TAG"],
+	}
+	bm.logger.Infof("tor: bootstrap: %d%%: %s", bev.Progress, bev.Summary)
+	bm.mu.Lock()
+	bm.events = append(bm.events, bev)
+	bm.mu.Unlock()
+	if bm.onProgress != nil {
+		bm.onProgress(bev)
+	}
+}
+
+// collected returns the events collected so far.
+func (bm *bootstrapMonitor) collected() []BootstrapEvent {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return append([]BootstrapEvent{}, bm.events...)
+}
+
+// enableNetworkWithMonitor enables the network while collecting the
+// bootstrap events, if config allows us to subscribe to events.
+func enableNetworkWithMonitor(ctx context.Context, config StartConfig,
+	instance *tor.Tor) ([]BootstrapEvent, error) {
+	if config.AddEventListener == nil || config.RemoveEventListener == nil {
+		return nil, config.EnableNetwork(ctx, instance, true)
+	}
+	monitor := newBootstrapMonitor(config.Sess.Logger(), config.OnProgress)
+	if err := config.AddEventListener(
+		instance.Control, monitor.ch, control.EventCodeStatusClient); err != nil {
+		return nil, err
+	}
+	monitor.start()
+	err := config.EnableNetwork(ctx, instance, true)
+	config.RemoveEventListener(
+		instance.Control, monitor.ch, control.EventCodeStatusClient)
+	monitor.stop()
+	return monitor.collected(), err
+}
+
+// parseCircuitStatus parses the value of the circuit-status info key
+// and returns the number of built circuits and the first hop of the
+// first built circuit. Each line has the following format:
+//
+//	ID BUILT $FP~NICK,$FP~NICK,... KEY=VALUE...
+func parseCircuitStatus(value string) (circuits int64, guard string) {
+	for _, line := range strings.Split(value, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "BUILT" {
+			continue
+		}
+		circuits++
+		if guard == "" {
+			guard = strings.Split(fields[2], ",")[0]
+		}
+	}
+	return
+}
@@ -0,0 +1,39 @@
+package torx
+
+import (
+	"testing"
+
+	"github.com/cretz/bine/control"
+)
+
+func TestParseCircuitStatus(t *testing.T) {
+	circuits, guard := parseCircuitStatus(
+		"1 LAUNCHED $AAAA~alice PURPOSE=GENERAL\n" +
+			"2 BUILT $BBBB~bob,$CCCC~carol,$DDDD~dave PURPOSE=GENERAL\n" +
+			"3 BUILT $EEEE~eve,$FFFF~frank PURPOSE=GENERAL\n" +
+			"\n")
+	if circuits != 2 {
+		t.Fatal("unexpected number of circuits", circuits)
+	}
+	if guard != "$BBBB~bob" {
+		t.Fatal("unexpected guard", guard)
+	}
+}
+
+func TestBootstrapMonitorIgnoresUnrelatedEvents(t *testing.T) {
+	monitor := newBootstrapMonitor(nil, nil)
+	monitor.handle(&control.StatusEvent{Action: "CIRCUIT_ESTABLISHED"})
+	monitor.handle(&control.StatusEvent{Action: "BOOTSTRAP", Arguments: map[string]string{
+		"PROGRESS": "xx",
+	}})
+	monitor.handle(&control.StatusEvent{Action: "BOOTSTRAP", Arguments: map[string]string{
+		"PROGRESS": "10", "TAG": "conn_done", "SUMMARY": "Connected to a relay",
+	}})
+	events := monitor.collected()
+	if len(events) != 1 {
+		t.Fatal("unexpected number of events", len(events))
+	}
+	if events[0].Progress != 10 || events[0].Tag != "conn_done" {
+		t.Fatalf("unexpected event: %+v", events[0])
+	}
+}
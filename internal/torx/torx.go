@@ -11,10 +11,12 @@ import (
 
 	"github.com/cretz/bine/control"
 	"github.com/cretz/bine/tor"
+	"github.com/ooni/probe-engine/model"
 )
 
 // Session is the way in which this package sees a Session.
 type Session interface {
+	Logger() model.Logger
 	TempDir() string
 	TorArgs() []string
 	TorBinary() string
@@ -30,6 +32,7 @@ type Tunnel struct {
 	bootstrapTime time.Duration
 	instance      TorProcess
 	proxy         *url.URL
+	report        *BootstrapReport
 }
 
 // BootstrapTime is the bootstrsap time
@@ -40,6 +43,14 @@ func (tt *Tunnel) BootstrapTime() (duration time.Duration) {
 	return
 }
 
+// BootstrapReport returns the bootstrap report
+func (tt *Tunnel) BootstrapReport() (report *BootstrapReport) {
+	if tt != nil {
+		report = tt.report
+	}
+	return
+}
+
 // SOCKS5ProxyURL returns the URL of the SOCKS5 proxy
 func (tt *Tunnel) SOCKS5ProxyURL() (url *url.URL) {
 	if tt != nil {
@@ -61,10 +72,26 @@ type StartConfig struct {
 	Start         func(ctx context.Context, conf *tor.StartConf) (*tor.Tor, error)
 	EnableNetwork func(ctx context.Context, tor *tor.Tor, wait bool) error
 	GetInfo       func(ctrl *control.Conn, keys ...string) ([]*control.KeyVal, error)
+
+	// AddEventListener and RemoveEventListener are optional. When both
+	// are set, we use them to follow tor's bootstrap progress.
+	AddEventListener    func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error
+	RemoveEventListener func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error
+
+	// OnProgress is an optional callback called for each
+	// bootstrap event emitted by tor.
+	OnProgress func(ev BootstrapEvent)
 }
 
 // Start starts the tor tunnel
 func Start(ctx context.Context, sess Session) (*Tunnel, error) {
+	return StartWithProgress(ctx, sess, nil)
+}
+
+// StartWithProgress is like Start but also calls onProgress, if not
+// nil, for each bootstrap event emitted by tor.
+func StartWithProgress(ctx context.Context, sess Session,
+	onProgress func(ev BootstrapEvent)) (*Tunnel, error) {
 	return StartWithConfig(ctx, StartConfig{
 		Sess: sess,
 		Start: func(ctx context.Context, conf *tor.StartConf) (*tor.Tor, error) {
@@ -76,6 +103,13 @@ func Start(ctx context.Context, sess Session) (*Tunnel, error) {
 		GetInfo: func(ctrl *control.Conn, keys ...string) ([]*control.KeyVal, error) {
 			return ctrl.GetInfo(keys...)
 		},
+		AddEventListener: func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error {
+			return ctrl.AddEventListener(ch, codes...)
+		},
+		RemoveEventListener: func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error {
+			return ctrl.RemoveEventListener(ch, codes...)
+		},
+		OnProgress: onProgress,
 	})
 }
 
@@ -103,7 +137,8 @@ func StartWithConfig(ctx context.Context, config StartConfig) (*Tunnel, error) {
 	}
 	instance.StopProcessOnClose = true
 	start := time.Now()
-	if err := config.EnableNetwork(ctx, instance, true); err != nil {
+	events, err := enableNetworkWithMonitor(ctx, config, instance)
+	if err != nil {
 		instance.Close()
 		return nil, err
 	}
@@ -123,10 +158,20 @@ func StartWithConfig(ctx context.Context, config StartConfig) (*Tunnel, error) {
 		instance.Close()
 		return nil, fmt.Errorf("tor returned unsupported proxy")
 	}
+	report := &BootstrapReport{
+		BootstrapTime: stop.Sub(start).Seconds(),
+		Events:        events,
+	}
+	// The circuit status is nice to have, hence we ignore errors.
+	if info, err := config.GetInfo(instance.Control, "circuit-status"); err == nil &&
+		len(info) == 1 && info[0].Key == "circuit-status" {
+		report.Circuits, report.Guard = parseCircuitStatus(info[0].Val)
+	}
 	return &Tunnel{
 		bootstrapTime: stop.Sub(start),
 		instance:      instance,
 		proxy:         &url.URL{Scheme: "socks5", Host: proxyAddress},
+		report:        report,
 	}, nil
 }
 
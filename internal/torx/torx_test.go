@@ -45,6 +45,9 @@ func TestTunnelNil(t *testing.T) {
 	if tun.SOCKS5ProxyURL() != nil {
 		t.Fatal("not the url we expected")
 	}
+	if tun.BootstrapReport() != nil {
+		t.Fatal("not the report we expected")
+	}
 	tun.Stop() // ensure we don't crash
 }
 
@@ -207,3 +210,85 @@ func TestStartWithConfigSuccess(t *testing.T) {
 		t.Fatal("expected nil tunnel here")
 	}
 }
+
+func TestStartWithConfigBootstrapEvents(t *testing.T) {
+	ctx := context.Background()
+	var (
+		listener chan<- control.Event
+		removed  bool
+		progress []int64
+	)
+	tun, err := torx.StartWithConfig(ctx, torx.StartConfig{
+		Sess: &mockable.Session{},
+		Start: func(ctx context.Context, conf *tor.StartConf) (*tor.Tor, error) {
+			return &tor.Tor{}, nil
+		},
+		EnableNetwork: func(ctx context.Context, tor *tor.Tor, wait bool) error {
+			for _, value := range []string{"50", "100"} {
+				listener <- &control.StatusEvent{Action: "BOOTSTRAP", Arguments: map[string]string{
+					"PROGRESS": value, "TAG": "done", "SUMMARY": "Done",
+				}}
+			}
+			return nil
+		},
+		GetInfo: func(ctrl *control.Conn, keys ...string) ([]*control.KeyVal, error) {
+			if keys[0] == "circuit-status" {
+				return []*control.KeyVal{{
+					Key: "circuit-status",
+					Val: "1 BUILT $AAAA~guard,$BBBB~middle,$CCCC~exit PURPOSE=GENERAL",
+				}}, nil
+			}
+			return []*control.KeyVal{{Key: "net/listeners/socks", Val: "127.0.0.1:9050"}}, nil
+		},
+		AddEventListener: func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error {
+			listener = ch
+			return nil
+		},
+		RemoveEventListener: func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error {
+			removed = true
+			return nil
+		},
+		OnProgress: func(ev torx.BootstrapEvent) {
+			progress = append(progress, ev.Progress)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !removed {
+		t.Fatal("did not remove the event listener")
+	}
+	if len(progress) != 2 || progress[0] != 50 || progress[1] != 100 {
+		t.Fatalf("unexpected progress: %+v", progress)
+	}
+	report := tun.BootstrapReport()
+	if len(report.Events) != 2 || report.Events[1].Progress != 100 {
+		t.Fatalf("unexpected events: %+v", report.Events)
+	}
+	if report.Guard != "$AAAA~guard" || report.Circuits != 1 {
+		t.Fatalf("unexpected circuits info: %+v", report)
+	}
+}
+
+func TestStartWithConfigAddEventListenerFailure(t *testing.T) {
+	expected := errors.New("mocked error")
+	ctx := context.Background()
+	tun, err := torx.StartWithConfig(ctx, torx.StartConfig{
+		Sess: &mockable.Session{},
+		Start: func(ctx context.Context, conf *tor.StartConf) (*tor.Tor, error) {
+			return &tor.Tor{}, nil
+		},
+		AddEventListener: func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error {
+			return expected
+		},
+		RemoveEventListener: func(ctrl *control.Conn, ch chan<- control.Event, codes ...control.EventCode) error {
+			return nil
+		},
+	})
+	if !errors.Is(err, expected) {
+		t.Fatal("not the error we expected")
+	}
+	if tun != nil {
+		t.Fatal("expected nil tunnel here")
+	}
+}
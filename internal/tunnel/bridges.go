@@ -0,0 +1,146 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	pt "git.torproject.org/pluggable-transports/goptlib.git"
+	"github.com/ooni/probe-engine/internal/torx"
+	"gitlab.com/yawning/obfs4.git/transports"
+	"gitlab.com/yawning/obfs4.git/transports/base"
+)
+
+// DefaultMeekBridge is the meek bridge we use when the config
+// does not specify any bridge for the meek tunnel. It is the
+// meek-azure bridge shipped with Tor Browser.
+const DefaultMeekBridge = "meek_lite 192.0.2.2:2 97700DFE9F483596DDA6264C4D7DF7641E1E39CE " +
+	"url=https://meek.azureedge.net/ front=ajax.aspnetcdn.com"
+
+// ErrNoBridges indicates that a tunnel requires bridges but
+// the config does not contain any.
+var ErrNoBridges = errors.New("tunnel: no bridges configured")
+
+var (
+	transportsOnce sync.Once
+	transportsErr  error
+)
+
+// GetTransport returns the obfs4proxy transport with the given name. We
+// initialize the transports lazily and only once, because initializing
+// them a second time fails since they are already registered. Every
+// package using obfs4proxy transports should use this function.
+func GetTransport(name string) (base.Transport, error) {
+	transportsOnce.Do(func() {
+		transportsErr = transports.Init()
+	})
+	if transportsErr != nil {
+		return nil, transportsErr
+	}
+	txp := transports.Get(name)
+	if txp == nil {
+		return nil, fmt.Errorf("tunnel: unknown pluggable transport: %s", name)
+	}
+	return txp, nil
+}
+
+// bridgeTunnel is a tor tunnel using bridges and pluggable transports.
+type bridgeTunnel struct {
+	*torx.Tunnel
+	forwarder *socksForwarder
+}
+
+// Stop implements Tunnel.Stop.
+func (bt *bridgeTunnel) Stop() {
+	bt.Tunnel.Stop()
+	bt.forwarder.Stop()
+}
+
+// bridgeSession adds the bridges options to the tor args.
+type bridgeSession struct {
+	Session
+	args []string
+}
+
+// TorArgs implements torx.Session.TorArgs.
+func (bs bridgeSession) TorArgs() []string {
+	return append(append([]string{}, bs.Session.TorArgs()...), bs.args...)
+}
+
+// startBridges starts tor using the configured bridges, which must
+// all use the given pluggable transport. Rather than running an external
+// obfs4proxy binary, we run the pluggable transport in process and we
+// tell tor to use it as a SOCKS5 proxy for the transport.
+func startBridges(ctx context.Context, config Config, transport string, bridges []string) (Tunnel, error) {
+	if len(bridges) <= 0 {
+		return nil, ErrNoBridges
+	}
+	for _, bridge := range bridges {
+		if !strings.HasPrefix(bridge, transport+" ") {
+			return nil, fmt.Errorf("tunnel: not a %s bridge: %s", transport, bridge)
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err() // allows to write unit tests using this code
+	default:
+	}
+	txp, err := GetTransport(transport)
+	if err != nil {
+		return nil, err
+	}
+	stateDir := filepath.Join(config.workDir(), transport)
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return nil, err
+	}
+	factory, err := txp.ClientFactory(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	forwarder, err := newSocksForwarder(config.Session.Logger(),
+		func(target string, args pt.Args) (net.Conn, error) {
+			parsedArgs, err := factory.ParseArgs(&args)
+			if err != nil {
+				return nil, err
+			}
+			// Note: the tunnel outlives ctx, so we cannot use it here.
+			dialer := &net.Dialer{Timeout: 30 * time.Second}
+			return factory.Dial("tcp", target, dialer.Dial, parsedArgs)
+		})
+	if err != nil {
+		return nil, err
+	}
+	args := []string{
+		"UseBridges", "1",
+		"ClientTransportPlugin", fmt.Sprintf(
+			"%s socks5 %s", transport, forwarder.URL().Host),
+	}
+	for _, bridge := range bridges {
+		args = append(args, "Bridge", bridge)
+	}
+	tun, err := torx.StartWithProgress(ctx, bridgeSession{Session: config.Session, args: args},
+		config.torProgress())
+	if err != nil {
+		forwarder.Stop()
+		return nil, err
+	}
+	return &bridgeTunnel{Tunnel: tun, forwarder: forwarder}, nil
+}
+
+func startOBFS4(ctx context.Context, config Config) (Tunnel, error) {
+	return startBridges(ctx, config, "obfs4", config.Bridges)
+}
+
+func startMeek(ctx context.Context, config Config) (Tunnel, error) {
+	bridges := config.Bridges
+	if len(bridges) <= 0 {
+		bridges = []string{DefaultMeekBridge}
+	}
+	return startBridges(ctx, config, "meek_lite", bridges)
+}
@@ -0,0 +1,54 @@
+package tunnel
+
+import (
+	"sync"
+	"time"
+)
+
+// BootstrapMetrics contains the bootstrap metrics of a tunnel type.
+type BootstrapMetrics struct {
+	// Attempts is the number of times we tried to start the tunnel.
+	Attempts int64
+
+	// Failures is the number of times we failed to start the tunnel.
+	Failures int64
+
+	// LastBootstrapTime is the bootstrap time of the last tunnel
+	// of this type that we successfully started.
+	LastBootstrapTime time.Duration
+
+	// LastFailure is the error that occurred the last time we
+	// failed to start this type of tunnel, if any.
+	LastFailure string
+}
+
+var (
+	metricsMu sync.Mutex
+	metrics   = make(map[string]BootstrapMetrics)
+)
+
+// Metrics returns the bootstrap metrics of all the tunnel types
+// we have tried to start since the program started.
+func Metrics() map[string]BootstrapMetrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	out := make(map[string]BootstrapMetrics)
+	for name, m := range metrics {
+		out[name] = m
+	}
+	return out
+}
+
+func recordBootstrap(name string, tun Tunnel, err error) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	m := metrics[name]
+	m.Attempts++
+	if err != nil {
+		m.Failures++
+		m.LastFailure = err.Error()
+	} else {
+		m.LastBootstrapTime = tun.BootstrapTime()
+	}
+	metrics[name] = m
+}
@@ -0,0 +1,148 @@
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	pt "git.torproject.org/pluggable-transports/goptlib.git"
+)
+
+// ErrNoUpstreamProxy indicates that the proxy tunnel
+// requires an upstream proxy but we don't have one.
+var ErrNoUpstreamProxy = errors.New("tunnel: no upstream proxy configured")
+
+// proxyTunnel is a tunnel using an upstream proxy.
+type proxyTunnel struct {
+	bootstrapTime time.Duration
+	forwarder     *socksForwarder
+	proxy         *url.URL
+}
+
+// BootstrapTime implements Tunnel.BootstrapTime.
+func (tun *proxyTunnel) BootstrapTime() time.Duration {
+	return tun.bootstrapTime
+}
+
+// SOCKS5ProxyURL implements Tunnel.SOCKS5ProxyURL.
+func (tun *proxyTunnel) SOCKS5ProxyURL() *url.URL {
+	return tun.proxy
+}
+
+// Stop implements Tunnel.Stop.
+func (tun *proxyTunnel) Stop() {
+	if tun.forwarder != nil {
+		tun.forwarder.Stop()
+	}
+}
+
+// startProxy starts a tunnel using the upstream proxy. We use a SOCKS5
+// upstream proxy directly, while we expose a HTTP upstream proxy as
+// a local SOCKS5 proxy that uses the CONNECT method, since the rest
+// of the engine only knows how to speak with SOCKS5 proxies.
+func startProxy(ctx context.Context, config Config) (Tunnel, error) {
+	upstream := config.UpstreamProxy
+	if upstream == nil {
+		return nil, ErrNoUpstreamProxy
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	start := time.Now()
+	switch upstream.Scheme {
+	case "socks5":
+		return &proxyTunnel{bootstrapTime: time.Since(start), proxy: upstream}, nil
+	case "http", "https":
+		forwarder, err := newSocksForwarder(config.Session.Logger(),
+			func(target string, args pt.Args) (net.Conn, error) {
+				return httpConnect(upstream, target)
+			})
+		if err != nil {
+			return nil, err
+		}
+		return &proxyTunnel{
+			bootstrapTime: time.Since(start),
+			forwarder:     forwarder,
+			proxy:         forwarder.URL(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("tunnel: unsupported proxy scheme: %s", upstream.Scheme)
+	}
+}
+
+// httpConnect connects to target using the CONNECT method of the proxy.
+func httpConnect(proxy *url.URL, target string) (net.Conn, error) {
+	address := proxy.Host
+	if proxy.Port() == "" {
+		port := "80"
+		if proxy.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(proxy.Hostname(), port)
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if proxy.Scheme == "https" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{
+			ServerName: proxy.Hostname(),
+		})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		req.SetBasicAuth(proxy.User.Username(), password)
+		req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+		req.Header.Del("Authorization")
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		conn.Close()
+		return nil, fmt.Errorf("tunnel: CONNECT failed: %s", resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// bufferedConn is a net.Conn that first reads the data
+// that was buffered while reading the CONNECT response.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Read implements net.Conn.Read.
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -0,0 +1,88 @@
+package tunnel
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"sync"
+
+	pt "git.torproject.org/pluggable-transports/goptlib.git"
+	"github.com/ooni/probe-engine/model"
+)
+
+// socksForwarder is a local SOCKS5 server that uses a custom function
+// to connect to the target requested by the client. We use it to expose
+// pluggable transports and upstream proxies as a SOCKS5 proxy.
+type socksForwarder struct {
+	dial     func(target string, args pt.Args) (net.Conn, error)
+	listener *pt.SocksListener
+	logger   model.Logger
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+// newSocksForwarder creates a new socksForwarder listening on
+// a random localhost port and starts serving requests.
+func newSocksForwarder(logger model.Logger,
+	dial func(target string, args pt.Args) (net.Conn, error)) (*socksForwarder, error) {
+	listener, err := pt.ListenSocks("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	sf := &socksForwarder{dial: dial, listener: listener, logger: logger}
+	sf.wg.Add(1)
+	go sf.serve()
+	return sf, nil
+}
+
+// URL returns the URL of the SOCKS5 proxy.
+func (sf *socksForwarder) URL() *url.URL {
+	return &url.URL{Scheme: "socks5", Host: sf.listener.Addr().String()}
+}
+
+// Stop stops the forwarder. It does not wait for the connections
+// in progress to terminate, which will close when the peer does.
+func (sf *socksForwarder) Stop() {
+	sf.once.Do(func() {
+		sf.listener.Close()
+		sf.wg.Wait()
+	})
+}
+
+func (sf *socksForwarder) serve() {
+	defer sf.wg.Done()
+	for {
+		conn, err := sf.listener.AcceptSocks()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		go sf.handle(conn)
+	}
+}
+
+func (sf *socksForwarder) handle(conn *pt.SocksConn) {
+	defer conn.Close()
+	remote, err := sf.dial(conn.Req.Target, conn.Req.Args)
+	if err != nil {
+		sf.logger.Debugf("tunnel: cannot connect to %s: %s", conn.Req.Target, err.Error())
+		conn.Reject()
+		return
+	}
+	defer remote.Close()
+	if err := conn.Grant(nil); err != nil {
+		return
+	}
+	done := make(chan interface{}, 2)
+	go func() {
+		io.Copy(remote, conn)
+		done <- true
+	}()
+	go func() {
+		io.Copy(conn, remote)
+		done <- true
+	}()
+	<-done // the deferred Close calls will unblock the other goroutine
+}
@@ -1,10 +1,19 @@
-// Package tunnel contains code to create a psiphon or tor tunnel.
+// Package tunnel contains code to create tunnels used by the session
+// and by experiments, e.g., psiphon, tor, tor with obfs4 or meek
+// bridges, and an upstream SOCKS5 or HTTP proxy.
+//
+// We do not support snowflake yet because we don't have a snowflake
+// client among our dependencies. Use Register to add more tunnels.
 package tunnel
 
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/url"
+	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/ooni/probe-engine/internal/psiphonx"
@@ -28,32 +37,120 @@ type Tunnel interface {
 
 // Config contains config for the session tunnel.
 type Config struct {
-	Name    string
+	// BootstrapProgress is an optional callback called with the
+	// bootstrap progress of the tor based tunnels.
+	BootstrapProgress func(percentage float64, message string)
+
+	// Bridges contains the bridge lines used by the obfs4
+	// and meek tunnels, e.g., "obfs4 1.2.3.4:443 FINGERPRINT
+	// cert=... iat-mode=0". The meek tunnel uses a default
+	// bridge when this field is empty.
+	Bridges []string
+
+	// Name is the name of the tunnel.
+	Name string
+
+	// Session is the session.
 	Session Session
+
+	// UpstreamProxy is the SOCKS5 or HTTP proxy used by
+	// the proxy tunnel, e.g., "http://10.0.0.1:3128".
+	UpstreamProxy *url.URL
+
+	// WorkDir is the directory where tunnels store their state.
 	WorkDir string
 }
 
+func (c Config) workDir() string {
+	if c.WorkDir != "" {
+		return c.WorkDir
+	}
+	return filepath.Join(c.Session.TempDir(), "tunnel")
+}
+
+// torProgress adapts BootstrapProgress to torx.StartWithProgress.
+func (c Config) torProgress() func(ev torx.BootstrapEvent) {
+	if c.BootstrapProgress == nil {
+		return nil
+	}
+	return func(ev torx.BootstrapEvent) {
+		c.BootstrapProgress(float64(ev.Progress)/100, fmt.Sprintf(
+			"%s: bootstrap: %d%%: %s", c.Name, ev.Progress, ev.Summary))
+	}
+}
+
+// Factory creates a new tunnel from its config. A factory must
+// return either a non-nil tunnel or a non-nil error.
+type Factory func(ctx context.Context, config Config) (Tunnel, error)
+
+// ErrNilTunnel indicates that a factory returned neither
+// a tunnel nor an error.
+var ErrNilTunnel = errors.New("tunnel: factory returned a nil tunnel")
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]Factory{
+		"meek":  startMeek,
+		"obfs4": startOBFS4,
+		"proxy": startProxy,
+		"psiphon": func(ctx context.Context, config Config) (Tunnel, error) {
+			return psiphonx.Start(ctx, config.Session, psiphonx.Config{
+				WorkDir: config.WorkDir,
+			})
+		},
+		"tor": func(ctx context.Context, config Config) (Tunnel, error) {
+			return torx.StartWithProgress(ctx, config.Session, config.torProgress())
+		},
+	}
+)
+
+// Register registers a new type of tunnel.
+func Register(name string, factory Factory) error {
+	if name == "" || factory == nil {
+		return errors.New("tunnel: invalid tunnel registration")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, found := registry[name]; found {
+		return fmt.Errorf("tunnel: already registered: %s", name)
+	}
+	registry[name] = factory
+	return nil
+}
+
+// Names returns the sorted names of the available tunnels.
+func Names() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Start starts a new tunnel by name or returns an error. Note that if you
 // pass to this function the "" tunnel, you get back nil, nil.
 func Start(ctx context.Context, config Config) (Tunnel, error) {
 	logger := config.Session.Logger()
-	switch config.Name {
-	case "":
+	if config.Name == "" {
 		logger.Debugf("no tunnel has been requested")
 		return enforceNilContract(nil, nil)
-	case "psiphon":
-		logger.Infof("starting %s tunnel; please be patient...", config.Name)
-		tun, err := psiphonx.Start(ctx, config.Session, psiphonx.Config{
-			WorkDir: config.WorkDir,
-		})
-		return enforceNilContract(tun, err)
-	case "tor":
-		logger.Infof("starting %s tunnel; please be patient...", config.Name)
-		tun, err := torx.Start(ctx, config.Session)
-		return enforceNilContract(tun, err)
-	default:
+	}
+	registryMu.Lock()
+	factory := registry[config.Name]
+	registryMu.Unlock()
+	if factory == nil {
 		return nil, errors.New("unsupported tunnel")
 	}
+	logger.Infof("starting %s tunnel; please be patient...", config.Name)
+	tun, err := enforceNilContract(factory(ctx, config))
+	if err == nil && tun == nil {
+		err = ErrNilTunnel // a registered factory is misbehaving
+	}
+	recordBootstrap(config.Name, tun, err)
+	return tun, err
 }
 
 func enforceNilContract(tun Tunnel, err error) (Tunnel, error) {
@@ -1,13 +1,19 @@
 package tunnel_test
 
 import (
+	"bufio"
 	"context"
 	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/apex/log"
 	"github.com/ooni/probe-engine/internal/mockable"
 	"github.com/ooni/probe-engine/internal/tunnel"
+	"golang.org/x/net/proxy"
 )
 
 func TestNoTunnel(t *testing.T) {
@@ -78,3 +84,253 @@ func TestInvalidTunnel(t *testing.T) {
 		t.Fatal("expected nil tunnel here")
 	}
 }
+
+func TestNames(t *testing.T) {
+	names := tunnel.Names()
+	expected := []string{"meek", "obfs4", "proxy", "psiphon", "tor"}
+	if len(names) != len(expected) {
+		t.Fatalf("unexpected names: %+v", names)
+	}
+	for idx := range names {
+		if names[idx] != expected[idx] {
+			t.Fatalf("unexpected names: %+v", names)
+		}
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	factory := func(ctx context.Context, config tunnel.Config) (tunnel.Tunnel, error) {
+		return nil, errors.New("mocked error")
+	}
+	if err := tunnel.Register("tor", factory); err == nil {
+		t.Fatal("expected an error here")
+	}
+	if err := tunnel.Register("", factory); err == nil {
+		t.Fatal("expected an error here")
+	}
+	if err := tunnel.Register("antani", nil); err == nil {
+		t.Fatal("expected an error here")
+	}
+}
+
+func TestFactoryReturningNilTunnel(t *testing.T) {
+	factory := func(ctx context.Context, config tunnel.Config) (tunnel.Tunnel, error) {
+		return nil, nil
+	}
+	if err := tunnel.Register("niltunnel", factory); err != nil {
+		t.Fatal(err)
+	}
+	tun, err := tunnel.Start(context.Background(), tunnel.Config{
+		Name: "niltunnel",
+		Session: &mockable.Session{
+			MockableLogger: log.Log,
+		},
+	})
+	if !errors.Is(err, tunnel.ErrNilTunnel) {
+		t.Fatal("not the error we expected")
+	}
+	if tun != nil {
+		t.Fatal("expected nil tunnel here")
+	}
+	if m := tunnel.Metrics()["niltunnel"]; m.Attempts != 1 || m.Failures != 1 {
+		t.Fatalf("unexpected metrics: %+v", m)
+	}
+}
+
+func TestGetTransport(t *testing.T) {
+	for i := 0; i < 2; i++ { // the second time transports are already initialized
+		txp, err := tunnel.GetTransport("obfs4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if txp.Name() != "obfs4" {
+			t.Fatal("not the transport we expected")
+		}
+	}
+	txp, err := tunnel.GetTransport("antani")
+	if err == nil || err.Error() != "tunnel: unknown pluggable transport: antani" {
+		t.Fatal("not the error we expected")
+	}
+	if txp != nil {
+		t.Fatal("expected nil transport here")
+	}
+}
+
+func TestOBFS4TunnelWithoutBridges(t *testing.T) {
+	tun, err := tunnel.Start(context.Background(), tunnel.Config{
+		Name: "obfs4",
+		Session: &mockable.Session{
+			MockableLogger: log.Log,
+		},
+	})
+	if !errors.Is(err, tunnel.ErrNoBridges) {
+		t.Fatal("not the error we expected")
+	}
+	if tun != nil {
+		t.Fatal("expected nil tunnel here")
+	}
+}
+
+func TestOBFS4TunnelWithWrongBridge(t *testing.T) {
+	tun, err := tunnel.Start(context.Background(), tunnel.Config{
+		Bridges: []string{"meek_lite 192.0.2.2:2 url=https://example.com/"},
+		Name:    "obfs4",
+		Session: &mockable.Session{
+			MockableLogger: log.Log,
+		},
+	})
+	if err == nil {
+		t.Fatal("expected an error here")
+	}
+	if tun != nil {
+		t.Fatal("expected nil tunnel here")
+	}
+}
+
+func TestMeekTunnel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tun, err := tunnel.Start(ctx, tunnel.Config{
+		Name: "meek",
+		Session: &mockable.Session{
+			MockableLogger: log.Log,
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("not the error we expected")
+	}
+	if tun != nil {
+		t.Fatal("expected nil tunnel here")
+	}
+}
+
+func TestProxyTunnelWithoutUpstream(t *testing.T) {
+	tun, err := tunnel.Start(context.Background(), tunnel.Config{
+		Name: "proxy",
+		Session: &mockable.Session{
+			MockableLogger: log.Log,
+		},
+	})
+	if !errors.Is(err, tunnel.ErrNoUpstreamProxy) {
+		t.Fatal("not the error we expected")
+	}
+	if tun != nil {
+		t.Fatal("expected nil tunnel here")
+	}
+}
+
+func TestProxyTunnelWithSOCKS5Upstream(t *testing.T) {
+	upstream := &url.URL{Scheme: "socks5", Host: "127.0.0.1:9050"}
+	tun, err := tunnel.Start(context.Background(), tunnel.Config{
+		Name: "proxy",
+		Session: &mockable.Session{
+			MockableLogger: log.Log,
+		},
+		UpstreamProxy: upstream,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tun.Stop()
+	if tun.SOCKS5ProxyURL().String() != upstream.String() {
+		t.Fatal("not the proxy URL we expected")
+	}
+}
+
+func TestProxyTunnelWithHTTPUpstream(t *testing.T) {
+	// echo server
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	// minimal CONNECT proxy
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		for {
+			conn, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || req.Method != "CONNECT" {
+					return
+				}
+				target, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer target.Close()
+				io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n")
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	tun, err := tunnel.Start(context.Background(), tunnel.Config{
+		Name: "proxy",
+		Session: &mockable.Session{
+			MockableLogger: log.Log,
+		},
+		UpstreamProxy: &url.URL{Scheme: "http", Host: upstream.Addr().String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tun.Stop()
+	dialer, err := proxy.SOCKS5("tcp", tun.SOCKS5ProxyURL().Host, nil, proxy.Direct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := dialer.Dial("tcp", echo.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "antani"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 6)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "antani" {
+		t.Fatal("unexpected echo")
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	before := tunnel.Metrics()["proxy"]
+	tunnel.Start(context.Background(), tunnel.Config{
+		Name: "proxy",
+		Session: &mockable.Session{
+			MockableLogger: log.Log,
+		},
+	})
+	after := tunnel.Metrics()["proxy"]
+	if after.Attempts != before.Attempts+1 || after.Failures != before.Failures+1 {
+		t.Fatal("unexpected metrics")
+	}
+	if after.LastFailure != tunnel.ErrNoUpstreamProxy.Error() {
+		t.Fatal("unexpected last failure")
+	}
+}
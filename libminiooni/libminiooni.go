@@ -14,6 +14,7 @@ package libminiooni
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -24,6 +25,7 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -31,6 +33,7 @@ import (
 	engine "github.com/ooni/probe-engine"
 	"github.com/ooni/probe-engine/internal/humanizex"
 	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx"
 	"github.com/ooni/probe-engine/netx/selfcensor"
 	"github.com/ooni/probe-engine/version"
 	"github.com/pborman/getopt/v2"
@@ -39,10 +42,14 @@ import (
 // Options contains the options you can set from the CLI.
 type Options struct {
 	Annotations      []string
+	CAFiles          []string
+	CAReplace        bool
 	ExtraOptions     []string
 	HomeDir          string
 	Inputs           []string
 	InputFilePaths   []string
+	ListExperiments  bool
+	ListOptions      bool
 	NoJSON           bool
 	NoCollector      bool
 	ProbeServicesURL string
@@ -53,6 +60,8 @@ type Options struct {
 	TorArgs          []string
 	TorBinary        string
 	Tunnel           string
+	TunnelBridges    []string
+	TunnelProxy      string
 	Verbose          bool
 	Yes              bool
 }
@@ -71,6 +80,14 @@ func init() {
 	getopt.FlagLong(
 		&globalOptions.Annotations, "annotation", 'A', "Add annotaton", "KEY=VALUE",
 	)
+	getopt.FlagLong(
+		&globalOptions.CAFiles, "ca-file", 0,
+		"Trust the root CAs in a PEM file for the session's own traffic (may be specified multiple times)", "PATH",
+	)
+	getopt.FlagLong(
+		&globalOptions.CAReplace, "ca-replace", 0,
+		"Only trust the root CAs passed using --ca-file for the session's own traffic",
+	)
 	getopt.FlagLong(
 		&globalOptions.ExtraOptions, "option", 'O',
 		"Pass an option to the experiment", "KEY=VALUE",
@@ -87,6 +104,14 @@ func init() {
 		&globalOptions.Inputs, "input", 'i',
 		"Add test-dependent input to the test input", "INPUT",
 	)
+	getopt.FlagLong(
+		&globalOptions.ListExperiments, "list-experiments", 0,
+		"List the available experiments and exit",
+	)
+	getopt.FlagLong(
+		&globalOptions.ListOptions, "list-options", 0,
+		"Print the JSON Schema of the experiment options and exit",
+	)
 	getopt.FlagLong(
 		&globalOptions.NoJSON, "no-json", 'N', "Disable writing to disk",
 	)
@@ -121,7 +146,15 @@ func init() {
 	)
 	getopt.FlagLong(
 		&globalOptions.Tunnel, "tunnel", 0,
-		"Name of the tunnel to use (one of `tor`, `psiphon`)",
+		"Name of the tunnel to use (one of `meek`, `obfs4`, `proxy`, `psiphon`, `tor`)",
+	)
+	getopt.FlagLong(
+		&globalOptions.TunnelBridges, "tunnel-bridge", 0,
+		"Bridge line for the meek and obfs4 tunnels (may be specified multiple times)",
+	)
+	getopt.FlagLong(
+		&globalOptions.TunnelProxy, "tunnel-proxy", 0,
+		"Upstream SOCKS5 or HTTP proxy URL for the proxy tunnel", "URL",
 	)
 	getopt.FlagLong(
 		&globalOptions.Verbose, "verbose", 'v', "Increase verbosity",
@@ -149,10 +182,46 @@ func fatalIfFalse(cond bool, msg string) {
 // integrate this function to either handle the panic of ignore it.
 func Main() {
 	getopt.Parse()
+	if globalOptions.ListExperiments {
+		listExperiments(os.Stdout)
+		return
+	}
 	fatalIfFalse(len(getopt.Args()) == 1, "Missing experiment name")
+	if globalOptions.ListOptions {
+		listOptions(os.Stdout, getopt.Arg(0))
+		return
+	}
 	MainWithConfiguration(getopt.Arg(0), globalOptions)
 }
 
+// listOptions writes on w the JSON Schema of the options
+// of the experiment with the given name.
+func listOptions(w io.Writer, experimentName string) {
+	schema, err := engine.ExperimentOptionsSchema(experimentName)
+	fatalOnError(err, "cannot get the options schema")
+	data, err := json.MarshalIndent(schema, "", "  ")
+	fatalOnError(err, "cannot serialize the options schema")
+	fmt.Fprintf(w, "%s\n", string(data))
+}
+
+// listExperiments writes the sorted list of the available experiments
+// on w, marking the experiments registered by third-party code.
+func listExperiments(w io.Writer) {
+	thirdParty := make(map[string]bool)
+	for _, name := range engine.ThirdPartyExperiments() {
+		thirdParty[name] = true
+	}
+	names := engine.AllExperiments()
+	sort.Strings(names)
+	for _, name := range names {
+		if thirdParty[name] {
+			fmt.Fprintf(w, "%s (third-party)\n", name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\n", name)
+	}
+}
+
 func split(s string) (string, string, error) {
 	v := strings.SplitN(s, "=", 2)
 	if len(v) != 2 {
@@ -303,19 +372,33 @@ func MainWithConfiguration(experimentName string, currentOptions Options) {
 		proxyURL = mustParseURL(currentOptions.Proxy)
 	}
 
+	var tunnelProxyURL *url.URL
+	if currentOptions.TunnelProxy != "" {
+		tunnelProxyURL = mustParseURL(currentOptions.TunnelProxy)
+	}
+
 	kvstore2dir := filepath.Join(miniooniDir, "kvstore2")
 	kvstore, err := engine.NewFileSystemKVStore(kvstore2dir)
 	fatalOnError(err, "cannot create kvstore2 directory")
 
 	config := engine.SessionConfig{
-		AssetsDir:       assetsDir,
-		KVStore:         kvstore,
-		Logger:          logger,
-		ProxyURL:        proxyURL,
-		SoftwareName:    softwareName,
-		SoftwareVersion: softwareVersion,
-		TorArgs:         currentOptions.TorArgs,
-		TorBinary:       currentOptions.TorBinary,
+		AssetsDir:           assetsDir,
+		KVStore:             kvstore,
+		Logger:              logger,
+		ProxyURL:            proxyURL,
+		SoftwareName:        softwareName,
+		SoftwareVersion:     softwareVersion,
+		TorArgs:             currentOptions.TorArgs,
+		TorBinary:           currentOptions.TorBinary,
+		TunnelBridges:       currentOptions.TunnelBridges,
+		TunnelUpstreamProxy: tunnelProxyURL,
+	}
+	fatalIfFalse(!currentOptions.CAReplace || len(currentOptions.CAFiles) > 0,
+		"--ca-replace requires --ca-file")
+	if len(currentOptions.CAFiles) > 0 {
+		config.CertPool, err = netx.NewCertPoolFromPEMFiles(
+			currentOptions.CAReplace, currentOptions.CAFiles...)
+		fatalOnError(err, "cannot load --ca-file root CAs")
 	}
 	if currentOptions.ProbeServicesURL != "" {
 		config.AvailableProbeServices = []model.Service{{
@@ -374,6 +457,27 @@ func MainWithConfiguration(experimentName string, currentOptions Options) {
 	err = builder.SetOptionsGuessType(extraOptions)
 	fatalOnError(err, "cannot parse extraOptions")
 
+	submitter, err := engine.NewSubmitter(ctx, engine.SubmitterConfig{
+		Enabled: currentOptions.NoCollector == false,
+		Session: sess,
+		Logger:  log.Log,
+	})
+	fatalOnError(err, "cannot create submitter")
+
+	// Experiments such as run save and submit the measurement of each
+	// step on their own. We use a distinct submitter for the steps, so
+	// that each step ends up in the report of its own experiment.
+	sink := &stepSink{}
+	if builder.SupportsStepSink() {
+		sink.submitter, err = engine.NewSubmitter(ctx, engine.SubmitterConfig{
+			Enabled: currentOptions.NoCollector == false,
+			Session: sess,
+			Logger:  log.Log,
+		})
+		fatalOnError(err, "cannot create step submitter")
+		fatalOnError(builder.SetStepSink(sink), "cannot set step sink")
+	}
+
 	experiment := builder.NewExperiment()
 	defer func() {
 		log.Infof("experiment: recv %s, sent %s",
@@ -382,13 +486,6 @@ func MainWithConfiguration(experimentName string, currentOptions Options) {
 		)
 	}()
 
-	submitter, err := engine.NewSubmitter(ctx, engine.SubmitterConfig{
-		Enabled: currentOptions.NoCollector == false,
-		Session: sess,
-		Logger:  log.Log,
-	})
-	fatalOnError(err, "cannot create submitter")
-
 	saver, err := engine.NewSaver(engine.SaverConfig{
 		Enabled:    currentOptions.NoJSON == false,
 		Experiment: experiment,
@@ -396,6 +493,7 @@ func MainWithConfiguration(experimentName string, currentOptions Options) {
 		Logger:     log.Log,
 	})
 	fatalOnError(err, "cannot create saver")
+	sink.saver = saver
 
 	inputProcessor := engine.InputProcessor{
 		Annotations: annotations,
@@ -440,3 +538,17 @@ func (sw submitterWrapper) Submit(ctx context.Context, idx int, m *model.Measure
 	// policy: we do not stop the loop if measurement submission fails
 	return nil
 }
+
+// stepSink saves and submits the measurements of the steps of
+// experiments, such as run, that perform several measurements.
+type stepSink struct {
+	saver     engine.Saver
+	submitter engine.Submitter
+}
+
+func (ss *stepSink) SaveAndSubmit(ctx context.Context, m *model.Measurement) error {
+	err := ss.submitter.Submit(ctx, m)
+	warnOnError(err, "submitting step measurement failed")
+	// policy: we save the measurement even if its submission fails
+	return ss.saver.SaveMeasurement(m)
+}
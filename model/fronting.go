@@ -0,0 +1,17 @@
+package model
+
+// Fronting is a domain fronting configuration. When fronting, we
+// resolve the Front domain and we use it as the SNI, while we use
+// Host inside the HTTP Host header, such that the CDN serving
+// the Front domain routes our requests to Host.
+type Fronting struct {
+	// Front is the domain we connect to, e.g., "a0.awsstatic.com".
+	Front string `json:"front"`
+
+	// Host is the real domain, e.g., "dkyhjv0wpi2dk.cloudfront.net".
+	Host string `json:"host"`
+
+	// IPs optionally contains the IP addresses of Front, so that
+	// we connect to them rather than resolving Front.
+	IPs []string `json:"ips,omitempty"`
+}
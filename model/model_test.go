@@ -141,6 +141,30 @@ func TestScrubWeAreScrubbing(t *testing.T) {
 	}
 }
 
+func TestScrubWithIPv4AndIPv6(t *testing.T) {
+	const probeIPv6 = "2001:db8::1"
+	config := makeMeasurementConfig{
+		ProbeIP:  "130.192.91.211",
+		ProbeASN: "AS137",
+		ProbeCC:  "IT",
+	}
+	m := makeMeasurement(config)
+	m.TestKeys.(*fakeTestKeys).Body += fmt.Sprintf("<P>And also %s</P>", probeIPv6)
+	if err := m.Scrub(config.ProbeIP, probeIPv6); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(data, []byte(config.ProbeIP)) != 0 {
+		t.Fatal("IPv4 probe IP not fully redacted")
+	}
+	if bytes.Count(data, []byte(probeIPv6)) != 0 {
+		t.Fatal("IPv6 probe IP not fully redacted")
+	}
+}
+
 func TestScrubNoScrubbingRequired(t *testing.T) {
 	config := makeMeasurementConfig{
 		ProbeIP:             "130.192.91.211",
@@ -11,12 +11,18 @@ import (
 // is not the valid serialization of an IP address.
 var ErrInvalidProbeIP = errors.New("model: invalid probe IP")
 
-// Scrub scrubs the probeIP out of the measurement.
-func (m *Measurement) Scrub(probeIP string) (err error) {
+// Scrub scrubs the probe IPs out of the measurement. On dual-stack
+// networks, you should pass both the IPv4 and the IPv6 probe IPs.
+func (m *Measurement) Scrub(probeIPs ...string) (err error) {
 	// We now behave like we can share everything except the
 	// probe IP, which we instead cannot ever share
 	m.ProbeIP = DefaultProbeIP
-	return m.MaybeRewriteTestKeys(probeIP, json.Marshal)
+	for _, probeIP := range probeIPs {
+		if err := m.MaybeRewriteTestKeys(probeIP, json.Marshal); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Scrubbed is the string that replaces IP addresses.
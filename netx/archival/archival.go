@@ -18,6 +18,7 @@ import (
 
 	"github.com/ooni/probe-engine/geolocate"
 	"github.com/ooni/probe-engine/model"
+	"github.com/ooni/probe-engine/netx/certchain"
 	"github.com/ooni/probe-engine/netx/errorx"
 	"github.com/ooni/probe-engine/netx/trace"
 )
@@ -93,13 +94,16 @@ func NewTCPConnectList(begin time.Time, events []trace.Event) []TCPConnectEntry
 		ip, sport, _ := net.SplitHostPort(event.Address)
 		iport, _ := strconv.Atoi(sport)
 		out = append(out, TCPConnectEntry{
-			IP:   ip,
-			Port: iport,
+			ConnID: event.ConnID,
+			DialID: event.DialID,
+			IP:     ip,
+			Port:   iport,
 			Status: TCPConnectStatus{
 				Failure: NewFailure(event.Err),
 				Success: event.Err == nil,
 			},
-			T: event.Time.Sub(begin).Seconds(),
+			T:             event.Time.Sub(begin).Seconds(),
+			TransactionID: event.TransactionID,
 		})
 	}
 	return out
@@ -262,6 +266,8 @@ func (hh *HTTPHeader) UnmarshalJSON(d []byte) error {
 type HTTPRequest struct {
 	Body            HTTPBody                    `json:"body"`
 	BodyIsTruncated bool                        `json:"body_is_truncated"`
+	BodyLength      int64                       `json:"x_body_length,omitempty"`
+	BodySHA256      string                      `json:"x_body_sha256,omitempty"`
 	HeadersList     []HTTPHeader                `json:"headers_list"`
 	Headers         map[string]MaybeBinaryValue `json:"headers"`
 	Method          string                      `json:"method"`
@@ -277,6 +283,8 @@ type HTTPRequest struct {
 type HTTPResponse struct {
 	Body            HTTPBody                    `json:"body"`
 	BodyIsTruncated bool                        `json:"body_is_truncated"`
+	BodyLength      int64                       `json:"x_body_length,omitempty"`
+	BodySHA256      string                      `json:"x_body_sha256,omitempty"`
 	Code            int64                       `json:"code"`
 	HeadersList     []HTTPHeader                `json:"headers_list"`
 	Headers         map[string]MaybeBinaryValue `json:"headers"`
@@ -341,9 +349,12 @@ func newRequestList(begin time.Time, events []trace.Event) []RequestEntry {
 		case "http_transaction_start":
 			entry = RequestEntry{}
 			entry.T = ev.Time.Sub(begin).Seconds()
+			entry.TransactionID = ev.TransactionID
 		case "http_request_body_snapshot":
 			entry.Request.Body.Value = string(ev.Data)
 			entry.Request.BodyIsTruncated = ev.DataIsTruncated
+			entry.Request.BodyLength = int64(ev.NumBytes)
+			entry.Request.BodySHA256 = ev.DataSHA256
 		case "http_request_metadata":
 			entry.Request.Headers = make(map[string]MaybeBinaryValue)
 			addheaders(
@@ -360,6 +371,8 @@ func newRequestList(begin time.Time, events []trace.Event) []RequestEntry {
 		case "http_response_body_snapshot":
 			entry.Response.Body.Value = string(ev.Data)
 			entry.Response.BodyIsTruncated = ev.DataIsTruncated
+			entry.Response.BodyLength = int64(ev.NumBytes)
+			entry.Response.BodySHA256 = ev.DataSHA256
 		case "http_transaction_done":
 			entry.Failure = NewFailure(ev.Err)
 			out = append(out, entry)
@@ -454,12 +467,14 @@ func (qtype dnsQueryType) makeanswerentry(addr string, dbpath string) DNSAnswerE
 
 func (qtype dnsQueryType) makequeryentry(begin time.Time, ev trace.Event) DNSQueryEntry {
 	return DNSQueryEntry{
+		DialID:          ev.DialID,
 		Engine:          ev.Proto,
 		Failure:         NewFailure(ev.Err),
 		Hostname:        ev.Hostname,
 		QueryType:       string(qtype),
 		ResolverAddress: ev.Address,
 		T:               ev.Time.Sub(begin).Seconds(),
+		TransactionID:   ev.TransactionID,
 	}
 }
 
@@ -482,56 +497,74 @@ func NewNetworkEventsList(begin time.Time, events []trace.Event) []NetworkEvent
 	for _, ev := range events {
 		if ev.Name == errorx.ConnectOperation {
 			out = append(out, NetworkEvent{
-				Address:   ev.Address,
-				Failure:   NewFailure(ev.Err),
-				Operation: ev.Name,
-				Proto:     ev.Proto,
-				T:         ev.Time.Sub(begin).Seconds(),
+				Address:       ev.Address,
+				ConnID:        ev.ConnID,
+				DialID:        ev.DialID,
+				Failure:       NewFailure(ev.Err),
+				Operation:     ev.Name,
+				Proto:         ev.Proto,
+				T:             ev.Time.Sub(begin).Seconds(),
+				TransactionID: ev.TransactionID,
 			})
 			continue
 		}
 		if ev.Name == errorx.ReadOperation {
 			out = append(out, NetworkEvent{
-				Failure:   NewFailure(ev.Err),
-				Operation: ev.Name,
-				NumBytes:  int64(ev.NumBytes),
-				T:         ev.Time.Sub(begin).Seconds(),
+				ConnID:        ev.ConnID,
+				DialID:        ev.DialID,
+				Failure:       NewFailure(ev.Err),
+				Operation:     ev.Name,
+				NumBytes:      int64(ev.NumBytes),
+				T:             ev.Time.Sub(begin).Seconds(),
+				TransactionID: ev.TransactionID,
 			})
 			continue
 		}
 		if ev.Name == errorx.WriteOperation {
 			out = append(out, NetworkEvent{
-				Failure:   NewFailure(ev.Err),
-				Operation: ev.Name,
-				NumBytes:  int64(ev.NumBytes),
-				T:         ev.Time.Sub(begin).Seconds(),
+				ConnID:        ev.ConnID,
+				DialID:        ev.DialID,
+				Failure:       NewFailure(ev.Err),
+				Operation:     ev.Name,
+				NumBytes:      int64(ev.NumBytes),
+				T:             ev.Time.Sub(begin).Seconds(),
+				TransactionID: ev.TransactionID,
 			})
 			continue
 		}
 		if ev.Name == errorx.ReadFromOperation {
 			out = append(out, NetworkEvent{
-				Address:   ev.Address,
-				Failure:   NewFailure(ev.Err),
-				Operation: ev.Name,
-				NumBytes:  int64(ev.NumBytes),
-				T:         ev.Time.Sub(begin).Seconds(),
+				Address:       ev.Address,
+				ConnID:        ev.ConnID,
+				DialID:        ev.DialID,
+				Failure:       NewFailure(ev.Err),
+				Operation:     ev.Name,
+				NumBytes:      int64(ev.NumBytes),
+				T:             ev.Time.Sub(begin).Seconds(),
+				TransactionID: ev.TransactionID,
 			})
 			continue
 		}
 		if ev.Name == errorx.WriteToOperation {
 			out = append(out, NetworkEvent{
-				Address:   ev.Address,
-				Failure:   NewFailure(ev.Err),
-				Operation: ev.Name,
-				NumBytes:  int64(ev.NumBytes),
-				T:         ev.Time.Sub(begin).Seconds(),
+				Address:       ev.Address,
+				ConnID:        ev.ConnID,
+				DialID:        ev.DialID,
+				Failure:       NewFailure(ev.Err),
+				Operation:     ev.Name,
+				NumBytes:      int64(ev.NumBytes),
+				T:             ev.Time.Sub(begin).Seconds(),
+				TransactionID: ev.TransactionID,
 			})
 			continue
 		}
 		out = append(out, NetworkEvent{
-			Failure:   NewFailure(ev.Err),
-			Operation: ev.Name,
-			T:         ev.Time.Sub(begin).Seconds(),
+			ConnID:        ev.ConnID,
+			DialID:        ev.DialID,
+			Failure:       NewFailure(ev.Err),
+			Operation:     ev.Name,
+			T:             ev.Time.Sub(begin).Seconds(),
+			TransactionID: ev.TransactionID,
 		})
 	}
 	return out
@@ -539,16 +572,17 @@ func NewNetworkEventsList(begin time.Time, events []trace.Event) []NetworkEvent
 
 // TLSHandshake contains TLS handshake data
 type TLSHandshake struct {
-	CipherSuite        string             `json:"cipher_suite"`
-	ConnID             int64              `json:"conn_id,omitempty"`
-	Failure            *string            `json:"failure"`
-	NegotiatedProtocol string             `json:"negotiated_protocol"`
-	NoTLSVerify        bool               `json:"no_tls_verify"`
-	PeerCertificates   []MaybeBinaryValue `json:"peer_certificates"`
-	ServerName         string             `json:"server_name"`
-	T                  float64            `json:"t"`
-	TLSVersion         string             `json:"tls_version"`
-	TransactionID      int64              `json:"transaction_id,omitempty"`
+	CertificateChain   *certchain.Analysis `json:"x_certificate_chain,omitempty"`
+	CipherSuite        string              `json:"cipher_suite"`
+	ConnID             int64               `json:"conn_id,omitempty"`
+	Failure            *string             `json:"failure"`
+	NegotiatedProtocol string              `json:"negotiated_protocol"`
+	NoTLSVerify        bool                `json:"no_tls_verify"`
+	PeerCertificates   []MaybeBinaryValue  `json:"peer_certificates"`
+	ServerName         string              `json:"server_name"`
+	T                  float64             `json:"t"`
+	TLSVersion         string              `json:"tls_version"`
+	TransactionID      int64               `json:"transaction_id,omitempty"`
 }
 
 // NewTLSHandshakesList creates a new TLSHandshakesList
@@ -559,7 +593,9 @@ func NewTLSHandshakesList(begin time.Time, events []trace.Event) []TLSHandshake
 			continue
 		}
 		out = append(out, TLSHandshake{
+			CertificateChain:   makeCertificateChain(ev),
 			CipherSuite:        ev.TLSCipherSuite,
+			ConnID:             ev.ConnID,
 			Failure:            NewFailure(ev.Err),
 			NegotiatedProtocol: ev.TLSNegotiatedProto,
 			NoTLSVerify:        ev.NoTLSVerify,
@@ -567,11 +603,34 @@ func NewTLSHandshakesList(begin time.Time, events []trace.Event) []TLSHandshake
 			ServerName:         ev.TLSServerName,
 			T:                  ev.Time.Sub(begin).Seconds(),
 			TLSVersion:         ev.TLSVersion,
+			TransactionID:      ev.TransactionID,
 		})
 	}
 	return out
 }
 
+func makeCertificateChain(ev trace.Event) *certchain.Analysis {
+	analysis, err := certchain.Analyze(ev.TLSPeerCerts)
+	if err != nil {
+		return nil
+	}
+	// When we do not verify certificates we are most likely measuring on
+	// purpose a server using a private CA, which is not interception.
+	analysis.Interception = analysis.Interception && !ev.NoTLSVerify
+	return analysis
+}
+
+// TLSInterception returns whether the certificate chain of any
+// of the handshakes indicates that someone is intercepting TLS.
+func TLSInterception(in []TLSHandshake) bool {
+	for _, entry := range in {
+		if entry.CertificateChain != nil && entry.CertificateChain.Interception {
+			return true
+		}
+	}
+	return false
+}
+
 func makePeerCerts(in []*x509.Certificate) (out []MaybeBinaryValue) {
 	for _, e := range in {
 		out = append(out, MaybeBinaryValue{Value: string(e.Raw)})
@@ -15,6 +15,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/ooni/probe-engine/model"
 	"github.com/ooni/probe-engine/netx/archival"
+	"github.com/ooni/probe-engine/netx/certchain"
 	"github.com/ooni/probe-engine/netx/errorx"
 	"github.com/ooni/probe-engine/netx/trace"
 	"github.com/ooni/probe-engine/resources"
@@ -83,6 +84,32 @@ func TestNewTCPConnectList(t *testing.T) {
 			},
 			T: 0.18,
 		}},
+	}, {
+		name: "run with IDs",
+		args: args{
+			begin: begin,
+			events: []trace.Event{{
+				Address:       "8.8.8.8:853",
+				ConnID:        44321,
+				DialID:        3,
+				Duration:      30 * time.Millisecond,
+				Name:          errorx.ConnectOperation,
+				Proto:         "tcp",
+				Time:          begin.Add(130 * time.Millisecond),
+				TransactionID: 1,
+			}},
+		},
+		want: []archival.TCPConnectEntry{{
+			ConnID: 44321,
+			DialID: 3,
+			IP:     "8.8.8.8",
+			Port:   853,
+			Status: archival.TCPConnectStatus{
+				Success: true,
+			},
+			T:             0.13,
+			TransactionID: 1,
+		}},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -275,6 +302,28 @@ func TestNewRequestList(t *testing.T) {
 			},
 			T: 0.01,
 		}},
+	}, {
+		name: "run with body saved into a side file",
+		args: args{
+			begin: begin,
+			events: []trace.Event{{
+				Name: "http_transaction_start",
+				Time: begin.Add(10 * time.Millisecond),
+			}, {
+				Name:       "http_response_body_snapshot",
+				DataSHA256: "5f8a4b8a1a4b8d8b3c0f9a2f2a4e6b3a9e1d0c7b6a5f4e3d2c1b0a9f8e7d6c5b",
+				NumBytes:   1 << 20,
+			}, {
+				Name: "http_transaction_done",
+			}},
+		},
+		want: []archival.RequestEntry{{
+			Response: archival.HTTPResponse{
+				BodyLength: 1 << 20,
+				BodySHA256: "5f8a4b8a1a4b8d8b3c0f9a2f2a4e6b3a9e1d0c7b6a5f4e3d2c1b0a9f8e7d6c5b",
+			},
+			T: 0.01,
+		}},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -521,6 +570,43 @@ func TestNewNetworkEventsList(t *testing.T) {
 			Operation: errorx.CloseOperation,
 			T:         0.017,
 		}},
+	}, {
+		name: "run with IDs",
+		args: args{
+			begin: begin,
+			events: []trace.Event{{
+				Address:       "8.8.8.8:853",
+				ConnID:        44321,
+				DialID:        3,
+				Name:          errorx.ConnectOperation,
+				Proto:         "tcp",
+				Time:          begin.Add(7 * time.Millisecond),
+				TransactionID: 1,
+			}, {
+				ConnID:        44321,
+				DialID:        3,
+				Name:          errorx.ReadOperation,
+				NumBytes:      7117,
+				Time:          begin.Add(11 * time.Millisecond),
+				TransactionID: 1,
+			}},
+		},
+		want: []archival.NetworkEvent{{
+			Address:       "8.8.8.8:853",
+			ConnID:        44321,
+			DialID:        3,
+			Operation:     errorx.ConnectOperation,
+			Proto:         "tcp",
+			T:             0.007,
+			TransactionID: 1,
+		}, {
+			ConnID:        44321,
+			DialID:        3,
+			NumBytes:      7117,
+			Operation:     errorx.ReadOperation,
+			T:             0.011,
+			TransactionID: 1,
+		}},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -573,6 +659,15 @@ func TestNewTLSHandshakesList(t *testing.T) {
 			}},
 		},
 		want: []archival.TLSHandshake{{
+			CertificateChain: &certchain.Analysis{
+				// The fake certificates are not parsed, hence we only
+				// compute the hash of the empty SPKI.
+				Certificates: []certchain.Certificate{{
+					SPKISHA256: "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+				}, {
+					SPKISHA256: "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+				}},
+			},
 			CipherSuite:        "SUITE",
 			Failure:            archival.NewFailure(io.EOF),
 			NegotiatedProtocol: "h2",
@@ -586,6 +681,24 @@ func TestNewTLSHandshakesList(t *testing.T) {
 			T:          0.055,
 			TLSVersion: "TLSv1.3",
 		}},
+	}, {
+		name: "run with IDs",
+		args: args{
+			begin: begin,
+			events: []trace.Event{{
+				ConnID:        44321,
+				Name:          "tls_handshake_done",
+				TLSServerName: "x.org",
+				Time:          begin.Add(55 * time.Millisecond),
+				TransactionID: 1,
+			}},
+		},
+		want: []archival.TLSHandshake{{
+			ConnID:        44321,
+			ServerName:    "x.org",
+			T:             0.055,
+			TransactionID: 1,
+		}},
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -596,6 +709,26 @@ func TestNewTLSHandshakesList(t *testing.T) {
 	}
 }
 
+func TestTLSInterception(t *testing.T) {
+	if archival.TLSInterception(nil) {
+		t.Fatal("no handshakes should mean no interception")
+	}
+	handshakes := []archival.TLSHandshake{{
+		CertificateChain: nil,
+	}, {
+		CertificateChain: &certchain.Analysis{AnchorIsPublic: true},
+	}}
+	if archival.TLSInterception(handshakes) {
+		t.Fatal("unexpected interception")
+	}
+	handshakes = append(handshakes, archival.TLSHandshake{
+		CertificateChain: &certchain.Analysis{Interception: true},
+	})
+	if !archival.TLSInterception(handshakes) {
+		t.Fatal("expected interception")
+	}
+}
+
 func TestExtSpec_AddTo(t *testing.T) {
 	m := new(model.Measurement)
 	archival.ExtDNS.AddTo(m)
@@ -0,0 +1,214 @@
+// Package certchain analyzes the certificate chains presented by
+// TLS peers. We check whether the chain is anchored to a public CA
+// (i.e., to one of the CAs of the vendored gocertifi bundle), which
+// allows us to detect TLS interception, e.g., by a middlebox.
+package certchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ooni/probe-engine/netx/gocertifi"
+)
+
+// Certificate contains information on a certificate of the chain.
+type Certificate struct {
+	DNSNames   []string  `json:"dns_names"`
+	HasSCTs    bool      `json:"has_scts"`
+	Issuer     string    `json:"issuer"`
+	NotAfter   time.Time `json:"not_after"`
+	NotBefore  time.Time `json:"not_before"`
+	SPKISHA256 string    `json:"spki_sha256"`
+	Subject    string    `json:"subject"`
+}
+
+// Analysis is the analysis of a certificate chain.
+type Analysis struct {
+	// Anchor is the root that anchored the chain. If the chain is not
+	// anchored to a public CA, it is the last certificate presented by
+	// the peer, if such certificate is self signed, and nil otherwise.
+	Anchor *Certificate `json:"anchor"`
+
+	// AnchorIsPublic indicates whether the chain is anchored
+	// to one of the public CAs of the gocertifi bundle.
+	AnchorIsPublic bool `json:"anchor_is_public"`
+
+	// AnchorIsSystem indicates whether the chain is anchored to
+	// one of the CAs of the system root store. It is always false
+	// when the system root store is not available.
+	AnchorIsSystem bool `json:"anchor_is_system"`
+
+	// Certificates contains the certificates presented by the
+	// peer in the order in which the peer presented them.
+	Certificates []Certificate `json:"certificates"`
+
+	// Interception indicates that the chain is complete and anchored to
+	// a self-signed root that is not a public CA, hence it seems someone is
+	// intercepting TLS. The root is either the last certificate presented
+	// by the peer or a root of the system store. We do not flag interception
+	// when the chain is incomplete, e.g., because the server does not send
+	// the intermediate, nor when the chain is invalid for other reasons.
+	Interception bool `json:"interception"`
+
+	// StoresDisagree indicates that the system root store and the
+	// public CAs disagree on whether the chain is anchored, e.g.,
+	// because there is a corporate CA in the system root store.
+	StoresDisagree bool `json:"stores_disagree"`
+}
+
+// oidSCTList is the OID of the X.509 extension containing the
+// signed certificate timestamps (see RFC6962, Sect. 3.3).
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// NewCertificate returns information on the certificate.
+func NewCertificate(cert *x509.Certificate) Certificate {
+	out := Certificate{
+		DNSNames:   cert.DNSNames,
+		Issuer:     cert.Issuer.String(),
+		NotAfter:   cert.NotAfter,
+		NotBefore:  cert.NotBefore,
+		SPKISHA256: SPKISHA256(cert),
+		Subject:    cert.Subject.String(),
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			out.HasSCTs = true
+			break
+		}
+	}
+	return out
+}
+
+// SPKISHA256 returns the base64 encoded SHA256 of the certificate's
+// SubjectPublicKeyInfo. This is the format used by SPKI pins.
+func SPKISHA256(cert *x509.Certificate) string {
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(spki[:])
+}
+
+var (
+	publicRoots     *x509.CertPool
+	publicRootsOnce sync.Once
+	systemRoots     *x509.CertPool
+	systemRootsOnce sync.Once
+)
+
+// PublicRoots returns the pool of public CAs we use for the analysis.
+func PublicRoots() *x509.CertPool {
+	publicRootsOnce.Do(func() {
+		pool, err := gocertifi.CACerts()
+		if err != nil {
+			pool = x509.NewCertPool() // just in case
+		}
+		publicRoots = pool
+	})
+	return publicRoots
+}
+
+// SystemRoots returns the system root store or nil if the
+// system root store is not available on this platform.
+func SystemRoots() *x509.CertPool {
+	systemRootsOnce.Do(func() {
+		pool, err := x509.SystemCertPool()
+		if err == nil {
+			systemRoots = pool
+		}
+	})
+	return systemRoots
+}
+
+// ErrNoCertificates indicates that there are no certificates to analyze.
+var ErrNoCertificates = errors.New("certchain: no certificates")
+
+// Analyze analyzes the certificates presented by the peer, where the
+// first certificate is the leaf, using the PublicRoots and SystemRoots.
+func Analyze(certs []*x509.Certificate) (*Analysis, error) {
+	return AnalyzeWithRoots(certs, PublicRoots(), SystemRoots())
+}
+
+// AnalyzeWithRoots is like Analyze but uses the specified roots as the
+// public roots and the specified system roots, which may be nil. We check
+// whether the certificates chain to one of the roots regardless of the
+// hostname, because a wrong hostname does not imply interception, and
+// regardless of the current time, because an expired certificate does
+// not imply interception either.
+func AnalyzeWithRoots(
+	certs []*x509.Certificate, roots, system *x509.CertPool) (*Analysis, error) {
+	if len(certs) <= 0 {
+		return nil, ErrNoCertificates
+	}
+	out := new(Analysis)
+	for _, cert := range certs {
+		out.Certificates = append(out.Certificates, NewCertificate(cert))
+	}
+	if system != nil {
+		_, err := verify(certs, system)
+		out.AnchorIsSystem = err == nil
+	}
+	anchor, err := verify(certs, roots)
+	out.AnchorIsPublic = err == nil
+	out.StoresDisagree = system != nil && out.AnchorIsSystem != out.AnchorIsPublic
+	if err == nil {
+		info := NewCertificate(anchor)
+		out.Anchor = &info
+		return out, nil
+	}
+	if last := certs[len(certs)-1]; isSelfSigned(last) {
+		info := out.Certificates[len(certs)-1]
+		out.Anchor = &info
+	}
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthority) {
+		out.Interception = out.AnchorIsSystem || isCompleteChain(certs)
+	}
+	return out, nil
+}
+
+// isCompleteChain returns whether the certificates form a complete chain
+// from the leaf to the last certificate, which must be a self-signed root.
+// We require at least two certificates, because a lone self-signed
+// certificate is more likely a test server than a middlebox.
+func isCompleteChain(certs []*x509.Certificate) bool {
+	last := certs[len(certs)-1]
+	if len(certs) < 2 || !isSelfSigned(last) {
+		return false
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(last)
+	_, err := verify(certs, roots)
+	return err == nil
+}
+
+// verify verifies the certificates using the roots and returns the
+// root that anchors the chain on success.
+func verify(certs []*x509.Certificate, roots *x509.CertPool) (*x509.Certificate, error) {
+	leaf := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		CurrentTime:   leaf.NotBefore.Add(leaf.NotAfter.Sub(leaf.NotBefore) / 2),
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		Roots:         roots,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(chains) <= 0 || len(chains[0]) <= 0 {
+		return nil, ErrNoCertificates // should not happen
+	}
+	return chains[0][len(chains[0])-1], nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignature(
+		cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
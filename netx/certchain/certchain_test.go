@@ -0,0 +1,261 @@
+package certchain_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-engine/netx/certchain"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate signed by parent or a self
+// signed certificate if parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert,
+	notBefore, notAfter time.Time, extensions []pkix.Extension) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		BasicConstraintsValid: true,
+		ExtraExtensions:       extensions,
+		IsCA:                  parent == nil || name != "leaf",
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		NotAfter:              notAfter,
+		NotBefore:             notBefore,
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+	}
+	if name == "leaf" {
+		template.DNSNames = []string{"www.example.com", "example.com"}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	data, err := x509.CreateCertificate(
+		rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+type testChain struct {
+	root, intermediate, leaf *testCert
+}
+
+func newTestChain(t *testing.T, leafNotAfter time.Time) testChain {
+	const year = 365 * 24 * time.Hour
+	notBefore, notAfter := time.Now().Add(-10*year), time.Now().Add(10*year)
+	root := newTestCert(t, "root", nil, notBefore, notAfter, nil)
+	intermediate := newTestCert(t, "intermediate", root, notBefore, notAfter, nil)
+	leaf := newTestCert(t, "leaf", intermediate, leafNotAfter.Add(-year),
+		leafNotAfter, []pkix.Extension{{
+			Id:    asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2},
+			Value: []byte{0x04, 0x00},
+		}})
+	return testChain{root: root, intermediate: intermediate, leaf: leaf}
+}
+
+func (c testChain) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.root.cert)
+	return pool
+}
+
+func TestNewCertificate(t *testing.T) {
+	chain := newTestChain(t, time.Now().Add(time.Hour))
+	info := certchain.NewCertificate(chain.leaf.cert)
+	if info.Subject != "CN=leaf" || info.Issuer != "CN=intermediate" {
+		t.Fatal("unexpected subject or issuer")
+	}
+	if len(info.DNSNames) != 2 || info.DNSNames[0] != "www.example.com" {
+		t.Fatal("unexpected DNS names")
+	}
+	if !info.NotAfter.Equal(chain.leaf.cert.NotAfter) ||
+		!info.NotBefore.Equal(chain.leaf.cert.NotBefore) {
+		t.Fatal("unexpected validity")
+	}
+	spki := sha256.Sum256(chain.leaf.cert.RawSubjectPublicKeyInfo)
+	if info.SPKISHA256 != base64.StdEncoding.EncodeToString(spki[:]) {
+		t.Fatal("unexpected SPKI hash")
+	}
+	if !info.HasSCTs {
+		t.Fatal("expected SCTs")
+	}
+	if certchain.NewCertificate(chain.intermediate.cert).HasSCTs {
+		t.Fatal("expected no SCTs")
+	}
+}
+
+func TestAnalyzeNoCertificates(t *testing.T) {
+	analysis, err := certchain.Analyze(nil)
+	if !errors.Is(err, certchain.ErrNoCertificates) {
+		t.Fatal("not the error we expected")
+	}
+	if analysis != nil {
+		t.Fatal("expected nil analysis")
+	}
+}
+
+func TestAnalyzeWithRootsAnchored(t *testing.T) {
+	chain := newTestChain(t, time.Now().Add(time.Hour))
+	certs := []*x509.Certificate{chain.leaf.cert, chain.intermediate.cert}
+	analysis, err := certchain.AnalyzeWithRoots(certs, chain.roots(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !analysis.AnchorIsPublic || analysis.Interception {
+		t.Fatal("the chain should be anchored")
+	}
+	if analysis.Anchor == nil || analysis.Anchor.Subject != "CN=root" {
+		t.Fatal("unexpected anchor")
+	}
+	if len(analysis.Certificates) != 2 ||
+		analysis.Certificates[1].Subject != "CN=intermediate" {
+		t.Fatal("unexpected certificates")
+	}
+}
+
+func TestAnalyzeWithRootsExpiredLeaf(t *testing.T) {
+	chain := newTestChain(t, time.Now().Add(-time.Hour))
+	certs := []*x509.Certificate{chain.leaf.cert, chain.intermediate.cert}
+	analysis, err := certchain.AnalyzeWithRoots(certs, chain.roots(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !analysis.AnchorIsPublic || analysis.Interception {
+		t.Fatal("an expired chain should still be anchored")
+	}
+}
+
+func TestAnalyzeWithRootsStoresDisagree(t *testing.T) {
+	chain := newTestChain(t, time.Now().Add(time.Hour))
+	certs := []*x509.Certificate{chain.leaf.cert, chain.intermediate.cert}
+	analysis, err := certchain.AnalyzeWithRoots(
+		certs, x509.NewCertPool(), chain.roots())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.AnchorIsPublic || !analysis.AnchorIsSystem {
+		t.Fatal("the chain should only be anchored in the system store")
+	}
+	if !analysis.StoresDisagree || !analysis.Interception {
+		t.Fatal("the stores should disagree and we should flag interception")
+	}
+	analysis, err = certchain.AnalyzeWithRoots(certs, chain.roots(), chain.roots())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !analysis.AnchorIsPublic || !analysis.AnchorIsSystem || analysis.StoresDisagree {
+		t.Fatal("the stores should agree")
+	}
+}
+
+func TestAnalyzeInterception(t *testing.T) {
+	chain := newTestChain(t, time.Now().Add(time.Hour))
+	certs := []*x509.Certificate{
+		chain.leaf.cert, chain.intermediate.cert, chain.root.cert}
+	analysis, err := certchain.Analyze(certs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.AnchorIsPublic || !analysis.Interception {
+		t.Fatal("we should have detected interception")
+	}
+	if analysis.Anchor == nil || analysis.Anchor.Subject != "CN=root" {
+		t.Fatal("the self signed root should be the anchor")
+	}
+}
+
+func TestAnalyzeMissingIntermediate(t *testing.T) {
+	chain := newTestChain(t, time.Now().Add(time.Hour))
+	for _, certs := range [][]*x509.Certificate{
+		{chain.leaf.cert},
+		{chain.leaf.cert, chain.root.cert},
+	} {
+		analysis, err := certchain.Analyze(certs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if analysis.AnchorIsPublic || analysis.Interception {
+			t.Fatal("an incomplete chain does not imply interception")
+		}
+	}
+	// The same happens when the chain is anchored to a public CA because
+	// Go does not fetch the missing intermediate using AIA.
+	analysis, err := certchain.AnalyzeWithRoots(
+		[]*x509.Certificate{chain.leaf.cert}, chain.roots(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.AnchorIsPublic || analysis.Interception {
+		t.Fatal("an incomplete chain does not imply interception")
+	}
+}
+
+func TestAnalyzeSelfSignedCertificate(t *testing.T) {
+	const year = 365 * 24 * time.Hour
+	cert := newTestCert(t, "root", nil, time.Now().Add(-year), time.Now().Add(year), nil)
+	analysis, err := certchain.Analyze([]*x509.Certificate{cert.cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.AnchorIsPublic || analysis.Interception {
+		t.Fatal("a self signed certificate does not imply interception")
+	}
+	if analysis.Anchor == nil || analysis.Anchor.Subject != "CN=root" {
+		t.Fatal("the self signed certificate should be the anchor")
+	}
+}
+
+func TestAnalyzeUnparsedCertificate(t *testing.T) {
+	// This is what archival tests use as fake certificates
+	analysis, err := certchain.Analyze([]*x509.Certificate{{Raw: []byte("deadbeef")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.AnchorIsPublic || analysis.Anchor != nil {
+		t.Fatal("unexpected anchor")
+	}
+}
+
+func TestSPKISHA256(t *testing.T) {
+	chain := newTestChain(t, time.Now().Add(time.Hour))
+	spki := sha256.Sum256(chain.root.cert.RawSubjectPublicKeyInfo)
+	if certchain.SPKISHA256(chain.root.cert) != base64.StdEncoding.EncodeToString(spki[:]) {
+		t.Fatal("unexpected SPKI hash")
+	}
+}
+
+func TestSystemRoots(t *testing.T) {
+	if certchain.SystemRoots() != certchain.SystemRoots() {
+		t.Fatal("we should load the system roots once")
+	}
+}
+
+func TestPublicRoots(t *testing.T) {
+	if certchain.PublicRoots() != certchain.PublicRoots() {
+		t.Fatal("we should create the public roots once")
+	}
+}
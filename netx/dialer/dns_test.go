@@ -6,12 +6,10 @@ import (
 	"io"
 	"net"
 	"testing"
-	"time"
 
-	"github.com/ooni/probe-engine/legacy/netx/handlers"
-	"github.com/ooni/probe-engine/legacy/netx/modelx"
 	"github.com/ooni/probe-engine/netx/dialer"
 	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/trace"
 )
 
 func TestDNSDialerNoPort(t *testing.T) {
@@ -100,17 +98,14 @@ func TestDNSDialerDialForManyIPSuccess(t *testing.T) {
 }
 
 func TestDNSDialerDialSetsDialID(t *testing.T) {
-	saver := &handlers.SavingHandler{}
-	ctx := modelx.WithMeasurementRoot(context.Background(), &modelx.MeasurementRoot{
-		Beginning: time.Now(),
-		Handler:   saver,
-	})
-	dialer := dialer.DNSDialer{Dialer: dialer.EmitterDialer{
+	saver := &trace.Saver{}
+	dialer := dialer.DNSDialer{Dialer: dialer.SaverDialer{
 		Dialer: dialer.EOFConnDialer{},
+		Saver:  saver,
 	}, Resolver: MockableResolver{
 		Addresses: []string{"1.1.1.1", "8.8.8.8"},
 	}}
-	conn, err := dialer.DialContext(ctx, "tcp", "dot.dns:853")
+	conn, err := dialer.DialContext(context.Background(), "tcp", "dot.dns:853")
 	if err != nil {
 		t.Fatal("expected nil error here")
 	}
@@ -119,13 +114,11 @@ func TestDNSDialerDialSetsDialID(t *testing.T) {
 	}
 	conn.Close()
 	events := saver.Read()
-	if len(events) != 2 {
+	if len(events) != 1 {
 		t.Fatal("unexpected number of events")
 	}
-	for _, ev := range events {
-		if ev.Connect != nil && ev.Connect.DialID == 0 {
-			t.Fatal("unexpected DialID")
-		}
+	if events[0].DialID == 0 {
+		t.Fatal("unexpected DialID")
 	}
 }
 
@@ -0,0 +1,34 @@
+package dialer
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// ErrWrongAddressFamily indicates that we refused to dial an
+// IP address that does not belong to the forced address family.
+var ErrWrongAddressFamily = errors.New("dialer: wrong address family")
+
+// AddressFamilyDialer is a Dialer that only uses the given address
+// family. You should use it below a DNSDialer, such that we skip the
+// resolved addresses that do not belong to the address family.
+type AddressFamilyDialer struct {
+	Dialer
+	Family string // either "4" or "6"
+}
+
+// DialContext implements Dialer.DialContext
+func (d AddressFamilyDialer) DialContext(
+	ctx context.Context, network, address string) (net.Conn, error) {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		if ip := net.ParseIP(host); ip != nil && (ip.To4() != nil) != (d.Family == "4") {
+			return nil, ErrWrongAddressFamily
+		}
+	}
+	switch network {
+	case "tcp", "udp":
+		network += d.Family
+	}
+	return d.Dialer.DialContext(ctx, network, address)
+}
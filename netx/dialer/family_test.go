@@ -0,0 +1,83 @@
+package dialer_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/ooni/probe-engine/netx/dialer"
+)
+
+type networkRecorderDialer struct {
+	network string
+}
+
+func (d *networkRecorderDialer) DialContext(
+	ctx context.Context, network, address string) (net.Conn, error) {
+	d.network = network
+	return nil, errors.New("mocked error")
+}
+
+func TestAddressFamilyDialer(t *testing.T) {
+	tests := []struct {
+		name    string
+		family  string
+		network string
+		address string
+		expect  string
+		err     error
+	}{{
+		name:    "with IPv4 and tcp",
+		family:  "4",
+		network: "tcp",
+		address: "8.8.8.8:443",
+		expect:  "tcp4",
+	}, {
+		name:    "with IPv6 and udp",
+		family:  "6",
+		network: "udp",
+		address: "[2001:4860:4860::8888]:53",
+		expect:  "udp6",
+	}, {
+		name:    "with IPv4 and an IPv6 address",
+		family:  "4",
+		network: "tcp",
+		address: "[2001:4860:4860::8888]:443",
+		err:     dialer.ErrWrongAddressFamily,
+	}, {
+		name:    "with IPv6 and an IPv4 address",
+		family:  "6",
+		network: "tcp",
+		address: "8.8.8.8:443",
+		err:     dialer.ErrWrongAddressFamily,
+	}, {
+		name:    "with a domain name",
+		family:  "6",
+		network: "tcp",
+		address: "dns.google:443",
+		expect:  "tcp6",
+	}, {
+		name:    "with an already forced network",
+		family:  "4",
+		network: "tcp4",
+		address: "8.8.8.8:443",
+		expect:  "tcp4",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &networkRecorderDialer{}
+			d := dialer.AddressFamilyDialer{Dialer: recorder, Family: tt.family}
+			conn, err := d.DialContext(context.Background(), tt.network, tt.address)
+			if conn != nil {
+				t.Fatal("expected nil conn here")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatal("not the error we expected", err)
+			}
+			if recorder.network != tt.expect {
+				t.Fatal("unexpected network", recorder.network)
+			}
+		})
+	}
+}
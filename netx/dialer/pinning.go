@@ -0,0 +1,56 @@
+package dialer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+
+	"github.com/ooni/probe-engine/netx/certchain"
+	"github.com/ooni/probe-engine/netx/errorx"
+)
+
+// PinningTLSHandshaker is a TLSHandshaker that fails the handshake
+// with errorx.ErrSSLPinMismatch when none of the certificates of the
+// peer matches the SPKI pins configured for the server name. On such
+// failure, we also return the connection state, so that the savers
+// can record the certificates presented by the peer.
+type PinningTLSHandshaker struct {
+	TLSHandshaker
+	Pins map[string][]string // server name -> base64 SHA256 of SPKIs
+}
+
+// Handshake implements Handshaker.Handshake
+func (h PinningTLSHandshaker) Handshake(
+	ctx context.Context, conn net.Conn, config *tls.Config,
+) (net.Conn, tls.ConnectionState, error) {
+	tlsconn, state, err := h.TLSHandshaker.Handshake(ctx, conn, config)
+	if err != nil {
+		return nil, state, err
+	}
+	pins := h.Pins[config.ServerName]
+	if len(pins) <= 0 || pinsMatch(pins, state) {
+		return tlsconn, state, nil
+	}
+	tlsconn.Close()
+	return nil, state, errorx.ErrSSLPinMismatch
+}
+
+// pinsMatch returns whether any of the pins matches any of the
+// certificates presented by the peer or any of the certificates
+// of the verified chains, which also contain the roots.
+func pinsMatch(pins []string, state tls.ConnectionState) bool {
+	certs := append([]*x509.Certificate{}, state.PeerCertificates...)
+	for _, chain := range state.VerifiedChains {
+		certs = append(certs, chain...)
+	}
+	for _, cert := range certs {
+		spki := certchain.SPKISHA256(cert)
+		for _, pin := range pins {
+			if pin == spki {
+				return true
+			}
+		}
+	}
+	return false
+}
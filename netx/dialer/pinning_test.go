@@ -0,0 +1,118 @@
+package dialer_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/ooni/probe-engine/netx/dialer"
+	"github.com/ooni/probe-engine/netx/errorx"
+)
+
+type StateTLSHandshaker struct {
+	Conn  *dialer.FakeConn
+	Err   error
+	State tls.ConnectionState
+}
+
+func (h StateTLSHandshaker) Handshake(
+	ctx context.Context, conn net.Conn, config *tls.Config,
+) (net.Conn, tls.ConnectionState, error) {
+	if h.Err != nil {
+		return nil, tls.ConnectionState{}, h.Err
+	}
+	return h.Conn, h.State, nil
+}
+
+func spkiPin(spki string) string {
+	sum := sha256.Sum256([]byte(spki))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func pinningState() tls.ConnectionState {
+	leaf := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("leaf")}
+	intermediate := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("intermediate")}
+	root := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("root")}
+	return tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, intermediate},
+		VerifiedChains:   [][]*x509.Certificate{{leaf, intermediate, root}},
+	}
+}
+
+func TestPinningTLSHandshakerFailure(t *testing.T) {
+	expected := errors.New("mocked error")
+	h := dialer.PinningTLSHandshaker{
+		TLSHandshaker: StateTLSHandshaker{Err: expected},
+		Pins:          map[string][]string{"x.org": {spkiPin("leaf")}},
+	}
+	conn, _, err := h.Handshake(
+		context.Background(), new(dialer.FakeConn), &tls.Config{ServerName: "x.org"})
+	if !errors.Is(err, expected) {
+		t.Fatal("not the error we expected")
+	}
+	if conn != nil {
+		t.Fatal("expected nil conn here")
+	}
+}
+
+func TestPinningTLSHandshakerMatch(t *testing.T) {
+	for _, pin := range []string{"leaf", "intermediate", "root"} {
+		h := dialer.PinningTLSHandshaker{
+			TLSHandshaker: StateTLSHandshaker{
+				Conn: new(dialer.FakeConn), State: pinningState()},
+			Pins: map[string][]string{"x.org": {spkiPin("antani"), spkiPin(pin)}},
+		}
+		conn, _, err := h.Handshake(
+			context.Background(), new(dialer.FakeConn), &tls.Config{ServerName: "x.org"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conn == nil {
+			t.Fatal("expected non-nil conn here")
+		}
+	}
+}
+
+func TestPinningTLSHandshakerNoPinsForServerName(t *testing.T) {
+	h := dialer.PinningTLSHandshaker{
+		TLSHandshaker: StateTLSHandshaker{
+			Conn: new(dialer.FakeConn), State: pinningState()},
+		Pins: map[string][]string{"x.org": {spkiPin("antani")}},
+	}
+	conn, _, err := h.Handshake(
+		context.Background(), new(dialer.FakeConn), &tls.Config{ServerName: "y.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn == nil {
+		t.Fatal("expected non-nil conn here")
+	}
+}
+
+func TestPinningTLSHandshakerMismatch(t *testing.T) {
+	h := dialer.ErrorWrapperTLSHandshaker{TLSHandshaker: dialer.PinningTLSHandshaker{
+		TLSHandshaker: StateTLSHandshaker{
+			Conn: new(dialer.FakeConn), State: pinningState()},
+		Pins: map[string][]string{"x.org": {spkiPin("antani")}},
+	}}
+	conn, state, err := h.Handshake(
+		context.Background(), new(dialer.FakeConn), &tls.Config{ServerName: "x.org"})
+	if !errors.Is(err, errorx.ErrSSLPinMismatch) {
+		t.Fatal("not the error we expected")
+	}
+	var errWrapper *errorx.ErrWrapper
+	if !errors.As(err, &errWrapper) || errWrapper.Failure != errorx.FailureSSLPinMismatch {
+		t.Fatal("not the failure we expected")
+	}
+	if conn != nil {
+		t.Fatal("expected nil conn here")
+	}
+	if len(state.PeerCertificates) != 2 {
+		t.Fatal("we should return the state on mismatch")
+	}
+}
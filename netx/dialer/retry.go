@@ -0,0 +1,41 @@
+package dialer
+
+import (
+	"context"
+	"net"
+
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/retry"
+)
+
+// RetryDialer is a Dialer that retries connects that time out.
+type RetryDialer struct {
+	Dialer
+	Policy retry.Policy
+}
+
+// DialContext implements Dialer.DialContext
+func (d RetryDialer) DialContext(ctx context.Context, network, address string) (conn net.Conn, err error) {
+	err = d.Policy.Do(ctx, errorx.ConnectOperation, func() (err error) {
+		conn, err = d.Dialer.DialContext(ctx, network, address)
+		return
+	})
+	return
+}
+
+// RetryTLSDialer is a TLSDialer that retries dialing when the TLS
+// handshake times out. Because we cannot handshake twice on the same
+// connection, every retry also dials a new connection.
+type RetryTLSDialer struct {
+	TLSDialer
+	Policy retry.Policy
+}
+
+// DialTLSContext implements TLSDialer.DialTLSContext
+func (d RetryTLSDialer) DialTLSContext(ctx context.Context, network, address string) (conn net.Conn, err error) {
+	err = d.Policy.Do(ctx, errorx.TLSHandshakeOperation, func() (err error) {
+		conn, err = d.TLSDialer.DialTLSContext(ctx, network, address)
+		return
+	})
+	return
+}
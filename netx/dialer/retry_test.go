@@ -0,0 +1,115 @@
+package dialer_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/ooni/probe-engine/netx/dialer"
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/retry"
+)
+
+type CountingDialer struct {
+	Count int
+	Err   error
+}
+
+func (d *CountingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.Count++
+	return nil, d.Err
+}
+
+func TestRetryDialerTimeout(t *testing.T) {
+	child := &CountingDialer{Err: &errorx.ErrWrapper{
+		Failure:   errorx.FailureGenericTimeoutError,
+		Operation: errorx.ConnectOperation,
+	}}
+	d := dialer.RetryDialer{Dialer: child, Policy: retry.Policy{MaxRetries: 2}}
+	conn, err := d.DialContext(context.Background(), "tcp", "8.8.8.8:853")
+	if !errors.Is(err, child.Err) {
+		t.Fatal("not the error we expected")
+	}
+	if conn != nil {
+		t.Fatal("expected nil conn here")
+	}
+	if child.Count != 3 {
+		t.Fatal("unexpected number of attempts")
+	}
+}
+
+func TestRetryDialerConnectionRefused(t *testing.T) {
+	child := &CountingDialer{Err: &errorx.ErrWrapper{
+		Failure:   errorx.FailureConnectionRefused,
+		Operation: errorx.ConnectOperation,
+	}}
+	d := dialer.RetryDialer{Dialer: child, Policy: retry.Policy{MaxRetries: 2}}
+	conn, err := d.DialContext(context.Background(), "tcp", "8.8.8.8:853")
+	if !errors.Is(err, child.Err) {
+		t.Fatal("not the error we expected")
+	}
+	if conn != nil {
+		t.Fatal("expected nil conn here")
+	}
+	if child.Count != 1 {
+		t.Fatal("we should not have retried")
+	}
+}
+
+type TimingOutTLSHandshaker struct {
+	Count int
+}
+
+func (h *TimingOutTLSHandshaker) Handshake(
+	ctx context.Context, conn net.Conn, config *tls.Config,
+) (net.Conn, tls.ConnectionState, error) {
+	h.Count++
+	return nil, tls.ConnectionState{}, &errorx.ErrWrapper{
+		Failure:   errorx.FailureGenericTimeoutError,
+		Operation: errorx.TLSHandshakeOperation,
+	}
+}
+
+func TestRetryTLSDialerHandshakeTimeout(t *testing.T) {
+	handshaker := new(TimingOutTLSHandshaker)
+	d := dialer.RetryTLSDialer{
+		TLSDialer: dialer.TLSDialer{
+			Dialer:        dialer.EOFConnDialer{},
+			TLSHandshaker: handshaker,
+		},
+		Policy: retry.Policy{MaxRetries: 2},
+	}
+	conn, err := d.DialTLSContext(context.Background(), "tcp", "www.google.com:443")
+	if err == nil || err.Error() != errorx.FailureGenericTimeoutError {
+		t.Fatal("not the error we expected")
+	}
+	if conn != nil {
+		t.Fatal("expected nil conn here")
+	}
+	if handshaker.Count != 3 {
+		t.Fatal("unexpected number of attempts")
+	}
+}
+
+func TestRetryTLSDialerConnectTimeout(t *testing.T) {
+	child := &CountingDialer{Err: &errorx.ErrWrapper{
+		Failure:   errorx.FailureGenericTimeoutError,
+		Operation: errorx.ConnectOperation,
+	}}
+	d := dialer.RetryTLSDialer{
+		TLSDialer: dialer.TLSDialer{Dialer: child},
+		Policy:    retry.Policy{MaxRetries: 2},
+	}
+	conn, err := d.DialTLSContext(context.Background(), "tcp", "www.google.com:443")
+	if !errors.Is(err, child.Err) {
+		t.Fatal("not the error we expected")
+	}
+	if conn != nil {
+		t.Fatal("expected nil conn here")
+	}
+	if child.Count != 1 {
+		t.Fatal("connect timeouts are not handshake timeouts")
+	}
+}
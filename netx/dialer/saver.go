@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"github.com/ooni/probe-engine/internal/tlsx"
+	"github.com/ooni/probe-engine/netx/dialid"
 	"github.com/ooni/probe-engine/netx/errorx"
 	"github.com/ooni/probe-engine/netx/trace"
+	"github.com/ooni/probe-engine/netx/transactionid"
 )
 
 // SaverDialer saves events occurring during the dial
@@ -23,12 +25,15 @@ func (d SaverDialer) DialContext(ctx context.Context, network, address string) (
 	conn, err := d.Dialer.DialContext(ctx, network, address)
 	stop := time.Now()
 	d.Saver.Write(trace.Event{
-		Address:  address,
-		Duration: stop.Sub(start),
-		Err:      err,
-		Name:     errorx.ConnectOperation,
-		Proto:    network,
-		Time:     stop,
+		Address:       address,
+		ConnID:        safeConnID(network, conn),
+		DialID:        dialid.ContextDialID(ctx),
+		Duration:      stop.Sub(start),
+		Err:           err,
+		Name:          errorx.ConnectOperation,
+		Proto:         network,
+		Time:          stop,
+		TransactionID: transactionid.ContextTransactionID(ctx),
 	})
 	return conn, err
 }
@@ -43,17 +48,22 @@ type SaverTLSHandshaker struct {
 func (h SaverTLSHandshaker) Handshake(
 	ctx context.Context, conn net.Conn, config *tls.Config,
 ) (net.Conn, tls.ConnectionState, error) {
+	connID := safeConnID("tcp", conn)
+	txID := transactionid.ContextTransactionID(ctx)
 	start := time.Now()
 	h.Saver.Write(trace.Event{
+		ConnID:        connID,
 		Name:          "tls_handshake_start",
 		NoTLSVerify:   config.InsecureSkipVerify,
 		TLSNextProtos: config.NextProtos,
 		TLSServerName: config.ServerName,
 		Time:          start,
+		TransactionID: txID,
 	})
 	tlsconn, state, err := h.TLSHandshaker.Handshake(ctx, conn, config)
 	stop := time.Now()
 	h.Saver.Write(trace.Event{
+		ConnID:             connID,
 		Duration:           stop.Sub(start),
 		Err:                err,
 		Name:               "tls_handshake_done",
@@ -65,6 +75,7 @@ func (h SaverTLSHandshaker) Handshake(
 		TLSServerName:      config.ServerName,
 		TLSVersion:         tlsx.VersionString(state.Version),
 		Time:               stop,
+		TransactionID:      txID,
 	})
 	return tlsconn, state, err
 }
@@ -82,12 +93,23 @@ func (d SaverConnDialer) DialContext(ctx context.Context, network, address strin
 	if err != nil {
 		return nil, err
 	}
-	return saverConn{saver: d.Saver, Conn: conn}, nil
+	return saverConn{
+		Conn:          conn,
+		connID:        safeConnID(network, conn),
+		dialID:        dialid.ContextDialID(ctx),
+		saver:         d.Saver,
+		transactionID: transactionid.ContextTransactionID(ctx),
+	}, nil
 }
 
+// saverConn remembers the IDs of the dial that created the
+// connection, so we can link its I/O events to such dial.
 type saverConn struct {
 	net.Conn
-	saver *trace.Saver
+	connID        int64
+	dialID        int64
+	saver         *trace.Saver
+	transactionID int64
 }
 
 func (c saverConn) Read(p []byte) (int, error) {
@@ -95,12 +117,15 @@ func (c saverConn) Read(p []byte) (int, error) {
 	count, err := c.Conn.Read(p)
 	stop := time.Now()
 	c.saver.Write(trace.Event{
-		Data:     p[:count],
-		Duration: stop.Sub(start),
-		Err:      err,
-		NumBytes: count,
-		Name:     errorx.ReadOperation,
-		Time:     stop,
+		ConnID:        c.connID,
+		Data:          p[:count],
+		DialID:        c.dialID,
+		Duration:      stop.Sub(start),
+		Err:           err,
+		NumBytes:      count,
+		Name:          errorx.ReadOperation,
+		Time:          stop,
+		TransactionID: c.transactionID,
 	})
 	return count, err
 }
@@ -110,12 +135,15 @@ func (c saverConn) Write(p []byte) (int, error) {
 	count, err := c.Conn.Write(p)
 	stop := time.Now()
 	c.saver.Write(trace.Event{
-		Data:     p[:count],
-		Duration: stop.Sub(start),
-		Err:      err,
-		NumBytes: count,
-		Name:     errorx.WriteOperation,
-		Time:     stop,
+		ConnID:        c.connID,
+		Data:          p[:count],
+		DialID:        c.dialID,
+		Duration:      stop.Sub(start),
+		Err:           err,
+		NumBytes:      count,
+		Name:          errorx.WriteOperation,
+		Time:          stop,
+		TransactionID: c.transactionID,
 	})
 	return count, err
 }
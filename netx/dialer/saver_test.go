@@ -10,8 +10,10 @@ import (
 	"time"
 
 	"github.com/ooni/probe-engine/netx/dialer"
+	"github.com/ooni/probe-engine/netx/dialid"
 	"github.com/ooni/probe-engine/netx/errorx"
 	"github.com/ooni/probe-engine/netx/trace"
+	"github.com/ooni/probe-engine/netx/transactionid"
 )
 
 func TestSaverDialerFailure(t *testing.T) {
@@ -72,6 +74,47 @@ func TestSaverConnDialerFailure(t *testing.T) {
 	}
 }
 
+func TestSaverDialerLinksEventsWithIDs(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	saver := &trace.Saver{}
+	dlr := dialer.SaverConnDialer{
+		Dialer: dialer.SaverDialer{Dialer: new(net.Dialer), Saver: saver},
+		Saver:  saver,
+	}
+	ctx := dialid.WithDialID(context.Background())
+	ctx = transactionid.WithTransactionID(ctx)
+	conn, err := dlr.DialContext(ctx, "tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("antani")); err != nil {
+		t.Fatal(err)
+	}
+	ev := saver.Read()
+	if len(ev) != 2 {
+		t.Fatal("unexpected number of events")
+	}
+	if ev[0].Name != errorx.ConnectOperation || ev[1].Name != errorx.WriteOperation {
+		t.Fatal("unexpected Name")
+	}
+	for _, e := range ev {
+		if e.ConnID <= 0 || e.ConnID != ev[0].ConnID {
+			t.Fatal("unexpected ConnID")
+		}
+		if e.DialID != dialid.ContextDialID(ctx) {
+			t.Fatal("unexpected DialID")
+		}
+		if e.TransactionID != transactionid.ContextTransactionID(ctx) {
+			t.Fatal("unexpected TransactionID")
+		}
+	}
+}
+
 func TestSaverTLSHandshakerSuccessWithReadWrite(t *testing.T) {
 	// This is the most common use case for collecting reads, writes
 	if testing.Short() {
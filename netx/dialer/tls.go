@@ -6,8 +6,7 @@ import (
 	"net"
 	"time"
 
-	"github.com/ooni/probe-engine/legacy/netx/connid"
-	"github.com/ooni/probe-engine/legacy/netx/modelx"
+	"github.com/ooni/probe-engine/netx/connid"
 	"github.com/ooni/probe-engine/netx/errorx"
 )
 
@@ -72,36 +71,6 @@ func (h ErrorWrapperTLSHandshaker) Handshake(
 	return tlsconn, state, err
 }
 
-// EmitterTLSHandshaker emits events using the MeasurementRoot
-type EmitterTLSHandshaker struct {
-	TLSHandshaker
-}
-
-// Handshake implements Handshaker.Handshake
-func (h EmitterTLSHandshaker) Handshake(
-	ctx context.Context, conn net.Conn, config *tls.Config,
-) (net.Conn, tls.ConnectionState, error) {
-	connID := connid.Compute(conn.RemoteAddr().Network(), conn.RemoteAddr().String())
-	root := modelx.ContextMeasurementRootOrDefault(ctx)
-	root.Handler.OnMeasurement(modelx.Measurement{
-		TLSHandshakeStart: &modelx.TLSHandshakeStartEvent{
-			ConnID:                 connID,
-			DurationSinceBeginning: time.Now().Sub(root.Beginning),
-			SNI:                    config.ServerName,
-		},
-	})
-	tlsconn, state, err := h.TLSHandshaker.Handshake(ctx, conn, config)
-	root.Handler.OnMeasurement(modelx.Measurement{
-		TLSHandshakeDone: &modelx.TLSHandshakeDoneEvent{
-			ConnID:                 connID,
-			ConnectionState:        modelx.NewTLSConnectionState(state),
-			Error:                  err,
-			DurationSinceBeginning: time.Now().Sub(root.Beginning),
-		},
-	})
-	return tlsconn, state, err
-}
-
 // TLSDialer is the TLS dialer
 type TLSDialer struct {
 	Config        *tls.Config
@@ -9,10 +9,9 @@ import (
 	"testing"
 	"time"
 
-	"github.com/ooni/probe-engine/legacy/netx/handlers"
-	"github.com/ooni/probe-engine/legacy/netx/modelx"
 	"github.com/ooni/probe-engine/netx/dialer"
 	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/trace"
 )
 
 func TestSystemTLSHandshakerEOFError(t *testing.T) {
@@ -120,14 +119,10 @@ func TestErrorWrapperTLSHandshakerFailure(t *testing.T) {
 	}
 }
 
-func TestEmitterTLSHandshakerFailure(t *testing.T) {
-	saver := &handlers.SavingHandler{}
-	ctx := modelx.WithMeasurementRoot(context.Background(), &modelx.MeasurementRoot{
-		Beginning: time.Now(),
-		Handler:   saver,
-	})
-	h := dialer.EmitterTLSHandshaker{TLSHandshaker: dialer.EOFTLSHandshaker{}}
-	conn, _, err := h.Handshake(ctx, dialer.EOFConn{}, &tls.Config{
+func TestSaverTLSHandshakerFailureSavesConnID(t *testing.T) {
+	saver := &trace.Saver{}
+	h := dialer.SaverTLSHandshaker{TLSHandshaker: dialer.EOFTLSHandshaker{}, Saver: saver}
+	conn, _, err := h.Handshake(context.Background(), dialer.EOFConn{}, &tls.Config{
 		ServerName: "www.kernel.org",
 	})
 	if !errors.Is(err, io.EOF) {
@@ -140,26 +135,19 @@ func TestEmitterTLSHandshakerFailure(t *testing.T) {
 	if len(events) != 2 {
 		t.Fatal("Wrong number of events")
 	}
-	if events[0].TLSHandshakeStart == nil {
-		t.Fatal("missing TLSHandshakeStart event")
-	}
-	if events[0].TLSHandshakeStart.ConnID == 0 {
-		t.Fatal("expected nonzero ConnID")
-	}
-	if events[0].TLSHandshakeStart.DurationSinceBeginning == 0 {
-		t.Fatal("expected nonzero DurationSinceBeginning")
-	}
-	if events[0].TLSHandshakeStart.SNI != "www.kernel.org" {
-		t.Fatal("expected nonzero SNI")
+	if events[0].Name != "tls_handshake_start" {
+		t.Fatal("missing tls_handshake_start event")
 	}
-	if events[1].TLSHandshakeDone == nil {
-		t.Fatal("missing TLSHandshakeDone event")
+	if events[0].TLSServerName != "www.kernel.org" {
+		t.Fatal("unexpected TLSServerName")
 	}
-	if events[1].TLSHandshakeDone.ConnID == 0 {
-		t.Fatal("expected nonzero ConnID")
+	if events[1].Name != "tls_handshake_done" {
+		t.Fatal("missing tls_handshake_done event")
 	}
-	if events[1].TLSHandshakeDone.DurationSinceBeginning == 0 {
-		t.Fatal("expected nonzero DurationSinceBeginning")
+	for _, ev := range events {
+		if ev.ConnID != 1234 {
+			t.Fatal("unexpected ConnID")
+		}
 	}
 }
 
@@ -1,3 +1,4 @@
+// Package dialid contains code to share the dialID
 package dialid
 
 import (
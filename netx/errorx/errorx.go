@@ -45,6 +45,10 @@ const (
 	// sort of errors causing it to be invalid.
 	FailureSSLInvalidCertificate = "ssl_invalid_certificate"
 
+	// FailureSSLPinMismatch means that no certificate presented by
+	// the peer matches the SPKI pins configured for the server name.
+	FailureSSLPinMismatch = "ssl_pin_mismatch"
+
 	// FailureJSONParseError indicates that we couldn't parse a JSON
 	FailureJSONParseError = "json_parse_error"
 )
@@ -93,6 +97,10 @@ const (
 // to tell this library to return an error when a bogon is found.
 var ErrDNSBogon = errors.New("dns: detected bogon address")
 
+// ErrSSLPinMismatch indicates that no certificate presented by
+// the peer matches the SPKI pins configured for the server name.
+var ErrSSLPinMismatch = errors.New("tls: certificates do not match SPKI pins")
+
 // ErrWrapper is our error wrapper for Go errors. The key objective of
 // this structure is to properly set Failure, which is also returned by
 // the Error() method, so be one of the OONI defined strings.
@@ -199,6 +207,9 @@ func toFailureString(err error) string {
 	if errors.Is(err, ErrDNSBogon) {
 		return FailureDNSBogonError // not in MK
 	}
+	if errors.Is(err, ErrSSLPinMismatch) {
+		return FailureSSLPinMismatch // not in MK
+	}
 	if errors.Is(err, context.Canceled) {
 		return FailureInterrupted
 	}
@@ -55,6 +55,11 @@ func TestToFailureString(t *testing.T) {
 			t.Fatal("unexpected result")
 		}
 	})
+	t.Run("for ErrSSLPinMismatch", func(t *testing.T) {
+		if toFailureString(ErrSSLPinMismatch) != FailureSSLPinMismatch {
+			t.Fatal("unexpected result")
+		}
+	})
 	t.Run("for context.Canceled", func(t *testing.T) {
 		if toFailureString(context.Canceled) != FailureInterrupted {
 			t.Fatal("unexpected result")
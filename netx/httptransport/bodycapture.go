@@ -0,0 +1,76 @@
+package httptransport
+
+import "sync"
+
+// BodyCapture is the mode with which SaverBodyHTTPTransport captures
+// the request and response bodies.
+type BodyCapture string
+
+const (
+	// BodyCaptureNone means that we do not capture bodies. We mark
+	// the bodies as truncated, since we did not save them.
+	BodyCaptureNone = BodyCapture("none")
+
+	// BodyCaptureHead means that we capture the first SnapshotSize
+	// bytes of each body. This is the default.
+	BodyCaptureHead = BodyCapture("head")
+
+	// BodyCaptureFull means that we capture the whole body and
+	// that we also save its SHA256 and its length. When a BodyBudget
+	// truncates the body, we only save the captured prefix.
+	BodyCaptureFull = BodyCapture("full")
+
+	// BodyCaptureFile is like BodyCaptureFull except that we write
+	// the body into a side file, named after its SHA256, rather than
+	// saving the body itself. The SHA256 references the side file. When
+	// a BodyBudget truncates the body, we save the captured prefix inline
+	// like BodyCaptureFull does, because we do not know its SHA256.
+	BodyCaptureFile = BodyCapture("file")
+)
+
+// BodyBudget is the total number of body bytes that we capture
+// across several round trips, e.g., during a measurement. When the
+// budget is exhausted, we mark the captured bodies as truncated. A
+// BodyBudget is safe to share among several goroutines.
+type BodyBudget struct {
+	mu        sync.Mutex
+	remaining int64
+}
+
+// NewBodyBudget creates a new BodyBudget of size bytes.
+func NewBodyBudget(size int64) *BodyBudget {
+	return &BodyBudget{remaining: size}
+}
+
+// Remaining returns the number of bytes left in the budget.
+func (b *BodyBudget) Remaining() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remaining
+}
+
+// reserve reserves at most limit bytes, where a negative limit means
+// no limit, and returns the limit to actually use. A nil BodyBudget
+// means that there is no budget and does not change the limit.
+func (b *BodyBudget) reserve(limit int64) int64 {
+	if b == nil {
+		return limit
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if limit < 0 || limit > b.remaining {
+		limit = b.remaining
+	}
+	b.remaining -= limit
+	return limit
+}
+
+// release returns unused reserved bytes to the budget.
+func (b *BodyBudget) release(count int64) {
+	if b == nil || count <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remaining += count
+}
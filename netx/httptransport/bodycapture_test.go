@@ -0,0 +1,253 @@
+package httptransport_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ooni/probe-engine/netx/httptransport"
+	"github.com/ooni/probe-engine/netx/trace"
+)
+
+// bodyCaptureRoundTrip performs a POST round trip using txp and returns
+// the events saved by txp after we've read the whole response body.
+func bodyCaptureRoundTrip(
+	t *testing.T, txp httptransport.SaverBodyHTTPTransport) []trace.Event {
+	saver := new(trace.Saver)
+	txp.Saver = saver
+	txp.RoundTripper = httptransport.FakeTransport{
+		Func: func(req *http.Request) (*http.Response, error) {
+			data, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "deadbeef" {
+				t.Fatal("invalid data")
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader("abad1dea")),
+			}, nil
+		},
+	}
+	req, err := http.NewRequest("POST", "http://x.org/y", strings.NewReader("deadbeef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := txp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "abad1dea" {
+		t.Fatal("unexpected body")
+	}
+	ev := saver.Read()
+	if len(ev) != 2 {
+		t.Fatal("unexpected number of events")
+	}
+	return ev
+}
+
+func sha256hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBodyCaptureNone(t *testing.T) {
+	ev := bodyCaptureRoundTrip(t, httptransport.SaverBodyHTTPTransport{
+		Capture: httptransport.BodyCaptureNone,
+	})
+	for _, e := range ev {
+		if len(e.Data) != 0 || !e.DataIsTruncated || e.DataSHA256 != "" {
+			t.Fatal("unexpected event")
+		}
+	}
+}
+
+func TestBodyCaptureFull(t *testing.T) {
+	ev := bodyCaptureRoundTrip(t, httptransport.SaverBodyHTTPTransport{
+		Capture:      httptransport.BodyCaptureFull,
+		SnapshotSize: 4, // ignored in full mode
+	})
+	for idx, expected := range []string{"deadbeef", "abad1dea"} {
+		if string(ev[idx].Data) != expected || ev[idx].DataIsTruncated {
+			t.Fatal("unexpected data")
+		}
+		if ev[idx].DataSHA256 != sha256hex(expected) {
+			t.Fatal("unexpected SHA256")
+		}
+		if ev[idx].NumBytes != len(expected) {
+			t.Fatal("unexpected NumBytes")
+		}
+	}
+}
+
+func TestBodyCaptureFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ooniprobe-engine-bodycapture-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	txp := httptransport.SaverBodyHTTPTransport{
+		Capture: httptransport.BodyCaptureFile,
+		Dir:     dir,
+	}
+	for i := 0; i < 2; i++ { // the second time the files already exist
+		ev := bodyCaptureRoundTrip(t, txp)
+		for idx, expected := range []string{"deadbeef", "abad1dea"} {
+			if len(ev[idx].Data) != 0 || ev[idx].DataIsTruncated {
+				t.Fatal("unexpected data")
+			}
+			if ev[idx].DataSHA256 != sha256hex(expected) {
+				t.Fatal("unexpected SHA256")
+			}
+			if ev[idx].NumBytes != len(expected) {
+				t.Fatal("unexpected NumBytes")
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, ev[idx].DataSHA256))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != expected {
+				t.Fatal("unexpected side file content")
+			}
+		}
+	}
+}
+
+func TestBodyCaptureFileWithoutDir(t *testing.T) {
+	txp := httptransport.SaverBodyHTTPTransport{
+		Capture: httptransport.BodyCaptureFile,
+		RoundTripper: httptransport.FakeTransport{
+			Func: func(req *http.Request) (*http.Response, error) {
+				panic("should not be called")
+			},
+		},
+		Saver: new(trace.Saver),
+	}
+	req, err := http.NewRequest("POST", "http://x.org/y", strings.NewReader("deadbeef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := txp.RoundTrip(req)
+	if err == nil || err.Error() != "httptransport: missing body directory" {
+		t.Fatal("not the error we expected")
+	}
+	if resp != nil {
+		t.Fatal("expected nil response")
+	}
+}
+
+func TestBodyCaptureUnknownMode(t *testing.T) {
+	txp := httptransport.SaverBodyHTTPTransport{
+		Capture: httptransport.BodyCapture("antani"),
+		RoundTripper: httptransport.FakeTransport{
+			Resp: &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(strings.NewReader("abad1dea")),
+			},
+		},
+		Saver: new(trace.Saver),
+	}
+	req, err := http.NewRequest("GET", "http://x.org/y", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := txp.RoundTrip(req)
+	if err == nil || !strings.HasSuffix(err.Error(), "unknown body capture mode: antani") {
+		t.Fatal("not the error we expected")
+	}
+	if resp != nil {
+		t.Fatal("expected nil response")
+	}
+}
+
+func TestBodyCaptureWithBudget(t *testing.T) {
+	budget := httptransport.NewBodyBudget(12)
+	ev := bodyCaptureRoundTrip(t, httptransport.SaverBodyHTTPTransport{
+		Budget:  budget,
+		Capture: httptransport.BodyCaptureFull,
+	})
+	if string(ev[0].Data) != "deadbeef" || ev[0].DataIsTruncated {
+		t.Fatal("unexpected request body")
+	}
+	if string(ev[1].Data) != "abad" || !ev[1].DataIsTruncated {
+		t.Fatal("unexpected response body")
+	}
+	if ev[1].DataSHA256 != "" || ev[1].NumBytes != 0 {
+		t.Fatal("unexpected SHA256 or NumBytes")
+	}
+	if budget.Remaining() != 0 {
+		t.Fatal("the budget should be exhausted")
+	}
+	ev = bodyCaptureRoundTrip(t, httptransport.SaverBodyHTTPTransport{
+		Budget:  budget,
+		Capture: httptransport.BodyCaptureHead,
+	})
+	for _, e := range ev {
+		if len(e.Data) != 0 || !e.DataIsTruncated {
+			t.Fatal("unexpected event")
+		}
+	}
+}
+
+func TestBodyCaptureFileWithBudget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ooniprobe-engine-bodycapture-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ev := bodyCaptureRoundTrip(t, httptransport.SaverBodyHTTPTransport{
+		Budget:  httptransport.NewBodyBudget(12),
+		Capture: httptransport.BodyCaptureFile,
+		Dir:     dir,
+	})
+	if len(ev[0].Data) != 0 || ev[0].DataSHA256 != sha256hex("deadbeef") {
+		t.Fatal("unexpected request body")
+	}
+	if string(ev[1].Data) != "abad" || !ev[1].DataIsTruncated {
+		t.Fatal("unexpected response body")
+	}
+	if ev[1].DataSHA256 != "" || ev[1].NumBytes != 0 {
+		t.Fatal("unexpected SHA256 or NumBytes")
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != sha256hex("deadbeef") {
+		t.Fatal("we should not store truncated bodies")
+	}
+}
+
+func TestBodyCaptureHeadWithBodyOfExactlySnapshotSize(t *testing.T) {
+	ev := bodyCaptureRoundTrip(t, httptransport.SaverBodyHTTPTransport{
+		SnapshotSize: 8,
+	})
+	for idx, expected := range []string{"deadbeef", "abad1dea"} {
+		if string(ev[idx].Data) != expected || ev[idx].DataIsTruncated {
+			t.Fatal("unexpected data")
+		}
+	}
+}
+
+func TestBodyCaptureBudgetRefundsUnusedBytes(t *testing.T) {
+	budget := httptransport.NewBodyBudget(1 << 20)
+	bodyCaptureRoundTrip(t, httptransport.SaverBodyHTTPTransport{
+		Budget:       budget,
+		SnapshotSize: 1 << 10,
+	})
+	if budget.Remaining() != 1<<20-16 {
+		t.Fatal("unexpected remaining budget")
+	}
+}
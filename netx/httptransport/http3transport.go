@@ -34,7 +34,7 @@ func (t *HTTP3Transport) CloseIdleConnections() {
 // NewHTTP3Transport creates a new HTTP3Transport instance.
 func NewHTTP3Transport(config Config) RoundTripper {
 	txp := &HTTP3Transport{}
-	txp.QuicConfig = &quic.Config{}
+	txp.QuicConfig = &quic.Config{MaxIdleTimeout: config.IdleTimeout}
 	txp.TLSClientConfig = config.TLSConfig
 	txp.Dial = config.QUICDialer.Dial
 	return txp
@@ -6,16 +6,19 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/lucas-clemente/quic-go"
 )
 
 // Config contains the configuration required for constructing an HTTP transport
 type Config struct {
-	Dialer     Dialer
-	QUICDialer QUICDialer
-	TLSDialer  TLSDialer
-	TLSConfig  *tls.Config
+	Dialer           Dialer
+	FirstByteTimeout time.Duration // default: no timeout (tcp only)
+	IdleTimeout      time.Duration // default: the transport's default
+	QUICDialer       QUICDialer
+	TLSDialer        TLSDialer
+	TLSConfig        *tls.Config
 }
 
 // Dialer is the definition of dialer assumed by this package.
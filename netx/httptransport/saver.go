@@ -2,13 +2,20 @@ package httptransport
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptrace"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/ooni/probe-engine/netx/trace"
+	"github.com/ooni/probe-engine/netx/transactionid"
 )
 
 // SaverPerformanceHTTPTransport is a RoundTripper that saves
@@ -22,16 +29,19 @@ type SaverPerformanceHTTPTransport struct {
 func (txp SaverPerformanceHTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	tracep := httptrace.ContextClientTrace(req.Context())
 	if tracep == nil {
+		txID := transactionid.ContextTransactionID(req.Context())
 		tracep = &httptrace.ClientTrace{
 			WroteHeaders: func() {
-				txp.Saver.Write(trace.Event{Name: "http_wrote_headers", Time: time.Now()})
+				txp.Saver.Write(trace.Event{Name: "http_wrote_headers",
+					Time: time.Now(), TransactionID: txID})
 			},
 			WroteRequest: func(httptrace.WroteRequestInfo) {
-				txp.Saver.Write(trace.Event{Name: "http_wrote_request", Time: time.Now()})
+				txp.Saver.Write(trace.Event{Name: "http_wrote_request",
+					Time: time.Now(), TransactionID: txID})
 			},
 			GotFirstResponseByte: func() {
-				txp.Saver.Write(trace.Event{
-					Name: "http_first_response_byte", Time: time.Now()})
+				txp.Saver.Write(trace.Event{Name: "http_first_response_byte",
+					Time: time.Now(), TransactionID: txID})
 			},
 		}
 		req = req.WithContext(httptrace.WithClientTrace(req.Context(), tracep))
@@ -49,13 +59,15 @@ type SaverMetadataHTTPTransport struct {
 
 // RoundTrip implements RoundTripper.RoundTrip
 func (txp SaverMetadataHTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	txID := transactionid.ContextTransactionID(req.Context())
 	txp.Saver.Write(trace.Event{
-		HTTPHeaders: req.Header,
-		HTTPMethod:  req.Method,
-		HTTPURL:     req.URL.String(),
-		Transport:   txp.Transport,
-		Name:        "http_request_metadata",
-		Time:        time.Now(),
+		HTTPHeaders:   req.Header,
+		HTTPMethod:    req.Method,
+		HTTPURL:       req.URL.String(),
+		Transport:     txp.Transport,
+		Name:          "http_request_metadata",
+		Time:          time.Now(),
+		TransactionID: txID,
 	})
 	resp, err := txp.RoundTripper.RoundTrip(req)
 	if err != nil {
@@ -66,12 +78,16 @@ func (txp SaverMetadataHTTPTransport) RoundTrip(req *http.Request) (*http.Respon
 		HTTPStatusCode: resp.StatusCode,
 		Name:           "http_response_metadata",
 		Time:           time.Now(),
+		TransactionID:  txID,
 	})
 	return resp, err
 }
 
 // SaverTransactionHTTPTransport is a RoundTripper that saves
-// events related to the HTTP transaction
+// events related to the HTTP transaction. It assigns a new
+// TransactionID to the request context, unless the context already
+// has one, such that we can link to this transaction all the events
+// occurring during the round trip, including dials and handshakes.
 type SaverTransactionHTTPTransport struct {
 	RoundTripper
 	Saver *trace.Saver
@@ -79,68 +95,143 @@ type SaverTransactionHTTPTransport struct {
 
 // RoundTrip implements RoundTripper.RoundTrip
 func (txp SaverTransactionHTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	txID := transactionid.ContextTransactionID(req.Context())
+	if txID == 0 {
+		ctx := transactionid.WithTransactionID(req.Context())
+		txID = transactionid.ContextTransactionID(ctx)
+		req = req.WithContext(ctx)
+	}
 	txp.Saver.Write(trace.Event{
-		Name: "http_transaction_start",
-		Time: time.Now(),
+		Name:          "http_transaction_start",
+		Time:          time.Now(),
+		TransactionID: txID,
 	})
 	resp, err := txp.RoundTripper.RoundTrip(req)
 	txp.Saver.Write(trace.Event{
-		Err:  err,
-		Name: "http_transaction_done",
-		Time: time.Now(),
+		Err:           err,
+		Name:          "http_transaction_done",
+		Time:          time.Now(),
+		TransactionID: txID,
 	})
 	return resp, err
 }
 
 // SaverBodyHTTPTransport is a RoundTripper that saves
-// body events occurring during the round trip
+// body events occurring during the round trip. The Capture field
+// selects how much of each body we save. See BodyCapture.
 type SaverBodyHTTPTransport struct {
 	RoundTripper
+	Budget       *BodyBudget // default: no budget
+	Capture      BodyCapture // default: BodyCaptureHead
+	Dir          string      // mandatory with BodyCaptureFile
 	Saver        *trace.Saver
-	SnapshotSize int
+	SnapshotSize int // default: 128 KiB (BodyCaptureHead only)
 }
 
 // RoundTrip implements RoundTripper.RoundTrip
 func (txp SaverBodyHTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	const defaultSnapSize = 1 << 17
-	snapsize := defaultSnapSize
-	if txp.SnapshotSize != 0 {
-		snapsize = txp.SnapshotSize
-	}
+	txID := transactionid.ContextTransactionID(req.Context())
 	if req.Body != nil {
-		data, err := saverSnapRead(req.Body, snapsize)
+		body, ev, err := txp.capture(req.Body)
 		if err != nil {
 			return nil, err
 		}
-		req.Body = saverCompose(data, req.Body)
-		txp.Saver.Write(trace.Event{
-			DataIsTruncated: len(data) >= snapsize,
-			Data:            data,
-			Name:            "http_request_body_snapshot",
-			Time:            time.Now(),
-		})
+		req.Body = body
+		ev.Name = "http_request_body_snapshot"
+		ev.Time = time.Now()
+		ev.TransactionID = txID
+		txp.Saver.Write(ev)
 	}
 	resp, err := txp.RoundTripper.RoundTrip(req)
 	if err != nil {
 		return nil, err
 	}
-	data, err := saverSnapRead(resp.Body, snapsize)
+	body, ev, err := txp.capture(resp.Body)
 	if err != nil {
 		resp.Body.Close()
 		return nil, err
 	}
-	resp.Body = saverCompose(data, resp.Body)
-	txp.Saver.Write(trace.Event{
-		DataIsTruncated: len(data) >= snapsize,
-		Data:            data,
-		Name:            "http_response_body_snapshot",
-		Time:            time.Now(),
-	})
+	resp.Body = body
+	ev.Name = "http_response_body_snapshot"
+	ev.Time = time.Now()
+	ev.TransactionID = txID
+	txp.Saver.Write(ev)
 	return resp, nil
 }
 
-func saverSnapRead(r io.ReadCloser, snapsize int) ([]byte, error) {
-	return ioutil.ReadAll(io.LimitReader(r, int64(snapsize)))
+// capture reads the part of the body selected by the capture mode and
+// returns a body equivalent to the original one along with an event
+// describing the captured data, whose name, time, etc. are not set.
+func (txp SaverBodyHTTPTransport) capture(
+	r io.ReadCloser) (io.ReadCloser, trace.Event, error) {
+	const defaultSnapSize = 1 << 17
+	var limit int64 = -1 // meaning: read the whole body
+	switch txp.Capture {
+	case BodyCaptureNone:
+		return r, trace.Event{DataIsTruncated: true}, nil
+	case "", BodyCaptureHead:
+		limit = defaultSnapSize
+		if txp.SnapshotSize != 0 {
+			limit = int64(txp.SnapshotSize)
+		}
+	case BodyCaptureFull:
+	case BodyCaptureFile:
+		if txp.Dir == "" {
+			return nil, trace.Event{}, errors.New("httptransport: missing body directory")
+		}
+	default:
+		return nil, trace.Event{}, fmt.Errorf(
+			"httptransport: unknown body capture mode: %s", txp.Capture)
+	}
+	limit = txp.Budget.reserve(limit)
+	data, err := saverSnapRead(r, limit)
+	snapshot, truncated := data, limit >= 0 && int64(len(data)) > limit
+	if truncated {
+		snapshot = data[:limit]
+	}
+	txp.Budget.release(limit - int64(len(snapshot)))
+	if err != nil {
+		return nil, trace.Event{}, err
+	}
+	ev := trace.Event{DataIsTruncated: truncated, Data: snapshot}
+	// Implementation note: when the budget truncates the body, we do not
+	// know the SHA256 and the length of the whole body, hence we leave them
+	// empty and we keep the captured prefix inline even in file mode.
+	if truncated {
+		return saverCompose(data, r), ev, nil
+	}
+	if txp.Capture == BodyCaptureFull || txp.Capture == BodyCaptureFile {
+		sum := sha256.Sum256(data)
+		ev.DataSHA256 = hex.EncodeToString(sum[:])
+		ev.NumBytes = len(data)
+	}
+	if txp.Capture == BodyCaptureFile {
+		if err := saverStoreBody(txp.Dir, ev.DataSHA256, data); err != nil {
+			return nil, trace.Event{}, err
+		}
+		ev.Data = nil
+	}
+	return saverCompose(data, r), ev, nil
+}
+
+// saverSnapRead reads up to limit+1 bytes, where a negative limit means
+// reading the whole body, so the caller can tell apart a body that is
+// exactly limit bytes long from a body that is longer than that.
+func saverSnapRead(r io.ReadCloser, limit int64) ([]byte, error) {
+	if limit < 0 {
+		return ioutil.ReadAll(r)
+	}
+	return ioutil.ReadAll(io.LimitReader(r, limit+1))
+}
+
+// saverStoreBody stores the body inside dir using the SHA256 of the
+// body as the file name, unless such file already exists.
+func saverStoreBody(dir, digest string, data []byte) error {
+	filename := filepath.Join(dir, digest)
+	if _, err := os.Stat(filename); err == nil {
+		return nil
+	}
+	return ioutil.WriteFile(filename, data, 0644)
 }
 
 func saverCompose(data []byte, r io.ReadCloser) io.ReadCloser {
@@ -240,6 +240,42 @@ func TestSaverTransactionFailure(t *testing.T) {
 	}
 }
 
+func TestSaverTransactionAssignsTransactionID(t *testing.T) {
+	saver := &trace.Saver{}
+	txp := httptransport.SaverTransactionHTTPTransport{
+		RoundTripper: httptransport.SaverMetadataHTTPTransport{
+			RoundTripper: httptransport.FakeTransport{
+				Resp: &http.Response{StatusCode: 200},
+			},
+			Saver: saver,
+		},
+		Saver: saver,
+	}
+	var ids []int64
+	for idx := 0; idx < 2; idx++ {
+		req, err := http.NewRequest("GET", "http://www.google.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := txp.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		ev := saver.Read()
+		if len(ev) != 4 {
+			t.Fatal("unexpected number of events")
+		}
+		for _, e := range ev {
+			if e.TransactionID <= 0 || e.TransactionID != ev[0].TransactionID {
+				t.Fatal("unexpected TransactionID")
+			}
+		}
+		ids = append(ids, ev[0].TransactionID)
+	}
+	if ids[0] == ids[1] {
+		t.Fatal("expected different transaction IDs")
+	}
+}
+
 func TestSaverBodySuccess(t *testing.T) {
 	saver := new(trace.Saver)
 	txp := httptransport.SaverBodyHTTPTransport{
@@ -18,6 +18,10 @@ func NewSystemTransport(config Config) RoundTripper {
 	// back the true headers, such as Content-Length. This change is
 	// functional to OONI's goal of observing the network.
 	txp.DisableCompression = true
+	txp.ResponseHeaderTimeout = config.FirstByteTimeout
+	if config.IdleTimeout != 0 {
+		txp.IdleConnTimeout = config.IdleTimeout
+	}
 	return txp
 }
 
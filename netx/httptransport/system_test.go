@@ -0,0 +1,47 @@
+package httptransport_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-engine/netx/httptransport"
+)
+
+type FakeTLSDialer struct{}
+
+func (FakeTLSDialer) DialTLSContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return nil, errors.New("mocked error")
+}
+
+func TestNewSystemTransportDefaultTimeouts(t *testing.T) {
+	txp := httptransport.NewSystemTransport(httptransport.Config{
+		Dialer:    httptransport.FakeDialer{},
+		TLSDialer: FakeTLSDialer{},
+	}).(*http.Transport)
+	if txp.ResponseHeaderTimeout != 0 {
+		t.Fatal("unexpected first byte timeout")
+	}
+	defaults := http.DefaultTransport.(*http.Transport)
+	if txp.IdleConnTimeout != defaults.IdleConnTimeout {
+		t.Fatal("unexpected idle timeout")
+	}
+}
+
+func TestNewSystemTransportWithTimeouts(t *testing.T) {
+	txp := httptransport.NewSystemTransport(httptransport.Config{
+		Dialer:           httptransport.FakeDialer{},
+		FirstByteTimeout: 5 * time.Second,
+		IdleTimeout:      time.Minute,
+		TLSDialer:        FakeTLSDialer{},
+	}).(*http.Transport)
+	if txp.ResponseHeaderTimeout != 5*time.Second {
+		t.Fatal("unexpected first byte timeout")
+	}
+	if txp.IdleConnTimeout != time.Minute {
+		t.Fatal("unexpected idle timeout")
+	}
+}
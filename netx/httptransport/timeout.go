@@ -0,0 +1,42 @@
+package httptransport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// TimeoutTransport is a transport that enforces a timeout on the whole
+// HTTP round trip, including reading the response body.
+type TimeoutTransport struct {
+	RoundTripper
+	Timeout time.Duration
+}
+
+// RoundTrip implements RoundTripper.RoundTrip
+func (txp TimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), txp.Timeout)
+	resp, err := txp.RoundTripper.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// We cannot cancel the context before the body is closed, because
+	// doing that would interrupt reading the body.
+	resp.Body = timeoutBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type timeoutBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b timeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+var _ RoundTripper = TimeoutTransport{}
@@ -0,0 +1,77 @@
+package httptransport_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-engine/netx/httptransport"
+)
+
+func TestTimeoutTransportFailure(t *testing.T) {
+	expected := errors.New("mocked error")
+	txp := httptransport.TimeoutTransport{
+		RoundTripper: httptransport.FakeTransport{
+			Func: func(req *http.Request) (*http.Response, error) {
+				if _, ok := req.Context().Deadline(); !ok {
+					t.Fatal("expected a deadline here")
+				}
+				return nil, expected
+			},
+		},
+		Timeout: time.Second,
+	}
+	req, err := http.NewRequest("GET", "http://www.google.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := txp.RoundTrip(req)
+	if !errors.Is(err, expected) {
+		t.Fatal("not the error we expected")
+	}
+	if resp != nil {
+		t.Fatal("expected nil resp here")
+	}
+}
+
+func TestTimeoutTransportSuccess(t *testing.T) {
+	var ctx context.Context
+	txp := httptransport.TimeoutTransport{
+		RoundTripper: httptransport.FakeTransport{
+			Func: func(req *http.Request) (*http.Response, error) {
+				ctx = req.Context()
+				return &http.Response{
+					Body:       ioutil.NopCloser(strings.NewReader("antani")),
+					StatusCode: 200,
+				}, nil
+			},
+		},
+		Timeout: time.Minute,
+	}
+	req, err := http.NewRequest("GET", "http://www.google.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := txp.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "antani" {
+		t.Fatal("unexpected body")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("the context should still be alive while reading the body")
+	}
+	resp.Body.Close()
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Fatal("closing the body should cancel the context")
+	}
+}
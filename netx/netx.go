@@ -26,9 +26,12 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/lucas-clemente/quic-go"
 	"github.com/ooni/probe-engine/internal/runtimex"
@@ -36,8 +39,10 @@ import (
 	"github.com/ooni/probe-engine/netx/dialer"
 	"github.com/ooni/probe-engine/netx/gocertifi"
 	"github.com/ooni/probe-engine/netx/httptransport"
+	"github.com/ooni/probe-engine/netx/otlp"
 	"github.com/ooni/probe-engine/netx/quicdialer"
 	"github.com/ooni/probe-engine/netx/resolver"
+	"github.com/ooni/probe-engine/netx/retry"
 	"github.com/ooni/probe-engine/netx/selfcensor"
 	"github.com/ooni/probe-engine/netx/trace"
 )
@@ -76,33 +81,68 @@ type Resolver interface {
 	Address() string
 }
 
+// Timeouts contains the timeouts of the operations performed by netx. When
+// any field of Timeouts is zero, we will use the default for such operation.
+//
+// You may want to increase the timeouts when measuring from high-latency
+// networks, e.g., satellite and mobile networks.
+type Timeouts struct {
+	Connect       time.Duration // default: 15 seconds
+	DNS           time.Duration // default: no timeout other than the transport's
+	FirstByte     time.Duration // default: no timeout (not implemented for HTTP3)
+	Idle          time.Duration // default: the HTTP transport's default
+	QUICHandshake time.Duration // default: the QUIC library's default
+	TLSHandshake  time.Duration // default: 10 seconds
+	Total         time.Duration // default: no timeout for the HTTP round trip
+}
+
+// Retries contains the retry policies of the operations performed by
+// netx. We only retry operations that fail with a timeout. When we retry,
+// every attempt is a distinct operation for the savers. The zero value
+// of every retry.Policy means that we do not retry.
+type Retries struct {
+	Connect       retry.Policy // default: no retries
+	DNS           retry.Policy // default: no retries
+	QUICHandshake retry.Policy // default: no retries
+	TLSHandshake  retry.Policy // default: no retries
+}
+
 // Config contains configuration for creating a new transport. When any
 // field of Config is nil/empty, we will use a suitable default.
 //
 // We use different savers for different kind of events such that the
 // user of this library can choose what to save.
 type Config struct {
-	BaseResolver        Resolver             // default: system resolver
-	BogonIsError        bool                 // default: bogon is not error
-	ByteCounter         *bytecounter.Counter // default: no explicit byte counting
-	CacheResolutions    bool                 // default: no caching
-	CertPool            *x509.CertPool       // default: use vendored gocertifi
-	ContextByteCounting bool                 // default: no implicit byte counting
-	DNSCache            map[string][]string  // default: cache is empty
-	DialSaver           *trace.Saver         // default: not saving dials
-	Dialer              Dialer               // default: dialer.DNSDialer
-	FullResolver        Resolver             // default: base resolver + goodies
-	QUICDialer          QUICDialer           // default: quicdialer.DNSDialer
-	HTTP3Enabled        bool                 // default: disabled
-	HTTPSaver           *trace.Saver         // default: not saving HTTP
-	Logger              Logger               // default: no logging
-	NoTLSVerify         bool                 // default: perform TLS verify
-	ProxyURL            *url.URL             // default: no proxy
-	ReadWriteSaver      *trace.Saver         // default: not saving read/write
-	ResolveSaver        *trace.Saver         // default: not saving resolves
-	TLSConfig           *tls.Config          // default: attempt using h2
-	TLSDialer           TLSDialer            // default: dialer.TLSDialer
-	TLSSaver            *trace.Saver         // default: not saving TLS
+	AddressFamily        string                    // default: any ("4" or "6" to force)
+	BaseResolver         Resolver                  // default: system resolver
+	BogonIsError         bool                      // default: bogon is not error
+	ByteCounter          *bytecounter.Counter      // default: no explicit byte counting
+	CacheResolutions     bool                      // default: no caching
+	CertPool             *x509.CertPool            // default: use vendored gocertifi
+	ContextByteCounting  bool                      // default: no implicit byte counting
+	DNSCache             map[string][]string       // default: cache is empty
+	DialSaver            *trace.Saver              // default: not saving dials
+	Dialer               Dialer                    // default: dialer.DNSDialer
+	FullResolver         Resolver                  // default: base resolver + goodies
+	QUICDialer           QUICDialer                // default: quicdialer.DNSDialer
+	HTTP3Enabled         bool                      // default: disabled
+	HTTPBodyBudget       *httptransport.BodyBudget // default: no budget
+	HTTPBodyCapture      httptransport.BodyCapture // default: first 128 KiB
+	HTTPBodyDir          string                    // default: none (mandatory with file capture)
+	HTTPBodySnapshotSize int                       // default: 128 KiB
+	HTTPSaver            *trace.Saver              // default: not saving HTTP
+	Logger               Logger                    // default: no logging
+	NoTLSVerify          bool                      // default: perform TLS verify
+	ProxyURL             *url.URL                  // default: no proxy
+	ReadWriteSaver       *trace.Saver              // default: not saving read/write
+	ResolveSaver         *trace.Saver              // default: not saving resolves
+	Retries              Retries                   // default: no retries
+	SPKIPins             map[string][]string       // default: no pinning (see dialer.PinningTLSHandshaker)
+	SpanExporter         *otlp.Exporter            // default: not exporting spans
+	TLSConfig            *tls.Config               // default: attempt using h2
+	TLSDialer            TLSDialer                 // default: dialer.TLSDialer
+	TLSSaver             *trace.Saver              // default: not saving TLS
+	Timeouts             Timeouts                  // default: see Timeouts
 }
 
 type tlsHandshaker interface {
@@ -118,14 +158,56 @@ func NewDefaultCertPool() *x509.CertPool {
 	return pool
 }
 
+// NewCertPoolFromPEMFiles returns a certificate pool containing the
+// roots inside the specified PEM files. If replace is false, the pool
+// also contains the roots of the default certificate pool.
+func NewCertPoolFromPEMFiles(replace bool, paths ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !replace {
+		pool = NewDefaultCertPool()
+	}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("netx: no PEM certificates in %s", path)
+		}
+	}
+	return pool, nil
+}
+
 var defaultCertPool *x509.CertPool = NewDefaultCertPool()
 
+// withSpanExporter attaches the SpanExporter, if any, to the savers of
+// the events that it exports as spans. When such savers are nil, we use
+// the SpanExporter's own saver, so we export spans regardless of whether
+// the config is saving events. We do not replace a nil HTTPSaver, since
+// that would also cause us to save body snapshots: NewHTTPTransport
+// deals with this case by only saving the events we need.
+func withSpanExporter(config Config) Config {
+	if config.SpanExporter != nil {
+		config.DialSaver = config.SpanExporter.Attach(config.DialSaver)
+		config.ResolveSaver = config.SpanExporter.Attach(config.ResolveSaver)
+		config.TLSSaver = config.SpanExporter.Attach(config.TLSSaver)
+		if config.HTTPSaver != nil {
+			config.HTTPSaver = config.SpanExporter.Attach(config.HTTPSaver)
+		}
+	}
+	return config
+}
+
 // NewResolver creates a new resolver from the specified config
 func NewResolver(config Config) Resolver {
+	config = withSpanExporter(config)
 	if config.BaseResolver == nil {
 		config.BaseResolver = resolver.SystemResolver{}
 	}
 	var r Resolver = config.BaseResolver
+	if config.Timeouts.DNS > 0 {
+		r = resolver.TimeoutResolver{Resolver: r, Timeout: config.Timeouts.DNS}
+	}
 	if config.CacheResolutions {
 		r = &resolver.CacheResolver{Resolver: r}
 	}
@@ -146,17 +228,21 @@ func NewResolver(config Config) Resolver {
 	if config.ResolveSaver != nil {
 		r = resolver.SaverResolver{Resolver: r, Saver: config.ResolveSaver}
 	}
+	if config.Retries.DNS.MaxRetries > 0 {
+		r = resolver.RetryResolver{Resolver: r, Policy: config.Retries.DNS}
+	}
 	r = resolver.AddressResolver{Resolver: r}
 	return resolver.IDNAResolver{Resolver: r}
 }
 
 // NewDialer creates a new Dialer from the specified config
 func NewDialer(config Config) Dialer {
+	config = withSpanExporter(config)
 	if config.FullResolver == nil {
 		config.FullResolver = NewResolver(config)
 	}
-	var d Dialer = selfcensor.SystemDialer{}
-	d = dialer.TimeoutDialer{Dialer: d}
+	var d Dialer = selfcensor.SystemDialer{ConnectTimeout: config.Timeouts.Connect}
+	d = dialer.TimeoutDialer{Dialer: d, ConnectTimeout: config.Timeouts.Connect}
 	d = dialer.ErrorWrapperDialer{Dialer: d}
 	if config.Logger != nil {
 		d = dialer.LoggingDialer{Dialer: d, Logger: config.Logger}
@@ -167,6 +253,12 @@ func NewDialer(config Config) Dialer {
 	if config.ReadWriteSaver != nil {
 		d = dialer.SaverConnDialer{Dialer: d, Saver: config.ReadWriteSaver}
 	}
+	if config.Retries.Connect.MaxRetries > 0 {
+		d = dialer.RetryDialer{Dialer: d, Policy: config.Retries.Connect}
+	}
+	if config.AddressFamily != "" {
+		d = dialer.AddressFamilyDialer{Dialer: d, Family: config.AddressFamily}
+	}
 	d = dialer.DNSDialer{Resolver: config.FullResolver, Dialer: d}
 	d = dialer.ProxyDialer{ProxyURL: config.ProxyURL, Dialer: d}
 	if config.ContextByteCounting {
@@ -178,14 +270,22 @@ func NewDialer(config Config) Dialer {
 
 // NewQUICDialer creates a new DNS Dialer for QUIC, with the resolver from the specified config
 func NewQUICDialer(config Config) QUICDialer {
+	config = withSpanExporter(config)
 	if config.FullResolver == nil {
 		config.FullResolver = NewResolver(config)
 	}
 	var d quicdialer.ContextDialer = &quicdialer.SystemDialer{Saver: config.ReadWriteSaver}
+	if config.Timeouts.QUICHandshake > 0 {
+		d = quicdialer.TimeoutDialer{
+			Dialer: d, HandshakeTimeout: config.Timeouts.QUICHandshake}
+	}
 	d = quicdialer.ErrorWrapperDialer{Dialer: d}
 	if config.TLSSaver != nil {
 		d = quicdialer.HandshakeSaver{Saver: config.TLSSaver, Dialer: d}
 	}
+	if config.Retries.QUICHandshake.MaxRetries > 0 {
+		d = quicdialer.RetryDialer{Dialer: d, Policy: config.Retries.QUICHandshake}
+	}
 	d = &quicdialer.DNSDialer{Resolver: config.FullResolver, Dialer: d}
 	var dialer QUICDialer = &httptransport.QUICWrapperDialer{Dialer: d}
 	return dialer
@@ -193,11 +293,16 @@ func NewQUICDialer(config Config) QUICDialer {
 
 // NewTLSDialer creates a new TLSDialer from the specified config
 func NewTLSDialer(config Config) TLSDialer {
+	config = withSpanExporter(config)
 	if config.Dialer == nil {
 		config.Dialer = NewDialer(config)
 	}
 	var h tlsHandshaker = dialer.SystemTLSHandshaker{}
-	h = dialer.TimeoutTLSHandshaker{TLSHandshaker: h}
+	h = dialer.TimeoutTLSHandshaker{
+		TLSHandshaker: h, HandshakeTimeout: config.Timeouts.TLSHandshake}
+	if len(config.SPKIPins) > 0 {
+		h = dialer.PinningTLSHandshaker{TLSHandshaker: h, Pins: config.SPKIPins}
+	}
 	h = dialer.ErrorWrapperTLSHandshaker{TLSHandshaker: h}
 	if config.Logger != nil {
 		h = dialer.LoggingTLSHandshaker{Logger: config.Logger, TLSHandshaker: h}
@@ -213,16 +318,21 @@ func NewTLSDialer(config Config) TLSDialer {
 	}
 	config.TLSConfig.RootCAs = config.CertPool
 	config.TLSConfig.InsecureSkipVerify = config.NoTLSVerify
-	return dialer.TLSDialer{
+	d := dialer.TLSDialer{
 		Config:        config.TLSConfig,
 		Dialer:        config.Dialer,
 		TLSHandshaker: h,
 	}
+	if config.Retries.TLSHandshake.MaxRetries > 0 {
+		return dialer.RetryTLSDialer{TLSDialer: d, Policy: config.Retries.TLSHandshake}
+	}
+	return d
 }
 
 // NewHTTPTransport creates a new HTTPRoundTripper. You can further extend the returned
 // HTTPRoundTripper before wrapping it into an http.Client.
 func NewHTTPTransport(config Config) HTTPRoundTripper {
+	config = withSpanExporter(config)
 	if config.Dialer == nil {
 		config.Dialer = NewDialer(config)
 	}
@@ -236,9 +346,15 @@ func NewHTTPTransport(config Config) HTTPRoundTripper {
 	tInfo := allTransportsInfo[config.HTTP3Enabled]
 	txp := tInfo.Factory(httptransport.Config{
 		Dialer: config.Dialer, QUICDialer: config.QUICDialer, TLSDialer: config.TLSDialer,
-		TLSConfig: config.TLSConfig})
+		TLSConfig: config.TLSConfig, FirstByteTimeout: config.Timeouts.FirstByte,
+		IdleTimeout: config.Timeouts.Idle})
 	transport := tInfo.TransportName
 
+	if config.Timeouts.Total > 0 {
+		txp = httptransport.TimeoutTransport{
+			RoundTripper: txp, Timeout: config.Timeouts.Total}
+	}
+
 	if config.ByteCounter != nil {
 		txp = httptransport.ByteCountingTransport{
 			Counter: config.ByteCounter, RoundTripper: txp}
@@ -250,11 +366,19 @@ func NewHTTPTransport(config Config) HTTPRoundTripper {
 		txp = httptransport.SaverMetadataHTTPTransport{
 			RoundTripper: txp, Saver: config.HTTPSaver, Transport: transport}
 		txp = httptransport.SaverBodyHTTPTransport{
-			RoundTripper: txp, Saver: config.HTTPSaver}
+			RoundTripper: txp, Budget: config.HTTPBodyBudget,
+			Capture: config.HTTPBodyCapture, Dir: config.HTTPBodyDir,
+			Saver: config.HTTPSaver, SnapshotSize: config.HTTPBodySnapshotSize}
 		txp = httptransport.SaverPerformanceHTTPTransport{
 			RoundTripper: txp, Saver: config.HTTPSaver}
 		txp = httptransport.SaverTransactionHTTPTransport{
 			RoundTripper: txp, Saver: config.HTTPSaver}
+	} else if config.SpanExporter != nil {
+		saver := config.SpanExporter.Attach(nil)
+		txp = httptransport.SaverMetadataHTTPTransport{
+			RoundTripper: txp, Saver: saver, Transport: transport}
+		txp = httptransport.SaverTransactionHTTPTransport{
+			RoundTripper: txp, Saver: saver}
 	}
 	txp = httptransport.UserAgentTransport{RoundTripper: txp}
 	return txp
@@ -345,6 +469,7 @@ func ConfigureTLSVersion(config *tls.Config, version string) error {
 // with the option to override the default Hostname and SNI.
 func NewDNSClientWithOverrides(config Config, URL, hostOverride, SNIOverride,
 	TLSVersion string) (DNSClient, error) {
+	config = withSpanExporter(config)
 	var c DNSClient
 	switch URL {
 	case "doh://powerdns":
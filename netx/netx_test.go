@@ -2,17 +2,26 @@ package netx_test
 
 import (
 	"crypto/tls"
+	"encoding/pem"
 	"errors"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/ooni/probe-engine/netx"
 	"github.com/ooni/probe-engine/netx/bytecounter"
 	"github.com/ooni/probe-engine/netx/dialer"
 	"github.com/ooni/probe-engine/netx/httptransport"
+	"github.com/ooni/probe-engine/netx/otlp"
+	"github.com/ooni/probe-engine/netx/quicdialer"
 	"github.com/ooni/probe-engine/netx/resolver"
+	"github.com/ooni/probe-engine/netx/retry"
 	"github.com/ooni/probe-engine/netx/selfcensor"
 	"github.com/ooni/probe-engine/netx/trace"
 )
@@ -249,6 +258,32 @@ func TestNewDialerVanilla(t *testing.T) {
 	}
 }
 
+func TestNewDialerWithAddressFamily(t *testing.T) {
+	d := netx.NewDialer(netx.Config{AddressFamily: "6"})
+	sd, ok := d.(dialer.ShapingDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	pd, ok := sd.Dialer.(dialer.ProxyDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	dnsd, ok := pd.Dialer.(dialer.DNSDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	afd, ok := dnsd.Dialer.(dialer.AddressFamilyDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	if afd.Family != "6" {
+		t.Fatal("not the address family we expected")
+	}
+	if _, ok := afd.Dialer.(dialer.ErrorWrapperDialer); !ok {
+		t.Fatal("not the dialer we expected")
+	}
+}
+
 func TestNewDialerWithResolver(t *testing.T) {
 	d := netx.NewDialer(netx.Config{
 		FullResolver: resolver.BogonResolver{
@@ -886,6 +921,354 @@ func TestNewWithSaver(t *testing.T) {
 	}
 }
 
+func newSpanExporter(t *testing.T) *otlp.Exporter {
+	// We never export spans in these tests, so we don't need a collector
+	exporter, err := otlp.NewExporter(otlp.Config{
+		Endpoint: "http://127.0.0.1:4318/v1/traces",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exporter
+}
+
+func TestNewWithSpanExporter(t *testing.T) {
+	exporter := newSpanExporter(t)
+	defer exporter.Close()
+	txp := netx.NewHTTPTransport(netx.Config{
+		SpanExporter: exporter,
+	})
+	uatxp, ok := txp.(httptransport.UserAgentTransport)
+	if !ok {
+		t.Fatal("not the transport we expected")
+	}
+	stxptxp, ok := uatxp.RoundTripper.(httptransport.SaverTransactionHTTPTransport)
+	if !ok {
+		t.Fatal("not the transport we expected")
+	}
+	if stxptxp.Saver != exporter.Attach(nil) {
+		t.Fatal("not the saver we expected")
+	}
+	// We should not save body snapshots when exporting spans
+	smtxp, ok := stxptxp.RoundTripper.(httptransport.SaverMetadataHTTPTransport)
+	if !ok {
+		t.Fatal("not the transport we expected")
+	}
+	if smtxp.Saver != exporter.Attach(nil) {
+		t.Fatal("not the saver we expected")
+	}
+	if _, ok := smtxp.RoundTripper.(*http.Transport); !ok {
+		t.Fatal("not the transport we expected")
+	}
+}
+
+func TestNewWithSaverAndSpanExporter(t *testing.T) {
+	saver := new(trace.Saver)
+	exporter := newSpanExporter(t)
+	defer exporter.Close()
+	txp := netx.NewHTTPTransport(netx.Config{
+		HTTPSaver:    saver,
+		SpanExporter: exporter,
+	})
+	uatxp, ok := txp.(httptransport.UserAgentTransport)
+	if !ok {
+		t.Fatal("not the transport we expected")
+	}
+	stxptxp, ok := uatxp.RoundTripper.(httptransport.SaverTransactionHTTPTransport)
+	if !ok {
+		t.Fatal("not the transport we expected")
+	}
+	if stxptxp.Saver != saver {
+		t.Fatal("not the saver we expected")
+	}
+	if _, ok := stxptxp.RoundTripper.(httptransport.SaverPerformanceHTTPTransport); !ok {
+		t.Fatal("not the transport we expected")
+	}
+}
+
+func TestNewDialerWithSpanExporter(t *testing.T) {
+	exporter := newSpanExporter(t)
+	defer exporter.Close()
+	d := netx.NewDialer(netx.Config{
+		SpanExporter: exporter,
+	})
+	sd, ok := d.(dialer.ShapingDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	pd, ok := sd.Dialer.(dialer.ProxyDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	dnsd, ok := pd.Dialer.(dialer.DNSDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	sad, ok := dnsd.Dialer.(dialer.SaverDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	if sad.Saver != exporter.Attach(nil) {
+		t.Fatal("not the saver we expected")
+	}
+}
+
+func TestNewResolverWithTimeoutsAndRetries(t *testing.T) {
+	r := netx.NewResolver(netx.Config{
+		Retries:  netx.Retries{DNS: retry.Policy{MaxRetries: 3}},
+		Timeouts: netx.Timeouts{DNS: 7 * time.Second},
+	})
+	ir, ok := r.(resolver.IDNAResolver)
+	if !ok {
+		t.Fatal("not the resolver we expected")
+	}
+	ar, ok := ir.Resolver.(resolver.AddressResolver)
+	if !ok {
+		t.Fatal("not the resolver we expected")
+	}
+	rr, ok := ar.Resolver.(resolver.RetryResolver)
+	if !ok {
+		t.Fatal("not the resolver we expected")
+	}
+	if rr.Policy.MaxRetries != 3 {
+		t.Fatal("not the retry policy we expected")
+	}
+	ewr, ok := rr.Resolver.(resolver.ErrorWrapperResolver)
+	if !ok {
+		t.Fatal("not the resolver we expected")
+	}
+	tr, ok := ewr.Resolver.(resolver.TimeoutResolver)
+	if !ok {
+		t.Fatal("not the resolver we expected")
+	}
+	if tr.Timeout != 7*time.Second {
+		t.Fatal("not the timeout we expected")
+	}
+	if _, ok := tr.Resolver.(resolver.SystemResolver); !ok {
+		t.Fatal("not the resolver we expected")
+	}
+}
+
+func TestNewDialerWithTimeoutsAndRetries(t *testing.T) {
+	d := netx.NewDialer(netx.Config{
+		Retries:  netx.Retries{Connect: retry.Policy{MaxRetries: 2}},
+		Timeouts: netx.Timeouts{Connect: time.Minute},
+	})
+	sd, ok := d.(dialer.ShapingDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	pd, ok := sd.Dialer.(dialer.ProxyDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	dnsd, ok := pd.Dialer.(dialer.DNSDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	rd, ok := dnsd.Dialer.(dialer.RetryDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	if rd.Policy.MaxRetries != 2 {
+		t.Fatal("not the retry policy we expected")
+	}
+	ewd, ok := rd.Dialer.(dialer.ErrorWrapperDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	td, ok := ewd.Dialer.(dialer.TimeoutDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	if td.ConnectTimeout != time.Minute {
+		t.Fatal("not the timeout we expected")
+	}
+	scd, ok := td.Dialer.(selfcensor.SystemDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	if scd.ConnectTimeout != time.Minute {
+		t.Fatal("not the timeout we expected")
+	}
+}
+
+func TestNewTLSDialerWithTimeoutsAndRetries(t *testing.T) {
+	td := netx.NewTLSDialer(netx.Config{
+		Retries:  netx.Retries{TLSHandshake: retry.Policy{MaxRetries: 1}},
+		Timeouts: netx.Timeouts{TLSHandshake: 30 * time.Second},
+	})
+	rtd, ok := td.(dialer.RetryTLSDialer)
+	if !ok {
+		t.Fatal("not the TLSDialer we expected")
+	}
+	if rtd.Policy.MaxRetries != 1 {
+		t.Fatal("not the retry policy we expected")
+	}
+	ewth, ok := rtd.TLSDialer.TLSHandshaker.(dialer.ErrorWrapperTLSHandshaker)
+	if !ok {
+		t.Fatal("not the TLSHandshaker we expected")
+	}
+	tth, ok := ewth.TLSHandshaker.(dialer.TimeoutTLSHandshaker)
+	if !ok {
+		t.Fatal("not the TLSHandshaker we expected")
+	}
+	if tth.HandshakeTimeout != 30*time.Second {
+		t.Fatal("not the timeout we expected")
+	}
+}
+
+func TestNewTLSDialerWithSPKIPins(t *testing.T) {
+	pins := map[string][]string{"x.org": {"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}}
+	td := netx.NewTLSDialer(netx.Config{SPKIPins: pins})
+	tld, ok := td.(dialer.TLSDialer)
+	if !ok {
+		t.Fatal("not the TLSDialer we expected")
+	}
+	ewth, ok := tld.TLSHandshaker.(dialer.ErrorWrapperTLSHandshaker)
+	if !ok {
+		t.Fatal("not the TLSHandshaker we expected")
+	}
+	pth, ok := ewth.TLSHandshaker.(dialer.PinningTLSHandshaker)
+	if !ok {
+		t.Fatal("not the TLSHandshaker we expected")
+	}
+	if len(pth.Pins["x.org"]) != 1 {
+		t.Fatal("not the pins we expected")
+	}
+	if _, ok := pth.TLSHandshaker.(dialer.TimeoutTLSHandshaker); !ok {
+		t.Fatal("not the TLSHandshaker we expected")
+	}
+}
+
+func writeTestPEMFile(t *testing.T, dir string) string {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	data := pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	path := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewCertPoolFromPEMFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writeTestPEMFile(t, dir)
+	pool, err := netx.NewCertPoolFromPEMFiles(true, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.Subjects()) != 1 {
+		t.Fatal("the pool should only contain our root")
+	}
+	pool, err = netx.NewCertPoolFromPEMFiles(false, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool.Subjects()) != len(netx.NewDefaultCertPool().Subjects())+1 {
+		t.Fatal("the pool should contain the default roots and our root")
+	}
+}
+
+func TestNewCertPoolFromPEMFilesNonexistentFile(t *testing.T) {
+	pool, err := netx.NewCertPoolFromPEMFiles(false, "/nonexistent/ca.pem")
+	if !os.IsNotExist(err) {
+		t.Fatal("not the error we expected")
+	}
+	if pool != nil {
+		t.Fatal("expected nil pool here")
+	}
+}
+
+func TestNewCertPoolFromPEMFilesNoCertificates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "netx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(path, []byte("antani"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := netx.NewCertPoolFromPEMFiles(false, path)
+	if err == nil || !strings.HasSuffix(err.Error(), "no PEM certificates in "+path) {
+		t.Fatal("not the error we expected")
+	}
+	if pool != nil {
+		t.Fatal("expected nil pool here")
+	}
+}
+
+func TestNewQUICDialerWithTimeoutsAndRetries(t *testing.T) {
+	d := netx.NewQUICDialer(netx.Config{
+		Retries:  netx.Retries{QUICHandshake: retry.Policy{MaxRetries: 2}},
+		Timeouts: netx.Timeouts{QUICHandshake: 20 * time.Second},
+	})
+	wd, ok := d.(*httptransport.QUICWrapperDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	dnsd, ok := wd.Dialer.(*quicdialer.DNSDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	rd, ok := dnsd.Dialer.(quicdialer.RetryDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	if rd.Policy.MaxRetries != 2 {
+		t.Fatal("not the retry policy we expected")
+	}
+	ewd, ok := rd.Dialer.(quicdialer.ErrorWrapperDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	td, ok := ewd.Dialer.(quicdialer.TimeoutDialer)
+	if !ok {
+		t.Fatal("not the dialer we expected")
+	}
+	if td.HandshakeTimeout != 20*time.Second {
+		t.Fatal("not the timeout we expected")
+	}
+}
+
+func TestNewWithTimeouts(t *testing.T) {
+	txp := netx.NewHTTPTransport(netx.Config{
+		Timeouts: netx.Timeouts{
+			FirstByte: 10 * time.Second,
+			Idle:      time.Minute,
+			Total:     2 * time.Minute,
+		},
+	})
+	uatxp, ok := txp.(httptransport.UserAgentTransport)
+	if !ok {
+		t.Fatal("not the transport we expected")
+	}
+	ttxp, ok := uatxp.RoundTripper.(httptransport.TimeoutTransport)
+	if !ok {
+		t.Fatal("not the transport we expected")
+	}
+	if ttxp.Timeout != 2*time.Minute {
+		t.Fatal("not the timeout we expected")
+	}
+	htxp, ok := ttxp.RoundTripper.(*http.Transport)
+	if !ok {
+		t.Fatal("not the transport we expected")
+	}
+	if htxp.ResponseHeaderTimeout != 10*time.Second {
+		t.Fatal("not the first byte timeout we expected")
+	}
+	if htxp.IdleConnTimeout != time.Minute {
+		t.Fatal("not the idle timeout we expected")
+	}
+}
+
 func TestNewDNSClientInvalidURL(t *testing.T) {
 	dnsclient, err := netx.NewDNSClient(netx.Config{}, "\t\t\t")
 	if err == nil || !strings.HasSuffix(err.Error(), "invalid control character in URL") {
@@ -0,0 +1,283 @@
+// Package otlp exports netx events as OpenTelemetry spans.
+//
+// The Exporter subscribes to one or more trace.Saver instances and
+// converts the resolver, dial, TLS, QUIC, and HTTP events into spans,
+// which it exports using OTLP/JSON either by appending to a file (one
+// export request per line) or by posting to a collector's OTLP/HTTP
+// traces endpoint (e.g., http://127.0.0.1:4318/v1/traces).
+//
+// Failed operations have an error status whose message is the errorx
+// failure string (e.g., "connection_refused").
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/ooni/probe-engine/netx/trace"
+)
+
+// Config contains the Exporter config. You must set exactly
+// one between the Endpoint and the File fields.
+type Config struct {
+	// BatchSize is the number of spans we export together. When it
+	// is zero, we use a reasonable default.
+	BatchSize int
+
+	// Endpoint is the URL of the collector's OTLP/HTTP traces endpoint.
+	Endpoint string
+
+	// File is the file to which we append the OTLP/JSON export requests.
+	File string
+
+	// HTTPClient is the optional HTTP client used to post to the
+	// Endpoint. When it is nil, we use http.DefaultClient. Do not use a
+	// client whose transport is exporting spans to this Exporter.
+	HTTPClient *http.Client
+
+	// ParentSpanID is the optional hex-encoded ID of the span
+	// that should be the parent of the netx spans.
+	ParentSpanID string
+
+	// QueueSize is the size of the queue of each subscription to
+	// a trace.Saver. When the queue is full, we drop events, so that
+	// we never slow down netx. When it is zero, we use a reasonable
+	// default.
+	QueueSize int
+
+	// ServiceName is the service.name resource attribute. When
+	// it is empty, we use "probe-engine".
+	ServiceName string
+
+	// TraceID is the optional hex-encoded ID of the trace the
+	// netx spans belong to. When it is empty, we create a new
+	// random trace ID for the Exporter.
+	TraceID string
+}
+
+const (
+	defaultBatchSize   = 64
+	defaultQueueSize   = 1024
+	defaultServiceName = "probe-engine"
+	scopeName          = "github.com/ooni/probe-engine/netx/otlp"
+)
+
+var (
+	// ErrInvalidConfig indicates that the Exporter config is invalid.
+	ErrInvalidConfig = errors.New("otlp: invalid config")
+
+	traceIDRegexp = regexp.MustCompile("^[0-9a-f]{32}$")
+	spanIDRegexp  = regexp.MustCompile("^[0-9a-f]{16}$")
+)
+
+// Exporter exports netx events as spans. It implements trace.Subscriber
+// and you can use Attach to subscribe it to a trace.Saver.
+type Exporter struct {
+	batchSize   int
+	closed      bool
+	conv        converter
+	err         error
+	mu          sync.Mutex
+	own         *trace.Saver
+	pending     []*span
+	queueSize   int
+	resource    resource
+	sink        sink
+	unsubscribe map[*trace.Saver]func()
+}
+
+// sink is where we write the export requests.
+type sink interface {
+	export(data []byte) error
+	io.Closer
+}
+
+// NewExporter creates a new Exporter. Remember to Close it when done
+// to export the remaining spans and release resources.
+func NewExporter(config Config) (*Exporter, error) {
+	if (config.Endpoint == "") == (config.File == "") {
+		return nil, fmt.Errorf("%w: set exactly one of Endpoint and File", ErrInvalidConfig)
+	}
+	if config.TraceID == "" {
+		config.TraceID = newTraceID()
+	}
+	if !traceIDRegexp.MatchString(config.TraceID) {
+		return nil, fmt.Errorf("%w: invalid TraceID", ErrInvalidConfig)
+	}
+	if config.ParentSpanID != "" && !spanIDRegexp.MatchString(config.ParentSpanID) {
+		return nil, fmt.Errorf("%w: invalid ParentSpanID", ErrInvalidConfig)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultQueueSize
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = defaultServiceName
+	}
+	var s sink
+	if config.File != "" {
+		filep, err := os.OpenFile(config.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		s = fileSink{File: filep}
+	} else {
+		client := config.HTTPClient
+		if client == nil {
+			client = http.DefaultClient
+		}
+		s = httpSink{client: client, endpoint: config.Endpoint}
+	}
+	return &Exporter{
+		batchSize: config.BatchSize,
+		conv: converter{
+			parentSpanID: config.ParentSpanID,
+			traceID:      config.TraceID,
+		},
+		queueSize: config.QueueSize,
+		resource: resource{Attributes: []keyValue{{
+			Key: "service.name", Value: stringValue(config.ServiceName),
+		}}},
+		sink:        s,
+		unsubscribe: make(map[*trace.Saver]func()),
+	}, nil
+}
+
+// TraceID returns the hex-encoded ID of the trace to which
+// the spans exported by this Exporter belong.
+func (e *Exporter) TraceID() string {
+	return e.conv.traceID
+}
+
+// Attach subscribes the Exporter to the saver, unless it is already
+// subscribed, and returns the saver. If the saver is nil, Attach returns
+// a saver owned by the Exporter, which does not keep events in memory
+// for longer than needed. This allows netx to export spans when the user
+// of netx is not otherwise interested into saving events.
+func (e *Exporter) Attach(saver *trace.Saver) *trace.Saver {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if saver == nil {
+		if e.own == nil {
+			e.own = &trace.Saver{MaxEvents: 1}
+		}
+		saver = e.own
+	}
+	if _, found := e.unsubscribe[saver]; !found && !e.closed {
+		e.unsubscribe[saver] = saver.Subscribe(e, trace.SubscribeOptions{
+			Filter:    isSpanEvent,
+			Policy:    trace.DropPolicy,
+			QueueSize: e.queueSize,
+		})
+	}
+	return saver
+}
+
+// OnEvent implements trace.Subscriber.OnEvent.
+func (e *Exporter) OnEvent(ev trace.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if s := e.conv.convert(ev); s != nil {
+		e.pending = append(e.pending, s)
+	}
+	if len(e.pending) >= e.batchSize {
+		e.flushLocked()
+	}
+}
+
+// Flush exports the pending spans and returns the first
+// error that occurred when exporting, if any.
+func (e *Exporter) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.flushLocked()
+	return e.err
+}
+
+func (e *Exporter) flushLocked() {
+	if len(e.pending) <= 0 {
+		return
+	}
+	data, err := json.Marshal(exportRequest{ResourceSpans: []resourceSpans{{
+		Resource: e.resource,
+		ScopeSpans: []scopeSpans{{
+			Scope: scope{Name: scopeName},
+			Spans: e.pending,
+		}},
+	}}})
+	e.pending = nil
+	if err == nil {
+		err = e.sink.export(data)
+	}
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+// Close unsubscribes from all the savers, exports the pending
+// spans, and returns the first error that occurred, if any. The spans
+// of the HTTP transactions that are still running are not exported.
+func (e *Exporter) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return e.err
+	}
+	e.closed = true
+	unsubscribe := e.unsubscribe
+	e.unsubscribe = nil
+	e.mu.Unlock()
+	for _, fn := range unsubscribe {
+		fn() // waits for queued events, hence we must not hold the lock
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.flushLocked()
+	if err := e.sink.Close(); err != nil && e.err == nil {
+		e.err = err
+	}
+	return e.err
+}
+
+// fileSink appends export requests to a file.
+type fileSink struct {
+	*os.File
+}
+
+func (s fileSink) export(data []byte) error {
+	_, err := s.Write(append(data, '\n'))
+	return err
+}
+
+// httpSink posts export requests to a collector.
+type httpSink struct {
+	client   *http.Client
+	endpoint string
+}
+
+func (s httpSink) export(data []byte) error {
+	resp, err := s.client.Post(s.endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: collector returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s httpSink) Close() error {
+	return nil
+}
@@ -0,0 +1,375 @@
+package otlp_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/otlp"
+	"github.com/ooni/probe-engine/netx/trace"
+)
+
+// exportRequest is the subset of the OTLP/JSON data model we check.
+type exportRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []attribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []span `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type span struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes"`
+	Status            struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+type attribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		BoolValue   *bool   `json:"boolValue"`
+		IntValue    *string `json:"intValue"`
+		StringValue *string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func (s span) attribute(key string) (string, bool) {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			switch {
+			case a.Value.StringValue != nil:
+				return *a.Value.StringValue, true
+			case a.Value.IntValue != nil:
+				return *a.Value.IntValue, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (r exportRequest) spans() (out []span) {
+	for _, rs := range r.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			out = append(out, ss.Spans...)
+		}
+	}
+	return
+}
+
+const (
+	traceID      = "0123456789abcdef0123456789abcdef"
+	parentSpanID = "0123456789abcdef"
+)
+
+func TestNewExporterInvalidConfig(t *testing.T) {
+	configs := []otlp.Config{{
+		// neither Endpoint nor File
+	}, {
+		Endpoint: "http://127.0.0.1:4318/v1/traces",
+		File:     "spans.jsonl",
+	}, {
+		Endpoint: "http://127.0.0.1:4318/v1/traces",
+		TraceID:  "antani",
+	}, {
+		Endpoint:     "http://127.0.0.1:4318/v1/traces",
+		ParentSpanID: "antani",
+	}}
+	for _, config := range configs {
+		exporter, err := otlp.NewExporter(config)
+		if !errors.Is(err, otlp.ErrInvalidConfig) {
+			t.Fatal("not the error we expected")
+		}
+		if exporter != nil {
+			t.Fatal("expected nil exporter here")
+		}
+	}
+}
+
+func TestNewExporterCannotOpenFile(t *testing.T) {
+	exporter, err := otlp.NewExporter(otlp.Config{File: "/nonexistent/spans.jsonl"})
+	if err == nil {
+		t.Fatal("expected an error here")
+	}
+	if exporter != nil {
+		t.Fatal("expected nil exporter here")
+	}
+}
+
+func TestExporterRandomTraceID(t *testing.T) {
+	exporter, err := otlp.NewExporter(otlp.Config{
+		Endpoint: "http://127.0.0.1:4318/v1/traces",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Close()
+	if len(exporter.TraceID()) != 32 || exporter.TraceID() == traceID {
+		t.Fatal("unexpected trace ID")
+	}
+}
+
+// writeTransaction writes the events of a failed HTTP transaction
+// followed by the events of an unrelated TLS handshake.
+func writeTransaction(saver *trace.Saver) {
+	begin := time.Now()
+	saver.Write(trace.Event{
+		Name:          "http_transaction_start",
+		Time:          begin,
+		TransactionID: 1,
+	})
+	saver.Write(trace.Event{
+		HTTPMethod:    "GET",
+		HTTPURL:       "https://www.example.com/",
+		Name:          "http_request_metadata",
+		Time:          begin,
+		TransactionID: 1,
+		Transport:     "tcp",
+	})
+	saver.Write(trace.Event{
+		Addresses:     []string{"93.184.216.34"},
+		Duration:      10 * time.Millisecond,
+		Hostname:      "www.example.com",
+		Name:          "resolve_done",
+		Proto:         "system",
+		Time:          begin.Add(10 * time.Millisecond),
+		TransactionID: 1,
+	})
+	saver.Write(trace.Event{
+		Address:  "93.184.216.34:443",
+		DialID:   7,
+		Duration: 20 * time.Millisecond,
+		Err: &errorx.ErrWrapper{
+			Failure:   errorx.FailureConnectionRefused,
+			Operation: errorx.ConnectOperation,
+		},
+		Name:          errorx.ConnectOperation,
+		Proto:         "tcp",
+		Time:          begin.Add(30 * time.Millisecond),
+		TransactionID: 1,
+	})
+	saver.Write(trace.Event{
+		Err:           errors.New("mocked error"),
+		Name:          "http_transaction_done",
+		Time:          begin.Add(30 * time.Millisecond),
+		TransactionID: 1,
+	})
+	saver.Write(trace.Event{Name: "http_request_body_snapshot"}) // ignored
+	saver.Write(trace.Event{
+		ConnID:        1234,
+		Duration:      time.Millisecond,
+		Name:          "tls_handshake_done",
+		TLSServerName: "www.example.com",
+		TLSVersion:    "TLSv1.3",
+		Time:          begin.Add(40 * time.Millisecond),
+	})
+}
+
+func TestExporterFile(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "ooniprobe-engine-otlp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	filename := filepath.Join(dirname, "spans.jsonl")
+	exporter, err := otlp.NewExporter(otlp.Config{
+		BatchSize:    3,
+		File:         filename,
+		ParentSpanID: parentSpanID,
+		ServiceName:  "antani",
+		TraceID:      traceID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saver := new(trace.Saver)
+	if exporter.Attach(saver) != saver {
+		t.Fatal("not the saver we expected")
+	}
+	exporter.Attach(saver) // should not subscribe twice
+	writeTransaction(saver)
+	if err := exporter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.Close(); err != nil {
+		t.Fatal("close should be idempotent")
+	}
+	filep, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer filep.Close()
+	var (
+		requests []exportRequest
+		spans    []span
+	)
+	scanner := bufio.NewScanner(filep)
+	for scanner.Scan() {
+		var request exportRequest
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, request)
+		spans = append(spans, request.spans()...)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Fatal("unexpected number of export requests")
+	}
+	attrs := requests[0].ResourceSpans[0].Resource.Attributes
+	if len(attrs) != 1 || attrs[0].Key != "service.name" || *attrs[0].Value.StringValue != "antani" {
+		t.Fatal("unexpected resource attributes")
+	}
+	if len(spans) != 4 {
+		t.Fatal("unexpected number of spans")
+	}
+	resolve, connect, transaction, handshake := spans[0], spans[1], spans[2], spans[3]
+	for _, s := range spans {
+		if s.TraceID != traceID {
+			t.Fatal("unexpected trace ID")
+		}
+		if len(s.SpanID) != 16 {
+			t.Fatal("unexpected span ID")
+		}
+		if s.Kind != 3 {
+			t.Fatal("unexpected span kind")
+		}
+	}
+	if transaction.Name != "http_transaction" || transaction.ParentSpanID != parentSpanID {
+		t.Fatal("unexpected transaction span")
+	}
+	if value, _ := transaction.attribute("http.url"); value != "https://www.example.com/" {
+		t.Fatal("unexpected http.url")
+	}
+	if transaction.Status.Code != 2 || transaction.Status.Message != "unknown_failure: mocked error" {
+		t.Fatal("unexpected transaction status")
+	}
+	if resolve.Name != "resolve" || resolve.ParentSpanID != transaction.SpanID {
+		t.Fatal("unexpected resolve span")
+	}
+	if resolve.Status.Code != 0 {
+		t.Fatal("unexpected resolve status")
+	}
+	if connect.Name != "connect" || connect.ParentSpanID != transaction.SpanID {
+		t.Fatal("unexpected connect span")
+	}
+	if connect.Status.Code != 2 || connect.Status.Message != errorx.FailureConnectionRefused {
+		t.Fatal("unexpected connect status")
+	}
+	if value, _ := connect.attribute("netx.failed_operation"); value != errorx.ConnectOperation {
+		t.Fatal("unexpected netx.failed_operation")
+	}
+	if value, _ := connect.attribute("net.peer.port"); value != "443" {
+		t.Fatal("unexpected net.peer.port")
+	}
+	if value, _ := connect.attribute("netx.dial_id"); value != "7" {
+		t.Fatal("unexpected netx.dial_id")
+	}
+	if connect.StartTimeUnixNano >= connect.EndTimeUnixNano {
+		t.Fatal("unexpected connect times")
+	}
+	if handshake.Name != "tls_handshake" || handshake.ParentSpanID != parentSpanID {
+		t.Fatal("unexpected handshake span")
+	}
+	if value, _ := handshake.attribute("tls.version"); value != "TLSv1.3" {
+		t.Fatal("unexpected tls.version")
+	}
+	if value, _ := handshake.attribute("netx.conn_id"); value != "1234" {
+		t.Fatal("unexpected netx.conn_id")
+	}
+}
+
+func TestExporterEndpoint(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []exportRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request exportRequest
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(400)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, request)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	exporter, err := otlp.NewExporter(otlp.Config{
+		BatchSize: 1,
+		Endpoint:  server.URL + "/v1/traces",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saver := exporter.Attach(nil)
+	if saver == nil || exporter.Attach(nil) != saver {
+		t.Fatal("not the saver we expected")
+	}
+	writeTransaction(saver)
+	if err := exporter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 4 {
+		t.Fatal("unexpected number of export requests")
+	}
+	for _, request := range requests {
+		if spans := request.spans(); len(spans) != 1 || spans[0].TraceID != exporter.TraceID() {
+			t.Fatal("unexpected spans")
+		}
+	}
+	// After Close, we should not subscribe to savers anymore
+	saver = new(trace.Saver)
+	exporter.Attach(saver)
+	writeTransaction(saver)
+	if err := exporter.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 4 {
+		t.Fatal("unexpected number of export requests")
+	}
+}
+
+func TestExporterEndpointFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+	exporter, err := otlp.NewExporter(otlp.Config{Endpoint: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTransaction(exporter.Attach(nil))
+	if err := exporter.Close(); err == nil || err.Error() != "otlp: collector returned 500" {
+		t.Fatal("not the error we expected")
+	}
+	if err := exporter.Flush(); err == nil {
+		t.Fatal("expected an error here")
+	}
+}
@@ -0,0 +1,275 @@
+package otlp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/trace"
+)
+
+// The following types are the subset of the OTLP/JSON data model
+// that we need. See <https://github.com/open-telemetry/opentelemetry-proto>.
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope   `json:"scope"`
+	Spans []*span `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	ArrayValue  *arrayValue `json:"arrayValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *string     `json:"intValue,omitempty"`
+	StringValue *string     `json:"stringValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// spanKindClient is SPAN_KIND_CLIENT.
+	spanKindClient = 3
+
+	// statusCodeError is STATUS_CODE_ERROR.
+	statusCodeError = 2
+)
+
+func stringValue(s string) anyValue {
+	return anyValue{StringValue: &s}
+}
+
+func intValue(v int64) anyValue {
+	// The protobuf JSON mapping encodes int64 as a string.
+	s := strconv.FormatInt(v, 10)
+	return anyValue{IntValue: &s}
+}
+
+func boolValue(v bool) anyValue {
+	return anyValue{BoolValue: &v}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func newID(size int) string {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return strings.Repeat("0", 2*size) // means invalid
+	}
+	return hex.EncodeToString(data)
+}
+
+// newTraceID returns a new random trace ID.
+func newTraceID() string {
+	return newID(16)
+}
+
+// newSpanID returns a new random span ID.
+func newSpanID() string {
+	return newID(8)
+}
+
+// spanEvents contains the names of the events we convert to spans.
+var spanEvents = map[string]bool{
+	"resolve_done":           true,
+	"dns_round_trip_done":    true,
+	errorx.ConnectOperation:  true,
+	"tls_handshake_done":     true,
+	"quic_handshake_done":    true,
+	"http_transaction_start": true,
+	"http_request_metadata":  true,
+	"http_response_metadata": true,
+	"http_transaction_done":  true,
+}
+
+// isSpanEvent returns whether we convert the event to a span.
+func isSpanEvent(ev trace.Event) bool {
+	return spanEvents[ev.Name]
+}
+
+// converter converts netx events into spans. All the spans belong to
+// the same trace. The spans of an HTTP transaction are children of the
+// transaction span. All the other spans are children of parentSpanID.
+type converter struct {
+	parentSpanID string
+	traceID      string
+	transactions map[int64]*span
+}
+
+// newSpan creates a span for the event. We assume that the event
+// occurred at the end of the operation and lasted for ev.Duration.
+func (c *converter) newSpan(name string, ev trace.Event) *span {
+	s := &span{
+		TraceID:           c.traceID,
+		SpanID:            newSpanID(),
+		ParentSpanID:      c.parentSpanID,
+		Name:              name,
+		Kind:              spanKindClient,
+		StartTimeUnixNano: unixNano(ev.Time.Add(-ev.Duration)),
+		EndTimeUnixNano:   unixNano(ev.Time),
+	}
+	if tx, found := c.transactions[ev.TransactionID]; found {
+		s.ParentSpanID = tx.SpanID
+	}
+	s.addIDs(ev)
+	s.setStatus(ev.Err)
+	return s
+}
+
+// convert processes the event and returns the span that the
+// event completes, if any, or nil otherwise.
+func (c *converter) convert(ev trace.Event) *span {
+	switch ev.Name {
+	case "resolve_done":
+		s := c.newSpan("resolve", ev)
+		s.addString("net.peer.name", ev.Hostname)
+		s.addString("netx.resolver.network", ev.Proto)
+		s.addString("netx.resolver.address", ev.Address)
+		if len(ev.Addresses) > 0 {
+			var values []anyValue
+			for _, address := range ev.Addresses {
+				values = append(values, stringValue(address))
+			}
+			s.Attributes = append(s.Attributes, keyValue{
+				Key: "netx.resolver.addresses", Value: anyValue{
+					ArrayValue: &arrayValue{Values: values}}})
+		}
+		return s
+	case "dns_round_trip_done":
+		s := c.newSpan("dns_round_trip", ev)
+		s.addString("netx.resolver.network", ev.Proto)
+		s.addString("netx.resolver.address", ev.Address)
+		return s
+	case errorx.ConnectOperation:
+		s := c.newSpan(errorx.ConnectOperation, ev)
+		s.addString("net.transport", ev.Proto)
+		s.addPeer(ev.Address)
+		return s
+	case "tls_handshake_done", "quic_handshake_done":
+		s := c.newSpan(strings.TrimSuffix(ev.Name, "_done"), ev)
+		s.addPeer(ev.Address)
+		s.addString("tls.server_name", ev.TLSServerName)
+		s.addString("tls.version", ev.TLSVersion)
+		s.addString("tls.cipher_suite", ev.TLSCipherSuite)
+		s.addString("tls.negotiated_protocol", ev.TLSNegotiatedProto)
+		s.Attributes = append(s.Attributes, keyValue{
+			Key: "tls.no_verify", Value: boolValue(ev.NoTLSVerify)})
+		return s
+	case "http_transaction_start":
+		s := c.newSpan("http_transaction", ev)
+		if c.transactions == nil {
+			c.transactions = make(map[int64]*span)
+		}
+		c.transactions[ev.TransactionID] = s
+	case "http_request_metadata":
+		if s, found := c.transactions[ev.TransactionID]; found {
+			s.addString("http.method", ev.HTTPMethod)
+			s.addString("http.url", ev.HTTPURL)
+			s.addString("netx.http.transport", ev.Transport)
+		}
+	case "http_response_metadata":
+		if s, found := c.transactions[ev.TransactionID]; found {
+			s.Attributes = append(s.Attributes, keyValue{
+				Key: "http.status_code", Value: intValue(int64(ev.HTTPStatusCode))})
+		}
+	case "http_transaction_done":
+		if s, found := c.transactions[ev.TransactionID]; found {
+			delete(c.transactions, ev.TransactionID)
+			s.EndTimeUnixNano = unixNano(ev.Time)
+			s.setStatus(ev.Err)
+			return s
+		}
+	}
+	return nil
+}
+
+func (s *span) addString(key, value string) {
+	if value != "" {
+		s.Attributes = append(s.Attributes, keyValue{Key: key, Value: stringValue(value)})
+	}
+}
+
+func (s *span) addInt(key string, value int64) {
+	if value != 0 {
+		s.Attributes = append(s.Attributes, keyValue{Key: key, Value: intValue(value)})
+	}
+}
+
+func (s *span) addIDs(ev trace.Event) {
+	s.addInt("netx.conn_id", ev.ConnID)
+	s.addInt("netx.dial_id", ev.DialID)
+	s.addInt("netx.transaction_id", ev.TransactionID)
+}
+
+func (s *span) addPeer(address string) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return
+	}
+	s.addString("net.peer.ip", host)
+	if iport, err := strconv.ParseInt(port, 10, 64); err == nil {
+		s.addInt("net.peer.port", iport)
+	}
+}
+
+// setStatus sets the span status from the error. We use the errorx
+// failure string as the status message, so that spans and OONI
+// measurements use the same failure strings.
+func (s *span) setStatus(err error) {
+	if err == nil {
+		return
+	}
+	err = errorx.SafeErrWrapperBuilder{
+		Error:     err,
+		Operation: errorx.TopLevelOperation,
+	}.MaybeBuild()
+	errWrapper := err.(*errorx.ErrWrapper)
+	s.Status = status{Code: statusCodeError, Message: errWrapper.Failure}
+	s.addString("netx.failure", errWrapper.Failure)
+	s.addString("netx.failed_operation", errWrapper.Operation)
+}
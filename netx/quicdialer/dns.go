@@ -6,8 +6,8 @@ import (
 	"net"
 
 	"github.com/lucas-clemente/quic-go"
-	"github.com/ooni/probe-engine/legacy/netx/dialid"
 	"github.com/ooni/probe-engine/netx/dialer"
+	"github.com/ooni/probe-engine/netx/dialid"
 )
 
 // DNSDialer is a dialer that uses the configured Resolver to resolve a
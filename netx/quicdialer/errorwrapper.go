@@ -5,7 +5,7 @@ import (
 	"crypto/tls"
 
 	"github.com/lucas-clemente/quic-go"
-	"github.com/ooni/probe-engine/legacy/netx/dialid"
+	"github.com/ooni/probe-engine/netx/dialid"
 	"github.com/ooni/probe-engine/netx/errorx"
 )
 
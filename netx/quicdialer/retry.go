@@ -0,0 +1,27 @@
+package quicdialer
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/retry"
+)
+
+// RetryDialer is a dialer that retries QUIC handshakes that time out.
+type RetryDialer struct {
+	Dialer ContextDialer
+	Policy retry.Policy
+}
+
+// DialContext implements ContextDialer.DialContext
+func (d RetryDialer) DialContext(
+	ctx context.Context, network string, host string,
+	tlsCfg *tls.Config, cfg *quic.Config) (sess quic.EarlySession, err error) {
+	err = d.Policy.Do(ctx, errorx.QUICHandshakeOperation, func() (err error) {
+		sess, err = d.Dialer.DialContext(ctx, network, host, tlsCfg, cfg)
+		return
+	})
+	return
+}
@@ -0,0 +1,62 @@
+package quicdialer_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/quicdialer"
+	"github.com/ooni/probe-engine/netx/retry"
+)
+
+type CountingDialer struct {
+	Count int
+	Err   error
+}
+
+func (d *CountingDialer) DialContext(ctx context.Context, network, host string,
+	tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlySession, error) {
+	d.Count++
+	return nil, d.Err
+}
+
+func TestRetryDialerTimeout(t *testing.T) {
+	child := &CountingDialer{Err: &errorx.ErrWrapper{
+		Failure:   errorx.FailureGenericTimeoutError,
+		Operation: errorx.QUICHandshakeOperation,
+	}}
+	d := quicdialer.RetryDialer{Dialer: child, Policy: retry.Policy{MaxRetries: 2}}
+	sess, err := d.DialContext(
+		context.Background(), "udp", "216.58.212.164:443", &tls.Config{}, &quic.Config{})
+	if !errors.Is(err, child.Err) {
+		t.Fatal("not the error we expected")
+	}
+	if sess != nil {
+		t.Fatal("expected nil sess here")
+	}
+	if child.Count != 3 {
+		t.Fatal("unexpected number of attempts")
+	}
+}
+
+func TestRetryDialerOtherFailure(t *testing.T) {
+	child := &CountingDialer{Err: &errorx.ErrWrapper{
+		Failure:   errorx.FailureNoCompatibleQUICVersion,
+		Operation: errorx.QUICHandshakeOperation,
+	}}
+	d := quicdialer.RetryDialer{Dialer: child, Policy: retry.Policy{MaxRetries: 2}}
+	sess, err := d.DialContext(
+		context.Background(), "udp", "216.58.212.164:443", &tls.Config{}, &quic.Config{})
+	if !errors.Is(err, child.Err) {
+		t.Fatal("not the error we expected")
+	}
+	if sess != nil {
+		t.Fatal("expected nil sess here")
+	}
+	if child.Count != 1 {
+		t.Fatal("we should not have retried")
+	}
+}
@@ -7,7 +7,10 @@ import (
 
 	"github.com/lucas-clemente/quic-go"
 	"github.com/ooni/probe-engine/internal/tlsx"
+	"github.com/ooni/probe-engine/netx/connid"
+	"github.com/ooni/probe-engine/netx/dialid"
 	"github.com/ooni/probe-engine/netx/trace"
+	"github.com/ooni/probe-engine/netx/transactionid"
 )
 
 // HandshakeSaver saves events occurring during the handshake
@@ -19,22 +22,27 @@ type HandshakeSaver struct {
 // DialContext implements ContextDialer.DialContext
 func (h HandshakeSaver) DialContext(ctx context.Context, network string,
 	host string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlySession, error) {
+	dialID := dialid.ContextDialID(ctx)
+	txID := transactionid.ContextTransactionID(ctx)
 	start := time.Now()
 	// TODO(bassosimone): in the future we probably want to also save
 	// information about what versions we're willing to accept.
 	h.Saver.Write(trace.Event{
 		Address:       host,
+		DialID:        dialID,
 		Name:          "quic_handshake_start",
 		NoTLSVerify:   tlsCfg.InsecureSkipVerify,
 		Proto:         network,
 		TLSNextProtos: tlsCfg.NextProtos,
 		TLSServerName: tlsCfg.ServerName,
 		Time:          start,
+		TransactionID: txID,
 	})
 	sess, err := h.Dialer.DialContext(ctx, network, host, tlsCfg, cfg)
 	stop := time.Now()
 	if err != nil {
 		h.Saver.Write(trace.Event{
+			DialID:        dialID,
 			Duration:      stop.Sub(start),
 			Err:           err,
 			Name:          "quic_handshake_done",
@@ -42,11 +50,14 @@ func (h HandshakeSaver) DialContext(ctx context.Context, network string,
 			TLSNextProtos: tlsCfg.NextProtos,
 			TLSServerName: tlsCfg.ServerName,
 			Time:          stop,
+			TransactionID: txID,
 		})
 		return nil, err
 	}
 	state := ConnectionState(sess)
 	h.Saver.Write(trace.Event{
+		ConnID:             connid.Compute("udp", sess.LocalAddr().String()),
+		DialID:             dialID,
 		Duration:           stop.Sub(start),
 		Name:               "quic_handshake_done",
 		NoTLSVerify:        tlsCfg.InsecureSkipVerify,
@@ -57,6 +68,7 @@ func (h HandshakeSaver) DialContext(ctx context.Context, network string,
 		TLSServerName:      tlsCfg.ServerName,
 		TLSVersion:         tlsx.VersionString(state.Version),
 		Time:               stop,
+		TransactionID:      txID,
 	})
 	return sess, nil
 }
@@ -9,8 +9,11 @@ import (
 	"time"
 
 	"github.com/lucas-clemente/quic-go"
+	"github.com/ooni/probe-engine/netx/connid"
+	"github.com/ooni/probe-engine/netx/dialid"
 	"github.com/ooni/probe-engine/netx/errorx"
 	"github.com/ooni/probe-engine/netx/trace"
+	"github.com/ooni/probe-engine/netx/transactionid"
 )
 
 // SystemDialer is the basic dialer for QUIC
@@ -36,9 +39,18 @@ func (d SystemDialer) DialContext(ctx context.Context, network string,
 		return nil, errors.New("quicdialer: invalid IP representation")
 	}
 	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	if err != nil {
+		return nil, err
+	}
 	var pconn net.PacketConn = udpConn
 	if d.Saver != nil {
-		pconn = saverUDPConn{UDPConn: udpConn, saver: d.Saver}
+		pconn = saverUDPConn{
+			UDPConn:       udpConn,
+			connID:        connid.Compute("udp", udpConn.LocalAddr().String()),
+			dialID:        dialid.ContextDialID(ctx),
+			saver:         d.Saver,
+			transactionID: transactionid.ContextTransactionID(ctx),
+		}
 	}
 	udpAddr := &net.UDPAddr{IP: ip, Port: port, Zone: ""}
 	return quic.DialEarlyContext(ctx, pconn, udpAddr, host, tlsCfg, cfg)
@@ -47,7 +59,10 @@ func (d SystemDialer) DialContext(ctx context.Context, network string,
 
 type saverUDPConn struct {
 	*net.UDPConn
-	saver *trace.Saver
+	connID        int64
+	dialID        int64
+	saver         *trace.Saver
+	transactionID int64
 }
 
 func (c saverUDPConn) WriteTo(p []byte, addr net.Addr) (int, error) {
@@ -55,13 +70,16 @@ func (c saverUDPConn) WriteTo(p []byte, addr net.Addr) (int, error) {
 	count, err := c.UDPConn.WriteTo(p, addr)
 	stop := time.Now()
 	c.saver.Write(trace.Event{
-		Address:  addr.String(),
-		Data:     p[:count],
-		Duration: stop.Sub(start),
-		Err:      err,
-		NumBytes: count,
-		Name:     errorx.WriteToOperation,
-		Time:     stop,
+		Address:       addr.String(),
+		ConnID:        c.connID,
+		Data:          p[:count],
+		DialID:        c.dialID,
+		Duration:      stop.Sub(start),
+		Err:           err,
+		NumBytes:      count,
+		Name:          errorx.WriteToOperation,
+		Time:          stop,
+		TransactionID: c.transactionID,
 	})
 	return count, err
 }
@@ -75,13 +93,16 @@ func (c saverUDPConn) ReadMsgUDP(b, oob []byte) (int, int, int, *net.UDPAddr, er
 		data = b[:n]
 	}
 	c.saver.Write(trace.Event{
-		Address:  addr.String(),
-		Data:     data,
-		Duration: stop.Sub(start),
-		Err:      err,
-		NumBytes: n,
-		Name:     errorx.ReadFromOperation,
-		Time:     stop,
+		Address:       addr.String(),
+		ConnID:        c.connID,
+		Data:          data,
+		DialID:        c.dialID,
+		Duration:      stop.Sub(start),
+		Err:           err,
+		NumBytes:      n,
+		Name:          errorx.ReadFromOperation,
+		Time:          stop,
+		TransactionID: c.transactionID,
 	})
 	return n, oobn, flags, addr, err
 }
@@ -0,0 +1,26 @@
+package quicdialer
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+)
+
+// TimeoutDialer is a dialer that enforces a timeout on the QUIC handshake
+type TimeoutDialer struct {
+	Dialer           ContextDialer
+	HandshakeTimeout time.Duration
+}
+
+// DialContext implements ContextDialer.DialContext
+func (d TimeoutDialer) DialContext(
+	ctx context.Context, network string, host string,
+	tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlySession, error) {
+	// Implementation note: quic-go only uses the context while
+	// handshaking, hence it's safe to cancel it when we return.
+	ctx, cancel := context.WithTimeout(ctx, d.HandshakeTimeout)
+	defer cancel()
+	return d.Dialer.DialContext(ctx, network, host, tlsCfg, cfg)
+}
@@ -0,0 +1,37 @@
+package quicdialer_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/ooni/probe-engine/netx/quicdialer"
+)
+
+type SlowDialer struct{}
+
+func (SlowDialer) DialContext(ctx context.Context, network, host string,
+	tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlySession, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(30 * time.Second):
+		return nil, io.EOF
+	}
+}
+
+func TestTimeoutDialer(t *testing.T) {
+	d := quicdialer.TimeoutDialer{Dialer: SlowDialer{}, HandshakeTimeout: time.Second}
+	sess, err := d.DialContext(
+		context.Background(), "udp", "216.58.212.164:443", &tls.Config{}, &quic.Config{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("not the error we expected")
+	}
+	if sess != nil {
+		t.Fatal("expected nil sess here")
+	}
+}
@@ -3,9 +3,9 @@ package resolver
 import (
 	"context"
 
-	"github.com/ooni/probe-engine/legacy/netx/dialid"
-	"github.com/ooni/probe-engine/legacy/netx/transactionid"
+	"github.com/ooni/probe-engine/netx/dialid"
 	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/transactionid"
 )
 
 // ErrorWrapperResolver is a Resolver that knows about wrapping errors.
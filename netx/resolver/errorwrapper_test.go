@@ -5,10 +5,10 @@ import (
 	"errors"
 	"testing"
 
-	"github.com/ooni/probe-engine/legacy/netx/dialid"
-	"github.com/ooni/probe-engine/legacy/netx/transactionid"
+	"github.com/ooni/probe-engine/netx/dialid"
 	"github.com/ooni/probe-engine/netx/errorx"
 	"github.com/ooni/probe-engine/netx/resolver"
+	"github.com/ooni/probe-engine/netx/transactionid"
 )
 
 func TestErrorWrapperSuccess(t *testing.T) {
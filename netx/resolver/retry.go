@@ -0,0 +1,25 @@
+package resolver
+
+import (
+	"context"
+
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/retry"
+)
+
+// RetryResolver is a Resolver that retries lookups that time out.
+type RetryResolver struct {
+	Resolver
+	Policy retry.Policy
+}
+
+// LookupHost implements Resolver.LookupHost
+func (r RetryResolver) LookupHost(ctx context.Context, hostname string) (addrs []string, err error) {
+	err = r.Policy.Do(ctx, errorx.ResolveOperation, func() (err error) {
+		addrs, err = r.Resolver.LookupHost(ctx, hostname)
+		return
+	})
+	return
+}
+
+var _ Resolver = RetryResolver{}
@@ -0,0 +1,62 @@
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ooni/probe-engine/atomicx"
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/resolver"
+	"github.com/ooni/probe-engine/netx/retry"
+)
+
+func TestRetryResolverTimeout(t *testing.T) {
+	child := resolver.FakeResolver{
+		NumFailures: atomicx.NewInt64(),
+		Err: &errorx.ErrWrapper{
+			Failure:   errorx.FailureGenericTimeoutError,
+			Operation: errorx.ResolveOperation,
+		},
+	}
+	r := resolver.RetryResolver{Resolver: child, Policy: retry.Policy{MaxRetries: 2}}
+	addrs, err := r.LookupHost(context.Background(), "www.google.com")
+	if !errors.Is(err, child.Err) {
+		t.Fatal("not the error we expected")
+	}
+	if addrs != nil {
+		t.Fatal("expected nil addrs here")
+	}
+	if child.NumFailures.Load() != 3 {
+		t.Fatal("unexpected number of attempts")
+	}
+}
+
+func TestRetryResolverNXDOMAIN(t *testing.T) {
+	child := resolver.NewFakeResolverThatFails()
+	r := resolver.RetryResolver{Resolver: child, Policy: retry.Policy{MaxRetries: 2}}
+	addrs, err := r.LookupHost(context.Background(), "www.google.com")
+	if err == nil {
+		t.Fatal("expected an error here")
+	}
+	if addrs != nil {
+		t.Fatal("expected nil addrs here")
+	}
+	if child.NumFailures.Load() != 1 {
+		t.Fatal("we should not have retried")
+	}
+}
+
+func TestRetryResolverSuccess(t *testing.T) {
+	r := resolver.RetryResolver{
+		Resolver: resolver.NewFakeResolverWithResult([]string{"8.8.8.8"}),
+		Policy:   retry.Policy{MaxRetries: 2},
+	}
+	addrs, err := r.LookupHost(context.Background(), "dns.google")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || addrs[0] != "8.8.8.8" {
+		t.Fatal("not the result we expected")
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"time"
 
+	"github.com/ooni/probe-engine/netx/dialid"
 	"github.com/ooni/probe-engine/netx/trace"
+	"github.com/ooni/probe-engine/netx/transactionid"
 )
 
 // SaverResolver is a resolver that saves events
@@ -15,25 +17,31 @@ type SaverResolver struct {
 
 // LookupHost implements Resolver.LookupHost
 func (r SaverResolver) LookupHost(ctx context.Context, hostname string) ([]string, error) {
+	dialID := dialid.ContextDialID(ctx)
+	txID := transactionid.ContextTransactionID(ctx)
 	start := time.Now()
 	r.Saver.Write(trace.Event{
-		Address:  r.Resolver.Address(),
-		Hostname: hostname,
-		Name:     "resolve_start",
-		Proto:    r.Resolver.Network(),
-		Time:     start,
+		Address:       r.Resolver.Address(),
+		DialID:        dialID,
+		Hostname:      hostname,
+		Name:          "resolve_start",
+		Proto:         r.Resolver.Network(),
+		Time:          start,
+		TransactionID: txID,
 	})
 	addrs, err := r.Resolver.LookupHost(ctx, hostname)
 	stop := time.Now()
 	r.Saver.Write(trace.Event{
-		Addresses: addrs,
-		Address:   r.Resolver.Address(),
-		Duration:  stop.Sub(start),
-		Err:       err,
-		Hostname:  hostname,
-		Name:      "resolve_done",
-		Proto:     r.Resolver.Network(),
-		Time:      stop,
+		Addresses:     addrs,
+		Address:       r.Resolver.Address(),
+		DialID:        dialID,
+		Duration:      stop.Sub(start),
+		Err:           err,
+		Hostname:      hostname,
+		Name:          "resolve_done",
+		Proto:         r.Resolver.Network(),
+		Time:          stop,
+		TransactionID: txID,
 	})
 	return addrs, err
 }
@@ -46,25 +54,31 @@ type SaverDNSTransport struct {
 
 // RoundTrip implements RoundTripper.RoundTrip
 func (txp SaverDNSTransport) RoundTrip(ctx context.Context, query []byte) ([]byte, error) {
+	dialID := dialid.ContextDialID(ctx)
+	txID := transactionid.ContextTransactionID(ctx)
 	start := time.Now()
 	txp.Saver.Write(trace.Event{
-		Address:  txp.Address(),
-		DNSQuery: query,
-		Name:     "dns_round_trip_start",
-		Proto:    txp.Network(),
-		Time:     start,
+		Address:       txp.Address(),
+		DNSQuery:      query,
+		DialID:        dialID,
+		Name:          "dns_round_trip_start",
+		Proto:         txp.Network(),
+		Time:          start,
+		TransactionID: txID,
 	})
 	reply, err := txp.RoundTripper.RoundTrip(ctx, query)
 	stop := time.Now()
 	txp.Saver.Write(trace.Event{
-		Address:  txp.Address(),
-		DNSQuery: query,
-		DNSReply: reply,
-		Duration: stop.Sub(start),
-		Err:      err,
-		Name:     "dns_round_trip_done",
-		Proto:    txp.Network(),
-		Time:     stop,
+		Address:       txp.Address(),
+		DNSQuery:      query,
+		DNSReply:      reply,
+		DialID:        dialID,
+		Duration:      stop.Sub(start),
+		Err:           err,
+		Name:          "dns_round_trip_done",
+		Proto:         txp.Network(),
+		Time:          stop,
+		TransactionID: txID,
 	})
 	return reply, err
 }
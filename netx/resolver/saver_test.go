@@ -8,8 +8,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ooni/probe-engine/netx/dialid"
 	"github.com/ooni/probe-engine/netx/resolver"
 	"github.com/ooni/probe-engine/netx/trace"
+	"github.com/ooni/probe-engine/netx/transactionid"
 )
 
 func TestSaverResolverFailure(t *testing.T) {
@@ -110,6 +112,33 @@ func TestSaverResolverSuccess(t *testing.T) {
 	}
 }
 
+func TestSaverResolverSavesIDs(t *testing.T) {
+	saver := &trace.Saver{}
+	reso := resolver.SaverResolver{
+		Resolver: resolver.FakeResolver{
+			Result: []string{"8.8.8.8"},
+		},
+		Saver: saver,
+	}
+	ctx := dialid.WithDialID(context.Background())
+	ctx = transactionid.WithTransactionID(ctx)
+	if _, err := reso.LookupHost(ctx, "www.google.com"); err != nil {
+		t.Fatal(err)
+	}
+	ev := saver.Read()
+	if len(ev) != 2 {
+		t.Fatal("expected number of events")
+	}
+	for _, e := range ev {
+		if e.DialID != dialid.ContextDialID(ctx) {
+			t.Fatal("unexpected DialID")
+		}
+		if e.TransactionID != transactionid.ContextTransactionID(ctx) {
+			t.Fatal("unexpected TransactionID")
+		}
+	}
+}
+
 func TestSaverDNSTransportFailure(t *testing.T) {
 	expected := errors.New("no such host")
 	saver := &trace.Saver{}
@@ -0,0 +1,21 @@
+package resolver
+
+import (
+	"context"
+	"time"
+)
+
+// TimeoutResolver is a Resolver that enforces a timeout
+type TimeoutResolver struct {
+	Resolver
+	Timeout time.Duration
+}
+
+// LookupHost implements Resolver.LookupHost
+func (r TimeoutResolver) LookupHost(ctx context.Context, hostname string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+	return r.Resolver.LookupHost(ctx, hostname)
+}
+
+var _ Resolver = TimeoutResolver{}
@@ -0,0 +1,34 @@
+package resolver_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-engine/netx/resolver"
+)
+
+type SlowResolver struct {
+	resolver.FakeResolver
+}
+
+func (SlowResolver) LookupHost(ctx context.Context, hostname string) ([]string, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(30 * time.Second):
+		return nil, errors.New("mocked error")
+	}
+}
+
+func TestTimeoutResolver(t *testing.T) {
+	r := resolver.TimeoutResolver{Resolver: SlowResolver{}, Timeout: time.Second}
+	addrs, err := r.LookupHost(context.Background(), "www.google.com")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("not the error we expected")
+	}
+	if addrs != nil {
+		t.Fatal("expected nil addrs here")
+	}
+}
@@ -0,0 +1,72 @@
+// Package retry contains the policy for retrying netx operations.
+//
+// We only retry operations that fail with a timeout. This is meant to
+// help with high-latency networks (e.g., satellite and mobile), where
+// a timeout does not necessarily mean that the operation is blocked,
+// while retrying does not help with other failures.
+package retry
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ooni/probe-engine/netx/errorx"
+)
+
+// Policy is the policy for retrying an operation. The zero
+// value means that we do not retry the operation.
+type Policy struct {
+	// Backoff is the time we wait before the first retry. We
+	// double it before every subsequent retry. When it is zero,
+	// we retry immediately.
+	Backoff time.Duration
+
+	// MaxRetries is the maximum number of retries.
+	MaxRetries int
+}
+
+// maxBackoffShift prevents the backoff from overflowing.
+const maxBackoffShift = 16
+
+// Do calls fn and then calls it again, according to the policy,
+// as long as fn fails because the given operation timed out. We stop
+// retrying when the context is done. We return the error returned
+// by the last call to fn. Note that every call to fn is a distinct
+// operation, which savers will record as such.
+func (p Policy) Do(ctx context.Context, operation string, fn func() error) error {
+	err := fn()
+	for i := 0; i < p.MaxRetries && Retryable(err, operation); i++ {
+		if !p.sleep(ctx, i) {
+			break
+		}
+		err = fn()
+	}
+	return err
+}
+
+// sleep waits before the i-th retry. It returns false if the
+// context is done, meaning that we should stop retrying.
+func (p Policy) sleep(ctx context.Context, i int) bool {
+	if p.Backoff > 0 {
+		if i > maxBackoffShift {
+			i = maxBackoffShift
+		}
+		timer := time.NewTimer(p.Backoff << i)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+	}
+	return ctx.Err() == nil
+}
+
+// Retryable returns whether err indicates that the given
+// operation failed with errorx.FailureGenericTimeoutError.
+func Retryable(err error, operation string) bool {
+	var errWrapper *errorx.ErrWrapper
+	return errors.As(err, &errWrapper) &&
+		errWrapper.Failure == errorx.FailureGenericTimeoutError &&
+		errWrapper.Operation == operation
+}
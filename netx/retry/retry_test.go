@@ -0,0 +1,118 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ooni/probe-engine/netx/errorx"
+	"github.com/ooni/probe-engine/netx/retry"
+)
+
+var errTimeout = &errorx.ErrWrapper{
+	Failure:   errorx.FailureGenericTimeoutError,
+	Operation: errorx.ConnectOperation,
+}
+
+func TestRetryable(t *testing.T) {
+	if !retry.Retryable(errTimeout, errorx.ConnectOperation) {
+		t.Fatal("a timeout should be retryable")
+	}
+	if retry.Retryable(errTimeout, errorx.TLSHandshakeOperation) {
+		t.Fatal("a timeout in another operation should not be retryable")
+	}
+	refused := &errorx.ErrWrapper{
+		Failure:   errorx.FailureConnectionRefused,
+		Operation: errorx.ConnectOperation,
+	}
+	if retry.Retryable(refused, errorx.ConnectOperation) {
+		t.Fatal("a non-timeout failure should not be retryable")
+	}
+	if retry.Retryable(errors.New("i/o timeout"), errorx.ConnectOperation) {
+		t.Fatal("a non-wrapped error should not be retryable")
+	}
+	if retry.Retryable(nil, errorx.ConnectOperation) {
+		t.Fatal("success should not be retryable")
+	}
+}
+
+func TestDoZeroPolicy(t *testing.T) {
+	var count int
+	err := retry.Policy{}.Do(context.Background(), errorx.ConnectOperation, func() error {
+		count++
+		return errTimeout
+	})
+	if !errors.Is(err, errTimeout) {
+		t.Fatal("not the error we expected")
+	}
+	if count != 1 {
+		t.Fatal("we should not have retried")
+	}
+}
+
+func TestDoRetriesUntilSuccess(t *testing.T) {
+	var count int
+	policy := retry.Policy{MaxRetries: 5}
+	err := policy.Do(context.Background(), errorx.ConnectOperation, func() error {
+		count++
+		if count < 3 {
+			return errTimeout
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatal("unexpected number of attempts")
+	}
+}
+
+func TestDoExhaustsRetries(t *testing.T) {
+	var count int
+	policy := retry.Policy{Backoff: time.Millisecond, MaxRetries: 3}
+	err := policy.Do(context.Background(), errorx.ConnectOperation, func() error {
+		count++
+		return errTimeout
+	})
+	if !errors.Is(err, errTimeout) {
+		t.Fatal("not the error we expected")
+	}
+	if count != 4 {
+		t.Fatal("unexpected number of attempts")
+	}
+}
+
+func TestDoDoesNotRetryOtherFailures(t *testing.T) {
+	var count int
+	expected := errors.New("mocked error")
+	policy := retry.Policy{MaxRetries: 3}
+	err := policy.Do(context.Background(), errorx.ConnectOperation, func() error {
+		count++
+		return expected
+	})
+	if !errors.Is(err, expected) {
+		t.Fatal("not the error we expected")
+	}
+	if count != 1 {
+		t.Fatal("we should not have retried")
+	}
+}
+
+func TestDoStopsWhenContextIsDone(t *testing.T) {
+	var count int
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := retry.Policy{Backoff: time.Hour, MaxRetries: 3}
+	err := policy.Do(ctx, errorx.ConnectOperation, func() error {
+		count++
+		cancel()
+		return errTimeout
+	})
+	if !errors.Is(err, errTimeout) {
+		t.Fatal("not the error we expected")
+	}
+	if count != 1 {
+		t.Fatal("we should not have retried")
+	}
+}
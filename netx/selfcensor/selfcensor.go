@@ -147,7 +147,9 @@ func (r SystemResolver) Address() string {
 
 // SystemDialer is a self-censoring system dialer. This dialer does
 // not censor anything unless you call selfcensor.Enable().
-type SystemDialer struct{}
+type SystemDialer struct {
+	ConnectTimeout time.Duration // default: 15 seconds
+}
 
 // defaultNetDialer is the dialer we use by default.
 var defaultNetDialer = &net.Dialer{
@@ -155,6 +157,17 @@ var defaultNetDialer = &net.Dialer{
 	KeepAlive: 15 * time.Second,
 }
 
+// netDialer returns the net.Dialer honouring d.ConnectTimeout.
+func (d SystemDialer) netDialer() *net.Dialer {
+	if d.ConnectTimeout == 0 {
+		return defaultNetDialer
+	}
+	return &net.Dialer{
+		Timeout:   d.ConnectTimeout,
+		KeepAlive: defaultNetDialer.KeepAlive,
+	}
+}
+
 // DefaultDialer is the dialer you should use in code that wants
 // to take advantage of selfcensor capabilities.
 var DefaultDialer = SystemDialer{}
@@ -181,7 +194,7 @@ func (d SystemDialer) DialContext(
 			}
 		}
 		if spec.BlockedFingerprints != nil {
-			conn, err := defaultNetDialer.DialContext(ctx, network, address)
+			conn, err := d.netDialer().DialContext(ctx, network, address)
 			if err != nil {
 				return nil, err
 			}
@@ -190,7 +203,7 @@ func (d SystemDialer) DialContext(
 		}
 		// FALLTHROUGH
 	}
-	return defaultNetDialer.DialContext(ctx, network, address)
+	return d.netDialer().DialContext(ctx, network, address)
 }
 
 type connWrapper struct {
@@ -0,0 +1,19 @@
+package selfcensor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSystemDialerNetDialer(t *testing.T) {
+	if (SystemDialer{}).netDialer() != defaultNetDialer {
+		t.Fatal("expected the default dialer here")
+	}
+	d := SystemDialer{ConnectTimeout: time.Minute}.netDialer()
+	if d.Timeout != time.Minute {
+		t.Fatal("unexpected connect timeout")
+	}
+	if d.KeepAlive != defaultNetDialer.KeepAlive {
+		t.Fatal("unexpected keep alive")
+	}
+}
@@ -8,14 +8,19 @@ import (
 	"time"
 )
 
-// Event is one of the events within a trace
+// Event is one of the events within a trace. The ConnID, DialID and
+// TransactionID fields link together events belonging to the same
+// connection, dial and HTTP transaction. Zero means unknown.
 type Event struct {
 	Addresses          []string            `json:",omitempty"`
 	Address            string              `json:",omitempty"`
+	ConnID             int64               `json:",omitempty"`
 	DNSQuery           []byte              `json:",omitempty"`
 	DNSReply           []byte              `json:",omitempty"`
 	DataIsTruncated    bool                `json:",omitempty"`
 	Data               []byte              `json:",omitempty"`
+	DataSHA256         string              `json:",omitempty"`
+	DialID             int64               `json:",omitempty"`
 	Duration           time.Duration       `json:",omitempty"`
 	Err                error               `json:",omitempty"`
 	HTTPHeaders        http.Header         `json:",omitempty"`
@@ -34,6 +39,7 @@ type Event struct {
 	TLSPeerCerts       []*x509.Certificate `json:",omitempty"`
 	TLSVersion         string              `json:",omitempty"`
 	Time               time.Time           `json:",omitempty"`
+	TransactionID      int64               `json:",omitempty"`
 	Transport          string              `json:",omitempty"`
 }
 
@@ -1,11 +1,26 @@
 package trace
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
-// The Saver saves a trace
+// The Saver saves a trace. The zero value keeps every event in
+// memory until you call Read. Set MaxEvents to bound the memory
+// used by the Saver and use Subscribe to stream events.
 type Saver struct {
-	ops []Event
-	mu  sync.Mutex
+	// dropped is first to be 64 bit aligned on 32 bit platforms.
+	dropped int64 // atomic
+
+	// MaxEvents is the maximum number of events we keep in memory
+	// until the next Read. When we have already saved MaxEvents events,
+	// we drop the oldest event and increment the Dropped counter. A
+	// zero or negative value means that there is no limit.
+	MaxEvents int
+
+	mu          sync.Mutex
+	ops         []Event
+	subscribers []*subscription
 }
 
 // Read reads and returns events inside the trace. It advances
@@ -19,9 +34,55 @@ func (s *Saver) Read() []Event {
 }
 
 // Write adds the given event to the trace. A subsequent call
-// to Read will read this event.
+// to Read will read this event. We also dispatch the event to
+// all the subscribers, honouring their policies.
 func (s *Saver) Write(ev Event) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	if s.MaxEvents > 0 && len(s.ops) >= s.MaxEvents {
+		// When append reallocates, it only copies the events we
+		// did not drop, so memory use stays proportional to MaxEvents.
+		s.ops = s.ops[len(s.ops)-s.MaxEvents+1:]
+		atomic.AddInt64(&s.dropped, 1)
+	}
 	s.ops = append(s.ops, ev)
+	subscribers := s.subscribers
+	s.mu.Unlock()
+	for _, sub := range subscribers {
+		if !sub.deliver(ev) {
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+}
+
+// Dropped returns the number of events we dropped, either because
+// we exceeded MaxEvents or because of a subscriber's DropPolicy.
+func (s *Saver) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Subscribe registers a subscriber that will receive all the events
+// subsequently written into the Saver and matching the filter in the
+// options. The returned function unsubscribes and waits for the
+// subscriber to process all the events that are still queued.
+func (s *Saver) Subscribe(subscriber Subscriber, options SubscribeOptions) (unsubscribe func()) {
+	sub := newSubscription(subscriber, options)
+	s.mu.Lock()
+	// Copy on write, so that Write can dispatch without holding the lock.
+	s.subscribers = append(append([]*subscription{}, s.subscribers...), sub)
+	s.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			var subscribers []*subscription
+			for _, entry := range s.subscribers {
+				if entry != sub {
+					subscribers = append(subscribers, entry)
+				}
+			}
+			s.subscribers = subscribers
+			s.mu.Unlock()
+			sub.close()
+		})
+	}
 }
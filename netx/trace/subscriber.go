@@ -0,0 +1,252 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/ooni/probe-engine/netx/errorx"
+)
+
+// Subscriber receives the events written into a Saver.
+type Subscriber interface {
+	OnEvent(ev Event)
+}
+
+// SubscriberFunc allows to use a function as a Subscriber.
+type SubscriberFunc func(ev Event)
+
+// OnEvent implements Subscriber.OnEvent.
+func (f SubscriberFunc) OnEvent(ev Event) {
+	f(ev)
+}
+
+// Policy is what we do when a subscriber's queue is full.
+type Policy int
+
+const (
+	// BlockPolicy blocks the writer until there is room in the queue.
+	BlockPolicy = Policy(iota)
+
+	// DropPolicy drops the event and increments the Dropped counter.
+	DropPolicy
+)
+
+// SubscribeOptions contains options for Saver.Subscribe.
+type SubscribeOptions struct {
+	// Filter is an optional function returning whether the
+	// subscriber is interested into the given event.
+	Filter func(ev Event) bool
+
+	// Policy is what we do when the queue is full.
+	Policy Policy
+
+	// QueueSize is the size of the queue. When it is zero, we
+	// call the subscriber synchronously from Saver.Write. Otherwise,
+	// we call the subscriber from a background goroutine.
+	QueueSize int
+}
+
+// subscription is a subscriber along with its options.
+type subscription struct {
+	closed     bool
+	mu         sync.RWMutex
+	options    SubscribeOptions
+	queue      chan Event
+	subscriber Subscriber
+	wg         sync.WaitGroup
+}
+
+func newSubscription(subscriber Subscriber, options SubscribeOptions) *subscription {
+	sub := &subscription{options: options, subscriber: subscriber}
+	if options.QueueSize > 0 {
+		sub.queue = make(chan Event, options.QueueSize)
+		sub.wg.Add(1)
+		go func() {
+			defer sub.wg.Done()
+			for ev := range sub.queue {
+				sub.subscriber.OnEvent(ev)
+			}
+		}()
+	}
+	return sub
+}
+
+// deliver delivers the event and returns false if the event
+// has been dropped because the queue is full.
+func (sub *subscription) deliver(ev Event) bool {
+	if sub.options.Filter != nil && !sub.options.Filter(ev) {
+		return true
+	}
+	sub.mu.RLock()
+	defer sub.mu.RUnlock()
+	if sub.closed {
+		return true // raced with unsubscribe
+	}
+	if sub.queue == nil {
+		sub.subscriber.OnEvent(ev)
+		return true
+	}
+	if sub.options.Policy == DropPolicy {
+		select {
+		case sub.queue <- ev:
+			return true
+		default:
+			return false
+		}
+	}
+	sub.queue <- ev
+	return true
+}
+
+// close closes the queue and waits for the background goroutine.
+func (sub *subscription) close() {
+	sub.mu.Lock()
+	sub.closed = true
+	if sub.queue != nil {
+		close(sub.queue)
+	}
+	sub.mu.Unlock()
+	sub.wg.Wait()
+}
+
+// ByAddress returns a filter selecting the events concerning the
+// given endpoints, e.g., "8.8.8.8:443". Use it to follow only the
+// connections towards specific endpoints.
+func ByAddress(addresses ...string) func(ev Event) bool {
+	set := make(map[string]bool)
+	for _, address := range addresses {
+		set[address] = true
+	}
+	return func(ev Event) bool {
+		return set[ev.Address]
+	}
+}
+
+// WithoutData returns a filter excluding the I/O events and the
+// body snapshots, which are frequent and carry data snapshots.
+func WithoutData() func(ev Event) bool {
+	return func(ev Event) bool {
+		switch ev.Name {
+		case errorx.ReadOperation, errorx.WriteOperation,
+			errorx.ReadFromOperation, errorx.WriteToOperation,
+			"http_request_body_snapshot", "http_response_body_snapshot":
+			return false
+		}
+		return true
+	}
+}
+
+// RingBuffer is a Subscriber keeping the most recent events.
+type RingBuffer struct {
+	dropped int64
+	events  []Event
+	mu      sync.Mutex
+	next    int
+	size    int
+}
+
+// NewRingBuffer creates a RingBuffer keeping at most size events.
+func NewRingBuffer(size int) *RingBuffer {
+	if size <= 0 {
+		size = 1
+	}
+	return &RingBuffer{size: size}
+}
+
+// OnEvent implements Subscriber.OnEvent.
+func (rb *RingBuffer) OnEvent(ev Event) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if len(rb.events) < rb.size {
+		rb.events = append(rb.events, ev)
+		return
+	}
+	rb.events[rb.next] = ev
+	rb.next = (rb.next + 1) % rb.size
+	rb.dropped++
+}
+
+// Read returns the events from the oldest to the most recent
+// one and clears the buffer.
+func (rb *RingBuffer) Read() []Event {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	out := append(append([]Event{}, rb.events[rb.next:]...), rb.events[:rb.next]...)
+	rb.events, rb.next = nil, 0
+	return out
+}
+
+// Dropped returns the number of events we overwrote.
+func (rb *RingBuffer) Dropped() int64 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.dropped
+}
+
+// Logger is the logger used by LogSubscriber.
+type Logger interface {
+	Debugf(format string, v ...interface{})
+}
+
+// LogSubscriber is a Subscriber logging a summary of each event.
+type LogSubscriber struct {
+	Logger Logger
+}
+
+// OnEvent implements Subscriber.OnEvent.
+func (ls LogSubscriber) OnEvent(ev Event) {
+	ls.Logger.Debugf("trace: %s address=%s hostname=%s bytes=%d duration=%s err=%v",
+		ev.Name, ev.Address, ev.Hostname, ev.NumBytes, ev.Duration, ev.Err)
+}
+
+// jsonlEvent is the JSON representation of an Event. We override
+// the fields that do not serialize well to JSON.
+type jsonlEvent struct {
+	Event
+	Err          string   `json:",omitempty"`
+	TLSPeerCerts [][]byte `json:",omitempty"`
+}
+
+// JSONLWriter is a Subscriber writing each event as a JSON
+// line into the underlying writer, e.g., a file.
+type JSONLWriter struct {
+	err error
+	mu  sync.Mutex
+	w   io.Writer
+}
+
+// NewJSONLWriter creates a new JSONLWriter.
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	return &JSONLWriter{w: w}
+}
+
+// OnEvent implements Subscriber.OnEvent.
+func (jw *JSONLWriter) OnEvent(ev Event) {
+	jev := jsonlEvent{Event: ev}
+	if ev.Err != nil {
+		jev.Err = ev.Err.Error()
+	}
+	for _, cert := range ev.TLSPeerCerts {
+		jev.TLSPeerCerts = append(jev.TLSPeerCerts, cert.Raw)
+	}
+	data, err := json.Marshal(jev)
+	jw.mu.Lock()
+	defer jw.mu.Unlock()
+	if jw.err != nil {
+		return
+	}
+	if err != nil {
+		jw.err = err
+		return
+	}
+	_, jw.err = jw.w.Write(append(data, '\n'))
+}
+
+// Err returns the first error that occurred, if any. We stop
+// writing events after the first error.
+func (jw *JSONLWriter) Err() error {
+	jw.mu.Lock()
+	defer jw.mu.Unlock()
+	return jw.err
+}
@@ -1,8 +1,13 @@
 package trace_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/ooni/probe-engine/netx/trace"
 )
@@ -24,3 +29,148 @@ func TestGood(t *testing.T) {
 		t.Fatal("unexpected number of events read")
 	}
 }
+
+func TestMaxEvents(t *testing.T) {
+	saver := &trace.Saver{MaxEvents: 3}
+	for idx := 0; idx < 10; idx++ {
+		saver.Write(trace.Event{NumBytes: idx})
+	}
+	ev := saver.Read()
+	if len(ev) != 3 || ev[0].NumBytes != 7 || ev[2].NumBytes != 9 {
+		t.Fatalf("unexpected events: %+v", ev)
+	}
+	if saver.Dropped() != 7 {
+		t.Fatal("unexpected number of dropped events")
+	}
+}
+
+func TestSubscribeSynchronous(t *testing.T) {
+	saver := new(trace.Saver)
+	var names []string
+	unsubscribe := saver.Subscribe(trace.SubscriberFunc(func(ev trace.Event) {
+		names = append(names, ev.Name)
+	}), trace.SubscribeOptions{Filter: trace.WithoutData()})
+	saver.Write(trace.Event{Name: "connect"})
+	saver.Write(trace.Event{Name: "read"})
+	saver.Write(trace.Event{Name: "http_response_body_snapshot"})
+	unsubscribe()
+	unsubscribe() // idempotent
+	saver.Write(trace.Event{Name: "close"})
+	if len(names) != 1 || names[0] != "connect" {
+		t.Fatalf("unexpected events: %+v", names)
+	}
+	if len(saver.Read()) != 4 {
+		t.Fatal("the saver should still keep all the events")
+	}
+}
+
+func TestSubscribeQueued(t *testing.T) {
+	saver := new(trace.Saver)
+	var count int
+	unsubscribe := saver.Subscribe(trace.SubscriberFunc(func(ev trace.Event) {
+		count++
+	}), trace.SubscribeOptions{
+		Filter:    trace.ByAddress("8.8.8.8:443"),
+		QueueSize: 4,
+	})
+	for idx := 0; idx < 100; idx++ {
+		saver.Write(trace.Event{Address: "8.8.8.8:443"})
+		saver.Write(trace.Event{Address: "8.8.4.4:443"})
+	}
+	unsubscribe() // waits for the queue to be drained
+	if count != 100 {
+		t.Fatal("unexpected number of events", count)
+	}
+	if saver.Dropped() != 0 {
+		t.Fatal("we should not drop with the blocking policy")
+	}
+}
+
+func TestSubscribeDropPolicy(t *testing.T) {
+	saver := new(trace.Saver)
+	block := make(chan interface{})
+	unsubscribe := saver.Subscribe(trace.SubscriberFunc(func(ev trace.Event) {
+		<-block
+	}), trace.SubscribeOptions{Policy: trace.DropPolicy, QueueSize: 1})
+	for idx := 0; idx < 10; idx++ {
+		saver.Write(trace.Event{})
+	}
+	close(block)
+	unsubscribe()
+	// The queue holds one event and the subscriber may be processing
+	// another one, hence we should have dropped at least 8 events.
+	if dropped := saver.Dropped(); dropped < 8 || dropped > 9 {
+		t.Fatal("unexpected number of dropped events", dropped)
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	rb := trace.NewRingBuffer(3)
+	for idx := 0; idx < 5; idx++ {
+		rb.OnEvent(trace.Event{NumBytes: idx})
+	}
+	ev := rb.Read()
+	if len(ev) != 3 || ev[0].NumBytes != 2 || ev[1].NumBytes != 3 || ev[2].NumBytes != 4 {
+		t.Fatalf("unexpected events: %+v", ev)
+	}
+	if rb.Dropped() != 2 {
+		t.Fatal("unexpected number of dropped events")
+	}
+	if len(rb.Read()) != 0 {
+		t.Fatal("Read should clear the buffer")
+	}
+}
+
+type logger struct {
+	lines []string
+}
+
+func (l *logger) Debugf(format string, v ...interface{}) {
+	l.lines = append(l.lines, format)
+}
+
+func TestLogSubscriber(t *testing.T) {
+	l := new(logger)
+	trace.LogSubscriber{Logger: l}.OnEvent(trace.Event{Name: "connect"})
+	if len(l.lines) != 1 {
+		t.Fatal("we did not log")
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	jw := trace.NewJSONLWriter(&buf)
+	jw.OnEvent(trace.Event{Name: "connect", Err: errors.New("mocked error")})
+	jw.OnEvent(trace.Event{Name: "close", Duration: time.Second})
+	if jw.Err() != nil {
+		t.Fatal(jw.Err())
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatal("unexpected number of lines")
+	}
+	var v struct {
+		Err  string
+		Name string
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Err != "mocked error" || v.Name != "connect" {
+		t.Fatalf("unexpected event: %+v", v)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("mocked error")
+}
+
+func TestJSONLWriterFailure(t *testing.T) {
+	jw := trace.NewJSONLWriter(failingWriter{})
+	jw.OnEvent(trace.Event{})
+	if jw.Err() == nil {
+		t.Fatal("expected an error here")
+	}
+}
@@ -0,0 +1,23 @@
+package oonimkall
+
+import (
+	"encoding/json"
+
+	engine "github.com/ooni/probe-engine"
+)
+
+// ExperimentOptionsSchema returns the JSON Schema of the options of the
+// experiment with the given name (e.g. `WebConnectivity`). Apps can use
+// this schema to render options forms and to reject invalid values before
+// starting a task. We return an error if there is no such experiment.
+func ExperimentOptionsSchema(name string) (string, error) {
+	schema, err := engine.ExperimentOptionsSchema(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
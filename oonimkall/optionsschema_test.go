@@ -0,0 +1,43 @@
+package oonimkall_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ooni/probe-engine/oonimkall"
+)
+
+func TestExperimentOptionsSchemaSuccess(t *testing.T) {
+	out, err := oonimkall.ExperimentOptionsSchema("Urlgetter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Properties map[string]struct {
+			Enum []string `json:"enum"`
+			Type string   `json:"type"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(out), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Properties["TLSVersion"].Type != "string" {
+		t.Fatal("unexpected TLSVersion type")
+	}
+	if len(schema.Properties["TLSVersion"].Enum) <= 0 {
+		t.Fatal("expected TLSVersion to be an enum")
+	}
+	if schema.Properties["NoTLSVerify"].Type != "boolean" {
+		t.Fatal("unexpected NoTLSVerify type")
+	}
+}
+
+func TestExperimentOptionsSchemaFailure(t *testing.T) {
+	out, err := oonimkall.ExperimentOptionsSchema("Antani")
+	if err == nil || err.Error() != "no such experiment: Antani" {
+		t.Fatalf("not the error we expected: %+v", err)
+	}
+	if out != "" {
+		t.Fatal("expected empty output")
+	}
+}
@@ -199,6 +199,15 @@ type GeolocateResults struct {
 	// IP is the IP address.
 	IP string
 
+	// IPConfidence is the fraction of the IP lookup backends
+	// that agree with the IP address.
+	IPConfidence float64
+
+	// IPLookups is a JSON array containing the result of
+	// each IP lookup backend. We use JSON because gomobile
+	// cannot export slices of structs.
+	IPLookups string
+
 	// Org is the commercial name of the ASN.
 	Org string
 }
@@ -219,11 +228,16 @@ func (sess *Session) Geolocate(ctx *Context) (*GeolocateResults, error) {
 	if err != nil {
 		return nil, err
 	}
+	// We assume that the following call cannot fail because it's a
+	// clearly serializable data structure.
+	lookups, _ := json.Marshal(info.ProbeIPLookups)
 	return &GeolocateResults{
-		ASN:     fmt.Sprintf("AS%d", info.ASN),
-		Country: info.CountryCode,
-		IP:      info.ProbeIP,
-		Org:     info.NetworkName,
+		ASN:          fmt.Sprintf("AS%d", info.ASN),
+		Country:      info.CountryCode,
+		IP:           info.ProbeIP,
+		IPConfidence: info.ProbeIPConfidence,
+		IPLookups:    string(lookups),
+		Org:          info.NetworkName,
 	}, nil
 }
 
@@ -141,6 +141,12 @@ func TestGeolocateGood(t *testing.T) {
 	if location.Org == "" {
 		t.Fatal("location.Org is empty")
 	}
+	if location.IPConfidence <= 0 {
+		t.Fatal("location.IPConfidence is not positive")
+	}
+	if location.IPLookups == "" || location.IPLookups == "null" {
+		t.Fatal("location.IPLookups is empty")
+	}
 }
 
 func ReduceErrorForSubmitter(err error) error {
@@ -0,0 +1,302 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// jsonSchema is the subset of JSON Schema used by events.schema.json.
+type jsonSchema struct {
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Const                interface{}            `json:"const"`
+	Definitions          map[string]*jsonSchema `json:"definitions"`
+	Items                *jsonSchema            `json:"items"`
+	OneOf                []*jsonSchema          `json:"oneOf"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Ref                  string                 `json:"$ref"`
+	Required             []string               `json:"required"`
+	Type                 interface{}            `json:"type"`
+}
+
+func loadJSONSchema(t *testing.T) *jsonSchema {
+	data, err := ioutil.ReadFile("eventschema/events.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func jsonTypeOf(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func (s *jsonSchema) allowsType(v interface{}) bool {
+	var types []string
+	switch t := s.Type.(type) {
+	case nil:
+		return true
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, e := range t {
+			types = append(types, e.(string))
+		}
+	}
+	actual := jsonTypeOf(v)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// validate validates v against s using root to resolve references.
+func (s *jsonSchema) validate(root *jsonSchema, v interface{}) error {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/definitions/")
+		def, found := root.Definitions[name]
+		if !found {
+			return fmt.Errorf("unknown reference: %s", s.Ref)
+		}
+		return def.validate(root, v)
+	}
+	if len(s.OneOf) > 0 {
+		var matches int
+		for _, alternative := range s.OneOf {
+			if alternative.validate(root, v) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%d alternatives match, expected one", matches)
+		}
+		return nil
+	}
+	if s.Const != nil && !reflect.DeepEqual(s.Const, v) {
+		return fmt.Errorf("expected %v, got %v", s.Const, v)
+	}
+	if !s.allowsType(v) {
+		return fmt.Errorf("unexpected type %s", jsonTypeOf(v))
+	}
+	switch v := v.(type) {
+	case []interface{}:
+		if s.Items != nil {
+			for _, e := range v {
+				if err := s.Items.validate(root, e); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, found := v[key]; !found {
+				return fmt.Errorf("missing required property: %s", key)
+			}
+		}
+		for key, value := range v {
+			property, found := s.Properties[key]
+			if !found {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("unexpected property: %s", key)
+				}
+				continue
+			}
+			if err := property.validate(root, value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateEvent checks whether the event is valid according to the schema
+// and whether its value has the type that the schema declares.
+func validateEvent(schema *jsonSchema, ev *Event) error {
+	expected, found := eventValueTypes[ev.Key]
+	if !found {
+		return fmt.Errorf("unknown event: %s", ev.Key)
+	}
+	actual := reflect.TypeOf(ev.Value)
+	if actual.Kind() == reflect.Ptr {
+		actual = actual.Elem()
+	}
+	if actual != expected {
+		return fmt.Errorf("%s: expected %s, got %s", ev.Key, expected, actual)
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if err := schema.validate(schema, v); err != nil {
+		return fmt.Errorf("%s: %w", data, err)
+	}
+	return nil
+}
+
+// collectEvents runs f with an emitter and returns the emitted events.
+func collectEvents(f func(out chan<- *Event)) []*Event {
+	out := make(chan *Event)
+	go func() {
+		f(out)
+		close(out)
+	}()
+	var events []*Event
+	for ev := range out {
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestEventSchemaValidator(t *testing.T) {
+	schema := loadJSONSchema(t)
+	if err := validateEvent(schema, &Event{
+		Key: statusProgress, Value: EventStatusProgress{Percentage: 0.5},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateEvent(schema, &Event{
+		Key: statusProgress, Value: EventFailure{},
+	}); err == nil {
+		t.Fatal("expected an error here")
+	}
+	if err := validateEvent(schema, &Event{Key: "antani", Value: eventEmpty{}}); err == nil {
+		t.Fatal("expected an error here")
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(
+		`{"key": "status.progress", "value": {"message": 1, "percentage": 0}}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.validate(schema, v); err == nil {
+		t.Fatal("expected an error here")
+	}
+}
+
+func TestEventSchemaCoversAllGoTypes(t *testing.T) {
+	// Every Go type, including zero values, must produce valid events.
+	schema := loadJSONSchema(t)
+	for key, valueType := range eventValueTypes {
+		value := reflect.New(valueType).Elem().Interface()
+		if err := validateEvent(schema, &Event{Key: key, Value: value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(schema.OneOf) != len(eventValueTypes) {
+		t.Fatal("the JSON schema and the Go code describe different events")
+	}
+}
+
+func TestEventEmitterEmitsSchemaValidEvents(t *testing.T) {
+	schema := loadJSONSchema(t)
+	events := collectEvents(func(out chan<- *Event) {
+		emitter := NewEventEmitter(nil, out)
+		emitter.EmitFailureStartup("mocked error")
+		emitter.EmitStatusProgress(0.4, "open report")
+		for _, key := range []string{
+			failureASNLookup, failureCCLookup, failureIPLookup,
+			failureReportCreate, failureResolverLookup,
+		} {
+			emitter.EmitFailureGeneric(key, "mocked error")
+		}
+		logger := NewChanLogger(emitter, "DEBUG", out)
+		logger.Debugf("%s", "debug")
+		logger.Infof("%s", "info")
+		logger.Warnf("%s", "warning")
+		(&runnerCallbacks{emitter: emitter}).OnProgress(0.5, "progress")
+		(&runnerCallbacks{emitter: emitter}).OnTunnelBootstrapProgress(0.5, "bootstrap")
+		emitter.Emit(statusEnd, &eventStatusEnd{DownloadedKB: 1, UploadedKB: 2})
+	})
+	events = append(events, collectEvents(func(out chan<- *Event) {
+		settings := &Settings{Options: SettingsOptions{Tunnel: "antani"}, Version: 1}
+		NewRunner(settings, out).Run(context.Background())
+	})...)
+	events = append(events, collectEvents(func(out chan<- *Event) {
+		NewRunner(&Settings{}, out).Run(context.Background())
+	})...)
+	if len(events) <= 0 {
+		t.Fatal("no events")
+	}
+	for _, ev := range events {
+		if err := validateEvent(schema, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEventSchemaMatchesGoTypes(t *testing.T) {
+	// Make sure the Go types have the same JSON fields as events.json,
+	// including the types we do not generate, e.g., IPLookupResult.
+	data, err := ioutil.ReadFile("events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Types []struct {
+			Name   string
+			Fields []struct {
+				Name     string
+				Optional bool
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	goTypes := make(map[string]reflect.Type)
+	for _, valueType := range eventValueTypes {
+		goTypes[valueType.Name()] = valueType
+		for idx := 0; idx < valueType.NumField(); idx++ {
+			if fieldType := valueType.Field(idx).Type; fieldType.Kind() == reflect.Slice {
+				goTypes[fieldType.Elem().Name()] = fieldType.Elem()
+			}
+		}
+	}
+	for _, ty := range schema.Types {
+		goType, found := goTypes[ty.Name]
+		if !found {
+			t.Fatalf("no Go type for %s", ty.Name)
+		}
+		if goType.NumField() != len(ty.Fields) {
+			t.Fatalf("%s: different number of fields", ty.Name)
+		}
+		for idx, field := range ty.Fields {
+			tag := field.Name
+			if field.Optional {
+				tag += ",omitempty"
+			}
+			if actual := goType.Field(idx).Tag.Get("json"); actual != tag {
+				t.Fatalf("%s: expected %s, got %s", ty.Name, tag, actual)
+			}
+		}
+	}
+}
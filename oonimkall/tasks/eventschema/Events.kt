@@ -0,0 +1,111 @@
+// Code generated by go generate; DO NOT EDIT.
+// Source: oonimkall/tasks/events.json
+
+package oonimkall.events
+
+import kotlinx.serialization.SerialName
+import kotlinx.serialization.Serializable
+
+/** Version of the events schema. */
+const val EVENT_SCHEMA_VERSION = 1
+
+/** Keys of the events emitted by a task. */
+object EventKeys {
+    const val FAILURE_ASN_LOOKUP = "failure.asn_lookup"
+    const val FAILURE_CC_LOOKUP = "failure.cc_lookup"
+    const val FAILURE_IP_LOOKUP = "failure.ip_lookup"
+    const val FAILURE_MEASUREMENT = "failure.measurement"
+    const val FAILURE_MEASUREMENT_SUBMISSION = "failure.measurement_submission"
+    const val FAILURE_REPORT_CREATE = "failure.report_create"
+    const val FAILURE_RESOLVER_LOOKUP = "failure.resolver_lookup"
+    const val FAILURE_STARTUP = "failure.startup"
+    const val LOG = "log"
+    const val MEASUREMENT = "measurement"
+    const val STATUS_END = "status.end"
+    const val STATUS_GEOIP_LOOKUP = "status.geoip_lookup"
+    const val STATUS_MEASUREMENT_DONE = "status.measurement_done"
+    const val STATUS_MEASUREMENT_START = "status.measurement_start"
+    const val STATUS_MEASUREMENT_SUBMISSION = "status.measurement_submission"
+    const val STATUS_PROGRESS = "status.progress"
+    const val STATUS_QUEUED = "status.queued"
+    const val STATUS_REPORT_CREATE = "status.report_create"
+    const val STATUS_RESOLVER_LOOKUP = "status.resolver_lookup"
+    const val STATUS_STARTED = "status.started"
+    const val STATUS_TUNNEL_BOOTSTRAP = "status.tunnel_bootstrap"
+    const val TASK_TERMINATED = "task_terminated"
+}
+
+/** EventEmpty is the value of events without a value. */
+@Serializable
+class EventEmpty
+
+/** EventFailure contains information on a failure. */
+@Serializable
+data class EventFailure(
+    @SerialName("failure") val failure: String
+)
+
+/** EventLog is an event containing a log message. */
+@Serializable
+data class EventLog(
+    @SerialName("log_level") val logLevel: String,
+    @SerialName("message") val message: String
+)
+
+/** EventMeasurementGeneric contains information on a measurement. */
+@Serializable
+data class EventMeasurementGeneric(
+    @SerialName("failure") val failure: String? = null,
+    @SerialName("idx") val idx: Long,
+    @SerialName("input") val input: String,
+    @SerialName("json_str") val jsonStr: String? = null
+)
+
+/** EventStatusEnd contains the bytes counters and the failure of the task. */
+@Serializable
+data class EventStatusEnd(
+    @SerialName("downloaded_kb") val downloadedKB: Double,
+    @SerialName("failure") val failure: String,
+    @SerialName("uploaded_kb") val uploadedKB: Double
+)
+
+/** EventStatusGeoIPLookup contains the probe location. */
+@Serializable
+data class EventStatusGeoIPLookup(
+    @SerialName("probe_asn") val probeASN: String,
+    @SerialName("probe_cc") val probeCC: String,
+    @SerialName("probe_ip") val probeIP: String,
+    @SerialName("probe_ip_confidence") val probeIPConfidence: Double,
+    @SerialName("probe_ip_disagreements") val probeIPDisagreements: List<IPLookupResult>?,
+    @SerialName("probe_ip_lookups") val probeIPLookups: List<IPLookupResult>?,
+    @SerialName("probe_network_name") val probeNetworkName: String
+)
+
+/** EventStatusProgress reports progress information. */
+@Serializable
+data class EventStatusProgress(
+    @SerialName("message") val message: String,
+    @SerialName("percentage") val percentage: Double
+)
+
+/** EventStatusReportGeneric contains the ID of the report. */
+@Serializable
+data class EventStatusReportGeneric(
+    @SerialName("report_id") val reportID: String
+)
+
+/** EventStatusResolverLookup contains the resolver location. */
+@Serializable
+data class EventStatusResolverLookup(
+    @SerialName("resolver_asn") val resolverASN: String,
+    @SerialName("resolver_ip") val resolverIP: String,
+    @SerialName("resolver_network_name") val resolverNetworkName: String
+)
+
+/** IPLookupResult is the result of looking up the probe IP using a backend. */
+@Serializable
+data class IPLookupResult(
+    @SerialName("backend") val backend: String,
+    @SerialName("failure") val failure: String? = null,
+    @SerialName("ip") val ip: String? = null
+)
@@ -0,0 +1,152 @@
+// Code generated by go generate; DO NOT EDIT.
+// Source: oonimkall/tasks/events.json
+
+import Foundation
+
+/// Version of the events schema.
+public let eventSchemaVersion: Int64 = 1
+
+/// Keys of the events emitted by a task.
+public enum EventKey: String, Codable {
+    case failureASNLookup = "failure.asn_lookup"
+    case failureCCLookup = "failure.cc_lookup"
+    case failureIPLookup = "failure.ip_lookup"
+    case failureMeasurement = "failure.measurement"
+    case failureMeasurementSubmission = "failure.measurement_submission"
+    case failureReportCreate = "failure.report_create"
+    case failureResolverLookup = "failure.resolver_lookup"
+    case failureStartup = "failure.startup"
+    case log = "log"
+    case measurement = "measurement"
+    case statusEnd = "status.end"
+    case statusGeoIPLookup = "status.geoip_lookup"
+    case statusMeasurementDone = "status.measurement_done"
+    case statusMeasurementStart = "status.measurement_start"
+    case statusMeasurementSubmission = "status.measurement_submission"
+    case statusProgress = "status.progress"
+    case statusQueued = "status.queued"
+    case statusReportCreate = "status.report_create"
+    case statusResolverLookup = "status.resolver_lookup"
+    case statusStarted = "status.started"
+    case statusTunnelBootstrap = "status.tunnel_bootstrap"
+    case taskTerminated = "task_terminated"
+}
+
+/// EventEmpty is the value of events without a value.
+public struct EventEmpty: Codable {
+}
+
+/// EventFailure contains information on a failure.
+public struct EventFailure: Codable {
+    public let failure: String
+
+    enum CodingKeys: String, CodingKey {
+        case failure = "failure"
+    }
+}
+
+/// EventLog is an event containing a log message.
+public struct EventLog: Codable {
+    public let logLevel: String
+    public let message: String
+
+    enum CodingKeys: String, CodingKey {
+        case logLevel = "log_level"
+        case message = "message"
+    }
+}
+
+/// EventMeasurementGeneric contains information on a measurement.
+public struct EventMeasurementGeneric: Codable {
+    public let failure: String?
+    public let idx: Int64
+    public let input: String
+    public let jsonStr: String?
+
+    enum CodingKeys: String, CodingKey {
+        case failure = "failure"
+        case idx = "idx"
+        case input = "input"
+        case jsonStr = "json_str"
+    }
+}
+
+/// EventStatusEnd contains the bytes counters and the failure of the task.
+public struct EventStatusEnd: Codable {
+    public let downloadedKB: Double
+    public let failure: String
+    public let uploadedKB: Double
+
+    enum CodingKeys: String, CodingKey {
+        case downloadedKB = "downloaded_kb"
+        case failure = "failure"
+        case uploadedKB = "uploaded_kb"
+    }
+}
+
+/// EventStatusGeoIPLookup contains the probe location.
+public struct EventStatusGeoIPLookup: Codable {
+    public let probeASN: String
+    public let probeCC: String
+    public let probeIP: String
+    public let probeIPConfidence: Double
+    public let probeIPDisagreements: [IPLookupResult]?
+    public let probeIPLookups: [IPLookupResult]?
+    public let probeNetworkName: String
+
+    enum CodingKeys: String, CodingKey {
+        case probeASN = "probe_asn"
+        case probeCC = "probe_cc"
+        case probeIP = "probe_ip"
+        case probeIPConfidence = "probe_ip_confidence"
+        case probeIPDisagreements = "probe_ip_disagreements"
+        case probeIPLookups = "probe_ip_lookups"
+        case probeNetworkName = "probe_network_name"
+    }
+}
+
+/// EventStatusProgress reports progress information.
+public struct EventStatusProgress: Codable {
+    public let message: String
+    public let percentage: Double
+
+    enum CodingKeys: String, CodingKey {
+        case message = "message"
+        case percentage = "percentage"
+    }
+}
+
+/// EventStatusReportGeneric contains the ID of the report.
+public struct EventStatusReportGeneric: Codable {
+    public let reportID: String
+
+    enum CodingKeys: String, CodingKey {
+        case reportID = "report_id"
+    }
+}
+
+/// EventStatusResolverLookup contains the resolver location.
+public struct EventStatusResolverLookup: Codable {
+    public let resolverASN: String
+    public let resolverIP: String
+    public let resolverNetworkName: String
+
+    enum CodingKeys: String, CodingKey {
+        case resolverASN = "resolver_asn"
+        case resolverIP = "resolver_ip"
+        case resolverNetworkName = "resolver_network_name"
+    }
+}
+
+/// IPLookupResult is the result of looking up the probe IP using a backend.
+public struct IPLookupResult: Codable {
+    public let backend: String
+    public let failure: String?
+    public let ip: String?
+
+    enum CodingKeys: String, CodingKey {
+        case backend = "backend"
+        case failure = "failure"
+        case ip = "ip"
+    }
+}
@@ -0,0 +1,113 @@
+/* Code generated by go generate; DO NOT EDIT. */
+/* Source: oonimkall/tasks/events.json */
+
+#ifndef OONIMKALL_EVENTS_H
+#define OONIMKALL_EVENTS_H
+
+/* Version of the events schema. */
+#define OONIMKALL_EVENT_SCHEMA_VERSION 1
+
+/*
+ * Keys of the events emitted by a task. Each event is a JSON object
+ * like {"key": "<key>", "value": {...}}. The comment next to each key
+ * names the type of the value, which is documented below.
+ */
+#define OONIMKALL_EVENT_FAILURE_ASN_LOOKUP "failure.asn_lookup" /* EventFailure */
+#define OONIMKALL_EVENT_FAILURE_CC_LOOKUP "failure.cc_lookup" /* EventFailure */
+#define OONIMKALL_EVENT_FAILURE_IP_LOOKUP "failure.ip_lookup" /* EventFailure */
+#define OONIMKALL_EVENT_FAILURE_MEASUREMENT "failure.measurement" /* EventMeasurementGeneric */
+#define OONIMKALL_EVENT_FAILURE_MEASUREMENT_SUBMISSION "failure.measurement_submission" /* EventMeasurementGeneric */
+#define OONIMKALL_EVENT_FAILURE_REPORT_CREATE "failure.report_create" /* EventFailure */
+#define OONIMKALL_EVENT_FAILURE_RESOLVER_LOOKUP "failure.resolver_lookup" /* EventFailure */
+#define OONIMKALL_EVENT_FAILURE_STARTUP "failure.startup" /* EventFailure */
+#define OONIMKALL_EVENT_LOG "log" /* EventLog */
+#define OONIMKALL_EVENT_MEASUREMENT "measurement" /* EventMeasurementGeneric */
+#define OONIMKALL_EVENT_STATUS_END "status.end" /* EventStatusEnd */
+#define OONIMKALL_EVENT_STATUS_GEOIP_LOOKUP "status.geoip_lookup" /* EventStatusGeoIPLookup */
+#define OONIMKALL_EVENT_STATUS_MEASUREMENT_DONE "status.measurement_done" /* EventMeasurementGeneric */
+#define OONIMKALL_EVENT_STATUS_MEASUREMENT_START "status.measurement_start" /* EventMeasurementGeneric */
+#define OONIMKALL_EVENT_STATUS_MEASUREMENT_SUBMISSION "status.measurement_submission" /* EventMeasurementGeneric */
+#define OONIMKALL_EVENT_STATUS_PROGRESS "status.progress" /* EventStatusProgress */
+#define OONIMKALL_EVENT_STATUS_QUEUED "status.queued" /* EventEmpty */
+#define OONIMKALL_EVENT_STATUS_REPORT_CREATE "status.report_create" /* EventStatusReportGeneric */
+#define OONIMKALL_EVENT_STATUS_RESOLVER_LOOKUP "status.resolver_lookup" /* EventStatusResolverLookup */
+#define OONIMKALL_EVENT_STATUS_STARTED "status.started" /* EventEmpty */
+#define OONIMKALL_EVENT_STATUS_TUNNEL_BOOTSTRAP "status.tunnel_bootstrap" /* EventStatusProgress */
+#define OONIMKALL_EVENT_TASK_TERMINATED "task_terminated" /* EventEmpty */
+
+/*
+ * EventEmpty is the value of events without a value.
+ */
+
+/*
+ * EventFailure contains information on a failure.
+ *
+ * - failure: string
+ */
+
+/*
+ * EventLog is an event containing a log message.
+ *
+ * - log_level: string
+ * - message: string
+ */
+
+/*
+ * EventMeasurementGeneric contains information on a measurement.
+ *
+ * - failure: string (optional)
+ * - idx: integer
+ * - input: string
+ * - json_str: string (optional)
+ */
+
+/*
+ * EventStatusEnd contains the bytes counters and the failure of the task.
+ *
+ * - downloaded_kb: number
+ * - failure: string
+ * - uploaded_kb: number
+ */
+
+/*
+ * EventStatusGeoIPLookup contains the probe location.
+ *
+ * - probe_asn: string
+ * - probe_cc: string
+ * - probe_ip: string
+ * - probe_ip_confidence: number
+ * - probe_ip_disagreements: array of IPLookupResult or null
+ * - probe_ip_lookups: array of IPLookupResult or null
+ * - probe_network_name: string
+ */
+
+/*
+ * EventStatusProgress reports progress information.
+ *
+ * - message: string
+ * - percentage: number
+ */
+
+/*
+ * EventStatusReportGeneric contains the ID of the report.
+ *
+ * - report_id: string
+ */
+
+/*
+ * EventStatusResolverLookup contains the resolver location.
+ *
+ * - resolver_asn: string
+ * - resolver_ip: string
+ * - resolver_network_name: string
+ */
+
+/*
+ * IPLookupResult is the result of looking up the probe IP using a backend.
+ *
+ * - backend: string
+ * - failure: string (optional)
+ * - ip: string (optional)
+ */
+
+#endif /* OONIMKALL_EVENTS_H */
@@ -0,0 +1,124 @@
+// Code generated by go generate; DO NOT EDIT.
+// Source: events.json
+
+package tasks
+
+import (
+	"reflect"
+
+	"github.com/ooni/probe-engine/geolocate"
+)
+
+// EventSchemaVersion is the version of the events schema. We bump
+// it every time we change events in a backwards incompatible way.
+const EventSchemaVersion = 1
+
+// The following are the keys of the events emitted by a task.
+const (
+	failureASNLookup             = "failure.asn_lookup"
+	failureCCLookup              = "failure.cc_lookup"
+	failureIPLookup              = "failure.ip_lookup"
+	failureMeasurement           = "failure.measurement"
+	failureMeasurementSubmission = "failure.measurement_submission"
+	failureReportCreate          = "failure.report_create"
+	failureResolverLookup        = "failure.resolver_lookup"
+	failureStartup               = "failure.startup"
+	logEvent                     = "log"
+	measurement                  = "measurement"
+	statusEnd                    = "status.end"
+	statusGeoIPLookup            = "status.geoip_lookup"
+	statusMeasurementDone        = "status.measurement_done"
+	statusMeasurementStart       = "status.measurement_start"
+	statusMeasurementSubmission  = "status.measurement_submission"
+	statusProgress               = "status.progress"
+	statusQueued                 = "status.queued"
+	statusReportCreate           = "status.report_create"
+	statusResolverLookup         = "status.resolver_lookup"
+	statusStarted                = "status.started"
+	statusTunnelBootstrap        = "status.tunnel_bootstrap"
+	taskTerminated               = "task_terminated"
+)
+
+// eventEmpty is the value of events without a value.
+type eventEmpty struct{}
+
+// EventFailure contains information on a failure.
+type EventFailure struct {
+	Failure string `json:"failure"`
+}
+
+// EventLog is an event containing a log message.
+type EventLog struct {
+	LogLevel string `json:"log_level"`
+	Message  string `json:"message"`
+}
+
+// EventMeasurementGeneric contains information on a measurement.
+type EventMeasurementGeneric struct {
+	Failure string `json:"failure,omitempty"`
+	Idx     int64  `json:"idx"`
+	Input   string `json:"input"`
+	JSONStr string `json:"json_str,omitempty"`
+}
+
+// eventStatusEnd contains the bytes counters and the failure of the task.
+type eventStatusEnd struct {
+	DownloadedKB float64 `json:"downloaded_kb"`
+	Failure      string  `json:"failure"`
+	UploadedKB   float64 `json:"uploaded_kb"`
+}
+
+// eventStatusGeoIPLookup contains the probe location.
+type eventStatusGeoIPLookup struct {
+	ProbeASN             string                     `json:"probe_asn"`
+	ProbeCC              string                     `json:"probe_cc"`
+	ProbeIP              string                     `json:"probe_ip"`
+	ProbeIPConfidence    float64                    `json:"probe_ip_confidence"`
+	ProbeIPDisagreements []geolocate.IPLookupResult `json:"probe_ip_disagreements"`
+	ProbeIPLookups       []geolocate.IPLookupResult `json:"probe_ip_lookups"`
+	ProbeNetworkName     string                     `json:"probe_network_name"`
+}
+
+// EventStatusProgress reports progress information.
+type EventStatusProgress struct {
+	Message    string  `json:"message"`
+	Percentage float64 `json:"percentage"`
+}
+
+// eventStatusReportGeneric contains the ID of the report.
+type eventStatusReportGeneric struct {
+	ReportID string `json:"report_id"`
+}
+
+// eventStatusResolverLookup contains the resolver location.
+type eventStatusResolverLookup struct {
+	ResolverASN         string `json:"resolver_asn"`
+	ResolverIP          string `json:"resolver_ip"`
+	ResolverNetworkName string `json:"resolver_network_name"`
+}
+
+// eventValueTypes maps each event key to the type of its value.
+var eventValueTypes = map[string]reflect.Type{
+	failureASNLookup:             reflect.TypeOf(EventFailure{}),
+	failureCCLookup:              reflect.TypeOf(EventFailure{}),
+	failureIPLookup:              reflect.TypeOf(EventFailure{}),
+	failureMeasurement:           reflect.TypeOf(EventMeasurementGeneric{}),
+	failureMeasurementSubmission: reflect.TypeOf(EventMeasurementGeneric{}),
+	failureReportCreate:          reflect.TypeOf(EventFailure{}),
+	failureResolverLookup:        reflect.TypeOf(EventFailure{}),
+	failureStartup:               reflect.TypeOf(EventFailure{}),
+	logEvent:                     reflect.TypeOf(EventLog{}),
+	measurement:                  reflect.TypeOf(EventMeasurementGeneric{}),
+	statusEnd:                    reflect.TypeOf(eventStatusEnd{}),
+	statusGeoIPLookup:            reflect.TypeOf(eventStatusGeoIPLookup{}),
+	statusMeasurementDone:        reflect.TypeOf(EventMeasurementGeneric{}),
+	statusMeasurementStart:       reflect.TypeOf(EventMeasurementGeneric{}),
+	statusMeasurementSubmission:  reflect.TypeOf(EventMeasurementGeneric{}),
+	statusProgress:               reflect.TypeOf(EventStatusProgress{}),
+	statusQueued:                 reflect.TypeOf(eventEmpty{}),
+	statusReportCreate:           reflect.TypeOf(eventStatusReportGeneric{}),
+	statusResolverLookup:         reflect.TypeOf(eventStatusResolverLookup{}),
+	statusStarted:                reflect.TypeOf(eventEmpty{}),
+	statusTunnelBootstrap:        reflect.TypeOf(EventStatusProgress{}),
+	taskTerminated:               reflect.TypeOf(eventEmpty{}),
+}
@@ -0,0 +1,438 @@
+// +build ignore
+
+// This script generates the events Go types, the events JSON Schema and
+// the Kotlin, Swift, and C bindings from the events.json schema.
+//
+// This script should not be invoked directly, rather it should be
+// executed by running go generate ./... from toplevel dir.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Schema is the events schema.
+type Schema struct {
+	Version int64   `json:"version"`
+	Types   []Type  `json:"types"`
+	Events  []Event `json:"events"`
+}
+
+// Type is the type of the value of an event.
+type Type struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	GoType      string  `json:"go_type"`
+	Fields      []Field `json:"fields"`
+}
+
+// Field is a field of a Type.
+type Field struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Optional bool   `json:"optional"`
+}
+
+// Event is an event.
+type Event struct {
+	Key    string `json:"key"`
+	Type   string `json:"type"`
+	GoName string `json:"go_name"`
+}
+
+// initialisms are the initialisms we use in the Go code.
+var initialisms = map[string]string{
+	"asn":   "ASN",
+	"cc":    "CC",
+	"geoip": "GeoIP",
+	"id":    "ID",
+	"ip":    "IP",
+	"json":  "JSON",
+	"kb":    "KB",
+}
+
+// camel converts "status.geoip_lookup" to "StatusGeoIPLookup".
+func camel(s string) string {
+	var out []string
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == '.' || r == '_'
+	}) {
+		if v, ok := initialisms[word]; ok {
+			out = append(out, v)
+			continue
+		}
+		out = append(out, strings.Title(word))
+	}
+	return strings.Join(out, "")
+}
+
+// lowerCamel converts "status.geoip_lookup" to "statusGeoIPLookup".
+func lowerCamel(s string) string {
+	v := camel(s)
+	for _, initialism := range initialisms {
+		if strings.HasPrefix(v, initialism) {
+			return strings.ToLower(initialism) + v[len(initialism):]
+		}
+	}
+	return strings.ToLower(v[:1]) + v[1:]
+}
+
+// upperSnake converts "status.geoip_lookup" to "STATUS_GEOIP_LOOKUP".
+func upperSnake(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(s, ".", "_"))
+}
+
+// exported returns the exported name of a type.
+func exported(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// elem returns the element type of an array type and whether
+// the type is actually an array type.
+func elem(t string) (string, bool) {
+	if strings.HasPrefix(t, "[]") {
+		return t[2:], true
+	}
+	return t, false
+}
+
+func (s *Schema) goType(t string) string {
+	if e, ok := elem(t); ok {
+		return "[]" + s.goType(e)
+	}
+	switch t {
+	case "bool", "float64", "int64", "string":
+		return t
+	}
+	for _, ty := range s.Types {
+		if ty.Name == t && ty.GoType != "" {
+			return ty.GoType
+		}
+	}
+	return t
+}
+
+func kotlinType(t string) string {
+	if e, ok := elem(t); ok {
+		return "List<" + kotlinType(e) + ">?"
+	}
+	switch t {
+	case "bool":
+		return "Boolean"
+	case "float64":
+		return "Double"
+	case "int64":
+		return "Long"
+	case "string":
+		return "String"
+	}
+	return exported(t)
+}
+
+func swiftType(t string) string {
+	if e, ok := elem(t); ok {
+		return "[" + swiftType(e) + "]?"
+	}
+	switch t {
+	case "bool":
+		return "Bool"
+	case "float64":
+		return "Double"
+	case "int64":
+		return "Int64"
+	case "string":
+		return "String"
+	}
+	return exported(t)
+}
+
+func docType(t string) string {
+	if e, ok := elem(t); ok {
+		return "array of " + exported(e) + " or null"
+	}
+	return jsonType(t)
+}
+
+func jsonType(t string) string {
+	if _, ok := elem(t); ok {
+		return "array"
+	}
+	switch t {
+	case "bool":
+		return "boolean"
+	case "float64":
+		return "number"
+	case "int64":
+		return "integer"
+	case "string":
+		return "string"
+	}
+	return "object"
+}
+
+var funcs = template.FuncMap{
+	"camel":      camel,
+	"docType":    docType,
+	"exported":   exported,
+	"jsonType":   jsonType,
+	"kotlinType": kotlinType,
+	"lowerCamel": lowerCamel,
+	"swiftType":  swiftType,
+	"upperSnake": upperSnake,
+}
+
+var goTemplate = `// Code generated by go generate; DO NOT EDIT.
+// Source: events.json
+
+package tasks
+
+import (
+	"reflect"
+{{ if .UsesGeolocate }}
+	"github.com/ooni/probe-engine/geolocate"
+{{ end }}
+)
+
+// EventSchemaVersion is the version of the events schema. We bump
+// it every time we change events in a backwards incompatible way.
+const EventSchemaVersion = {{ .Schema.Version }}
+
+// The following are the keys of the events emitted by a task.
+const (
+{{- range .Schema.Events }}
+	{{ .GoName }} = "{{ .Key }}"
+{{- end }}
+)
+{{ range .Schema.Types }}{{ if not .GoType }}
+// {{ .Name }} {{ .Description }}
+{{ if .Fields -}}
+type {{ .Name }} struct {
+{{- range .Fields }}
+	{{ camel .Name }} {{ $.Schema.GoType .Type }} ` + "`" + `json:"{{ .Name }}{{ if .Optional }},omitempty{{ end }}"` + "`" + `
+{{- end }}
+}
+{{- else -}}
+type {{ .Name }} struct{}
+{{- end }}
+{{ end }}{{ end }}
+// eventValueTypes maps each event key to the type of its value.
+var eventValueTypes = map[string]reflect.Type{
+{{- range .Schema.Events }}
+	{{ .GoName }}: reflect.TypeOf({{ $.Schema.GoType .Type }}{}),
+{{- end }}
+}
+`
+
+var kotlinTemplate = `// Code generated by go generate; DO NOT EDIT.
+// Source: oonimkall/tasks/events.json
+
+package oonimkall.events
+
+import kotlinx.serialization.SerialName
+import kotlinx.serialization.Serializable
+
+/** Version of the events schema. */
+const val EVENT_SCHEMA_VERSION = {{ .Schema.Version }}
+
+/** Keys of the events emitted by a task. */
+object EventKeys {
+{{- range .Schema.Events }}
+    const val {{ upperSnake .Key }} = "{{ .Key }}"
+{{- end }}
+}
+{{ range .Schema.Types }}
+/** {{ exported .Name }} {{ .Description }} */
+@Serializable
+{{ if .Fields }}data class {{ exported .Name }}(
+{{- range $idx, $field := .Fields }}{{ if $idx }},{{ end }}
+    @SerialName("{{ .Name }}") val {{ lowerCamel .Name }}: {{ kotlinType .Type }}{{ if .Optional }}? = null{{ end }}
+{{- end }}
+){{ else }}class {{ exported .Name }}{{ end }}
+{{ end -}}
+`
+
+var swiftTemplate = `// Code generated by go generate; DO NOT EDIT.
+// Source: oonimkall/tasks/events.json
+
+import Foundation
+
+/// Version of the events schema.
+public let eventSchemaVersion: Int64 = {{ .Schema.Version }}
+
+/// Keys of the events emitted by a task.
+public enum EventKey: String, Codable {
+{{- range .Schema.Events }}
+    case {{ lowerCamel .Key }} = "{{ .Key }}"
+{{- end }}
+}
+{{ range .Schema.Types }}
+/// {{ exported .Name }} {{ .Description }}
+public struct {{ exported .Name }}: Codable {
+{{- range .Fields }}
+    public let {{ lowerCamel .Name }}: {{ swiftType .Type }}{{ if .Optional }}?{{ end }}
+{{- end }}
+{{- if .Fields }}
+
+    enum CodingKeys: String, CodingKey {
+{{- range .Fields }}
+        case {{ lowerCamel .Name }} = "{{ .Name }}"
+{{- end }}
+    }
+{{- end }}
+}
+{{ end -}}
+`
+
+var cTemplate = `/* Code generated by go generate; DO NOT EDIT. */
+/* Source: oonimkall/tasks/events.json */
+
+#ifndef OONIMKALL_EVENTS_H
+#define OONIMKALL_EVENTS_H
+
+/* Version of the events schema. */
+#define OONIMKALL_EVENT_SCHEMA_VERSION {{ .Schema.Version }}
+
+/*
+ * Keys of the events emitted by a task. Each event is a JSON object
+ * like {"key": "<key>", "value": {...}}. The comment next to each key
+ * names the type of the value, which is documented below.
+ */
+{{- range .Schema.Events }}
+#define OONIMKALL_EVENT_{{ upperSnake .Key }} "{{ .Key }}" /* {{ exported .Type }} */
+{{- end }}
+{{ range .Schema.Types }}
+/*
+ * {{ exported .Name }} {{ .Description }}
+{{- if .Fields }}
+ *
+{{- range .Fields }}
+ * - {{ .Name }}: {{ docType .Type }}{{ if .Optional }} (optional){{ end }}
+{{- end }}
+{{- end }}
+ */
+{{ end }}
+#endif /* OONIMKALL_EVENTS_H */
+`
+
+// GoType is like goType but callable from templates.
+func (s *Schema) GoType(t string) string {
+	return s.goType(t)
+}
+
+func render(name, text string, data interface{}) []byte {
+	tmpl := template.Must(template.New(name).Funcs(funcs).Parse(text))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jsonSchema returns the JSON Schema of the events.
+func jsonSchema(s *Schema) []byte {
+	definitions := make(map[string]interface{})
+	property := func(t string) map[string]interface{} {
+		if e, ok := elem(t); ok {
+			return map[string]interface{}{
+				"type":  []string{"array", "null"},
+				"items": map[string]interface{}{"$ref": "#/definitions/" + exported(e)},
+			}
+		}
+		return map[string]interface{}{"type": jsonType(t)}
+	}
+	for _, ty := range s.Types {
+		properties := make(map[string]interface{})
+		required := []string{}
+		for _, field := range ty.Fields {
+			properties[field.Name] = property(field.Type)
+			if !field.Optional {
+				required = append(required, field.Name)
+			}
+		}
+		definitions[exported(ty.Name)] = map[string]interface{}{
+			"additionalProperties": false,
+			"description":          exported(ty.Name) + " " + ty.Description,
+			"properties":           properties,
+			"required":             required,
+			"type":                 "object",
+		}
+	}
+	var events []interface{}
+	for _, ev := range s.Events {
+		events = append(events, map[string]interface{}{
+			"additionalProperties": false,
+			"properties": map[string]interface{}{
+				"key":   map[string]interface{}{"const": ev.Key},
+				"value": map[string]interface{}{"$ref": "#/definitions/" + exported(ev.Type)},
+			},
+			"required": []string{"key", "value"},
+			"type":     "object",
+		})
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"$id":         fmt.Sprintf("https://ooni.org/schemas/oonimkall/events/v%d.json", s.Version),
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"definitions": definitions,
+		"oneOf":       events,
+		"title":       "oonimkall task event",
+		"version":     s.Version,
+	}, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	return append(data, '\n')
+}
+
+func write(path string, data []byte) {
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	data, err := ioutil.ReadFile("events.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		log.Fatal(err)
+	}
+	usesGeolocate := false
+	for idx, ev := range schema.Events {
+		if ev.GoName == "" {
+			schema.Events[idx].GoName = lowerCamel(ev.Key)
+		}
+	}
+	for _, ty := range schema.Types {
+		usesGeolocate = usesGeolocate || strings.HasPrefix(ty.GoType, "geolocate.")
+	}
+	code, err := format.Source(render("go", goTemplate, map[string]interface{}{
+		"Schema":        &schema,
+		"UsesGeolocate": usesGeolocate,
+	}))
+	if err != nil {
+		log.Fatal(err)
+	}
+	write("eventtypes.go", code)
+	dir := "eventschema"
+	write(filepath.Join(dir, "events.schema.json"), jsonSchema(&schema))
+	write(filepath.Join(dir, "Events.kt"), render("kotlin", kotlinTemplate, map[string]interface{}{
+		"Schema": &schema,
+	}))
+	write(filepath.Join(dir, "Events.swift"), render("swift", swiftTemplate, map[string]interface{}{
+		"Schema": &schema,
+	}))
+	write(filepath.Join(dir, "oonimkall_events.h"), render("c", cTemplate, map[string]interface{}{
+		"Schema": &schema,
+	}))
+}
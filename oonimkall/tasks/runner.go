@@ -5,35 +5,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"time"
 
 	engine "github.com/ooni/probe-engine"
+	"github.com/ooni/probe-engine/geolocate"
 	"github.com/ooni/probe-engine/internal/runtimex"
+	"github.com/ooni/probe-engine/internal/tunnel"
 	"github.com/ooni/probe-engine/model"
 )
 
-const (
-	failureIPLookup              = "failure.ip_lookup"
-	failureASNLookup             = "failure.asn_lookup"
-	failureCCLookup              = "failure.cc_lookup"
-	failureMeasurement           = "failure.measurement"
-	failureMeasurementSubmission = "failure.measurement_submission"
-	failureReportCreate          = "failure.report_create"
-	failureResolverLookup        = "failure.resolver_lookup"
-	failureStartup               = "failure.startup"
-	measurement                  = "measurement"
-	statusEnd                    = "status.end"
-	statusGeoIPLookup            = "status.geoip_lookup"
-	statusMeasurementDone        = "status.measurement_done"
-	statusMeasurementStart       = "status.measurement_start"
-	statusMeasurementSubmission  = "status.measurement_submission"
-	statusProgress               = "status.progress"
-	statusQueued                 = "status.queued"
-	statusReportCreate           = "status.report_create"
-	statusResolverLookup         = "status.resolver_lookup"
-	statusStarted                = "status.started"
-)
-
 // Run runs the task specified by settings.Name until completion. This is the
 // top-level API that should be called by oonimkall.
 func Run(ctx context.Context, settings *Settings, out chan<- *Event) {
@@ -46,6 +27,7 @@ type Runner struct {
 	emitter             *EventEmitter
 	maybeLookupLocation func(*engine.Session) error
 	out                 chan<- *Event
+	session             *engine.Session
 	settings            *Settings
 }
 
@@ -58,30 +40,129 @@ func NewRunner(settings *Settings, out chan<- *Event) *Runner {
 	}
 }
 
-// FailureInvalidVersion is the failure returned when Version is invalid
-const FailureInvalidVersion = "invalid Settings.Version number"
+// NewRunnerWithSession creates a new task runner that uses the given
+// session rather than creating a new session for running the task. The
+// runner does not close the session, so long running processes can
+// share the same session among several tasks. Tasks must not run
+// concurrently using the same session. Since the session logger
+// is shared, log events are emitted by the session logger.
+func NewRunnerWithSession(
+	settings *Settings, out chan<- *Event, sess *engine.Session) *Runner {
+	r := NewRunner(settings, out)
+	r.session = sess
+	return r
+}
+
+// The following failures are returned when Settings are invalid.
+const (
+	// FailureInvalidVersion is the failure returned when Version is invalid
+	FailureInvalidVersion = "invalid Settings.Version number"
+
+	// FailureInvalidProxy is the failure returned when Options.Proxy is invalid
+	FailureInvalidProxy = "invalid Settings.Options.Proxy URL"
+
+	// FailureInvalidTunnel is the failure returned when Options.Tunnel is invalid
+	FailureInvalidTunnel = "invalid Settings.Options.Tunnel name"
+
+	// FailureInvalidURLLimit is the failure returned when URLLimit is invalid
+	FailureInvalidURLLimit = "invalid Settings.URLLimit value"
+
+	// FailureProxyAndTunnel is the failure returned when both Options.Proxy
+	// and Options.Tunnel are set, since we can only use one of them.
+	FailureProxyAndTunnel = "cannot use both Settings.Options.Proxy and Settings.Options.Tunnel"
+
+	// FailureProxyWithSharedSession is the failure returned when Options.Proxy
+	// is set and we're using a shared session, which has its own proxy.
+	FailureProxyWithSharedSession = "cannot use Settings.Options.Proxy with a shared session"
+
+	// FailureTunnelWithSharedSession is the failure returned when Options.Tunnel
+	// is set and we're using a shared session, which has its own tunnel or proxy.
+	FailureTunnelWithSharedSession = "cannot use Settings.Options.Tunnel with a shared session"
+)
 
 func (r *Runner) hasUnsupportedSettings(logger *ChanLogger) bool {
-	if r.settings.Version < 1 {
-		r.emitter.EmitFailureStartup(FailureInvalidVersion)
+	if failure := r.validateSettings(); failure != "" {
+		r.emitter.EmitFailureStartup(failure)
 		return true
 	}
 	return false
 }
 
+// validateSettings returns the failure string describing why the
+// settings are invalid, or an empty string if they are valid.
+func (r *Runner) validateSettings() string {
+	if r.settings.Version < 1 {
+		return FailureInvalidVersion
+	}
+	if r.settings.Options.Proxy != "" {
+		if _, err := r.proxyURL(); err != nil {
+			return FailureInvalidProxy
+		}
+		if r.settings.Options.Tunnel != "" {
+			return FailureProxyAndTunnel
+		}
+		if r.session != nil {
+			return FailureProxyWithSharedSession
+		}
+	}
+	if r.settings.Options.Tunnel != "" {
+		if !isTunnelName(r.settings.Options.Tunnel) {
+			return FailureInvalidTunnel
+		}
+		if r.session != nil {
+			return FailureTunnelWithSharedSession
+		}
+	}
+	if r.settings.URLLimit < 0 {
+		return FailureInvalidURLLimit
+	}
+	return ""
+}
+
+// proxyURL returns the parsed Options.Proxy or nil if it is empty.
+func (r *Runner) proxyURL() (*url.URL, error) {
+	if r.settings.Options.Proxy == "" {
+		return nil, nil
+	}
+	URL, err := url.Parse(r.settings.Options.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	if URL.Scheme != "socks5" || URL.Host == "" {
+		return nil, fmt.Errorf("unsupported proxy URL: %s", r.settings.Options.Proxy)
+	}
+	return URL, nil
+}
+
+func isTunnelName(name string) bool {
+	for _, candidate := range tunnel.Names() {
+		if name == candidate {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Runner) newsession(logger *ChanLogger) (*engine.Session, error) {
 	kvstore, err := engine.NewFileSystemKVStore(r.settings.StateDir)
 	if err != nil {
 		return nil, err
 	}
+	proxyURL, err := r.proxyURL()
+	if err != nil {
+		return nil, err
+	}
 	config := engine.SessionConfig{
 		AssetsDir:       r.settings.AssetsDir,
 		KVStore:         kvstore,
 		Logger:          logger,
+		ProxyURL:        proxyURL,
 		SoftwareName:    r.settings.Options.SoftwareName,
 		SoftwareVersion: r.settings.Options.SoftwareVersion,
 		TempDir:         r.settings.TempDir,
 	}
+	cb := &runnerCallbacks{emitter: r.emitter}
+	config.TunnelBootstrapProgress = cb.OnTunnelBootstrapProgress
 	if r.settings.Options.ProbeServicesBaseURL != "" {
 		config.AvailableProbeServices = []model.Service{{
 			Type:    "https",
@@ -91,6 +172,20 @@ func (r *Runner) newsession(logger *ChanLogger) (*engine.Session, error) {
 	return engine.NewSession(config)
 }
 
+// maybeNewSession returns the session passed to NewRunnerWithSession, if
+// any, or a new session. The returned function closes the session if
+// we have created it and otherwise does nothing.
+func (r *Runner) maybeNewSession(logger *ChanLogger) (*engine.Session, func(), error) {
+	if r.session != nil {
+		return r.session, func() {}, nil
+	}
+	sess, err := r.newsession(logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sess, func() { sess.Close() }, nil
+}
+
 func (r *Runner) contextForExperiment(
 	ctx context.Context, builder *engine.ExperimentBuilder,
 ) context.Context {
@@ -111,6 +206,15 @@ func (cb *runnerCallbacks) OnProgress(percentage float64, message string) {
 	})
 }
 
+// OnTunnelBootstrapProgress emits the bootstrap progress of the session
+// tunnel, which is separate from the progress of the task.
+func (cb *runnerCallbacks) OnTunnelBootstrapProgress(percentage float64, message string) {
+	cb.emitter.Emit(statusTunnelBootstrap, EventStatusProgress{
+		Percentage: percentage,
+		Message:    message,
+	})
+}
+
 // Run runs the runner until completion. The context argument controls
 // when to stop when processing multiple inputs, as well as when to stop
 // experiments explicitly marked as interruptible.
@@ -121,14 +225,14 @@ func (r *Runner) Run(ctx context.Context) {
 		return
 	}
 	r.emitter.Emit(statusStarted, eventEmpty{})
-	sess, err := r.newsession(logger)
+	sess, closeSession, err := r.maybeNewSession(logger)
 	if err != nil {
 		r.emitter.EmitFailureStartup(err.Error())
 		return
 	}
 	endEvent := new(eventStatusEnd)
 	defer func() {
-		sess.Close()
+		closeSession()
 		r.emitter.Emit(statusEnd, endEvent)
 	}()
 
@@ -137,6 +241,18 @@ func (r *Runner) Run(ctx context.Context) {
 		r.emitter.EmitFailureStartup(err.Error())
 		return
 	}
+	if err := builder.SetOptionsGuessType(r.settings.ExperimentOptions); err != nil {
+		r.emitter.EmitFailureStartup(err.Error())
+		return
+	}
+
+	if r.settings.Options.Tunnel != "" {
+		logger.Infof("Starting %s tunnel... please, be patient", r.settings.Options.Tunnel)
+		if err := sess.MaybeStartTunnel(ctx, r.settings.Options.Tunnel); err != nil {
+			r.emitter.EmitFailureStartup(err.Error())
+			return
+		}
+	}
 
 	logger.Info("Looking up OONI backends... please, be patient")
 	if err := sess.MaybeLookupBackends(); err != nil {
@@ -162,10 +278,13 @@ func (r *Runner) Run(ctx context.Context) {
 	r.emitter.EmitStatusProgress(0.2, "geoip lookup")
 	r.emitter.EmitStatusProgress(0.3, "resolver lookup")
 	r.emitter.Emit(statusGeoIPLookup, eventStatusGeoIPLookup{
-		ProbeIP:          sess.ProbeIP(),
-		ProbeASN:         sess.ProbeASNString(),
-		ProbeCC:          sess.ProbeCC(),
-		ProbeNetworkName: sess.ProbeNetworkName(),
+		ProbeIP:              sess.ProbeIP(),
+		ProbeIPConfidence:    sess.ProbeIPConfidence(),
+		ProbeIPDisagreements: probeIPDisagreements(sess),
+		ProbeIPLookups:       sess.ProbeIPLookups(),
+		ProbeASN:             sess.ProbeASNString(),
+		ProbeCC:              sess.ProbeCC(),
+		ProbeNetworkName:     sess.ProbeNetworkName(),
 	})
 	r.emitter.Emit(statusResolverLookup, eventStatusResolverLookup{
 		ResolverASN:         sess.ResolverASNString(),
@@ -174,6 +293,14 @@ func (r *Runner) Run(ctx context.Context) {
 	})
 
 	builder.SetCallbacks(&runnerCallbacks{emitter: r.emitter})
+	if len(r.settings.Inputs) <= 0 && r.settings.loadsTestLists() &&
+		builder.InputPolicy() == engine.InputOrQueryTestLists {
+		logger.Info("Fetching the test lists... please, be patient")
+		if err := r.loadTestLists(ctx, sess, builder); err != nil {
+			r.emitter.EmitFailureStartup(err.Error())
+			return
+		}
+	}
 	if len(r.settings.Inputs) <= 0 {
 		switch builder.InputPolicy() {
 		case engine.InputOrQueryTestLists, engine.InputStrictlyRequired:
@@ -182,6 +309,23 @@ func (r *Runner) Run(ctx context.Context) {
 		}
 		r.settings.Inputs = append(r.settings.Inputs, "")
 	}
+	// Experiments such as run save and submit the measurement of each
+	// step on their own. We use a distinct submitter for the steps, so
+	// that each step ends up in the report of its own experiment.
+	sink := &runnerStepSink{emitter: r.emitter, logger: logger}
+	if builder.SupportsStepSink() {
+		if !r.settings.Options.NoCollector {
+			sink.submitter, err = sess.NewSubmitter(ctx)
+			if err != nil {
+				r.emitter.EmitFailureGeneric(failureReportCreate, err.Error())
+				return
+			}
+		}
+		if err := builder.SetStepSink(sink); err != nil {
+			r.emitter.EmitFailureStartup(err.Error())
+			return
+		}
+	}
 	experiment := builder.NewExperiment()
 	defer func() {
 		endEvent.DownloadedKB = experiment.KibiBytesReceived()
@@ -226,7 +370,8 @@ func (r *Runner) Run(ctx context.Context) {
 			break
 		}
 		logger.Infof("Starting measurement with index %d", idx)
-		r.emitter.Emit(statusMeasurementStart, eventMeasurementGeneric{
+		sink.idx, sink.input = int64(idx), input
+		r.emitter.Emit(statusMeasurementStart, EventMeasurementGeneric{
 			Idx:   int64(idx),
 			Input: input,
 		})
@@ -253,7 +398,7 @@ func (r *Runner) Run(ctx context.Context) {
 		}
 		m.AddAnnotations(r.settings.Annotations)
 		if err != nil {
-			r.emitter.Emit(failureMeasurement, eventMeasurementGeneric{
+			r.emitter.Emit(failureMeasurement, EventMeasurementGeneric{
 				Failure: err.Error(),
 				Idx:     int64(idx),
 				Input:   input,
@@ -262,7 +407,7 @@ func (r *Runner) Run(ctx context.Context) {
 		}
 		data, err := json.Marshal(m)
 		runtimex.PanicOnError(err, "measurement.MarshalJSON failed")
-		r.emitter.Emit(measurement, eventMeasurementGeneric{
+		r.emitter.Emit(measurement, EventMeasurementGeneric{
 			Idx:     int64(idx),
 			Input:   input,
 			JSONStr: string(data),
@@ -270,20 +415,83 @@ func (r *Runner) Run(ctx context.Context) {
 		if !r.settings.Options.NoCollector {
 			logger.Info("Submitting measurement... please, be patient")
 			err := experiment.SubmitAndUpdateMeasurement(m)
-			r.emitter.Emit(measurementSubmissionEventName(err), eventMeasurementGeneric{
+			r.emitter.Emit(measurementSubmissionEventName(err), EventMeasurementGeneric{
 				Idx:     int64(idx),
 				Input:   input,
 				JSONStr: string(data),
 				Failure: measurementSubmissionFailure(err),
 			})
 		}
-		r.emitter.Emit(statusMeasurementDone, eventMeasurementGeneric{
+		r.emitter.Emit(statusMeasurementDone, EventMeasurementGeneric{
 			Idx:   int64(idx),
 			Input: input,
 		})
 	}
 }
 
+// runnerStepSink emits the measurements of the steps of experiments,
+// such as run, that perform several measurements for each input and
+// submits them using its own submitter, if any.
+type runnerStepSink struct {
+	emitter   *EventEmitter
+	idx       int64
+	input     string
+	logger    *ChanLogger
+	submitter engine.Submitter
+}
+
+func (ss *runnerStepSink) SaveAndSubmit(ctx context.Context, m *model.Measurement) error {
+	var err error
+	if ss.submitter != nil {
+		ss.logger.Info("Submitting step measurement... please, be patient")
+		err = ss.submitter.Submit(ctx, m)
+	}
+	data, marshalErr := json.Marshal(m)
+	runtimex.PanicOnError(marshalErr, "measurement.MarshalJSON failed")
+	ss.emitter.Emit(measurement, EventMeasurementGeneric{
+		Idx:     ss.idx,
+		Input:   ss.input,
+		JSONStr: string(data),
+	})
+	if ss.submitter != nil {
+		ss.emitter.Emit(measurementSubmissionEventName(err), EventMeasurementGeneric{
+			Idx:     ss.idx,
+			Input:   ss.input,
+			JSONStr: string(data),
+			Failure: measurementSubmissionFailure(err),
+		})
+	}
+	return err
+}
+
+// loadTestLists loads the inputs from the test lists using the
+// categories and the limit specified in the settings.
+func (r *Runner) loadTestLists(
+	ctx context.Context, sess *engine.Session, builder *engine.ExperimentBuilder) error {
+	loader := engine.NewInputLoader(engine.InputLoaderConfig{
+		InputPolicy:   builder.InputPolicy(),
+		Session:       sess,
+		URLCategories: r.settings.URLCategories,
+		URLLimit:      r.settings.URLLimit,
+	})
+	inputs, err := loader.Load(ctx)
+	if err != nil {
+		return err
+	}
+	for _, input := range inputs {
+		r.settings.Inputs = append(r.settings.Inputs, input.URL)
+	}
+	return nil
+}
+
+func probeIPDisagreements(sess *engine.Session) []geolocate.IPLookupResult {
+	results := &geolocate.Results{
+		ProbeIP:        sess.ProbeIP(),
+		ProbeIPLookups: sess.ProbeIPLookups(),
+	}
+	return results.ProbeIPDisagreements()
+}
+
 func measurementSubmissionEventName(err error) string {
 	if err != nil {
 		return failureMeasurementSubmission
@@ -70,3 +70,129 @@ func TestRunnerMaybeLookupLocationFailure(t *testing.T) {
 		t.Fatal("unexpected number of events")
 	}
 }
+
+func TestRunnerMaybeNewSessionWithSharedSession(t *testing.T) {
+	sess := &engine.Session{}
+	r := NewRunnerWithSession(&Settings{}, make(chan *Event), sess)
+	got, closeSession, err := r.maybeNewSession(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != sess {
+		t.Fatal("we did not use the shared session")
+	}
+	closeSession() // must not close the shared session
+}
+
+func TestRunnerValidateSettings(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings Settings
+		shared   bool
+		failure  string
+	}{{
+		name:     "with valid settings",
+		settings: Settings{Version: 1},
+	}, {
+		name:     "with invalid version",
+		settings: Settings{},
+		failure:  FailureInvalidVersion,
+	}, {
+		name: "with valid proxy",
+		settings: Settings{Options: SettingsOptions{
+			Proxy: "socks5://127.0.0.1:9050",
+		}, Version: 1},
+	}, {
+		name: "with non-socks5 proxy",
+		settings: Settings{Options: SettingsOptions{
+			Proxy: "http://127.0.0.1:8080",
+		}, Version: 1},
+		failure: FailureInvalidProxy,
+	}, {
+		name: "with unparseable proxy",
+		settings: Settings{Options: SettingsOptions{
+			Proxy: "\t",
+		}, Version: 1},
+		failure: FailureInvalidProxy,
+	}, {
+		name: "with proxy and tunnel",
+		settings: Settings{Options: SettingsOptions{
+			Proxy:  "socks5://127.0.0.1:9050",
+			Tunnel: "psiphon",
+		}, Version: 1},
+		failure: FailureProxyAndTunnel,
+	}, {
+		name: "with proxy and shared session",
+		settings: Settings{Options: SettingsOptions{
+			Proxy: "socks5://127.0.0.1:9050",
+		}, Version: 1},
+		shared:  true,
+		failure: FailureProxyWithSharedSession,
+	}, {
+		name: "with valid tunnel",
+		settings: Settings{Options: SettingsOptions{
+			Tunnel: "psiphon",
+		}, Version: 1},
+	}, {
+		name: "with invalid tunnel",
+		settings: Settings{Options: SettingsOptions{
+			Tunnel: "antani",
+		}, Version: 1},
+		failure: FailureInvalidTunnel,
+	}, {
+		name: "with tunnel and shared session",
+		settings: Settings{Options: SettingsOptions{
+			Tunnel: "psiphon",
+		}, Version: 1},
+		shared:  true,
+		failure: FailureTunnelWithSharedSession,
+	}, {
+		name:     "with negative URL limit",
+		settings: Settings{URLLimit: -1, Version: 1},
+		failure:  FailureInvalidURLLimit,
+	}}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRunner(&tc.settings, make(chan *Event))
+			if tc.shared {
+				r.session = &engine.Session{}
+			}
+			if failure := r.validateSettings(); failure != tc.failure {
+				t.Fatalf("expected %q, got %q", tc.failure, failure)
+			}
+		})
+	}
+}
+
+func TestRunnerInvalidSettingsEmitsFailureStartup(t *testing.T) {
+	out := make(chan *Event)
+	settings := &Settings{
+		Options: SettingsOptions{Tunnel: "antani"},
+		Version: 1,
+	}
+	go func() {
+		NewRunner(settings, out).Run(context.Background())
+		close(out)
+	}()
+	var failures []string
+	for ev := range out {
+		if ev.Key == failureStartup {
+			failures = append(failures, ev.Value.(EventFailure).Failure)
+		}
+	}
+	if len(failures) != 1 || failures[0] != FailureInvalidTunnel {
+		t.Fatalf("unexpected failures: %+v", failures)
+	}
+}
+
+func TestSettingsLoadsTestLists(t *testing.T) {
+	if (&Settings{}).loadsTestLists() {
+		t.Fatal("expected false here")
+	}
+	if !(&Settings{URLLimit: 10}).loadsTestLists() {
+		t.Fatal("expected true here")
+	}
+	if !(&Settings{URLCategories: []string{"NEWS"}}).loadsTestLists() {
+		t.Fatal("expected true here")
+	}
+}
@@ -17,8 +17,15 @@ type Settings struct {
 	// https://git.io/Jv4Rv for the events names.
 	DisabledEvents []string `json:"disabled_events,omitempty"`
 
+	// ExperimentOptions contains the experiment options, e.g.,
+	// `{"TLSServerName": "example.com"}` for urlgetter. We guess the
+	// type of each option from its value, like the miniooni command
+	// line does. This field is an extension of MK's specification.
+	ExperimentOptions map[string]string `json:"experiment_options,omitempty"`
+
 	// Inputs contains the inputs. The task will fail if it
-	// requires input and you provide no input.
+	// requires input and you provide no input, unless you set
+	// URLCategories or URLLimit and the task can use test lists.
 	Inputs []string `json:"inputs,omitempty"`
 
 	// LogLevel contains the logs level. See https://git.io/Jv4Rv
@@ -44,10 +51,26 @@ type Settings struct {
 	// for iOS and does not work for Android.
 	TempDir string `json:"temp_dir"`
 
+	// URLCategories contains the categories of the URLs that we
+	// should fetch from the test lists API when there is no input. This
+	// field is an extension of MK's specification.
+	URLCategories []string `json:"url_categories,omitempty"`
+
+	// URLLimit is the maximum number of URLs that we should fetch
+	// from the test lists API when there is no input. A zero value
+	// means no limit. This field is an extension of MK's specification.
+	URLLimit int64 `json:"url_limit,omitempty"`
+
 	// Version indicates the version of this structure.
 	Version int64 `json:"version"`
 }
 
+// loadsTestLists returns whether the settings request loading the
+// input from the test lists when there is no input.
+func (s *Settings) loadsTestLists() bool {
+	return len(s.URLCategories) > 0 || s.URLLimit > 0
+}
+
 // SettingsOptions contains the settings options
 type SettingsOptions struct {
 	// MaxRuntime is the maximum runtime expressed in seconds. A negative
@@ -63,6 +86,11 @@ type SettingsOptions struct {
 	// ProbeServicesBaseURL contains the probe services base URL.
 	ProbeServicesBaseURL string `json:"probe_services_base_url,omitempty"`
 
+	// Proxy is the optional URL of the SOCKS5 proxy to use, e.g.,
+	// `socks5://127.0.0.1:9050`. You cannot use this option along
+	// with a shared session, nor along with Tunnel.
+	Proxy string `json:"proxy,omitempty"`
+
 	// SoftwareName is the software name. If this option is not
 	// present, then the library startup will fail.
 	SoftwareName string `json:"software_name,omitempty"`
@@ -70,4 +98,8 @@ type SettingsOptions struct {
 	// SoftwareVersion is the software version. If this option is not
 	// present, then the library startup will fail.
 	SoftwareVersion string `json:"software_version,omitempty"`
+
+	// Tunnel is the optional name of the tunnel to use, e.g.,
+	// `psiphon` or `tor`. You cannot use this option along with Proxy.
+	Tunnel string `json:"tunnel,omitempty"`
 }
@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// OptionsSchema is a JSON Schema (draft-07) describing the options of an
+// experiment. We generate it from the fields of the experiment config
+// tagged with `ooni:"..."`, which are the options that you can set.
+//
+// The optional `ooni_enum:"..."` tag contains the comma separated list
+// of the values allowed for a string option. The optional `ooni_pattern:"..."`
+// tag contains a regular expression that a string option must match. For
+// both tags, the empty string is always allowed, because it means that
+// the experiment should use its default.
+type OptionsSchema struct {
+	Schema               string                  `json:"$schema"`
+	Title                string                  `json:"title"`
+	Type                 string                  `json:"type"`
+	Properties           map[string]OptionSchema `json:"properties"`
+	AdditionalProperties bool                    `json:"additionalProperties"`
+}
+
+// OptionSchema is the JSON Schema of a single option.
+type OptionSchema struct {
+	Default     interface{} `json:"default"`
+	Description string      `json:"description"`
+	Enum        []string    `json:"enum,omitempty"`
+	Pattern     string      `json:"pattern,omitempty"`
+	Type        string      `json:"type"`
+}
+
+// ErrInvalidOptionValue indicates that the value of an option is
+// not one of the allowed values or does not match the pattern.
+var ErrInvalidOptionValue = errors.New("invalid option value")
+
+// jsonSchemaTypes maps the types of settable options to JSON Schema types.
+var jsonSchemaTypes = map[reflect.Kind]string{
+	reflect.Bool:   "boolean",
+	reflect.Int64:  "integer",
+	reflect.String: "string",
+}
+
+// OptionsSchema returns the JSON Schema of the experiment options, where
+// the defaults are the values that options have when we call this method.
+func (b *ExperimentBuilder) OptionsSchema() (*OptionsSchema, error) {
+	ptrinfo := reflect.ValueOf(b.config)
+	if ptrinfo.Kind() != reflect.Ptr {
+		return nil, errors.New("config is not a pointer")
+	}
+	structinfo := ptrinfo.Elem()
+	if structinfo.Kind() != reflect.Struct {
+		return nil, errors.New("config is not a struct")
+	}
+	schema := &OptionsSchema{
+		Schema:     "http://json-schema.org/draft-07/schema#",
+		Title:      structinfo.Type().String(),
+		Type:       "object",
+		Properties: make(map[string]OptionSchema),
+	}
+	for i := 0; i < structinfo.NumField(); i++ {
+		field := structinfo.Type().Field(i)
+		doc := field.Tag.Get("ooni")
+		jsonType, found := jsonSchemaTypes[field.Type.Kind()]
+		if doc == "" || !found {
+			continue // not settable from command line
+		}
+		option := OptionSchema{
+			Default:     structinfo.Field(i).Interface(),
+			Description: doc,
+			Type:        jsonType,
+		}
+		if enum := optionEnum(field); len(enum) > 0 {
+			option.Enum = append([]string{""}, enum...)
+		}
+		if pattern := field.Tag.Get("ooni_pattern"); pattern != "" {
+			option.Pattern = optionPattern(pattern)
+		}
+		schema.Properties[field.Name] = option
+	}
+	return schema, nil
+}
+
+// ExperimentOptionsSchema returns the JSON Schema of the options of the
+// experiment with the given name, without the need of a session.
+func ExperimentOptionsSchema(name string) (*OptionsSchema, error) {
+	experimentsMu.Lock()
+	factory, _ := experimentsByName[canonicalizeExperimentName(name)]
+	experimentsMu.Unlock()
+	if factory == nil {
+		return nil, fmt.Errorf("no such experiment: %s", name)
+	}
+	// Implementation note: a nil session is fine here because factories
+	// only use the session when we create the experiment.
+	return factory(nil).OptionsSchema()
+}
+
+func optionEnum(field reflect.StructField) []string {
+	enum := field.Tag.Get("ooni_enum")
+	if enum == "" {
+		return nil
+	}
+	return strings.Split(enum, ",")
+}
+
+// optionPattern returns the pattern that also allows the empty string.
+func optionPattern(pattern string) string {
+	return "^$|" + pattern
+}
+
+// validateOptionString checks whether value is valid for the string
+// option key according to the `ooni_enum` and `ooni_pattern` tags.
+func validateOptionString(config interface{}, key, value string) error {
+	field, found := reflect.TypeOf(config).Elem().FieldByName(key)
+	if !found {
+		return errors.New("no such field")
+	}
+	if enum := optionEnum(field); len(enum) > 0 && value != "" {
+		var allowed bool
+		for _, v := range enum {
+			allowed = allowed || v == value
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s must be one of: %s", ErrInvalidOptionValue,
+				key, strings.Join(enum, ", "))
+		}
+	}
+	if pattern := field.Tag.Get("ooni_pattern"); pattern != "" {
+		re, err := regexp.Compile(optionPattern(pattern))
+		if err != nil {
+			return fmt.Errorf("%w: invalid pattern for %s: %s",
+				ErrInvalidOptionValue, key, err.Error())
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("%w: %s must match: %s", ErrInvalidOptionValue,
+				key, pattern)
+		}
+	}
+	return nil
+}
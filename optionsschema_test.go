@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ooni/probe-engine/experiment/urlgetter"
+)
+
+func TestOptionsSchema(t *testing.T) {
+	t.Run("when config is not a pointer", func(t *testing.T) {
+		b := &ExperimentBuilder{config: 17}
+		if _, err := b.OptionsSchema(); err == nil {
+			t.Fatal("expected an error here")
+		}
+	})
+	t.Run("when config is not a struct", func(t *testing.T) {
+		number := 17
+		b := &ExperimentBuilder{config: &number}
+		if _, err := b.OptionsSchema(); err == nil {
+			t.Fatal("expected an error here")
+		}
+	})
+	t.Run("for the example experiment", func(t *testing.T) {
+		schema, err := ExperimentOptionsSchema("example")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(schema.Properties) != 3 {
+			t.Fatal("unexpected number of properties")
+		}
+		message := schema.Properties["Message"]
+		if message.Type != "string" || message.Default != "Good day from the example experiment!" {
+			t.Fatalf("unexpected Message schema: %+v", message)
+		}
+		if schema.Properties["ReturnError"].Type != "boolean" {
+			t.Fatal("unexpected ReturnError type")
+		}
+		if schema.Properties["SleepTime"].Type != "integer" {
+			t.Fatal("unexpected SleepTime type")
+		}
+	})
+	t.Run("for the urlgetter experiment", func(t *testing.T) {
+		schema, err := ExperimentOptionsSchema("urlgetter")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, found := schema.Properties["CertPool"]; found {
+			t.Fatal("CertPool should not be an option")
+		}
+		version := schema.Properties["TLSVersion"]
+		if len(version.Enum) != 6 || version.Enum[0] != "" {
+			t.Fatalf("unexpected TLSVersion enum: %+v", version.Enum)
+		}
+		if schema.Properties["Method"].Pattern != "^$|^[A-Z]+$" {
+			t.Fatal("unexpected Method pattern")
+		}
+	})
+	t.Run("for a nonexistent experiment", func(t *testing.T) {
+		if _, err := ExperimentOptionsSchema("antani"); err == nil {
+			t.Fatal("expected an error here")
+		}
+	})
+}
+
+func TestSetOptionStringValidation(t *testing.T) {
+	config := &urlgetter.Config{}
+	b := &ExperimentBuilder{config: config}
+	tests := []struct {
+		key   string
+		value string
+		err   error
+	}{
+		{key: "TLSVersion", value: "TLSv1.3", err: nil},
+		{key: "TLSVersion", value: "", err: nil},
+		{key: "TLSVersion", value: "SSLv3", err: ErrInvalidOptionValue},
+		{key: "Method", value: "HEAD", err: nil},
+		{key: "Method", value: "", err: nil},
+		{key: "Method", value: "head", err: ErrInvalidOptionValue},
+		{key: "UserAgent", value: "antani/1.0", err: nil},
+	}
+	for _, tt := range tests {
+		err := b.SetOptionString(tt.key, tt.value)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s=%s: not the error we expected: %+v", tt.key, tt.value, err)
+		}
+	}
+	if config.TLSVersion != "" || config.Method != "" {
+		t.Fatal("we did not restore the default values")
+	}
+	if config.UserAgent != "antani/1.0" {
+		t.Fatal("we did not set UserAgent")
+	}
+}
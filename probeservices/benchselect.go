@@ -62,6 +62,24 @@ func OnlyFallbacks(in []model.Service) (out []model.Service) {
 	return
 }
 
+// WithFronting returns a copy of the input services to which we append a
+// "cloudfront" service for each fronting configuration. Because TryAll
+// only tries the fallbacks when all the HTTPS services fail, this means
+// we automatically use fronting only when the direct endpoints fail. The
+// fronting IPs, if any, are not part of the returned services, hence the
+// session should arrange for resolving the fronts to such IPs.
+func WithFronting(in []model.Service, fronting []model.Fronting) (out []model.Service) {
+	out = append(out, in...)
+	for _, entry := range fronting {
+		out = append(out, model.Service{
+			Address: "https://" + entry.Host,
+			Front:   entry.Front,
+			Type:    "cloudfront",
+		})
+	}
+	return
+}
+
 // Candidate is a candidate probe service.
 type Candidate struct {
 	// Duration is the time it took to access the service.
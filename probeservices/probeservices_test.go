@@ -295,6 +295,44 @@ func TestOnlyFallbacks(t *testing.T) {
 	}
 }
 
+func TestWithFronting(t *testing.T) {
+	in := []model.Service{{
+		Type:    "https",
+		Address: "https://ams-ps-nonexistent.ooni.io",
+	}}
+	fronting := []model.Fronting{{
+		Front: "a0.awsstatic.com",
+		Host:  "dkyhjv0wpi2dk.cloudfront.net",
+		IPs:   []string{"13.35.7.1"},
+	}}
+	expect := []model.Service{{
+		Type:    "https",
+		Address: "https://ams-ps-nonexistent.ooni.io",
+	}, {
+		Front:   "a0.awsstatic.com",
+		Type:    "cloudfront",
+		Address: "https://dkyhjv0wpi2dk.cloudfront.net",
+	}}
+	out := probeservices.WithFronting(in, fronting)
+	diff := cmp.Diff(out, expect)
+	if diff != "" {
+		t.Fatal(diff)
+	}
+	if len(in) != 1 {
+		t.Fatal("we should not modify the input")
+	}
+	client, err := probeservices.NewClient(&mockable.Session{}, out[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Host != "dkyhjv0wpi2dk.cloudfront.net" {
+		t.Fatal("not the Host we expected")
+	}
+	if client.BaseURL != "https://a0.awsstatic.com" {
+		t.Fatal("not the BaseURL we expected")
+	}
+}
+
 func TestTryAllCanceledContext(t *testing.T) {
 	// put onion first so we also verify that we sort the endpoints
 	in := []model.Service{{
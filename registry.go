@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ooni/probe-engine/model"
+)
+
+// ExperimentFactory allows code outside of this repository to add
+// experiments to the engine without forking it.
+type ExperimentFactory struct {
+	// NewConfig returns a pointer to a new config struct filled with
+	// default values. Like for the experiments in this repository, we
+	// use the fields tagged with `ooni:"..."` as the experiment options.
+	NewConfig func() interface{}
+
+	// NewMeasurer creates a new measurer given the config that was
+	// returned by NewConfig and later modified by setting options.
+	NewMeasurer func(config interface{}) model.ExperimentMeasurer
+}
+
+var (
+	// experimentsMu protects experimentsByName and thirdPartyExperiments.
+	experimentsMu sync.Mutex
+
+	// thirdPartyExperiments contains the names of the
+	// experiments registered using RegisterExperiment.
+	thirdPartyExperiments = make(map[string]bool)
+)
+
+var (
+	// ErrExperimentAlreadyRegistered indicates that there is
+	// already an experiment with the same name.
+	ErrExperimentAlreadyRegistered = errors.New("experiment already registered")
+
+	// ErrInvalidExperimentName indicates that the name of the
+	// experiment is empty or is not in snake case.
+	ErrInvalidExperimentName = errors.New("invalid experiment name")
+
+	// ErrInvalidExperimentFactory indicates that the factory
+	// does not contain all the required functions.
+	ErrInvalidExperimentFactory = errors.New("invalid experiment factory")
+
+	// ErrInvalidInputPolicy indicates that the input policy
+	// is not one of the policies we know of.
+	ErrInvalidInputPolicy = errors.New("invalid input policy")
+
+	// ErrInvalidExperimentConfig indicates that the config
+	// is not usable for setting the experiment options.
+	ErrInvalidExperimentConfig = errors.New("invalid experiment config")
+)
+
+// RegisterExperiment registers a third-party experiment with the given
+// name, factory, input policy, and interruptible flag (see the documentation
+// of ExperimentBuilder.Interruptible). You should call this function before
+// creating a session, for example from an init function. Once registered,
+// you can run the experiment like any other experiment.
+//
+// We return an error if the name is not in snake case, if the name is
+// already in use, if the input policy is unknown, or if the config returned
+// by the factory is not a pointer to struct whose options are all bool,
+// int64, or string fields (the types we know how to set).
+func RegisterExperiment(
+	name string, factory ExperimentFactory,
+	inputPolicy InputPolicy, interruptible bool,
+) error {
+	if name == "" || canonicalizeExperimentName(name) != name {
+		return fmt.Errorf("%w: %s", ErrInvalidExperimentName, name)
+	}
+	if factory.NewConfig == nil || factory.NewMeasurer == nil {
+		return ErrInvalidExperimentFactory
+	}
+	switch inputPolicy {
+	case InputOrQueryTestLists, InputStrictlyRequired, InputOptional, InputNone:
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidInputPolicy, inputPolicy)
+	}
+	if err := validateExperimentConfig(factory.NewConfig()); err != nil {
+		return err
+	}
+	if measurer := factory.NewMeasurer(factory.NewConfig()); measurer.ExperimentName() != name {
+		return fmt.Errorf("%w: measurer name is %s", ErrInvalidExperimentName,
+			measurer.ExperimentName())
+	}
+	experimentsMu.Lock()
+	defer experimentsMu.Unlock()
+	if _, found := experimentsByName[name]; found {
+		return fmt.Errorf("%w: %s", ErrExperimentAlreadyRegistered, name)
+	}
+	experimentsByName[name] = func(session *Session) *ExperimentBuilder {
+		return &ExperimentBuilder{
+			build: func(config interface{}) *Experiment {
+				return NewExperiment(session, factory.NewMeasurer(config))
+			},
+			config:        factory.NewConfig(),
+			inputPolicy:   inputPolicy,
+			interruptible: interruptible,
+		}
+	}
+	thirdPartyExperiments[name] = true
+	return nil
+}
+
+// validateExperimentConfig uses the same reflection used to list and
+// set the options to ensure that all options have a settable type.
+func validateExperimentConfig(config interface{}) error {
+	options, err := (&ExperimentBuilder{config: config}).Options()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidExperimentConfig, err.Error())
+	}
+	for name, info := range options {
+		if info.Doc == "" {
+			continue // not settable from command line
+		}
+		switch info.Type {
+		case "bool", "int64", "string":
+		default:
+			return fmt.Errorf("%w: option %s has unsupported type %s",
+				ErrInvalidExperimentConfig, name, info.Type)
+		}
+	}
+	return nil
+}
+
+// ThirdPartyExperiments returns the sorted names of the
+// experiments registered using RegisterExperiment.
+func ThirdPartyExperiments() []string {
+	experimentsMu.Lock()
+	defer experimentsMu.Unlock()
+	var names []string
+	for key := range thirdPartyExperiments {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return names
+}
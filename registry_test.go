@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/ooni/probe-engine/experiment/example"
+	"github.com/ooni/probe-engine/model"
+)
+
+func newExampleFactory(name string) ExperimentFactory {
+	return ExperimentFactory{
+		NewConfig: func() interface{} {
+			return &example.Config{Message: "hello from a third-party experiment"}
+		},
+		NewMeasurer: func(config interface{}) model.ExperimentMeasurer {
+			return example.NewExperimentMeasurer(*config.(*example.Config), name)
+		},
+	}
+}
+
+func unregisterExperiment(name string) {
+	experimentsMu.Lock()
+	defer experimentsMu.Unlock()
+	delete(experimentsByName, name)
+	delete(thirdPartyExperiments, name)
+}
+
+func TestRegisterExperimentSuccess(t *testing.T) {
+	const name = "third_party_example"
+	err := RegisterExperiment(name, newExampleFactory(name), InputNone, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unregisterExperiment(name)
+	var found bool
+	for _, v := range AllExperiments() {
+		found = found || v == name
+	}
+	if !found {
+		t.Fatal("experiment not listed by AllExperiments")
+	}
+	if names := ThirdPartyExperiments(); len(names) != 1 || names[0] != name {
+		t.Fatal("unexpected ThirdPartyExperiments result")
+	}
+	sess := &Session{logger: log.Log}
+	builder, err := sess.NewExperimentBuilder("ThirdPartyExample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if builder.InputPolicy() != InputNone || !builder.Interruptible() {
+		t.Fatal("unexpected builder settings")
+	}
+	if err := builder.SetOptionGuessType("SleepTime", "0"); err != nil {
+		t.Fatal(err)
+	}
+	exp := builder.NewExperiment()
+	if exp.Name() != name {
+		t.Fatal("unexpected experiment name")
+	}
+	err = RegisterExperiment(name, newExampleFactory(name), InputNone, true)
+	if !errors.Is(err, ErrExperimentAlreadyRegistered) {
+		t.Fatal("not the error we expected", err)
+	}
+}
+
+func TestRegisterExperimentFailures(t *testing.T) {
+	type fiction struct {
+		Values []string `ooni:"list of values"`
+	}
+	tests := []struct {
+		name        string
+		expName     string
+		factory     ExperimentFactory
+		inputPolicy InputPolicy
+		err         error
+	}{{
+		name:        "with empty name",
+		expName:     "",
+		factory:     newExampleFactory(""),
+		inputPolicy: InputNone,
+		err:         ErrInvalidExperimentName,
+	}, {
+		name:        "with name not in snake case",
+		expName:     "ThirdParty",
+		factory:     newExampleFactory("ThirdParty"),
+		inputPolicy: InputNone,
+		err:         ErrInvalidExperimentName,
+	}, {
+		name:        "with already existing experiment",
+		expName:     "example",
+		factory:     newExampleFactory("example"),
+		inputPolicy: InputNone,
+		err:         ErrExperimentAlreadyRegistered,
+	}, {
+		name:        "with measurer name mismatch",
+		expName:     "third_party",
+		factory:     newExampleFactory("antani"),
+		inputPolicy: InputNone,
+		err:         ErrInvalidExperimentName,
+	}, {
+		name:        "with incomplete factory",
+		expName:     "third_party",
+		factory:     ExperimentFactory{},
+		inputPolicy: InputNone,
+		err:         ErrInvalidExperimentFactory,
+	}, {
+		name:        "with unknown input policy",
+		expName:     "third_party",
+		factory:     newExampleFactory("third_party"),
+		inputPolicy: InputPolicy("antani"),
+		err:         ErrInvalidInputPolicy,
+	}, {
+		name:    "with config that is not a pointer",
+		expName: "third_party",
+		factory: ExperimentFactory{
+			NewConfig:   func() interface{} { return example.Config{} },
+			NewMeasurer: newExampleFactory("third_party").NewMeasurer,
+		},
+		inputPolicy: InputNone,
+		err:         ErrInvalidExperimentConfig,
+	}, {
+		name:    "with option having unsupported type",
+		expName: "third_party",
+		factory: ExperimentFactory{
+			NewConfig:   func() interface{} { return &fiction{} },
+			NewMeasurer: newExampleFactory("third_party").NewMeasurer,
+		},
+		inputPolicy: InputNone,
+		err:         ErrInvalidExperimentConfig,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RegisterExperiment(tt.expName, tt.factory, tt.inputPolicy, false)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("not the error we expected: %+v", err)
+			}
+		})
+	}
+	if len(ThirdPartyExperiments()) != 0 {
+		t.Fatal("we should not have registered any experiment")
+	}
+}
@@ -17,17 +17,18 @@ const (
 // ResourceInfo contains information on a resource.
 type ResourceInfo struct {
 	// URLPath is the resource's URL path.
-	URLPath string
+	URLPath string `json:"url_path"`
 
 	// GzSHA256 is used to validate the downloaded file.
-	GzSHA256 string
+	GzSHA256 string `json:"gz_sha256"`
 
 	// SHA256 is used to check whether the assets file
 	// stored locally is still up-to-date.
-	SHA256 string
+	SHA256 string `json:"sha256"`
 }
 
-// All contains info on all known assets.
+// All contains info on all known assets. We use it when we cannot
+// use a signed manifest, e.g., because we don't have its public key.
 var All = map[string]ResourceInfo{
 	"asn.mmdb": {
 		URLPath:  "/ooni/probe-assets/releases/download/20210112220115/asn.mmdb.gz",
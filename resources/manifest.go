@@ -0,0 +1,263 @@
+package resources
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+const (
+	// ManifestURLPath is the default URL path of the signed manifest.
+	ManifestURLPath = "/ooni/probe-assets/releases/latest/download/manifest.json"
+
+	// manifestName is the name of the verified manifest in WorkDir.
+	manifestName = "manifest.json"
+
+	// maxManifestSize is the maximum size of the manifest we accept.
+	maxManifestSize = 1 << 20
+)
+
+var (
+	// ErrInvalidSignature indicates that the manifest signature is not valid.
+	ErrInvalidSignature = errors.New("resources: invalid manifest signature")
+
+	// ErrManifestRollback indicates that the manifest is older than the
+	// one we already have or than the compiled-in resources.
+	ErrManifestRollback = errors.New("resources: manifest version is too old")
+)
+
+// Manifest lists the resources and their hashes.
+type Manifest struct {
+	// Resources maps a resource name to its info.
+	Resources map[string]ResourceInfo `json:"resources"`
+
+	// Version is the manifest version. It must be monotonically increasing
+	// and not smaller than the compiled-in Version.
+	Version int64 `json:"version"`
+}
+
+// SignedManifest is the manifest as served by the assets repository.
+type SignedManifest struct {
+	// Manifest is the serialized Manifest. We verify the signature over
+	// these exact bytes, so we keep them as they are.
+	Manifest json.RawMessage `json:"manifest"`
+
+	// Signature is the base64 encoded ed25519 signature of Manifest.
+	Signature string `json:"signature"`
+}
+
+// Sign signs the manifest using the given private key. We use this
+// function to publish new manifests and in tests.
+func Sign(m *Manifest, key ed25519.PrivateKey) ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(SignedManifest{
+		Manifest:  data,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)),
+	})
+}
+
+// Verify parses a signed manifest and verifies its signature.
+func Verify(data []byte, key ed25519.PublicKey) (*Manifest, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("resources: invalid public key size")
+	}
+	var sm SignedManifest
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return nil, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(sm.Signature)
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(key, sm.Manifest, signature) {
+		return nil, ErrInvalidSignature
+	}
+	var m Manifest
+	if err := json.Unmarshal(sm.Manifest, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+var (
+	typesMu sync.Mutex
+	types   = map[string]func(data []byte) error{
+		ASNDatabaseName:     validateMMDB,
+		CountryDatabaseName: validateMMDB,
+	}
+)
+
+// Register registers an additional resource type. A manifest can only
+// install resources whose name has been registered, so that we never
+// write files we don't know how to use. The validate function checks
+// the uncompressed resource before we install it. It may be nil.
+func Register(name string, validate func(data []byte) error) error {
+	if name == "" || name == manifestName || name != filepath.Base(name) ||
+		strings.HasPrefix(name, ".") {
+		return fmt.Errorf("resources: invalid resource name: %s", name)
+	}
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	if _, found := types[name]; found {
+		return fmt.Errorf("resources: already registered: %s", name)
+	}
+	types[name] = validate
+	return nil
+}
+
+// lookupType returns the validate function of name and whether
+// we have registered such resource type.
+func lookupType(name string) (func(data []byte) error, bool) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	validate, found := types[name]
+	return validate, found
+}
+
+func validateMMDB(data []byte) error {
+	db, err := geoip2.FromBytes(data)
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+// InstalledVersion returns the version of the resources in workDir, which
+// is the version of the manifest we have installed, if any, and otherwise
+// the compiled-in Version. We do not verify the manifest signature here
+// because we already did that before saving it.
+func InstalledVersion(workDir string) int64 {
+	data, err := ioutil.ReadFile(filepath.Join(workDir, manifestName))
+	if err != nil {
+		return Version
+	}
+	var sm SignedManifest
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return Version
+	}
+	var m Manifest
+	if err := json.Unmarshal(sm.Manifest, &m); err != nil || m.Version < Version {
+		return Version
+	}
+	return m.Version
+}
+
+// manifestUpdate is a verified manifest that we have fetched. We save
+// it only after we have installed its resources, so that a failed install
+// does not advance the installed version.
+type manifestUpdate struct {
+	data []byte
+	etag string
+}
+
+// installedManifest returns the verified manifest we have installed
+// or nil if we have not installed any valid manifest.
+func (c *Client) installedManifest() *Manifest {
+	data, err := ioutil.ReadFile(filepath.Join(c.WorkDir, manifestName))
+	if err != nil {
+		return nil
+	}
+	current, err := Verify(data, c.ManifestPublicKey)
+	if err != nil {
+		c.Logger.Warnf("resources: ignoring saved manifest: %s", err.Error())
+		return nil
+	}
+	return current
+}
+
+// loadManifest returns the verified manifest to use, given the current
+// manifest, which may be nil, and the update to save once we have installed
+// its resources, which is nil if current is still the latest manifest. We
+// send the ETag of the current manifest, if any, such that the server can
+// tell us that we already have the latest manifest.
+func (c *Client) loadManifest(
+	ctx context.Context, current *Manifest) (*Manifest, *manifestUpdate, error) {
+	fullpath := filepath.Join(c.WorkDir, manifestName)
+	etag := ""
+	if current != nil {
+		if data, err := ioutil.ReadFile(fullpath + ".etag"); err == nil {
+			etag = strings.TrimSpace(string(data))
+		}
+	}
+	data, newEtag, err := c.fetchManifest(ctx, etag)
+	if err != nil {
+		return nil, nil, err
+	}
+	if data == nil {
+		c.Logger.Debug("resources: manifest not modified")
+		return current, nil, nil
+	}
+	m, err := Verify(data, c.ManifestPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m.Version < Version || (current != nil && m.Version < current.Version) {
+		return nil, nil, ErrManifestRollback
+	}
+	return m, &manifestUpdate{data: data, etag: newEtag}, nil
+}
+
+// saveManifest saves the manifest and its ETag into WorkDir.
+func (c *Client) saveManifest(update *manifestUpdate) error {
+	fullpath := filepath.Join(c.WorkDir, manifestName)
+	if err := writeFileAtomic(fullpath, update.data); err != nil {
+		return err
+	}
+	if update.etag != "" {
+		return writeFileAtomic(fullpath+".etag", []byte(update.etag))
+	}
+	err := os.Remove(fullpath + ".etag")
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// fetchManifest fetches the signed manifest. It returns a nil
+// slice if the server says that the manifest is not modified.
+func (c *Client) fetchManifest(ctx context.Context, etag string) ([]byte, string, error) {
+	URL := c.ManifestURL
+	if URL == "" {
+		URL = strings.TrimSuffix(c.baseURL(), "/") + ManifestURLPath
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	c.Logger.Debugf("resources: GET %s", URL)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 304 && etag != "" {
+		return nil, "", nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, "", fmt.Errorf("resources: manifest request failed: %d", resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
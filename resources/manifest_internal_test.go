@@ -0,0 +1,308 @@
+package resources
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/apex/log"
+)
+
+const testResourceName = "testlist.txt"
+
+var errBadResource = errors.New("bad resource")
+
+func init() {
+	err := Register(testResourceName, func(data []byte) error {
+		if bytes.Equal(data, []byte("bad")) {
+			return errBadResource
+		}
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// fakeAssets is a fake assets repository.
+type fakeAssets struct {
+	etag     string
+	files    map[string][]byte
+	manifest []byte
+	mu       sync.Mutex
+	notmod   int
+}
+
+func (fa *fakeAssets) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	if req.URL.Path == ManifestURLPath {
+		if fa.etag != "" && req.Header.Get("If-None-Match") == fa.etag {
+			fa.notmod++
+			w.WriteHeader(304)
+			return
+		}
+		w.Header().Set("ETag", fa.etag)
+		w.Write(fa.manifest)
+		return
+	}
+	data, found := fa.files[req.URL.Path]
+	if !found {
+		w.WriteHeader(404)
+		return
+	}
+	w.Write(data)
+}
+
+func (fa *fakeAssets) publish(t *testing.T, key ed25519.PrivateKey, version int64, content string) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	gzw.Write([]byte(content))
+	gzw.Close()
+	URLPath := fmt.Sprintf("/%d/%s.gz", version, testResourceName)
+	data, err := Sign(&Manifest{
+		Resources: map[string]ResourceInfo{
+			testResourceName: {
+				URLPath:  URLPath,
+				GzSHA256: fmt.Sprintf("%x", sha256.Sum256(buf.Bytes())),
+				SHA256:   fmt.Sprintf("%x", sha256.Sum256([]byte(content))),
+			},
+			"unregistered.txt": {
+				URLPath: "/nonexistent",
+			},
+		},
+		Version: version,
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+	if fa.files == nil {
+		fa.files = make(map[string][]byte)
+	}
+	fa.files[URLPath] = buf.Bytes()
+	fa.manifest = data
+	fa.etag = fmt.Sprintf(`"%d"`, version)
+}
+
+func newTestClient(t *testing.T, URL string, key ed25519.PublicKey) *Client {
+	tempdir, err := ioutil.TempDir("", "ooniprobe-engine-resources-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Client{
+		BaseURL:           URL,
+		HTTPClient:        http.DefaultClient,
+		Logger:            log.Log,
+		ManifestPublicKey: key,
+		UserAgent:         "ooniprobe-engine/0.1.0",
+		WorkDir:           tempdir,
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Sign(&Manifest{Version: Version}, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Verify(data, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != Version {
+		t.Fatal("unexpected version")
+	}
+	otherpub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(data, otherpub); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatal("not the error we expected", err)
+	}
+	if _, err := Verify(data, nil); err == nil {
+		t.Fatal("expected an error here")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := Register(testResourceName, nil); err == nil {
+		t.Fatal("expected an error here")
+	}
+	for _, name := range []string{"", "../x", "a/b", ".hidden", manifestName} {
+		if err := Register(name, nil); err == nil {
+			t.Fatal("expected an error for", name)
+		}
+	}
+}
+
+func TestEnsureWithManifest(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fa := &fakeAssets{}
+	fa.publish(t, priv, Version+1, "first")
+	server := httptest.NewServer(fa)
+	defer server.Close()
+	client := newTestClient(t, server.URL, pub)
+	defer os.RemoveAll(client.WorkDir)
+	fullpath := filepath.Join(client.WorkDir, testResourceName)
+	if err := client.Ensure(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(fullpath); err != nil || string(data) != "first" {
+		t.Fatal("unexpected content", string(data), err)
+	}
+	if InstalledVersion(client.WorkDir) != Version+1 {
+		t.Fatal("unexpected installed version")
+	}
+	// the second round should use a conditional request
+	if err := client.Ensure(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fa.notmod != 1 {
+		t.Fatal("did not use a conditional request")
+	}
+	// a newer manifest should replace the resource
+	fa.publish(t, priv, Version+2, "second")
+	if err := client.Ensure(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(fullpath); err != nil || string(data) != "second" {
+		t.Fatal("unexpected content", string(data), err)
+	}
+	// an invalid resource should not replace the current one
+	fa.publish(t, priv, Version+3, "bad")
+	if err := client.Ensure(context.Background()); !errors.Is(err, errBadResource) {
+		t.Fatal("not the error we expected", err)
+	}
+	if data, err := ioutil.ReadFile(fullpath); err != nil || string(data) != "second" {
+		t.Fatal("unexpected content", string(data), err)
+	}
+	if _, err := os.Stat(fullpath + ".new"); !os.IsNotExist(err) {
+		t.Fatal("staging file not removed")
+	}
+	// a failed install should not advance the installed version
+	if InstalledVersion(client.WorkDir) != Version+2 {
+		t.Fatal("unexpected installed version")
+	}
+}
+
+func TestEnsureWithInvalidManifestKeepsInstalledResources(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherpriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fa := &fakeAssets{}
+	fa.publish(t, priv, Version+2, "second")
+	server := httptest.NewServer(fa)
+	defer server.Close()
+	client := newTestClient(t, server.URL, pub)
+	defer os.RemoveAll(client.WorkDir)
+	fullpath := filepath.Join(client.WorkDir, testResourceName)
+	if err := client.Ensure(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// an invalid manifest should neither replace the resources we have
+	// installed nor cause us to fall back to the compiled-in ones
+	fa.publish(t, otherpriv, Version+3, "third")
+	if err := client.Ensure(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(fullpath); err != nil || string(data) != "second" {
+		t.Fatal("unexpected content", string(data), err)
+	}
+	if InstalledVersion(client.WorkDir) != Version+2 {
+		t.Fatal("unexpected installed version")
+	}
+}
+
+func TestLoadManifestRejectsRollback(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fa := &fakeAssets{}
+	fa.publish(t, priv, Version+2, "first")
+	server := httptest.NewServer(fa)
+	defer server.Close()
+	client := newTestClient(t, server.URL, pub)
+	defer os.RemoveAll(client.WorkDir)
+	if err := client.Ensure(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	fa.publish(t, priv, Version+1, "older")
+	current := client.installedManifest()
+	if current == nil || current.Version != Version+2 {
+		t.Fatal("unexpected installed manifest")
+	}
+	if _, _, err := client.loadManifest(context.Background(), current); !errors.Is(err, ErrManifestRollback) {
+		t.Fatal("not the error we expected", err)
+	}
+	if InstalledVersion(client.WorkDir) != Version+2 {
+		t.Fatal("unexpected installed version")
+	}
+}
+
+func TestLoadManifestInvalidSignature(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherpriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fa := &fakeAssets{}
+	fa.publish(t, otherpriv, Version+1, "first")
+	server := httptest.NewServer(fa)
+	defer server.Close()
+	client := newTestClient(t, server.URL, pub)
+	defer os.RemoveAll(client.WorkDir)
+	if _, _, err := client.loadManifest(context.Background(), nil); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatal("not the error we expected", err)
+	}
+}
+
+func TestInstallRollback(t *testing.T) {
+	client := newTestClient(t, "", nil)
+	defer os.RemoveAll(client.WorkDir)
+	first := filepath.Join(client.WorkDir, "first")
+	if err := ioutil.WriteFile(first, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(first+".new", []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	second := filepath.Join(client.WorkDir, "second")
+	err := client.install([]stagedResource{
+		{fullpath: first, stagingpath: first + ".new"},
+		{fullpath: second, stagingpath: second + ".new"}, // does not exist
+	})
+	if err == nil {
+		t.Fatal("expected an error here")
+	}
+	if data, err := ioutil.ReadFile(first); err != nil || string(data) != "old" {
+		t.Fatal("did not rollback", string(data), err)
+	}
+}
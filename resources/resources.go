@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"fmt"
 	"io"
@@ -19,12 +20,27 @@ import (
 
 // Client is a client for fetching resources.
 type Client struct {
+	// BaseURL is the optional base URL of resources. When empty
+	// we use the default BaseURL.
+	BaseURL string
+
 	// HTTPClient is the HTTP client to use.
 	HTTPClient *http.Client
 
 	// Logger is the logger to use.
 	Logger model.Logger
 
+	// ManifestPublicKey is the optional ed25519 key used to verify the
+	// signed manifest. When set, we fetch the manifest and use it instead
+	// of All. When we cannot obtain a valid manifest, we keep using the
+	// manifest we have already installed, if any. When not set, or when
+	// we have never installed a manifest, we use the compiled-in resources.
+	ManifestPublicKey ed25519.PublicKey
+
+	// ManifestURL is the optional URL of the signed manifest. When
+	// empty we use the ManifestURLPath relative to the base URL.
+	ManifestURL string
+
 	// OSMkdirAll allows testing os.MkdirAll failures.
 	OSMkdirAll func(path string, perm os.FileMode) error
 
@@ -35,7 +51,9 @@ type Client struct {
 	WorkDir string
 }
 
-// Ensure ensures that resources are downloaded and current.
+// Ensure ensures that resources are downloaded and current. We first
+// download and verify all the outdated resources and then we install
+// all of them, so we either update all resources or none of them.
 func (c *Client) Ensure(ctx context.Context) error {
 	mkdirall := c.OSMkdirAll
 	if mkdirall == nil {
@@ -44,17 +62,65 @@ func (c *Client) Ensure(ctx context.Context) error {
 	if err := mkdirall(c.WorkDir, 0700); err != nil {
 		return err
 	}
-	for name, resource := range All {
-		if err := c.EnsureForSingleResource(
+	var staged []stagedResource
+	defer func() {
+		for _, sr := range staged {
+			os.Remove(sr.stagingpath) // ignore errors: may have been renamed
+		}
+	}()
+	resources, update := c.resources(ctx)
+	for name, resource := range resources {
+		sr, err := c.stageSingleResource(
 			ctx, name, resource, func(real, expected string) bool {
 				return real == expected
 			},
 			gzip.NewReader, ioutil.ReadAll,
-		); err != nil {
+		)
+		if err != nil {
 			return err
 		}
+		if sr != nil {
+			staged = append(staged, *sr)
+		}
 	}
-	return nil
+	if err := c.install(staged); err != nil {
+		return err
+	}
+	if update == nil {
+		return nil
+	}
+	return c.saveManifest(update)
+}
+
+// resources returns the resources to ensure and the manifest update
+// to save after we have installed them, if any. When we cannot obtain
+// a valid manifest and we have already installed a manifest, we keep
+// using it rather than falling back to the compiled-in resources,
+// which may be older than the ones we have installed. Otherwise, an
+// attacker could roll back our resources by blocking the manifest.
+func (c *Client) resources(ctx context.Context) (map[string]ResourceInfo, *manifestUpdate) {
+	if c.ManifestPublicKey == nil {
+		return All, nil
+	}
+	current := c.installedManifest()
+	m, update, err := c.loadManifest(ctx, current)
+	if err != nil {
+		if current == nil || current.Version < Version {
+			c.Logger.Warnf("resources: cannot use manifest: %s", err.Error())
+			return All, nil
+		}
+		c.Logger.Warnf("resources: keeping installed manifest: %s", err.Error())
+		m = current
+	}
+	out := make(map[string]ResourceInfo)
+	for name, resource := range m.Resources {
+		if _, found := lookupType(name); !found {
+			c.Logger.Debugf("resources: skipping unknown resource: %s", name)
+			continue
+		}
+		out[name] = resource
+	}
+	return out, update
 }
 
 // EnsureForSingleResource ensures that a single resource
@@ -65,40 +131,141 @@ func (c *Client) EnsureForSingleResource(
 	gzipNewReader func(r io.Reader) (*gzip.Reader, error),
 	ioutilReadAll func(r io.Reader) ([]byte, error),
 ) error {
+	sr, err := c.stageSingleResource(
+		ctx, name, resource, equal, gzipNewReader, ioutilReadAll)
+	if err != nil || sr == nil {
+		return err
+	}
+	defer os.Remove(sr.stagingpath) // ignore errors: may have been renamed
+	return c.install([]stagedResource{*sr})
+}
+
+// stagedResource is a resource that we have downloaded and
+// verified but we have not installed yet.
+type stagedResource struct {
+	fullpath    string
+	stagingpath string
+}
+
+// stageSingleResource downloads and verifies a resource and writes
+// it next to its final location. It returns nil if the resource
+// we already have is current.
+func (c *Client) stageSingleResource(
+	ctx context.Context, name string, resource ResourceInfo,
+	equal func(real, expected string) bool,
+	gzipNewReader func(r io.Reader) (*gzip.Reader, error),
+	ioutilReadAll func(r io.Reader) ([]byte, error),
+) (*stagedResource, error) {
 	fullpath := filepath.Join(c.WorkDir, name)
 	data, err := ioutil.ReadFile(fullpath)
 	if err == nil {
 		sha256sum := fmt.Sprintf("%x", sha256.Sum256(data))
 		if equal(sha256sum, resource.SHA256) {
-			return nil
+			return nil, nil
 		}
 		c.Logger.Debugf("resources: %s is outdated", fullpath)
 	} else {
 		c.Logger.Debugf("resources: can't read %s: %s", fullpath, err.Error())
 	}
 	data, err = (httpx.Client{
-		BaseURL:    BaseURL,
+		BaseURL:    c.baseURL(),
 		HTTPClient: c.HTTPClient,
 		Logger:     c.Logger,
 		UserAgent:  c.UserAgent,
 	}).FetchResourceAndVerify(ctx, resource.URLPath, resource.GzSHA256)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	c.Logger.Debugf("resources: uncompress %s", fullpath)
 	gzreader, err := gzipNewReader(bytes.NewReader(data))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer gzreader.Close()              // we already have a sha256 for it
 	data, err = ioutilReadAll(gzreader) // small file
 	if err != nil {
-		return err
+		return nil, err
 	}
 	sha256sum := fmt.Sprintf("%x", sha256.Sum256(data))
 	if equal(sha256sum, resource.SHA256) == false {
-		return fmt.Errorf("resources: %s sha256 mismatch", fullpath)
+		return nil, fmt.Errorf("resources: %s sha256 mismatch", fullpath)
+	}
+	if validate, _ := lookupType(name); validate != nil {
+		if err := validate(data); err != nil {
+			return nil, fmt.Errorf("resources: %s is not valid: %w", fullpath, err)
+		}
+	}
+	sr := &stagedResource{fullpath: fullpath, stagingpath: fullpath + ".new"}
+	c.Logger.Debugf("resources: stage %s", sr.stagingpath)
+	if err := writeFileSync(sr.stagingpath, data); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
+// install replaces the current resources with the staged ones. We
+// keep a backup of each resource we replace, so that, if we cannot
+// install any of them, we restore the previous set of resources.
+func (c *Client) install(staged []stagedResource) error {
+	var done []stagedResource
+	for _, sr := range staged {
+		c.Logger.Debugf("resources: overwrite %s", sr.fullpath)
+		if err := os.Rename(sr.fullpath, sr.fullpath+".bak"); err != nil && !os.IsNotExist(err) {
+			c.rollback(done)
+			return err
+		}
+		if err := os.Rename(sr.stagingpath, sr.fullpath); err != nil {
+			os.Rename(sr.fullpath+".bak", sr.fullpath) // best effort
+			c.rollback(done)
+			return err
+		}
+		done = append(done, sr)
+	}
+	for _, sr := range done {
+		os.Remove(sr.fullpath + ".bak") // ignore errors: may not exist
+	}
+	return nil
+}
+
+// rollback restores the backups of the resources we have installed.
+func (c *Client) rollback(done []stagedResource) {
+	for _, sr := range done {
+		c.Logger.Warnf("resources: rollback %s", sr.fullpath)
+		if err := os.Rename(sr.fullpath+".bak", sr.fullpath); os.IsNotExist(err) {
+			os.Remove(sr.fullpath) // we did not have this resource before
+		}
+	}
+}
+
+func (c *Client) baseURL() string {
+	if c.BaseURL != "" {
+		return c.BaseURL
+	}
+	return BaseURL
+}
+
+// writeFileSync writes data to path and flushes it to disk, so that
+// we can safely rename it later.
+func writeFileSync(path string, data []byte) error {
+	filep, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := filep.Write(data); err != nil {
+		filep.Close()
+		return err
+	}
+	if err := filep.Sync(); err != nil {
+		filep.Close()
+		return err
+	}
+	return filep.Close()
+}
+
+// writeFileAtomic atomically replaces path with data.
+func writeFileAtomic(path string, data []byte) error {
+	if err := writeFileSync(path+".new", data); err != nil {
+		return err
 	}
-	c.Logger.Debugf("resources: overwrite %s", fullpath)
-	return ioutil.WriteFile(fullpath, data, 0600)
+	return os.Rename(path+".new", path)
 }
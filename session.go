@@ -2,6 +2,8 @@ package engine
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -20,23 +22,42 @@ import (
 	"github.com/ooni/probe-engine/model"
 	"github.com/ooni/probe-engine/netx"
 	"github.com/ooni/probe-engine/netx/bytecounter"
+	"github.com/ooni/probe-engine/netx/otlp"
+	"github.com/ooni/probe-engine/netx/resolver"
 	"github.com/ooni/probe-engine/probeservices"
 	"github.com/ooni/probe-engine/resources"
 	"github.com/ooni/probe-engine/version"
 )
 
-// SessionConfig contains the Session config
+// SessionConfig contains the Session config. The optional SpanExporter
+// exports the spans of the network operations performed by the session's
+// HTTP clients and resolver. You own it, so remember to close it after
+// you have closed the session. The optional CertPool contains the
+// roots trusted by the session's HTTP clients and resolver, e.g., a
+// pool created using netx.NewCertPoolFromPEMFiles. The optional
+// Fronting contains domain fronting configurations that we use to
+// reach the probe services when all the direct endpoints fail. The
+// optional TunnelBootstrapProgress is called with the bootstrap
+// progress of the tunnel started by MaybeStartTunnel.
 type SessionConfig struct {
-	AssetsDir              string
-	AvailableProbeServices []model.Service
-	KVStore                KVStore
-	Logger                 model.Logger
-	ProxyURL               *url.URL
-	SoftwareName           string
-	SoftwareVersion        string
-	TempDir                string
-	TorArgs                []string
-	TorBinary              string
+	AssetsDir               string
+	AvailableProbeServices  []model.Service
+	CertPool                *x509.CertPool
+	Fronting                []model.Fronting
+	GeolocateOverrides      geolocate.Overrides
+	KVStore                 KVStore
+	Logger                  model.Logger
+	ProxyURL                *url.URL
+	ResourcesPublicKey      ed25519.PublicKey
+	SoftwareName            string
+	SoftwareVersion         string
+	SpanExporter            *otlp.Exporter
+	TempDir                 string
+	TorArgs                 []string
+	TorBinary               string
+	TunnelBootstrapProgress func(percentage float64, message string)
+	TunnelBridges           []string
+	TunnelUpstreamProxy     *url.URL
 }
 
 // Session is a measurement session
@@ -45,22 +66,30 @@ type Session struct {
 	availableProbeServices   []model.Service
 	availableTestHelpers     map[string][]model.Service
 	byteCounter              *bytecounter.Counter
+	certPool                 *x509.CertPool
+	fronting                 []model.Fronting
+	geolocateOverrides       geolocate.Overrides
 	httpDefaultTransport     netx.HTTPRoundTripper
 	kvStore                  model.KeyValueStore
 	location                 *geolocate.Results
 	logger                   model.Logger
 	proxyURL                 *url.URL
 	queryProbeServicesCount  *atomicx.Int64
+	resourcesPublicKey       ed25519.PublicKey
 	resolver                 *sessionresolver.Resolver
 	selectedProbeServiceHook func(*model.Service)
 	selectedProbeService     *model.Service
 	softwareName             string
 	softwareVersion          string
+	spanExporter             *otlp.Exporter
 	tempDir                  string
 	torArgs                  []string
 	torBinary                string
+	tunnelBootstrapProgress  func(percentage float64, message string)
+	tunnelBridges            []string
 	tunnelMu                 sync.Mutex
 	tunnelName               string
+	tunnelUpstreamProxy      *url.URL
 	tunnel                   tunnel.Tunnel
 }
 
@@ -93,31 +122,45 @@ func NewSession(config SessionConfig) (*Session, error) {
 		assetsDir:               config.AssetsDir,
 		availableProbeServices:  config.AvailableProbeServices,
 		byteCounter:             bytecounter.New(),
+		certPool:                config.CertPool,
+		fronting:                config.Fronting,
+		geolocateOverrides:      config.GeolocateOverrides,
 		kvStore:                 config.KVStore,
 		logger:                  config.Logger,
 		proxyURL:                config.ProxyURL,
 		queryProbeServicesCount: atomicx.NewInt64(),
+		resourcesPublicKey:      config.ResourcesPublicKey,
 		softwareName:            config.SoftwareName,
 		softwareVersion:         config.SoftwareVersion,
+		spanExporter:            config.SpanExporter,
 		tempDir:                 tempDir,
 		torArgs:                 config.TorArgs,
 		torBinary:               config.TorBinary,
+		tunnelBootstrapProgress: config.TunnelBootstrapProgress,
+		tunnelBridges:           config.TunnelBridges,
+		tunnelUpstreamProxy:     config.TunnelUpstreamProxy,
 	}
 	httpConfig := netx.Config{
 		ByteCounter:  sess.byteCounter,
 		BogonIsError: true,
+		CertPool:     sess.certPool,
 		Logger:       sess.logger,
+		SpanExporter: sess.spanExporter,
 	}
 	sess.resolver = sessionresolver.New(httpConfig)
-	httpConfig.FullResolver = sess.resolver
+	httpConfig.FullResolver = sess.newFrontingResolver()
 	httpConfig.ProxyURL = config.ProxyURL // no need to proxy the resolver
 	sess.httpDefaultTransport = netx.NewHTTPTransport(httpConfig)
 	return sess, nil
 }
 
 // ASNDatabasePath returns the path where the ASN database path should
-// be if you have called s.FetchResourcesIdempotent.
+// be if you have called s.FetchResourcesIdempotent, unless you have
+// configured a custom ASN database using GeolocateOverrides.
 func (s *Session) ASNDatabasePath() string {
+	if s.geolocateOverrides.ASNDatabasePath != "" {
+		return s.geolocateOverrides.ASNDatabasePath
+	}
 	return filepath.Join(s.assetsDir, resources.ASNDatabaseName)
 }
 
@@ -149,6 +192,9 @@ func (s *Session) Close() error {
 
 // CountryDatabasePath is like ASNDatabasePath but for the country DB path.
 func (s *Session) CountryDatabasePath() string {
+	if s.geolocateOverrides.CountryDatabasePath != "" {
+		return s.geolocateOverrides.CountryDatabasePath
+	}
 	return filepath.Join(s.assetsDir, resources.CountryDatabaseName)
 }
 
@@ -224,8 +270,11 @@ func (s *Session) MaybeStartTunnel(ctx context.Context, name string) error {
 		return ErrAlreadyUsingProxy
 	}
 	tunnel, err := tunnel.Start(ctx, tunnel.Config{
-		Name:    name,
-		Session: s,
+		BootstrapProgress: s.tunnelBootstrapProgress,
+		Bridges:           s.tunnelBridges,
+		Name:              name,
+		Session:           s,
+		UpstreamProxy:     s.tunnelUpstreamProxy,
 	})
 	if err != nil {
 		s.logger.Warnf("cannot start tunnel: %+v", err)
@@ -341,6 +390,57 @@ func (s *Session) ProbeIP() string {
 	return ip
 }
 
+// ProbeIPv4Identity returns the IPv4 identity of the probe, or
+// nil if we don't know it, e.g., because we are using a proxy.
+func (s *Session) ProbeIPv4Identity() *geolocate.ProbeIdentity {
+	if s.location != nil {
+		return s.location.ProbeIPv4
+	}
+	return nil
+}
+
+// ProbeIPv6Identity returns the IPv6 identity of the probe, or
+// nil if we don't know it, e.g., because there is no IPv6.
+func (s *Session) ProbeIPv6Identity() *geolocate.ProbeIdentity {
+	if s.location != nil {
+		return s.location.ProbeIPv6
+	}
+	return nil
+}
+
+// probeIPs returns all the known IPs of the probe, which we
+// need to scrub out of measurements.
+func (s *Session) probeIPs() []string {
+	ips := []string{s.ProbeIP()}
+	for _, identity := range []*geolocate.ProbeIdentity{
+		s.ProbeIPv4Identity(), s.ProbeIPv6Identity(),
+	} {
+		if identity != nil && identity.ProbeIP != ips[0] {
+			ips = append(ips, identity.ProbeIP)
+		}
+	}
+	return ips
+}
+
+// ProbeIPConfidence returns the fraction of the IP lookup backends
+// that agree with the probe IP, or zero if we don't know it.
+func (s *Session) ProbeIPConfidence() float64 {
+	var confidence float64
+	if s.location != nil {
+		confidence = s.location.ProbeIPConfidence
+	}
+	return confidence
+}
+
+// ProbeIPLookups returns the results of the IP lookup backends.
+func (s *Session) ProbeIPLookups() []geolocate.IPLookupResult {
+	var lookups []geolocate.IPLookupResult
+	if s.location != nil {
+		lookups = s.location.ProbeIPLookups
+	}
+	return lookups
+}
+
 // ProxyURL returns the Proxy URL, or nil if not set
 func (s *Session) ProxyURL() *url.URL {
 	return s.proxyURL
@@ -415,10 +515,11 @@ func (s *Session) UserAgent() (useragent string) {
 // MaybeUpdateResources updates the resources if needed.
 func (s *Session) MaybeUpdateResources(ctx context.Context) error {
 	return (&resources.Client{
-		HTTPClient: s.DefaultHTTPClient(),
-		Logger:     s.logger,
-		UserAgent:  s.UserAgent(),
-		WorkDir:    s.assetsDir,
+		HTTPClient:        s.DefaultHTTPClient(),
+		Logger:            s.logger,
+		ManifestPublicKey: s.resourcesPublicKey,
+		UserAgent:         s.UserAgent(),
+		WorkDir:           s.assetsDir,
 	}).Ensure(ctx)
 }
 
@@ -429,6 +530,25 @@ func (s *Session) getAvailableProbeServices() []model.Service {
 	return probeservices.Default()
 }
 
+// newFrontingResolver returns the session resolver, wrapped such
+// that we resolve the fronts to the IPs configured for them, if any.
+func (s *Session) newFrontingResolver() netx.Resolver {
+	var cache *resolver.CacheResolver
+	for _, entry := range s.fronting {
+		if len(entry.IPs) <= 0 {
+			continue
+		}
+		if cache == nil {
+			cache = &resolver.CacheResolver{ReadOnly: true, Resolver: s.resolver}
+		}
+		cache.Set(entry.Front, entry.IPs)
+	}
+	if cache == nil {
+		return s.resolver
+	}
+	return cache
+}
+
 func (s *Session) initOrchestraClient(
 	ctx context.Context, clnt *probeservices.Client,
 	maybeLogin func(ctx context.Context) error,
@@ -472,7 +592,8 @@ func (s *Session) maybeLookupBackends(ctx context.Context) error {
 		return nil
 	}
 	s.queryProbeServicesCount.Add(1)
-	candidates := probeservices.TryAll(ctx, s, s.getAvailableProbeServices())
+	candidates := probeservices.TryAll(ctx, s, probeservices.WithFronting(
+		s.getAvailableProbeServices(), s.fronting))
 	selected := probeservices.SelectBest(candidates)
 	if selected == nil {
 		return ErrAllProbeServicesFailed
@@ -488,13 +609,38 @@ func (s *Session) maybeLookupBackends(ctx context.Context) error {
 func (s *Session) LookupLocationContext(ctx context.Context) (*geolocate.Results, error) {
 	// Implementation note: we don't perform the lookup of the resolver IP
 	// when we are using a proxy because that might leak information.
-	task := geolocate.Must(geolocate.NewTask(geolocate.Config{
+	// The same reasoning applies to discovering the IPv4 and the IPv6
+	// identities of the probe, which would be those of the proxy.
+	var transports []netx.HTTPRoundTripper
+	config := geolocate.Config{
 		EnableResolverLookup: s.proxyURL == nil,
 		HTTPClient:           s.DefaultHTTPClient(),
 		Logger:               s.Logger(),
+		Overrides:            s.geolocateOverrides,
 		ResourcesManager:     s,
 		UserAgent:            s.UserAgent(),
-	}))
+	}
+	if s.proxyURL == nil {
+		config.NewHTTPClientForFamily = func(family string) *http.Client {
+			txp := netx.NewHTTPTransport(netx.Config{
+				AddressFamily: family,
+				BogonIsError:  true,
+				ByteCounter:   s.byteCounter,
+				CertPool:      s.certPool,
+				FullResolver:  s.resolver,
+				Logger:        s.logger,
+				SpanExporter:  s.spanExporter,
+			})
+			transports = append(transports, txp)
+			return &http.Client{Transport: txp}
+		}
+	}
+	task := geolocate.Must(geolocate.NewTask(config))
+	defer func() {
+		for _, txp := range transports {
+			txp.CloseIdleConnections()
+		}
+	}()
 	return task.Run(ctx)
 }
 
@@ -511,4 +657,17 @@ func (s *Session) MaybeLookupLocationContext(ctx context.Context) error {
 	return nil
 }
 
+// RefreshLocationContext performs a new location lookup, ignoring the
+// cached location, and caches the new location on success. Long running
+// clients, e.g., ooniprobed, use this method because the probe may have
+// moved to another network since the previous lookup.
+func (s *Session) RefreshLocationContext(ctx context.Context) error {
+	location, err := s.LookupLocationContext(ctx)
+	if err != nil {
+		return err
+	}
+	s.location = location
+	return nil
+}
+
 var _ model.ExperimentSession = &Session{}
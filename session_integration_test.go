@@ -269,6 +269,30 @@ func TestMaybeLookupBackendsNewClientError(t *testing.T) {
 	}
 }
 
+func TestNewFrontingResolver(t *testing.T) {
+	sess := newSessionForTestingNoLookups(t)
+	defer sess.Close()
+	if sess.newFrontingResolver() != sess.resolver {
+		t.Fatal("without fronting IPs we should use the session resolver")
+	}
+	sess.fronting = []model.Fronting{{
+		Front: "a0.awsstatic.com",
+		Host:  "dkyhjv0wpi2dk.cloudfront.net",
+		IPs:   []string{"10.0.0.1", "10.0.0.2"},
+	}, {
+		Front: "ajax.aspnetcdn.com",
+		Host:  "x.org",
+	}}
+	addrs, err := sess.newFrontingResolver().LookupHost(
+		context.Background(), "a0.awsstatic.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 2 || addrs[0] != "10.0.0.1" || addrs[1] != "10.0.0.2" {
+		t.Fatal("not the addresses we expected")
+	}
+}
+
 func TestSessionLocationLookup(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skip test in short mode")
@@ -313,6 +337,26 @@ func TestSessionLocationLookup(t *testing.T) {
 	}
 }
 
+func TestSessionRefreshLocationIgnoresTheCache(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip test in short mode")
+	}
+	sess := newSessionForTestingNoLookups(t)
+	defer sess.Close()
+	if err := sess.MaybeLookupLocation(); err != nil {
+		t.Fatal(err)
+	}
+	probeIP := sess.ProbeIP()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // so that a new lookup fails immediately
+	if err := sess.RefreshLocationContext(ctx); err == nil {
+		t.Fatal("expected an error here")
+	}
+	if sess.ProbeIP() != probeIP {
+		t.Fatal("we should keep the previous location on failure")
+	}
+}
+
 func TestSessionCloseCancelsTempDir(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skip test in short mode")
@@ -657,3 +701,56 @@ func TestNewOrchestraClientProbeServicesNewClientFailure(t *testing.T) {
 		t.Fatal("expected nil client here")
 	}
 }
+
+func TestSessionWithGeolocateOverrides(t *testing.T) {
+	sess, err := NewSession(SessionConfig{
+		AssetsDir: "testdata",
+		GeolocateOverrides: geolocate.Overrides{
+			ASNDatabasePath: "/nonexistent/asn.mmdb",
+			ProbeASN:        30722,
+			ProbeCC:         "IT",
+			ProbeIP:         "130.25.90.7",
+		},
+		Logger:          model.DiscardLogger,
+		SoftwareName:    "ooniprobe-engine",
+		SoftwareVersion: "0.0.1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	if sess.ASNDatabasePath() != "/nonexistent/asn.mmdb" {
+		t.Fatal("unexpected ASN database path")
+	}
+	if err := sess.MaybeLookupLocation(); err != nil {
+		t.Fatal(err)
+	}
+	if sess.ProbeASN() != 30722 || sess.ProbeCC() != "IT" || sess.ProbeIP() != "130.25.90.7" {
+		t.Fatal("unexpected probe location")
+	}
+	if sess.ResolverIP() != geolocate.DefaultResolverIP {
+		t.Fatal("we should not have looked up the resolver")
+	}
+}
+
+func TestSessionProbeIdentities(t *testing.T) {
+	sess := &Session{}
+	if sess.ProbeIPv4Identity() != nil || sess.ProbeIPv6Identity() != nil {
+		t.Fatal("expected nil identities")
+	}
+	if ips := sess.probeIPs(); len(ips) != 1 || ips[0] != geolocate.DefaultProbeIP {
+		t.Fatal("unexpected probe IPs", ips)
+	}
+	sess.location = &geolocate.Results{
+		ProbeIP:   "130.25.90.7",
+		ProbeIPv4: &geolocate.ProbeIdentity{ASN: 30722, ProbeIP: "130.25.90.7"},
+		ProbeIPv6: &geolocate.ProbeIdentity{ASN: 3269, ProbeIP: "2001:db8::1"},
+	}
+	if sess.ProbeIPv6Identity().ASN != 3269 {
+		t.Fatal("unexpected IPv6 identity")
+	}
+	ips := sess.probeIPs()
+	if diff := cmp.Diff([]string{"130.25.90.7", "2001:db8::1"}, ips); diff != "" {
+		t.Fatal(diff)
+	}
+}